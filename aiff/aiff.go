@@ -0,0 +1,409 @@
+// Package aiff provides pipe components that allow to read signal
+// encoded in AIFF and AIFF-C formats and write it in AIFF format.
+package aiff
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+// Sizes of the header written by sink: FORM header, COMM chunk and
+// SSND chunk header.
+const (
+	formHeaderSize = 12
+	commSize       = 18
+	headerSize     = formHeaderSize + 8 + commSize + 16
+)
+
+// maxChannels is the maximum number of channels supported by sink.
+const maxChannels = 1<<15 - 1
+
+// Compression types of AIFF-C. Plain AIFF is always uncompressed.
+const (
+	compressionNone    = "NONE"
+	compressionTwos    = "twos"
+	compressionSowt    = "sowt"
+	compressionFloat32 = "fl32"
+	compressionFloat64 = "fl64"
+)
+
+// ErrInvalidAIFF is returned when stream is not valid AIFF or AIFF-C.
+var ErrInvalidAIFF = errors.New("invalid AIFF")
+
+// Header contains properties of AIFF stream.
+type Header struct {
+	SampleRate int
+	Channels   int
+	// BitDepth is the sample size defined by COMM chunk.
+	BitDepth int
+	// Frames is the number of samples per channel.
+	Frames int64
+	// Compression type of AIFF-C, plain AIFF has NONE.
+	Compression string
+}
+
+// ReadHeader reads properties of AIFF stream. The stream is positioned
+// at the first sample.
+func ReadHeader(rs io.ReadSeeker) (Header, error) {
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return Header{}, err
+	}
+	var form [formHeaderSize]byte
+	if _, err := io.ReadFull(rs, form[:]); err != nil {
+		return Header{}, fmt.Errorf("error reading AIFF header: %w", err)
+	}
+	formType := string(form[8:12])
+	if string(form[:4]) != "FORM" || (formType != "AIFF" && formType != "AIFC") {
+		return Header{}, ErrInvalidAIFF
+	}
+	end, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return Header{}, err
+	}
+	h := Header{Compression: compressionNone}
+	var (
+		comm, ssnd bool
+		// position and size of samples.
+		dataStart, dataSize int64
+	)
+	for offset := int64(formHeaderSize); offset+8 <= end; {
+		if _, err := rs.Seek(offset, io.SeekStart); err != nil {
+			return Header{}, err
+		}
+		var b [8]byte
+		if _, err := io.ReadFull(rs, b[:]); err != nil {
+			return Header{}, fmt.Errorf("error reading AIFF chunk: %w", err)
+		}
+		size := int64(binary.BigEndian.Uint32(b[4:8]))
+		if offset+8+size > end {
+			size = end - offset - 8
+		}
+		switch string(b[:4]) {
+		case "COMM":
+			if err := h.readComm(rs, size, formType == "AIFC"); err != nil {
+				return Header{}, err
+			}
+			comm = true
+		case "SSND":
+			if size < 8 {
+				return Header{}, errors.New("invalid SSND chunk")
+			}
+			var ssndHeader [8]byte
+			if _, err := io.ReadFull(rs, ssndHeader[:]); err != nil {
+				return Header{}, fmt.Errorf("error reading SSND chunk: %w", err)
+			}
+			// samples are preceded by offset bytes.
+			dataOffset := int64(binary.BigEndian.Uint32(ssndHeader[:4]))
+			if dataOffset > size-8 {
+				return Header{}, errors.New("invalid SSND data offset")
+			}
+			dataStart = offset + 16 + dataOffset
+			dataSize = size - 8 - dataOffset
+			ssnd = true
+		}
+		offset += 8 + size + size%2
+	}
+	if !comm {
+		return Header{}, errors.New("missing COMM chunk")
+	}
+	if !ssnd {
+		// files without samples are allowed to omit SSND chunk.
+		if h.Frames > 0 {
+			return Header{}, errors.New("missing SSND chunk")
+		}
+		dataStart = end
+	}
+	if frameSize := int64(h.Channels * h.sampleSize()); frameSize > 0 && dataSize/frameSize < h.Frames {
+		h.Frames = dataSize / frameSize
+	}
+	if h.Frames < 0 {
+		h.Frames = 0
+	}
+	if _, err := rs.Seek(dataStart, io.SeekStart); err != nil {
+		return Header{}, err
+	}
+	return h, nil
+}
+
+// readComm decodes COMM chunk of provided size. AIFF-C chunk also
+// contains compression type.
+func (h *Header) readComm(r io.Reader, size int64, aifc bool) error {
+	if size < commSize || (aifc && size < commSize+4) {
+		return errors.New("invalid COMM chunk")
+	}
+	b := make([]byte, commSize+4)
+	if !aifc {
+		b = b[:commSize]
+	}
+	if _, err := io.ReadFull(r, b); err != nil {
+		return fmt.Errorf("error reading COMM chunk: %w", err)
+	}
+	h.Channels = int(binary.BigEndian.Uint16(b[0:2]))
+	h.Frames = int64(binary.BigEndian.Uint32(b[2:6]))
+	h.BitDepth = int(binary.BigEndian.Uint16(b[6:8]))
+	h.SampleRate = int(decodeExtended(b[8:18]))
+	if aifc {
+		h.Compression = string(b[18:22])
+	}
+	if h.Channels < 1 {
+		return fmt.Errorf("invalid number of channels: %d", h.Channels)
+	}
+	if h.SampleRate < 1 {
+		return fmt.Errorf("invalid sample rate: %d", h.SampleRate)
+	}
+	switch h.Compression {
+	case compressionNone, compressionTwos, compressionSowt:
+		if h.BitDepth < 1 || h.BitDepth > 32 {
+			return fmt.Errorf("unsupported bit depth: %d", h.BitDepth)
+		}
+	case compressionFloat32, compressionFloat64:
+	default:
+		return fmt.Errorf("unsupported AIFF-C compression: %q", h.Compression)
+	}
+	return nil
+}
+
+// sampleSize returns the number of bytes per sample. Integer samples are
+// padded to whole bytes.
+func (h Header) sampleSize() int {
+	switch h.Compression {
+	case compressionFloat32:
+		return 4
+	case compressionFloat64:
+		return 8
+	}
+	return (h.BitDepth + 7) / 8
+}
+
+// Source reads AIFF or AIFF-C data from ReadSeeker. Uncompressed,
+// little-endian and floating point AIFF-C streams are supported.
+func Source(rs io.ReadSeeker) pipe.SourceAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int) (pipe.Source, error) {
+		h, err := ReadHeader(rs)
+		if err != nil {
+			return pipe.Source{}, err
+		}
+		sampleSize := h.sampleSize()
+		// padding bits are kept, so samples are scaled by whole bytes.
+		ints := signal.Allocator{
+			Channels: h.Channels,
+			Capacity: bufferSize,
+			Length:   bufferSize,
+		}.Int64(signal.BitDepth(sampleSize * 8))
+		buf := make([]byte, bufferSize*h.Channels*sampleSize)
+		remaining := h.Frames
+		return pipe.Source{
+			SourceFunc: func(floating signal.Floating) (int, error) {
+				if remaining <= 0 {
+					return 0, io.EOF
+				}
+				n := floating.Length()
+				if int64(n) > remaining {
+					n = int(remaining)
+				}
+				b := buf[:n*h.Channels*sampleSize]
+				if _, err := io.ReadFull(rs, b); err != nil {
+					return 0, fmt.Errorf("error reading AIFF samples: %w", err)
+				}
+				remaining -= int64(n)
+				switch h.Compression {
+				case compressionFloat32:
+					for i := 0; i < len(b)/4; i++ {
+						floating.SetSample(i, float64(math.Float32frombits(binary.BigEndian.Uint32(b[i*4:]))))
+					}
+					return n, nil
+				case compressionFloat64:
+					for i := 0; i < len(b)/8; i++ {
+						floating.SetSample(i, math.Float64frombits(binary.BigEndian.Uint64(b[i*8:])))
+					}
+					return n, nil
+				}
+				littleEndian := h.Compression == compressionSowt
+				for i := 0; i < len(b)/sampleSize; i++ {
+					ints.SetSample(i, decodeInt(b[i*sampleSize:(i+1)*sampleSize], littleEndian))
+				}
+				return signal.SignedAsFloating(ints.Slice(0, n), floating), nil
+			},
+			SignalProperties: pipe.SignalProperties{
+				SampleRate: signal.Frequency(h.SampleRate),
+				Channels:   h.Channels,
+			},
+		}, nil
+	}
+}
+
+// Sink writes uncompressed AIFF data to WriteSeeker. BitDepth is output
+// bit depth. Supported values: 8, 16, 24 and 32.
+func Sink(ws io.WriteSeeker, bitDepth signal.BitDepth) pipe.SinkAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Sink, error) {
+		switch bitDepth {
+		case signal.BitDepth8, signal.BitDepth16, signal.BitDepth24, signal.BitDepth32:
+		default:
+			return pipe.Sink{}, fmt.Errorf("AIFF doesn't support bit depth %d", bitDepth)
+		}
+		if props.Channels < 1 || props.Channels > maxChannels {
+			return pipe.Sink{}, fmt.Errorf("AIFF doesn't support %d channels", props.Channels)
+		}
+		start, err := ws.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return pipe.Sink{}, err
+		}
+		sampleSize := int(bitDepth) / 8
+		w := writer{
+			ws:        ws,
+			start:     start,
+			frameSize: props.Channels * sampleSize,
+		}
+		if err := w.writeHeader(props, bitDepth); err != nil {
+			return pipe.Sink{}, err
+		}
+		ints := signal.Allocator{
+			Channels: props.Channels,
+			Capacity: bufferSize,
+			Length:   bufferSize,
+		}.Int64(bitDepth)
+		buf := make([]byte, bufferSize*w.frameSize)
+		return pipe.Sink{
+			SinkFunc: func(floats signal.Floating) error {
+				n := signal.FloatingAsSigned(floats, ints)
+				b := buf[:n*w.frameSize]
+				for i := 0; i < len(b)/sampleSize; i++ {
+					encodeInt(b[i*sampleSize:(i+1)*sampleSize], ints.Sample(i))
+				}
+				if _, err := ws.Write(b); err != nil {
+					return fmt.Errorf("error writing AIFF samples: %w", err)
+				}
+				w.frames += int64(n)
+				return nil
+			},
+			FlushFunc: func(context.Context) error {
+				if err := w.flush(); err != nil {
+					return fmt.Errorf("error flushing AIFF: %w", err)
+				}
+				return nil
+			},
+		}, nil
+	}
+}
+
+// writer tracks the number of written frames, so sizes in the header
+// can be updated on flush.
+type writer struct {
+	ws        io.WriteSeeker
+	start     int64
+	frameSize int
+	frames    int64
+}
+
+// writeHeader writes FORM header, COMM chunk and SSND chunk header.
+// Sizes and the number of frames are written on flush.
+func (w *writer) writeHeader(props pipe.SignalProperties, bitDepth signal.BitDepth) error {
+	b := make([]byte, headerSize)
+	copy(b[0:], "FORM")
+	copy(b[8:], "AIFF")
+	copy(b[12:], "COMM")
+	binary.BigEndian.PutUint32(b[16:], commSize)
+	binary.BigEndian.PutUint16(b[20:], uint16(props.Channels))
+	binary.BigEndian.PutUint16(b[26:], uint16(bitDepth))
+	encodeExtended(b[28:38], float64(props.SampleRate))
+	copy(b[38:], "SSND")
+	if _, err := w.ws.Write(b); err != nil {
+		return fmt.Errorf("error writing AIFF header: %w", err)
+	}
+	return nil
+}
+
+// flush pads sound data to even size and updates the header.
+func (w *writer) flush() error {
+	dataSize := w.frames * int64(w.frameSize)
+	if dataSize%2 == 1 {
+		if _, err := w.ws.Write([]byte{0}); err != nil {
+			return err
+		}
+	}
+	end, err := w.ws.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	for _, field := range []struct {
+		offset int64
+		value  uint32
+	}{
+		{offset: 4, value: uint32(end - w.start - 8)},
+		{offset: 22, value: uint32(w.frames)},
+		{offset: 42, value: uint32(dataSize + 8)},
+	} {
+		if _, err := w.ws.Seek(w.start+field.offset, io.SeekStart); err != nil {
+			return err
+		}
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], field.value)
+		if _, err := w.ws.Write(b[:]); err != nil {
+			return err
+		}
+	}
+	_, err = w.ws.Seek(end, io.SeekStart)
+	return err
+}
+
+// decodeInt returns signed integer of provided bytes.
+func decodeInt(b []byte, littleEndian bool) int64 {
+	var v uint64
+	for i := range b {
+		if littleEndian {
+			v |= uint64(b[i]) << uint(8*i)
+		} else {
+			v = v<<8 | uint64(b[i])
+		}
+	}
+	// extend the sign.
+	shift := uint(64 - 8*len(b))
+	return int64(v<<shift) >> shift
+}
+
+// encodeInt writes big-endian signed integer into provided bytes.
+func encodeInt(b []byte, v int64) {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+}
+
+// decodeExtended returns the value of 80-bit IEEE 754 extended precision
+// number.
+func decodeExtended(b []byte) float64 {
+	exponent := int(binary.BigEndian.Uint16(b[0:2]))
+	mantissa := binary.BigEndian.Uint64(b[2:10])
+	sign := 1.0
+	if exponent&0x8000 != 0 {
+		sign = -1
+		exponent &= 0x7FFF
+	}
+	if exponent == 0 && mantissa == 0 {
+		return 0
+	}
+	// mantissa has an explicit integer bit.
+	return sign * math.Ldexp(float64(mantissa), exponent-16383-63)
+}
+
+// encodeExtended writes non-negative value as 80-bit IEEE 754 extended
+// precision number.
+func encodeExtended(b []byte, v float64) {
+	for i := range b {
+		b[i] = 0
+	}
+	if v <= 0 {
+		return
+	}
+	fraction, exponent := math.Frexp(v)
+	binary.BigEndian.PutUint16(b[0:2], uint16(exponent-1+16383))
+	binary.BigEndian.PutUint64(b[2:10], uint64(math.Ldexp(fraction, 64)))
+}
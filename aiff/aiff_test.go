@@ -0,0 +1,200 @@
+package aiff_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/audio/wav"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+
+	"pipelined.dev/phono/aiff"
+)
+
+const (
+	bufferSize = 512
+	wavSample  = "../_testdata/sample.wav"
+)
+
+// samplesSink collects all samples of the signal.
+func samplesSink(samples *[]float64) pipe.SinkAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Sink, error) {
+		return pipe.Sink{
+			SinkFunc: func(in signal.Floating) error {
+				for i := 0; i < in.Len(); i++ {
+					*samples = append(*samples, in.Sample(i))
+				}
+				return nil
+			},
+		}, nil
+	}
+}
+
+func TestSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phono-aiff")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	var expected []float64
+	in, err := os.Open(wavSample)
+	assert.Nil(t, err)
+	defer in.Close()
+	err = pipe.Run(context.Background(), bufferSize, pipe.Line{
+		Source: wav.Source(in),
+		Sink:   samplesSink(&expected),
+	})
+	assert.Nil(t, err)
+
+	testBitDepth := func(bitDepth signal.BitDepth, exact bool) func(*testing.T) {
+		return func(t *testing.T) {
+			_, err := in.Seek(0, 0)
+			assert.Nil(t, err)
+			out, err := os.Create(filepath.Join(dir, "out.aiff"))
+			assert.Nil(t, err)
+			defer out.Close()
+
+			err = pipe.Run(context.Background(), bufferSize, pipe.Line{
+				Source: wav.Source(in),
+				Sink:   aiff.Sink(out, bitDepth),
+			})
+			assert.Nil(t, err)
+
+			h, err := aiff.ReadHeader(out)
+			assert.Nil(t, err)
+			assert.Equal(t, aiff.Header{
+				SampleRate:  44100,
+				Channels:    2,
+				BitDepth:    int(bitDepth),
+				Frames:      int64(len(expected) / 2),
+				Compression: "NONE",
+			}, h)
+
+			var result []float64
+			err = pipe.Run(context.Background(), bufferSize, pipe.Line{
+				Source: aiff.Source(out),
+				Sink:   samplesSink(&result),
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, len(expected), len(result))
+			if exact {
+				assert.Equal(t, expected, result)
+			}
+		}
+	}
+	t.Run("8 bits", testBitDepth(signal.BitDepth8, false))
+	t.Run("16 bits", testBitDepth(signal.BitDepth16, true))
+	t.Run("24 bits", testBitDepth(signal.BitDepth24, false))
+	t.Run("32 bits", testBitDepth(signal.BitDepth32, false))
+	t.Run("invalid bit depth", func(t *testing.T) {
+		_, err := in.Seek(0, 0)
+		assert.Nil(t, err)
+		out, err := os.Create(filepath.Join(dir, "invalid.aiff"))
+		assert.Nil(t, err)
+		defer out.Close()
+		err = pipe.Run(context.Background(), bufferSize, pipe.Line{
+			Source: wav.Source(in),
+			Sink:   aiff.Sink(out, signal.BitDepth(12)),
+		})
+		assert.NotNil(t, err)
+	})
+}
+
+func TestSource(t *testing.T) {
+	testSource := func(data []byte, expected []float64, fails bool) func(*testing.T) {
+		return func(t *testing.T) {
+			var result []float64
+			err := pipe.Run(context.Background(), bufferSize, pipe.Line{
+				Source: aiff.Source(bytes.NewReader(data)),
+				Sink:   samplesSink(&result),
+			})
+			if fails {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, expected, result)
+		}
+	}
+	t.Run("aiff-c sowt", testSource(
+		aifc("sowt", 16, []byte{0x00, 0x80, 0x00, 0xC0}),
+		[]float64{-1, -0.5},
+		false,
+	))
+	t.Run("aiff-c twos", testSource(
+		aifc("twos", 16, []byte{0x80, 0x00, 0xC0, 0x00}),
+		[]float64{-1, -0.5},
+		false,
+	))
+	t.Run("aiff-c fl32", testSource(
+		aifc("fl32", 32, float32s(0.25, -0.75)),
+		[]float64{0.25, -0.75},
+		false,
+	))
+	t.Run("unsupported compression", testSource(
+		aifc("ulaw", 16, []byte{0x00, 0x00}),
+		nil,
+		true,
+	))
+	t.Run("invalid data offset", testSource(
+		invalidOffset(),
+		nil,
+		true,
+	))
+	t.Run("not aiff", testSource(
+		[]byte("RIFF\x00\x00\x00\x00WAVE"),
+		nil,
+		true,
+	))
+}
+
+// aifc returns mono AIFF-C stream with provided compression and sample
+// data.
+func aifc(compression string, bitDepth int, data []byte) []byte {
+	comm := make([]byte, 24)
+	binary.BigEndian.PutUint16(comm[0:], 1)
+	binary.BigEndian.PutUint32(comm[2:], uint32(len(data)*8/bitDepth))
+	binary.BigEndian.PutUint16(comm[6:], uint16(bitDepth))
+	// 44100 as 80-bit extended.
+	copy(comm[8:], []byte{0x40, 0x0E, 0xAC, 0x44})
+	copy(comm[18:], compression)
+
+	var b bytes.Buffer
+	b.WriteString("FORM")
+	binary.Write(&b, binary.BigEndian, uint32(4+8+len(comm)+16+len(data)))
+	b.WriteString("AIFC")
+	b.WriteString("COMM")
+	binary.Write(&b, binary.BigEndian, uint32(len(comm)))
+	b.Write(comm)
+	b.WriteString("SSND")
+	binary.Write(&b, binary.BigEndian, uint32(8+len(data)))
+	b.Write(make([]byte, 8))
+	b.Write(data)
+	return b.Bytes()
+}
+
+// invalidOffset returns AIFF stream with SSND data offset that exceeds
+// the chunk.
+func invalidOffset() []byte {
+	b := aifc("NONE", 16, make([]byte, 16))
+	// offset field follows SSND chunk header.
+	i := bytes.Index(b, []byte("SSND"))
+	binary.BigEndian.PutUint32(b[i+8:], 1000)
+	return b
+}
+
+func float32s(values ...float32) []byte {
+	b := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.BigEndian.PutUint32(b[i*4:], math.Float32bits(v))
+	}
+	return b
+}
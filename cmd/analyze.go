@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"pipelined.dev/phono/encode"
+	"pipelined.dev/phono/process"
+)
+
+var (
+	analyzeFlags = struct {
+		recursive  bool
+		json       bool
+		bufferSize int
+	}{}
+	analyzeCmd = &cobra.Command{
+		Use:                   "analyze [flags] path...",
+		DisableFlagsInUseLine: true,
+		Short:                 "Print quality metrics of audio files",
+		Args:                  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			// create channel for interruption and context for cancellation
+			ctx, cancelFn := context.WithCancel(context.Background())
+			// interrupt signal received, shut down
+			onInterrupt(func() { cancelFn() })
+			files := analyzeFiles(ctx, args, analyzeFlags.recursive, analyzeFlags.bufferSize)
+			var err error
+			if analyzeFlags.json {
+				err = printAnalysisJSON(os.Stdout, files)
+			} else {
+				err = printAnalysis(os.Stdout, files)
+			}
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+			for _, f := range files {
+				if f.Error != "" {
+					os.Exit(1)
+				}
+			}
+		},
+	}
+)
+
+type (
+	// fileAnalysis contains analysis of a single file or error if it
+	// cannot be analyzed.
+	fileAnalysis struct {
+		Path string `json:"path"`
+		*analysis
+		Error string `json:"error,omitempty"`
+	}
+
+	// analysis is json representation of process.Analysis.
+	analysis struct {
+		SampleRate int               `json:"sampleRate"`
+		Frames     int64             `json:"frames"`
+		Duration   float64           `json:"duration"`
+		Integrated level             `json:"integratedLoudness"`
+		TruePeak   level             `json:"truePeak"`
+		Clipped    int               `json:"clipped"`
+		Channels   []channelAnalysis `json:"channels"`
+	}
+
+	channelAnalysis struct {
+		Peak     level   `json:"peak"`
+		RMS      level   `json:"rms"`
+		DCOffset float64 `json:"dcOffset"`
+		Crest    float64 `json:"crestFactor"`
+		Clipped  int     `json:"clipped"`
+	}
+
+	// level in dB, negative infinity is encoded as null.
+	level float64
+)
+
+func init() {
+	rootCmd.AddCommand(analyzeCmd)
+	analyzeCmd.Flags().BoolVar(&analyzeFlags.recursive, "recursive", false, "process paths recursive")
+	analyzeCmd.Flags().BoolVar(&analyzeFlags.json, "json", false, "print analysis in json format")
+	analyzeCmd.Flags().IntVar(&analyzeFlags.bufferSize, "buffersize", 1024, "buffer size")
+	analyzeCmd.Flags().SortFlags = false
+}
+
+// MarshalJSON encodes infinite levels as null.
+func (l level) MarshalJSON() ([]byte, error) {
+	v := float64(l)
+	if math.IsInf(v, 0) || math.IsNaN(v) {
+		return []byte("null"), nil
+	}
+	return json.Marshal(v)
+}
+
+// format returns level with provided unit, infinite level is -inf.
+func (l level) format(unit string) string {
+	v := float64(l)
+	if math.IsInf(v, 0) || math.IsNaN(v) {
+		return "-inf " + unit
+	}
+	return strconv.FormatFloat(v, 'f', 2, 64) + " " + unit
+}
+
+func newAnalysis(a process.Analysis) *analysis {
+	result := analysis{
+		SampleRate: int(a.SampleRate),
+		Frames:     a.Frames,
+		Integrated: level(a.Integrated),
+		TruePeak:   level(a.TruePeak),
+		Clipped:    a.Clipped(),
+		Channels:   make([]channelAnalysis, 0, len(a.Channels)),
+	}
+	if a.SampleRate > 0 {
+		result.Duration = float64(a.Frames) / float64(a.SampleRate)
+	}
+	for _, c := range a.Channels {
+		result.Channels = append(result.Channels, channelAnalysis{
+			Peak:     level(c.Peak),
+			RMS:      level(c.RMS),
+			DCOffset: c.DCOffset,
+			Crest:    c.Crest,
+			Clipped:  c.Clipped,
+		})
+	}
+	return &result
+}
+
+// analyzeFiles analyzes all supported files in paths.
+func analyzeFiles(ctx context.Context, paths []string, recursive bool, bufferSize int) []fileAnalysis {
+	files := discover(paths, recursive, "")
+	result := make([]fileAnalysis, 0, len(files))
+	for _, file := range files {
+		fa := fileAnalysis{Path: file.path}
+		a, err := analyzeFile(ctx, file, bufferSize)
+		if err != nil {
+			fa.Error = err.Error()
+		} else {
+			fa.analysis = newAnalysis(a)
+		}
+		result = append(result, fa)
+	}
+	return result
+}
+
+func analyzeFile(ctx context.Context, file inputFile, bufferSize int) (process.Analysis, error) {
+	if file.err != nil {
+		return process.Analysis{}, file.err
+	}
+	f, err := os.Open(file.path)
+	if err != nil {
+		return process.Analysis{}, fmt.Errorf("error opening file: %w", err)
+	}
+	defer f.Close()
+	return encode.Analyze(ctx, bufferSize, file.format, f)
+}
+
+func printAnalysisJSON(w io.Writer, files []fileAnalysis) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(files)
+}
+
+func printAnalysis(w io.Writer, files []fileAnalysis) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	for _, f := range files {
+		fmt.Fprintln(tw, f.Path)
+		if f.Error != "" {
+			fmt.Fprintf(tw, "  error:\t%s\n", f.Error)
+			continue
+		}
+		fmt.Fprintf(tw, "  sample rate:\t%d Hz\n", f.SampleRate)
+		fmt.Fprintf(tw, "  frames:\t%d\n", f.Frames)
+		fmt.Fprintf(tw, "  integrated loudness:\t%s\n", f.Integrated.format("LUFS"))
+		fmt.Fprintf(tw, "  true peak:\t%s\n", f.TruePeak.format("dBTP"))
+		fmt.Fprintf(tw, "  clipped samples:\t%d\n", f.Clipped)
+		for i, c := range f.Channels {
+			fmt.Fprintf(tw, "  channel %d:\t\n", i)
+			fmt.Fprintf(tw, "    peak:\t%s\n", c.Peak.format("dBFS"))
+			fmt.Fprintf(tw, "    rms:\t%s\n", c.RMS.format("dBFS"))
+			fmt.Fprintf(tw, "    dc offset:\t%.6f\n", c.DCOffset)
+			fmt.Fprintf(tw, "    crest factor:\t%.2f dB\n", c.Crest)
+			fmt.Fprintf(tw, "    clipped samples:\t%d\n", c.Clipped)
+		}
+	}
+	return tw.Flush()
+}
+
+// analysisSummary returns analysis in a compact form, one line for the
+// signal and one per channel.
+func analysisSummary(a process.Analysis) []string {
+	lines := []string{
+		fmt.Sprintf("loudness %s, true peak %s", level(a.Integrated).format("LUFS"), level(a.TruePeak).format("dBTP")),
+	}
+	for i, c := range a.Channels {
+		lines = append(lines, fmt.Sprintf("channel %d peak %s, rms %s, dc offset %.6f, crest factor %.2f dB, %d clipped",
+			i, level(c.Peak).format("dBFS"), level(c.RMS).format("dBFS"), c.DCOffset, c.Crest, c.Clipped))
+	}
+	return lines
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyzeFiles(t *testing.T) {
+	files := analyzeFiles(context.Background(), []string{wavSample, "fake.wav"}, false, 512)
+	assert.Equal(t, 2, len(files))
+	assert.Empty(t, files[0].Error)
+	assert.Equal(t, 2, len(files[0].Channels))
+	assert.NotEmpty(t, files[1].Error)
+
+	var buf bytes.Buffer
+	assert.Nil(t, printAnalysis(&buf, files))
+	assert.Contains(t, buf.String(), "integrated loudness: -18.79 LUFS")
+
+	buf.Reset()
+	assert.Nil(t, printAnalysisJSON(&buf, files))
+	var decoded []map[string]interface{}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, 2, len(decoded))
+}
+
+func TestLevel(t *testing.T) {
+	data, err := json.Marshal([]level{level(math.Inf(-1)), -3.5})
+	assert.Nil(t, err)
+	assert.Equal(t, "[null,-3.5]", string(data))
+	assert.Equal(t, "-inf dBFS", level(math.Inf(-1)).format("dBFS"))
+	assert.Equal(t, "-3.50 dBFS", level(-3.5).format("dBFS"))
+}
@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"pipelined.dev/pipe"
+
+	"pipelined.dev/phono/encode"
+	"pipelined.dev/phono/fileformat"
+	"pipelined.dev/phono/process"
+	"pipelined.dev/phono/userinput"
+)
+
+var (
+	concatFlags = struct {
+		outPath        string
+		collision      string
+		bufferSize     int
+		gap            string
+		crossfade      string
+		crossfadeCurve string
+		convert        bool
+		sink           sinkFlags
+		process        processFlags
+		tags           tagFlags
+	}{}
+	concatCmd = &cobra.Command{
+		Use:                   "concat [flags] path...",
+		DisableFlagsInUseLine: true,
+		Short:                 "Join audio files into one",
+		Args:                  cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			if concatFlags.outPath == "" {
+				log.Print("output file is not specified")
+				os.Exit(1)
+			}
+			sink, err := concatFlags.sink.sink(cmd, concatFlags.outPath)
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+			sink, err = concatFlags.tags.sink(concatFlags.outPath, sink)
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+			join, err := userinput.Concat.Join(
+				concatFlags.gap,
+				concatFlags.crossfade,
+				concatFlags.crossfadeCurve,
+				concatFlags.convert,
+				concatFlags.process.resampleQuality,
+			)
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+			processing, err := concatFlags.process.processing()
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+			// create channel for interruption and context for cancellation
+			ctx, cancelFn := context.WithCancel(context.Background())
+			// interrupt signal received, shut down
+			onInterrupt(func() { cancelFn() })
+			err = concatCLI(ctx,
+				args,
+				concatFlags.outPath,
+				concatFlags.collision,
+				concatFlags.bufferSize,
+				join,
+				sink,
+				processing,
+			)
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(concatCmd)
+	concatCmd.Flags().StringVarP(&concatFlags.outPath, "out", "o", "", "output file, its extension defines the format")
+	concatCmd.Flags().StringVar(&concatFlags.collision, "collision", collisionSuffix, collisionFlagUsage)
+	concatCmd.Flags().IntVar(&concatFlags.bufferSize, "buffersize", 1024, "buffer size")
+	concatCmd.Flags().StringVar(&concatFlags.gap, "gap", "", "silence inserted between files, same format as start")
+	concatCmd.Flags().StringVar(&concatFlags.crossfade, "crossfade", "", "crossfade length between files, same format as start")
+	concatCmd.Flags().StringVar(&concatFlags.crossfadeCurve, "crossfade-curve", string(userinput.Concat.DefaultCurve), "crossfade curve: linear, log or equal-power")
+	concatCmd.Flags().BoolVar(&concatFlags.convert, "convert", false, "convert sample rate and channels to the first file, files must match if not specified")
+	concatFlags.sink.register(concatCmd)
+	concatFlags.process.register(concatCmd)
+	concatFlags.tags.register(concatCmd)
+	concatCmd.Flags().SortFlags = false
+}
+
+// concatCLI joins files in provided order and encodes them into the
+// output file. Output file is removed if encoding fails.
+func concatCLI(ctx context.Context, paths []string, outPath, collision string, bufferSize int, join process.Join, sink userinput.Sink, processing encode.Processing) error {
+	files, err := openInputs(paths, outPath, collision)
+	if err != nil {
+		return err
+	}
+	defer closeInputs(files)
+	input := func() (pipe.SourceAllocatorFunc, error) {
+		sources, err := files.sources()
+		if err != nil {
+			return nil, err
+		}
+		return process.Concat(sources, join), nil
+	}
+	return encodeInputs(ctx, paths, outPath, collision, bufferSize, input, sink, processing)
+}
+
+// openedInput is the opened input file with its format.
+type openedInput struct {
+	*os.File
+	format *fileformat.Format
+}
+
+type openedInputs []openedInput
+
+// openInputs opens all input files. Inputs can't be overwritten by the
+// output.
+func openInputs(paths []string, outPath, collision string) (openedInputs, error) {
+	files := make(openedInputs, 0, len(paths))
+	for _, path := range paths {
+		if collision == collisionOverwrite && sameFile(path, outPath) {
+			closeInputs(files)
+			return nil, fmt.Errorf("output file %s overwrites the input", outPath)
+		}
+		format := fileformat.FormatByPath(path)
+		if format == nil {
+			closeInputs(files)
+			return nil, fmt.Errorf("unsupported input format: %s", path)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			closeInputs(files)
+			return nil, fmt.Errorf("error opening file: %w", err)
+		}
+		files = append(files, openedInput{File: f, format: format})
+	}
+	return files, nil
+}
+
+func closeInputs(files openedInputs) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+// sources returns sources of all inputs read from the beginning.
+func (files openedInputs) sources() ([]pipe.SourceAllocatorFunc, error) {
+	sources := make([]pipe.SourceAllocatorFunc, 0, len(files))
+	for _, f := range files {
+		source, err := encode.FileInput(f.format, f)()
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+// encodeInputs encodes the signal of multiple inputs into the output
+// file and logs the result. Output file is removed if encoding fails.
+func encodeInputs(ctx context.Context, paths []string, outPath, collision string, bufferSize int, input encode.InputFunc, sink userinput.Sink, processing encode.Processing) error {
+	namer, err := newOutNamer(filepath.Base(outPath), collision, filepath.Ext(outPath), nil)
+	if err != nil {
+		return err
+	}
+	out, err := namer.create(filepath.Dir(outPath), paths[0], 1)
+	if err != nil {
+		if errors.Is(err, errOutputExists) {
+			log.Printf("Skipped %s: %v\n", outPath, err)
+			return nil
+		}
+		return err
+	}
+	report, err := encode.RunInput(ctx, bufferSize, input, sink(out), processing)
+	if err != nil {
+		out.Close()
+		if err := os.Remove(out.Name()); err != nil {
+			log.Printf("Failed to remove output file: %v", err)
+		}
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	log.Printf("Encoded %d files to %s\n", len(paths), out.Name())
+	if report.Silence != nil {
+		log.Printf("Trimmed silence %s: %v at start, %v at end\n", out.Name(), report.Silence.Start, report.Silence.End)
+	}
+	for _, warning := range report.Warnings() {
+		log.Printf("Warning %s: %s\n", out.Name(), warning)
+	}
+	if report.Analysis != nil {
+		for _, line := range analysisSummary(*report.Analysis) {
+			log.Printf("Analysis %s: %s\n", out.Name(), line)
+		}
+	}
+	return nil
+}
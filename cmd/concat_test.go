@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/phono/encode"
+	"pipelined.dev/phono/userinput"
+)
+
+func TestConcatCLI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phono-concat")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	join, err := userinput.Concat.Join("1", "", "", false, "")
+	assert.Nil(t, err)
+	sink, err := userinput.WAV.Sink(16)
+	assert.Nil(t, err)
+	out := filepath.Join(dir, "out.wav")
+	err = concatCLI(context.Background(), []string{wavSample, wavSample}, out, collisionSuffix, 512, join, sink, encode.Processing{})
+	assert.Nil(t, err)
+	fi, err := os.Stat(out)
+	assert.Nil(t, err)
+	// two samples and one second gap in 16 bit stereo.
+	assert.Equal(t, int64(44+(2*330534+44100)*4), fi.Size())
+
+	err = concatCLI(context.Background(), []string{wavSample, filepath.Join(dir, "in.txt")}, out, collisionSuffix, 512, join, sink, encode.Processing{})
+	assert.NotNil(t, err)
+	err = concatCLI(context.Background(), []string{out, wavSample}, out, collisionOverwrite, 512, join, sink, encode.Processing{})
+	assert.NotNil(t, err)
+}
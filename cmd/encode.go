@@ -2,19 +2,24 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/spf13/cobra"
 
-	"pipelined.dev/audio/fileformat"
 	"pipelined.dev/pipe"
 
 	"pipelined.dev/phono/encode"
+	"pipelined.dev/phono/fileformat"
+	"pipelined.dev/phono/process"
+	"pipelined.dev/phono/tag"
 )
 
 var (
@@ -31,13 +36,109 @@ func init() {
 	rootCmd.AddCommand(encodeCmd)
 }
 
-func encodeCLI(ctx context.Context, paths []string, recursive bool, outDir string, bufferSize int, sink func(io.WriteSeeker) pipe.SinkAllocatorFunc, ext string) {
-	if outDir != "" {
-		if _, err := os.Stat(outDir); os.IsNotExist(err) {
-			log.Printf("Out path doesn't exist: %v", err)
-			return
+type (
+	// inputFile is a single supported file found in input paths.
+	inputFile struct {
+		root   string
+		path   string
+		index  int
+		format *fileformat.Format
+		// error that occurred while the file was discovered.
+		err error
+	}
+
+	// encodeResult is the outcome of a single file encoding.
+	encodeResult struct {
+		in      string
+		out     string
+		skipped bool
+		err     error
+		// warnings are reported only for encoded files.
+		warnings []string
+		silence  *encode.TrimmedSilence
+		analysis *process.Analysis
+	}
+)
+
+// encodeCLI encodes files found in paths and reports the results. Error is
+// returned if input is invalid or any of files failed.
+func encodeCLI(ctx context.Context, paths []string, recursive bool, output cliOutput, bufferSize, jobs int, sink func(io.WriteSeeker) pipe.SinkAllocatorFunc, processing encode.Processing) error {
+	if jobs < 1 {
+		return fmt.Errorf("number of jobs must be positive: %d", jobs)
+	}
+	if output.mirror && (!recursive || output.dir == "") {
+		return errors.New("mirror requires recursive processing and out path")
+	}
+	if output.dir != "" {
+		if _, err := os.Stat(output.dir); os.IsNotExist(err) {
+			return fmt.Errorf("out path doesn't exist: %w", err)
 		}
 	}
+
+	files := discover(paths, recursive, output.dir)
+	results := make([]encodeResult, len(files))
+	// fan out files to workers, every worker writes results only for
+	// received files.
+	filesc := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range filesc {
+				results[idx] = encodeFile(ctx, files[idx], output, bufferSize, sink, processing)
+			}
+		}()
+	}
+	for i := range files {
+		filesc <- i
+	}
+	close(filesc)
+	wg.Wait()
+	return report(results)
+}
+
+// report logs results of every file and the summary. Error is returned
+// if any of files failed.
+func report(results []encodeResult) error {
+	var (
+		encoded, skipped int
+		failed           []string
+	)
+	for _, r := range results {
+		switch {
+		case r.skipped:
+			skipped++
+			log.Printf("Skipped %s: %v\n", r.in, r.err)
+		case r.err != nil:
+			failed = append(failed, r.in)
+			log.Printf("Failed %s: %v\n", r.in, r.err)
+		default:
+			encoded++
+			log.Printf("Encoded %s to %s\n", r.in, r.out)
+			if r.silence != nil {
+				log.Printf("Trimmed silence %s: %v at start, %v at end\n", r.in, r.silence.Start, r.silence.End)
+			}
+			for _, warning := range r.warnings {
+				log.Printf("Warning %s: %s\n", r.in, warning)
+			}
+			if r.analysis != nil {
+				for _, line := range analysisSummary(*r.analysis) {
+					log.Printf("Analysis %s: %s\n", r.out, line)
+				}
+			}
+		}
+	}
+	log.Printf("Summary: %d encoded, %d failed, %d skipped\n", encoded, len(failed), skipped)
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to encode %d files:\n%s", len(failed), strings.Join(failed, "\n"))
+	}
+	return nil
+}
+
+// discover walks the paths and returns supported files in the order of
+// walk.
+func discover(paths []string, recursive bool, outDir string) []inputFile {
 	// build a map for easy-check
 	mpaths := make(map[string]struct{})
 	if !recursive {
@@ -46,12 +147,27 @@ func encodeCLI(ctx context.Context, paths []string, recursive bool, outDir strin
 		}
 	}
 
-	command := "phono-encode"
+	var (
+		// currently walked path
+		root  string
+		files []inputFile
+		// number of discovered files, errors are not counted
+		discovered int
+	)
 	walkFn := func(path string, fi os.FileInfo, err error) error {
 		if err != nil {
-			log.Printf("Error during walk: %v\n", err)
+			files = append(files, inputFile{
+				root: root,
+				path: path,
+				err:  fmt.Errorf("error during walk: %w", err),
+			})
+			return nil
 		}
 		if fi.IsDir() {
+			// don't process output folder
+			if path != root && outDir != "" && sameFile(path, outDir) {
+				return filepath.SkipDir
+			}
 			// process subdirs
 			if recursive {
 				return nil
@@ -70,50 +186,78 @@ func encodeCLI(ctx context.Context, paths []string, recursive bool, outDir strin
 			// file is not supported, skip
 			return nil
 		}
-
-		// open file
-		in, err := os.Open(path)
-		if err != nil {
-			log.Printf("Error opening file: %v\n", err)
-			return nil
-		}
-		defer in.Close() // since we only read file, it's ok to close it with defer
-
-		// create output filename
-		var outFilename string
-		if outDir != "" {
-			outFilename = filepath.Join(outDir, outName("", command, ext))
-		} else {
-			outFilename = filepath.Join(filepath.Dir(path), outName("", command, ext))
-		}
-
-		out, err := os.Create(outFilename)
-		if err != nil {
-			log.Printf("Error creating output file: %v\n", err)
-		}
-		// error will be handled in the end of the flow
-		defer out.Close()
-
-		if err = encode.Run(ctx, bufferSize, format.Source(in), sink(out)); err != nil {
-			return fmt.Errorf("failed to execute pipe: %v", err)
-		}
-		return out.Close()
+		discovered++
+		files = append(files, inputFile{
+			root:   root,
+			path:   path,
+			index:  discovered,
+			format: format,
+		})
+		return nil
 	}
 	for _, path := range paths {
-		err := filepath.Walk(path, walkFn)
-		if err != nil {
-			log.Print(err)
-		}
+		root = path
+		// walk function never returns errors
+		_ = filepath.Walk(path, walkFn)
 	}
+	return files
 }
 
-// outName generates an output file name with a next template:
-// 	[prefix-]name-timestamp.ext
-func outName(prefix, command, ext string) string {
-	if prefix != "" {
-		return fmt.Sprintf("%s-%s-%s%s", prefix, command, timestamp(), ext)
+// encodeFile encodes a single file. Output file is removed if encoding
+// fails.
+func encodeFile(ctx context.Context, file inputFile, output cliOutput, bufferSize int, sink func(io.WriteSeeker) pipe.SinkAllocatorFunc, processing encode.Processing) encodeResult {
+	result := encodeResult{in: file.path}
+	if file.err != nil {
+		result.err = file.err
+		return result
+	}
+	// don't start new files after interruption
+	if err := ctx.Err(); err != nil {
+		result.err = err
+		return result
+	}
+
+	// open file
+	in, err := os.Open(file.path)
+	if err != nil {
+		result.err = fmt.Errorf("error opening file: %w", err)
+		return result
+	}
+	defer in.Close() // since we only read file, it's ok to close it with defer
+	m, err := tag.Read(file.format, in)
+	if err != nil {
+		// invalid tags of the input are not carried.
+		result.warnings = append(result.warnings, fmt.Sprintf("tags are not carried: %v", err))
+	}
+	if m, err = output.tags.Apply(m); err != nil {
+		result.err = err
+		return result
+	}
+
+	// create output file
+	out, err := output.create(file.root, file.path, file.index)
+	if err != nil {
+		result.skipped = errors.Is(err, errOutputExists)
+		result.err = err
+		return result
+	}
+	result.out = out.Name()
+	sink = tag.Sink(fileformat.FormatByPath(out.Name()), m, sink)
+
+	report, err := encode.Run(ctx, bufferSize, file.format, in, sink(out), processing)
+	if err != nil {
+		out.Close()
+		if err := os.Remove(out.Name()); err != nil {
+			log.Printf("Failed to remove output file: %v", err)
+		}
+		result.err = err
+		return result
 	}
-	return fmt.Sprintf("%s-%s%s", command, timestamp(), ext)
+	result.warnings = append(result.warnings, report.Warnings()...)
+	result.silence = report.Silence
+	result.analysis = report.Analysis
+	result.err = out.Close()
+	return result
 }
 
 func timestamp() string {
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"pipelined.dev/phono/fileformat"
+	"pipelined.dev/phono/userinput"
+)
+
+var (
+	encodeAiff = struct {
+		outPath    string
+		name       string
+		collision  string
+		recursive  bool
+		mirror     bool
+		bufferSize int
+		jobs       int
+		bitDepth   int
+		process    processFlags
+		tags       tagFlags
+	}{}
+	encodeAiffCmd = &cobra.Command{
+		Use:                   "aiff [flags] path...",
+		DisableFlagsInUseLine: true,
+		Short:                 "Encode audio files to aiff format",
+		Args:                  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			// parse user userinput
+			sink, err := userinput.AIFF.Sink(encodeAiff.bitDepth)
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+			processing, err := encodeAiff.process.processing()
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+			tags, err := encodeAiff.tags.edit()
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+			namer, err := newOutNamer(
+				encodeAiff.name,
+				encodeAiff.collision,
+				fileformat.AIFF().DefaultExtension(),
+				map[string]string{
+					"bitdepth": strconv.Itoa(encodeAiff.bitDepth),
+				},
+			)
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+			// create channel for interruption and context for cancellation
+			ctx, cancelFn := context.WithCancel(context.Background())
+			// interrupt signal received, shut down
+			onInterrupt(func() { cancelFn() })
+			err = encodeCLI(ctx,
+				args,
+				encodeAiff.recursive,
+				cliOutput{
+					dir:      encodeAiff.outPath,
+					mirror:   encodeAiff.mirror,
+					tags:     tags,
+					outNamer: namer,
+				},
+				encodeAiff.bufferSize,
+				encodeAiff.jobs,
+				sink,
+				processing,
+			)
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+func init() {
+	encodeCmd.AddCommand(encodeAiffCmd)
+	encodeAiffCmd.Flags().StringVar(&encodeAiff.outPath, "out", "", "output folder, the userinput folder is used if not specified")
+	encodeAiffCmd.Flags().IntVar(&encodeAiff.bufferSize, "buffersize", 1024, "buffer size")
+	encodeAiffCmd.Flags().IntVar(&encodeAiff.jobs, "jobs", 1, "number of files encoded concurrently")
+	encodeAiffCmd.Flags().IntVar(&encodeAiff.bitDepth, "bitdepth", 24, "bit depth")
+	encodeAiffCmd.Flags().BoolVar(&encodeAiff.recursive, "recursive", false, "process paths recursive")
+	encodeAiffCmd.Flags().BoolVar(&encodeAiff.mirror, "mirror", false, "recreate folders structure of recursive paths in the out folder")
+	encodeAiffCmd.Flags().StringVar(&encodeAiff.name, "name", defaultNameTemplate, nameFlagUsage+"\n{bitdepth} - output bit depth")
+	encodeAiffCmd.Flags().StringVar(&encodeAiff.collision, "collision", collisionSuffix, collisionFlagUsage)
+	encodeAiff.process.register(encodeAiffCmd)
+	encodeAiff.tags.register(encodeAiffCmd)
+	encodeAiffCmd.Flags().SortFlags = false
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"pipelined.dev/phono/fileformat"
+	"pipelined.dev/phono/flac"
+	"pipelined.dev/phono/userinput"
+)
+
+var (
+	encodeFlac = struct {
+		outPath          string
+		name             string
+		collision        string
+		recursive        bool
+		mirror           bool
+		bufferSize       int
+		jobs             int
+		bitDepth         int
+		compressionLevel int
+		process          processFlags
+		tags             tagFlags
+	}{}
+	encodeFlacCmd = &cobra.Command{
+		Use:                   "flac [flags] path...",
+		DisableFlagsInUseLine: true,
+		Short:                 "Encode audio files to flac format",
+		Args:                  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			// parse user userinput
+			sink, err := userinput.FLAC.Sink(encodeFlac.bitDepth, encodeFlac.compressionLevel)
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+			processing, err := encodeFlac.process.processing()
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+			tags, err := encodeFlac.tags.edit()
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+			namer, err := newOutNamer(
+				encodeFlac.name,
+				encodeFlac.collision,
+				fileformat.FLAC().DefaultExtension(),
+				map[string]string{
+					"bitdepth": strconv.Itoa(encodeFlac.bitDepth),
+				},
+			)
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+			// create channel for interruption and context for cancellation
+			ctx, cancelFn := context.WithCancel(context.Background())
+			// interrupt signal received, shut down
+			onInterrupt(func() { cancelFn() })
+			err = encodeCLI(ctx,
+				args,
+				encodeFlac.recursive,
+				cliOutput{
+					dir:      encodeFlac.outPath,
+					mirror:   encodeFlac.mirror,
+					tags:     tags,
+					outNamer: namer,
+				},
+				encodeFlac.bufferSize,
+				encodeFlac.jobs,
+				sink,
+				processing,
+			)
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+func init() {
+	encodeCmd.AddCommand(encodeFlacCmd)
+	encodeFlacCmd.Flags().StringVar(&encodeFlac.outPath, "out", "", "output folder, the userinput folder is used if not specified")
+	encodeFlacCmd.Flags().IntVar(&encodeFlac.bufferSize, "buffersize", 1024, "buffer size")
+	encodeFlacCmd.Flags().IntVar(&encodeFlac.jobs, "jobs", 1, "number of files encoded concurrently")
+	encodeFlacCmd.Flags().IntVar(&encodeFlac.bitDepth, "bitdepth", 24, "bit depth:\n8, 16 or 24")
+	encodeFlacCmd.Flags().IntVar(&encodeFlac.compressionLevel, "compression", int(flac.DefaultCompressionLevel), "compression level [0..8]")
+	encodeFlacCmd.Flags().BoolVar(&encodeFlac.recursive, "recursive", false, "process paths recursive")
+	encodeFlacCmd.Flags().BoolVar(&encodeFlac.mirror, "mirror", false, "recreate folders structure of recursive paths in the out folder")
+	encodeFlacCmd.Flags().StringVar(&encodeFlac.name, "name", defaultNameTemplate, nameFlagUsage+"\n{bitdepth} - output bit depth")
+	encodeFlacCmd.Flags().StringVar(&encodeFlac.collision, "collision", collisionSuffix, collisionFlagUsage)
+	encodeFlac.process.register(encodeFlacCmd)
+	encodeFlac.tags.register(encodeFlacCmd)
+	encodeFlacCmd.Flags().SortFlags = false
+}
@@ -7,6 +7,8 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"runtime"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -19,12 +21,16 @@ var (
 		port       int
 		tempDir    string
 		bufferSize int
+		workers    int
+		queueSize  int
+		retention  time.Duration
+		cleanup    time.Duration
 	}{}
 	encodeHTTPCmd = &cobra.Command{
 		Use:   "http",
 		Short: "Spin up the http service to encode files",
 		Run: func(cmd *cobra.Command, args []string) {
-			serve(encodeHTTP.port, encodeHTTP.tempDir, encodeHTTP.bufferSize)
+			serve(encodeHTTP.port, encodeHTTP.tempDir, encodeHTTP.bufferSize, encodeHTTP.workers, encodeHTTP.queueSize, encodeHTTP.retention, encodeHTTP.cleanup)
 		},
 	}
 )
@@ -34,18 +40,32 @@ func init() {
 	encodeHTTPCmd.Flags().IntVar(&encodeHTTP.port, "port", 8080, "port to use")
 	encodeHTTPCmd.Flags().StringVar(&encodeHTTP.tempDir, "tempdir", "", "directory for temp files. defaults to os.TempDir if empty")
 	encodeHTTPCmd.Flags().IntVar(&encodeHTTP.bufferSize, "buffersize", 1024, "buffer size")
+	encodeHTTPCmd.Flags().IntVar(&encodeHTTP.workers, "workers", runtime.NumCPU(), "number of asynchronous jobs encoded concurrently")
+	encodeHTTPCmd.Flags().IntVar(&encodeHTTP.queueSize, "queue", 100, "number of asynchronous jobs waiting for a worker")
+	encodeHTTPCmd.Flags().DurationVar(&encodeHTTP.retention, "retention", time.Hour, "how long results of asynchronous jobs are kept. zero keeps results until shutdown")
+	encodeHTTPCmd.Flags().DurationVar(&encodeHTTP.cleanup, "cleanup", time.Minute, "interval of expired results clean up")
 }
 
-func serve(port int, tempDir string, bufferSize int) {
+func serve(port int, tempDir string, bufferSize, workers, queueSize int, retention, cleanup time.Duration) {
+	if workers < 1 {
+		log.Fatalf("Number of workers must be positive: %d", workers)
+	}
 	// temporary directory
 	dir, err := ioutil.TempDir(tempDir, "phono")
 	if err != nil {
 		log.Fatal(fmt.Sprintf("Failed to create temp folder: %v", err))
 	}
 
+	// queue of asynchronous jobs
+	queue := encode.NewQueue(bufferSize, workers, queueSize, dir, retention, cleanup)
+
 	// setting router rule
+	api := userinput.NewEncodeAPI(userinput.Limits{})
 	mux := http.NewServeMux()
 	mux.Handle("/", encode.Handler(userinput.NewEncodeForm(userinput.Limits{}), bufferSize, dir))
+	mux.Handle("/api/", encode.APIHandler(api, bufferSize, dir))
+	mux.Handle("/jobs", encode.JobsHandler(api, queue))
+	mux.Handle("/jobs/", encode.JobsHandler(api, queue))
 	server := http.Server{
 		Addr:    fmt.Sprintf(":%d", port),
 		Handler: mux,
@@ -66,6 +86,7 @@ func serve(port int, tempDir string, bufferSize int) {
 	<-interrupted
 
 	// clean up
+	queue.Close()
 	err = os.RemoveAll(dir)
 	if err != nil {
 		log.Printf("Clean up error: %v", err)
@@ -2,24 +2,32 @@ package cmd
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
-	"pipelined.dev/audio/fileformat"
 
+	"pipelined.dev/phono/fileformat"
 	"pipelined.dev/phono/userinput"
 )
 
 var (
 	encodeMp3 = struct {
 		outPath     string
+		name        string
+		collision   string
 		recursive   bool
+		mirror      bool
 		bufferSize  int
+		jobs        int
 		channelMode int
 		bitRateMode string
 		bitRate     int
 		quality     int
+		process     processFlags
+		tags        tagFlags
 	}{}
 	encodeMp3Cmd = &cobra.Command{
 		Use:                   "mp3 [flags] path...",
@@ -42,19 +50,50 @@ var (
 				log.Print(err)
 				os.Exit(1)
 			}
+			processing, err := encodeMp3.process.processing()
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+			tags, err := encodeMp3.tags.edit()
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+			namer, err := newOutNamer(
+				encodeMp3.name,
+				encodeMp3.collision,
+				fileformat.MP3().DefaultExtension(),
+				map[string]string{
+					"bitrate": fmt.Sprintf("%s-%d", strings.ToLower(encodeMp3.bitRateMode), encodeMp3.bitRate),
+				},
+			)
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
 			// create channel for interruption and context for cancellation
 			ctx, cancelFn := context.WithCancel(context.Background())
 			// interrupt signal received, shut down
-			interrupted := onInterrupt(func() { cancelFn() })
-			encodeCLI(ctx,
+			onInterrupt(func() { cancelFn() })
+			err = encodeCLI(ctx,
 				args,
 				encodeMp3.recursive,
-				encodeMp3.outPath,
+				cliOutput{
+					dir:      encodeMp3.outPath,
+					mirror:   encodeMp3.mirror,
+					tags:     tags,
+					outNamer: namer,
+				},
 				encodeMp3.bufferSize,
+				encodeMp3.jobs,
 				sink,
-				fileformat.MP3().DefaultExtension(),
+				processing,
 			)
-			<-interrupted
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
 		},
 	}
 )
@@ -63,10 +102,16 @@ func init() {
 	encodeCmd.AddCommand(encodeMp3Cmd)
 	encodeMp3Cmd.Flags().StringVar(&encodeMp3.outPath, "out", "", "output folder, the userinput folder is used if not specified")
 	encodeMp3Cmd.Flags().IntVar(&encodeMp3.bufferSize, "buffersize", 1024, "buffer size")
+	encodeMp3Cmd.Flags().IntVar(&encodeMp3.jobs, "jobs", 1, "number of files encoded concurrently")
 	encodeMp3Cmd.Flags().IntVar(&encodeMp3.channelMode, "channelmode", 2, "channel mode:\n0 - mono\n1 - stereo\n2 - joint stereo")
 	encodeMp3Cmd.Flags().StringVar(&encodeMp3.bitRateMode, "bitratemode", "vbr", "bit rate mode:\ncbr - constant bit rate\nabr - average bit rate\nvbr - variable bit rate")
 	encodeMp3Cmd.Flags().IntVar(&encodeMp3.bitRate, "bitrate", 4, "bit rate:\n[8..320] for cbr and abr\n[0..9] for vbr")
 	encodeMp3Cmd.Flags().IntVar(&encodeMp3.quality, "quality", 5, "quality [0..9]")
 	encodeMp3Cmd.Flags().BoolVar(&encodeMp3.recursive, "recursive", false, "process paths recursive")
+	encodeMp3Cmd.Flags().BoolVar(&encodeMp3.mirror, "mirror", false, "recreate folders structure of recursive paths in the out folder")
+	encodeMp3Cmd.Flags().StringVar(&encodeMp3.name, "name", defaultNameTemplate, nameFlagUsage+"\n{bitrate} - output bit rate mode and value")
+	encodeMp3Cmd.Flags().StringVar(&encodeMp3.collision, "collision", collisionSuffix, collisionFlagUsage)
+	encodeMp3.process.register(encodeMp3Cmd)
+	encodeMp3.tags.register(encodeMp3Cmd)
 	encodeMp3Cmd.Flags().SortFlags = false
 }
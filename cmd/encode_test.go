@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/phono/encode"
+	"pipelined.dev/phono/fileformat"
+	"pipelined.dev/phono/info"
+	"pipelined.dev/phono/tag"
+	"pipelined.dev/phono/userinput"
+)
+
+const wavSample = "../_testdata/sample.wav"
+
+// copyFile copies source file to destination.
+func copyFile(t *testing.T, src, dst string) {
+	t.Helper()
+	data, err := ioutil.ReadFile(src)
+	assert.Nil(t, err)
+	assert.Nil(t, os.MkdirAll(filepath.Dir(dst), 0777))
+	assert.Nil(t, ioutil.WriteFile(dst, data, 0666))
+}
+
+func TestEncodeCLI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phono-encode")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	root := filepath.Join(dir, "in")
+	outDir := filepath.Join(dir, "out")
+	assert.Nil(t, os.Mkdir(outDir, 0777))
+	inputs := []string{
+		filepath.Join(root, "1.wav"),
+		filepath.Join(root, "a", "2.wav"),
+		filepath.Join(root, "a", "b", "3.wav"),
+	}
+	for _, in := range inputs {
+		copyFile(t, wavSample, in)
+	}
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(root, "not-media"), nil, 0666))
+
+	files := discover([]string{root}, true, outDir)
+	assert.Equal(t, len(inputs), len(files))
+	for i := range files {
+		assert.Equal(t, inputs[i], files[i].path)
+		assert.Equal(t, i+1, files[i].index)
+	}
+	// walk errors don't shift indices of discovered files.
+	files = discover([]string{filepath.Join(dir, "missing"), inputs[0]}, false, "")
+	assert.Equal(t, 2, len(files))
+	assert.NotNil(t, files[0].err)
+	assert.Equal(t, 1, files[1].index)
+
+	sink, err := userinput.WAV.Sink(16)
+	assert.Nil(t, err)
+	namer, err := newOutNamer("{index}{ext}", collisionSuffix, ".wav", nil)
+	assert.Nil(t, err)
+	err = encodeCLI(context.Background(), []string{root}, true, cliOutput{dir: outDir, outNamer: namer}, 512, 2, sink, encode.Processing{})
+	assert.Nil(t, err)
+	for _, name := range []string{"1.wav", "2.wav", "3.wav"} {
+		fi, err := os.Stat(filepath.Join(outDir, name))
+		assert.Nil(t, err)
+		assert.NotZero(t, fi.Size())
+	}
+}
+
+func TestEncodeAIFF(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phono-encode")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	copyFile(t, wavSample, filepath.Join(dir, "sample.wav"))
+
+	encodeTo := func(in string, sink userinput.Sink, ext string) {
+		t.Helper()
+		namer, err := newOutNamer("{base}{ext}", collisionSuffix, ext, nil)
+		assert.Nil(t, err)
+		err = encodeCLI(context.Background(), []string{in}, false, cliOutput{outNamer: namer}, 512, 1, sink, encode.Processing{})
+		assert.Nil(t, err)
+	}
+	aiffSink, err := userinput.AIFF.Sink(16)
+	assert.Nil(t, err)
+	encodeTo(filepath.Join(dir, "sample.wav"), aiffSink, ".aiff")
+
+	// aiff is decoded and tags of the input are carried.
+	out := filepath.Join(dir, "sample.aiff")
+	f, err := os.Open(out)
+	assert.Nil(t, err)
+	defer f.Close()
+	i, err := info.Read(fileformat.AIFF(), f)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(330534), i.Frames)
+	assert.Equal(t, 16, i.BitDepth)
+	m, err := tag.Read(fileformat.AIFF(), f)
+	assert.Nil(t, err)
+	assert.Equal(t, "freewavesamples.com", m.Tags[tag.Artist])
+
+	flacSink, err := userinput.FLAC.Sink(16, 5)
+	assert.Nil(t, err)
+	encodeTo(out, flacSink, ".flac")
+	flacOut, err := os.Open(filepath.Join(dir, "sample.flac"))
+	assert.Nil(t, err)
+	defer flacOut.Close()
+	i, err = info.Read(fileformat.FLAC(), flacOut)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(330534), i.Frames)
+}
+
+func TestEncodeCorruptTags(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phono-encode")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	in := filepath.Join(dir, "corrupt.mp3")
+	copyFile(t, "../_testdata/corrupt-tags.mp3", in)
+
+	sink, err := userinput.WAV.Sink(16)
+	assert.Nil(t, err)
+	namer, err := newOutNamer("{base}{ext}", collisionSuffix, ".wav", nil)
+	assert.Nil(t, err)
+	files := discover([]string{in}, false, "")
+	assert.Equal(t, 1, len(files))
+	result := encodeFile(context.Background(), files[0], cliOutput{outNamer: namer}, 512, sink, encode.Processing{})
+	// input is encoded and invalid tags are reported.
+	assert.Nil(t, result.err)
+	assert.Equal(t, 1, len(result.warnings))
+	assert.Contains(t, result.warnings[0], "invalid size of ID3v2 frame")
+	fi, err := os.Stat(filepath.Join(dir, "corrupt.wav"))
+	assert.Nil(t, err)
+	assert.NotZero(t, fi.Size())
+}
+
+func TestReport(t *testing.T) {
+	assert.Nil(t, report([]encodeResult{
+		{in: "1.wav", out: "1.mp3"},
+		{in: "2.wav", skipped: true, err: errOutputExists},
+	}))
+	err := report([]encodeResult{
+		{in: "1.wav", out: "1.mp3"},
+		{in: "2.wav", err: context.Canceled},
+	})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "2.wav")
+}
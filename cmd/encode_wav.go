@@ -4,19 +4,26 @@ import (
 	"context"
 	"log"
 	"os"
+	"strconv"
 
 	"github.com/spf13/cobra"
-	"pipelined.dev/audio/fileformat"
 
+	"pipelined.dev/phono/fileformat"
 	"pipelined.dev/phono/userinput"
 )
 
 var (
 	encodeWav = struct {
 		outPath    string
+		name       string
+		collision  string
 		recursive  bool
+		mirror     bool
 		bufferSize int
+		jobs       int
 		bitDepth   int
+		process    processFlags
+		tags       tagFlags
 	}{}
 	encodeWavCmd = &cobra.Command{
 		Use:                   "wav [flags] path...",
@@ -30,19 +37,50 @@ var (
 				log.Print(err)
 				os.Exit(1)
 			}
+			processing, err := encodeWav.process.processing()
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+			tags, err := encodeWav.tags.edit()
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+			namer, err := newOutNamer(
+				encodeWav.name,
+				encodeWav.collision,
+				fileformat.WAV().DefaultExtension(),
+				map[string]string{
+					"bitdepth": strconv.Itoa(encodeWav.bitDepth),
+				},
+			)
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
 			// create channel for interruption and context for cancellation
 			ctx, cancelFn := context.WithCancel(context.Background())
 			// interrupt signal received, shut down
-			interrupted := onInterrupt(func() { cancelFn() })
-			encodeCLI(ctx,
+			onInterrupt(func() { cancelFn() })
+			err = encodeCLI(ctx,
 				args,
 				encodeWav.recursive,
-				encodeWav.outPath,
+				cliOutput{
+					dir:      encodeWav.outPath,
+					mirror:   encodeWav.mirror,
+					tags:     tags,
+					outNamer: namer,
+				},
 				encodeWav.bufferSize,
+				encodeWav.jobs,
 				sink,
-				fileformat.WAV().DefaultExtension(),
+				processing,
 			)
-			<-interrupted
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
 		},
 	}
 )
@@ -51,7 +89,13 @@ func init() {
 	encodeCmd.AddCommand(encodeWavCmd)
 	encodeWavCmd.Flags().StringVar(&encodeWav.outPath, "out", "", "output folder, the userinput folder is used if not specified")
 	encodeWavCmd.Flags().IntVar(&encodeWav.bufferSize, "buffersize", 1024, "buffer size")
+	encodeWavCmd.Flags().IntVar(&encodeWav.jobs, "jobs", 1, "number of files encoded concurrently")
 	encodeWavCmd.Flags().IntVar(&encodeWav.bitDepth, "bitdepth", 24, "bit depth")
 	encodeWavCmd.Flags().BoolVar(&encodeWav.recursive, "recursive", false, "process paths recursive")
+	encodeWavCmd.Flags().BoolVar(&encodeWav.mirror, "mirror", false, "recreate folders structure of recursive paths in the out folder")
+	encodeWavCmd.Flags().StringVar(&encodeWav.name, "name", defaultNameTemplate, nameFlagUsage+"\n{bitdepth} - output bit depth")
+	encodeWavCmd.Flags().StringVar(&encodeWav.collision, "collision", collisionSuffix, collisionFlagUsage)
+	encodeWav.process.register(encodeWavCmd)
+	encodeWav.tags.register(encodeWavCmd)
 	encodeWavCmd.Flags().SortFlags = false
 }
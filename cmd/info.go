@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"pipelined.dev/phono/info"
+)
+
+var (
+	infoFlags = struct {
+		recursive bool
+		json      bool
+	}{}
+	infoCmd = &cobra.Command{
+		Use:                   "info [flags] path...",
+		DisableFlagsInUseLine: true,
+		Short:                 "Print properties of audio files",
+		Args:                  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			files := readInfo(args, infoFlags.recursive)
+			var err error
+			if infoFlags.json {
+				err = printInfoJSON(os.Stdout, files)
+			} else {
+				err = printInfo(os.Stdout, files)
+			}
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+			for _, f := range files {
+				if f.Error != "" {
+					os.Exit(1)
+				}
+			}
+		},
+	}
+)
+
+// fileInfo contains info of a single file or error if it cannot be read.
+type fileInfo struct {
+	Path string `json:"path"`
+	*info.Info
+	Error string `json:"error,omitempty"`
+}
+
+func init() {
+	rootCmd.AddCommand(infoCmd)
+	infoCmd.Flags().BoolVar(&infoFlags.recursive, "recursive", false, "process paths recursive")
+	infoCmd.Flags().BoolVar(&infoFlags.json, "json", false, "print info in json format")
+	infoCmd.Flags().SortFlags = false
+}
+
+// readInfo reads info of all supported files in paths.
+func readInfo(paths []string, recursive bool) []fileInfo {
+	files := discover(paths, recursive, "")
+	result := make([]fileInfo, 0, len(files))
+	for _, file := range files {
+		fi := fileInfo{Path: file.path}
+		i, err := readFileInfo(file)
+		if err != nil {
+			fi.Error = err.Error()
+		} else {
+			fi.Info = &i
+		}
+		result = append(result, fi)
+	}
+	return result
+}
+
+func readFileInfo(file inputFile) (info.Info, error) {
+	if file.err != nil {
+		return info.Info{}, file.err
+	}
+	f, err := os.Open(file.path)
+	if err != nil {
+		return info.Info{}, fmt.Errorf("error opening file: %w", err)
+	}
+	defer f.Close()
+	return info.Read(file.format, f)
+}
+
+func printInfoJSON(w io.Writer, files []fileInfo) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(files)
+}
+
+func printInfo(w io.Writer, files []fileInfo) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	for _, f := range files {
+		fmt.Fprintln(tw, f.Path)
+		if f.Error != "" {
+			fmt.Fprintf(tw, "  error:\t%s\n", f.Error)
+			continue
+		}
+		fmt.Fprintf(tw, "  format:\t%s\n", f.Format)
+		fmt.Fprintf(tw, "  sample rate:\t%d Hz\n", f.SampleRate)
+		fmt.Fprintf(tw, "  channels:\t%d\n", f.Channels)
+		if f.BitDepth != 0 {
+			fmt.Fprintf(tw, "  bit depth:\t%d\n", f.BitDepth)
+		}
+		fmt.Fprintf(tw, "  duration:\t%v\n", f.DurationTime())
+		fmt.Fprintf(tw, "  frames:\t%d\n", f.Frames)
+		if f.BitRateMode != "" {
+			fmt.Fprintf(tw, "  bit rate mode:\t%s\n", f.BitRateMode)
+			fmt.Fprintf(tw, "  bit rate:\t%d kbps\n", f.BitRate)
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+	"pipelined.dev/pipe"
+
+	"pipelined.dev/phono/encode"
+	"pipelined.dev/phono/process"
+	"pipelined.dev/phono/userinput"
+)
+
+var (
+	mixFlags = struct {
+		outPath    string
+		collision  string
+		bufferSize int
+		gains      []string
+		offsets    []string
+		convert    bool
+		sink       sinkFlags
+		process    processFlags
+		tags       tagFlags
+	}{}
+	mixCmd = &cobra.Command{
+		Use:                   "mix [flags] path...",
+		DisableFlagsInUseLine: true,
+		Short:                 "Mix audio files into one",
+		Args:                  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if mixFlags.outPath == "" {
+				log.Print("output file is not specified")
+				os.Exit(1)
+			}
+			sink, err := mixFlags.sink.sink(cmd, mixFlags.outPath)
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+			sink, err = mixFlags.tags.sink(mixFlags.outPath, sink)
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+			quality, err := userinput.Resample.Quality(mixFlags.process.resampleQuality)
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+			tracks, err := userinput.Mix.Tracks(len(args), mixFlags.gains, mixFlags.offsets)
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+			processing, err := mixFlags.process.processing()
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+			// create channel for interruption and context for cancellation
+			ctx, cancelFn := context.WithCancel(context.Background())
+			// interrupt signal received, shut down
+			onInterrupt(func() { cancelFn() })
+			err = mixCLI(ctx,
+				args,
+				mixFlags.outPath,
+				mixFlags.collision,
+				mixFlags.bufferSize,
+				tracks,
+				mixFlags.convert,
+				quality,
+				sink,
+				processing,
+			)
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(mixCmd)
+	mixCmd.Flags().StringVarP(&mixFlags.outPath, "out", "o", "", "output file, its extension defines the format")
+	mixCmd.Flags().StringVar(&mixFlags.collision, "collision", collisionSuffix, collisionFlagUsage)
+	mixCmd.Flags().IntVar(&mixFlags.bufferSize, "buffersize", 1024, "buffer size")
+	mixCmd.Flags().StringSliceVar(&mixFlags.gains, "gains", nil, "comma-separated gains of files in provided order, e.g. 0,-12dB")
+	mixCmd.Flags().StringSliceVar(&mixFlags.offsets, "offsets", nil, "comma-separated offsets of files in provided order, same format as start, e.g. 0,2.5")
+	mixCmd.Flags().BoolVar(&mixFlags.convert, "convert", false, "convert sample rate and channels to the first file, files must match if not specified")
+	mixFlags.sink.register(mixCmd)
+	mixFlags.process.register(mixCmd)
+	mixFlags.tags.register(mixCmd)
+	mixCmd.Flags().SortFlags = false
+}
+
+// mixCLI mixes files with provided track parameters and encodes them into
+// the output file. Output file is removed if encoding fails.
+func mixCLI(ctx context.Context, paths []string, outPath, collision string, bufferSize int, tracks []process.Track, convert bool, quality process.ResampleQuality, sink userinput.Sink, processing encode.Processing) error {
+	files, err := openInputs(paths, outPath, collision)
+	if err != nil {
+		return err
+	}
+	defer closeInputs(files)
+	input := func() (pipe.SourceAllocatorFunc, error) {
+		sources, err := files.sources()
+		if err != nil {
+			return nil, err
+		}
+		// copy to keep provided tracks unchanged.
+		mixed := make([]process.Track, len(tracks))
+		for i := range tracks {
+			mixed[i] = tracks[i]
+			mixed[i].Source = sources[i]
+		}
+		return process.Mix(mixed, convert, quality), nil
+	}
+	return encodeInputs(ctx, paths, outPath, collision, bufferSize, input, sink, processing)
+}
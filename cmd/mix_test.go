@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/phono/encode"
+	"pipelined.dev/phono/userinput"
+)
+
+func TestMixCLI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phono-mix")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	tracks, err := userinput.Mix.Tracks(2, []string{"-6", "-6"}, []string{"", "1"})
+	assert.Nil(t, err)
+	sink, err := userinput.WAV.Sink(16)
+	assert.Nil(t, err)
+	out := filepath.Join(dir, "out.wav")
+	err = mixCLI(context.Background(), []string{wavSample, wavSample}, out, collisionSuffix, 512, tracks, false, "", sink, encode.Processing{})
+	assert.Nil(t, err)
+	fi, err := os.Stat(out)
+	assert.Nil(t, err)
+	// the second sample starts after one second.
+	assert.Equal(t, int64(44+(330534+44100)*4), fi.Size())
+}
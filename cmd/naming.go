@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"pipelined.dev/phono/tag"
+)
+
+// Policies to resolve collisions with existing output files.
+const (
+	collisionSkip      = "skip"
+	collisionOverwrite = "overwrite"
+	collisionSuffix    = "suffix"
+)
+
+// defaultNameTemplate preserves the input file name.
+const defaultNameTemplate = "{base}{ext}"
+
+// Usage of cli flags that define output naming.
+const (
+	nameFlagUsage = "output file name template, placeholders:\n" +
+		"{base} - input file name without extension\n" +
+		"{dir} - input file parent directory name\n" +
+		"{index} - input file number in the batch\n" +
+		"{timestamp} - time of output file creation\n" +
+		"{format} - output format\n" +
+		"{ext} - output extension"
+	collisionFlagUsage = "policy for existing output files:\n" +
+		"skip - don't encode the file\n" +
+		"overwrite - replace existing file\n" +
+		"suffix - add numeric suffix to the name"
+)
+
+// errOutputExists is returned when output file exists and skip policy is
+// used.
+var errOutputExists = errors.New("output file exists")
+
+var placeholderRegexp = regexp.MustCompile(`{([^{}]*)}`)
+
+// outNamer generates output file names from user-defined template:
+//
+//	{base}-{format}{ext}
+//
+// Following placeholders are always available:
+//
+//	base - input file name without extension
+//	dir - name of the input file parent directory
+//	index - number of the input file in the batch, starting with 1
+//	timestamp - time when output file is created
+//	format - output format name
+//	ext - output format extension with dot
+//
+// Commands can provide additional format-specific placeholders.
+type outNamer struct {
+	template  string
+	collision string
+	ext       string
+	params    map[string]string
+}
+
+func newOutNamer(template, collision, ext string, params map[string]string) (outNamer, error) {
+	switch collision {
+	case collisionSkip, collisionOverwrite, collisionSuffix:
+	default:
+		return outNamer{}, fmt.Errorf("unsupported collision policy: %s", collision)
+	}
+	if template == "" {
+		return outNamer{}, errors.New("name template is empty")
+	}
+	if strings.ContainsRune(template, '/') || strings.ContainsRune(template, filepath.Separator) {
+		return outNamer{}, fmt.Errorf("name template contains path separator: %s", template)
+	}
+	n := outNamer{
+		template:  template,
+		collision: collision,
+		ext:       ext,
+		params:    params,
+	}
+	supported := n.placeholders()
+	for _, match := range placeholderRegexp.FindAllStringSubmatch(template, -1) {
+		if _, ok := supported[match[1]]; !ok {
+			return outNamer{}, fmt.Errorf("unsupported placeholder %s, use one of: %s", match[0], placeholdersList(supported))
+		}
+	}
+	return n, nil
+}
+
+// placeholders returns all supported placeholders, values of
+// file-dependent ones are empty.
+func (n outNamer) placeholders() map[string]string {
+	m := map[string]string{
+		"base":      "",
+		"dir":       "",
+		"index":     "",
+		"timestamp": "",
+		"format":    strings.TrimPrefix(n.ext, "."),
+		"ext":       n.ext,
+	}
+	for k, v := range n.params {
+		m[k] = v
+	}
+	return m
+}
+
+// with returns the namer with additional parameters. Parameters must be
+// known by the namer, so the template stays valid.
+func (n outNamer) with(params map[string]string) outNamer {
+	merged := make(map[string]string, len(n.params)+len(params))
+	for k, v := range n.params {
+		merged[k] = v
+	}
+	for k, v := range params {
+		merged[k] = v
+	}
+	n.params = merged
+	return n
+}
+
+func placeholdersList(m map[string]string) string {
+	result := make([]string, 0, len(m))
+	for k := range m {
+		result = append(result, "{"+k+"}")
+	}
+	sort.Strings(result)
+	return strings.Join(result, ", ")
+}
+
+// name returns output file name for the input file path.
+func (n outNamer) name(path string, index int) string {
+	values := n.placeholders()
+	base := filepath.Base(path)
+	values["base"] = strings.TrimSuffix(base, filepath.Ext(base))
+	values["dir"] = filepath.Base(filepath.Dir(path))
+	values["index"] = strconv.Itoa(index)
+	values["timestamp"] = timestamp()
+	return placeholderRegexp.ReplaceAllStringFunc(n.template, func(p string) string {
+		return values[p[1:len(p)-1]]
+	})
+}
+
+// create creates the output file in the directory with respect to the
+// collision policy. If skip policy is used and file exists,
+// errOutputExists is returned.
+func (n outNamer) create(dir, path string, index int) (*os.File, error) {
+	name := n.name(path, index)
+	outPath := filepath.Join(dir, name)
+	if sameFile(path, outPath) && n.collision == collisionOverwrite {
+		return nil, fmt.Errorf("output file %s overwrites the input", outPath)
+	}
+	switch n.collision {
+	case collisionOverwrite:
+		return os.Create(outPath)
+	case collisionSkip:
+		f, err := os.OpenFile(outPath, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if os.IsExist(err) {
+			return nil, fmt.Errorf("%w: %s", errOutputExists, outPath)
+		}
+		return f, err
+	}
+	// suffix policy, exclusive creation guarantees that existing files
+	// are never touched.
+	ext := filepath.Ext(name)
+	prefix := strings.TrimSuffix(name, ext)
+	for i := 1; ; i++ {
+		f, err := os.OpenFile(outPath, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if !os.IsExist(err) {
+			return f, err
+		}
+		outPath = filepath.Join(dir, fmt.Sprintf("%s-%d%s", prefix, i, ext))
+	}
+}
+
+// cliOutput defines where output files of cli commands are created.
+type cliOutput struct {
+	// output folder, input file folder is used if empty.
+	dir string
+	// recreate the structure of walked folders in the output folder.
+	mirror bool
+	// tags changes tags and cover of the input written to the output.
+	tags tag.Edit
+	outNamer
+}
+
+// create creates the output file for the input path found while walking
+// the root.
+func (o cliOutput) create(root, path string, index int) (*os.File, error) {
+	if o.dir == "" {
+		return o.outNamer.create(filepath.Dir(path), path, index)
+	}
+	if !o.mirror {
+		return o.outNamer.create(o.dir, path, index)
+	}
+	rel, err := filepath.Rel(root, filepath.Dir(path))
+	// root is a file or path is outside of the root
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		rel = "."
+	}
+	dir := filepath.Join(o.dir, rel)
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, fmt.Errorf("failed to create output folder: %w", err)
+	}
+	return o.outNamer.create(dir, path, index)
+}
+
+// sameFile checks if two paths point to the same file.
+func sameFile(p1, p2 string) bool {
+	fi1, err := os.Stat(p1)
+	if err != nil {
+		return false
+	}
+	fi2, err := os.Stat(p2)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(fi1, fi2)
+}
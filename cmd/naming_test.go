@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewOutNamer(t *testing.T) {
+	var tests = []struct {
+		template  string
+		collision string
+		negative  bool
+	}{
+		{
+			template:  defaultNameTemplate,
+			collision: collisionSuffix,
+		},
+		{
+			template:  "{dir}-{base}-{index}-{bitdepth}{ext}",
+			collision: collisionSkip,
+		},
+		{
+			template:  "{base}{ext}",
+			collision: "fake",
+			negative:  true,
+		},
+		{
+			template:  "{fake}{ext}",
+			collision: collisionOverwrite,
+			negative:  true,
+		},
+		{
+			template:  "sub/{base}{ext}",
+			collision: collisionOverwrite,
+			negative:  true,
+		},
+		{
+			template:  "",
+			collision: collisionOverwrite,
+			negative:  true,
+		},
+	}
+	for _, test := range tests {
+		_, err := newOutNamer(test.template, test.collision, ".wav", map[string]string{"bitdepth": "16"})
+		if test.negative {
+			assert.NotNil(t, err)
+		} else {
+			assert.Nil(t, err)
+		}
+	}
+}
+
+func TestOutName(t *testing.T) {
+	n, err := newOutNamer("{dir}-{base}-{index}-{bitdepth}-{format}{ext}", collisionSuffix, ".wav", map[string]string{"bitdepth": "16"})
+	assert.Nil(t, err)
+	assert.Equal(t, "album-track-3-16-wav.wav", n.name(filepath.Join("music", "album", "track.mp3"), 3))
+}
+
+func TestOutCreate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phono-naming")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	in := filepath.Join(dir, "track.wav")
+	assert.Nil(t, ioutil.WriteFile(in, []byte("input"), 0666))
+
+	create := func(collision string) (string, error) {
+		n, err := newOutNamer(defaultNameTemplate, collision, ".wav", nil)
+		assert.Nil(t, err)
+		f, err := n.create(dir, in, 1)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		return filepath.Base(f.Name()), nil
+	}
+
+	name, err := create(collisionSuffix)
+	assert.Nil(t, err)
+	assert.Equal(t, "track-1.wav", name)
+	name, err = create(collisionSuffix)
+	assert.Nil(t, err)
+	assert.Equal(t, "track-2.wav", name)
+
+	_, err = create(collisionSkip)
+	assert.True(t, errors.Is(err, errOutputExists))
+
+	// overwrite of the input is not allowed
+	_, err = create(collisionOverwrite)
+	assert.NotNil(t, err)
+	content, err := ioutil.ReadFile(in)
+	assert.Nil(t, err)
+	assert.Equal(t, "input", string(content))
+}
+
+func TestOutMirror(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phono-naming")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	root := filepath.Join(dir, "library")
+	outDir := filepath.Join(dir, "out")
+	assert.Nil(t, os.Mkdir(outDir, 0777))
+
+	n, err := newOutNamer(defaultNameTemplate, collisionSuffix, ".mp3", nil)
+	assert.Nil(t, err)
+	o := cliOutput{
+		dir:      outDir,
+		mirror:   true,
+		outNamer: n,
+	}
+	f, err := o.create(root, filepath.Join(root, "artist", "album", "track.wav"), 1)
+	assert.Nil(t, err)
+	defer f.Close()
+	assert.Equal(t, filepath.Join(outDir, "artist", "album", "track.mp3"), f.Name())
+
+	// root is a file
+	track := filepath.Join(root, "track.wav")
+	f, err = o.create(track, track, 2)
+	assert.Nil(t, err)
+	defer f.Close()
+	assert.Equal(t, filepath.Join(outDir, "track.mp3"), f.Name())
+
+	// directories that start with dots are inside the root
+	f, err = o.create(root, filepath.Join(root, "..hidden", "track.wav"), 3)
+	assert.Nil(t, err)
+	defer f.Close()
+	assert.Equal(t, filepath.Join(outDir, "..hidden", "track.mp3"), f.Name())
+}
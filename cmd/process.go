@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"pipelined.dev/phono/encode"
+	"pipelined.dev/phono/userinput"
+)
+
+// processFlags contains processing flags shared by encode commands.
+type processFlags struct {
+	start            string
+	end              string
+	duration         string
+	trimSilence      bool
+	silenceThreshold float64
+	silenceDuration  string
+	silencePadding   string
+	fadeIn           string
+	fadeOut          string
+	fadeCurve        string
+	sampleRate       int
+	resampleQuality  string
+	channels         int
+	channelMap       string
+	normalize        float64
+	truePeak         float64
+	gain             string
+	peakNormalize    string
+	analyze          bool
+}
+
+// register adds processing flags to the command.
+func (f *processFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.start, "start", "", "start of the input range in seconds, hh:mm:ss.mmm or samples, e.g. 44100smp")
+	cmd.Flags().StringVar(&f.end, "end", "", "end of the input range, same format as start")
+	cmd.Flags().StringVar(&f.duration, "duration", "", "duration of the input range, same format as start")
+	cmd.Flags().BoolVar(&f.trimSilence, "trim-silence", false, "trim leading and trailing silence")
+	cmd.Flags().Float64Var(&f.silenceThreshold, "silence-threshold", userinput.Silence.DefaultThreshold, "silence threshold in dBFS")
+	cmd.Flags().StringVar(&f.silenceDuration, "silence-duration", userinput.Silence.DefaultMinDuration, "minimum duration of trimmed silence, same format as start")
+	cmd.Flags().StringVar(&f.silencePadding, "silence-padding", userinput.Silence.DefaultPadding, "silence kept next to the sound, same format as start")
+	cmd.Flags().StringVar(&f.fadeIn, "fade-in", "", "fade-in length, same format as start")
+	cmd.Flags().StringVar(&f.fadeOut, "fade-out", "", "fade-out length, same format as start")
+	cmd.Flags().StringVar(&f.fadeCurve, "fade-curve", string(userinput.Fade.DefaultCurve), "fade curve: linear, log or equal-power")
+	cmd.Flags().IntVar(&f.sampleRate, "samplerate", 0, "output sample rate, input sample rate is kept if not specified")
+	cmd.Flags().StringVar(&f.resampleQuality, "resample-quality", string(userinput.Resample.DefaultQuality), "sample rate conversion quality: low, medium or high")
+	cmd.Flags().IntVar(&f.channels, "channels", 0, "output number of channels, input channels are kept if not specified")
+	cmd.Flags().StringVar(&f.channelMap, "channel-map", "", "comma-separated output channels as sums of zero-based input channels, e.g. 1,0 or 0.5*0+0.5*1")
+	cmd.Flags().Float64Var(&f.normalize, "normalize", 0, "target integrated loudness in LUFS, e.g. -16, loudness is kept if not specified")
+	cmd.Flags().Float64Var(&f.truePeak, "true-peak", userinput.Normalize.DefaultCeiling, "true peak ceiling of loudness normalization in dBTP")
+	cmd.Flags().StringVar(&f.gain, "gain", "", "gain applied to the signal, e.g. -3dB")
+	cmd.Flags().StringVar(&f.peakNormalize, "peak-normalize", "", "target peak level, e.g. -1dBFS, peak is kept if not specified")
+	cmd.Flags().BoolVar(&f.analyze, "analyze", false, "report peak, RMS, DC offset, crest factor, clipping and loudness of the output")
+}
+
+// processing validates flags and returns processing of encoding.
+func (f processFlags) processing() (encode.Processing, error) {
+	params := userinput.ProcessParams{
+		Start:            f.start,
+		End:              f.end,
+		Duration:         f.duration,
+		TrimSilence:      f.trimSilence,
+		SilenceThreshold: f.silenceThreshold,
+		SilenceDuration:  f.silenceDuration,
+		SilencePadding:   f.silencePadding,
+		FadeIn:           f.fadeIn,
+		FadeOut:          f.fadeOut,
+		FadeCurve:        f.fadeCurve,
+		SampleRate:       f.sampleRate,
+		ResampleQuality:  f.resampleQuality,
+		Channels:         f.channels,
+		ChannelMap:       f.channelMap,
+		TruePeak:         f.truePeak,
+	}
+	if f.normalize != 0 {
+		params.Normalize = &f.normalize
+	}
+	if f.gain != "" {
+		gain, err := userinput.ParseDecibels(f.gain)
+		if err != nil {
+			return encode.Processing{}, err
+		}
+		params.Gain = gain
+	}
+	if f.peakNormalize != "" {
+		peak, err := userinput.ParseDecibels(f.peakNormalize)
+		if err != nil {
+			return encode.Processing{}, err
+		}
+		params.PeakNormalize = &peak
+	}
+	p, err := params.Processing()
+	if err != nil {
+		return encode.Processing{}, err
+	}
+	p.Analyze = f.analyze
+	return p, nil
+}
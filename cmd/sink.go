@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"pipelined.dev/phono/fileformat"
+	"pipelined.dev/phono/flac"
+	"pipelined.dev/phono/userinput"
+)
+
+// sinkFlags contains flags of all output formats. They are used by
+// commands that write a single output file, the format is defined by its
+// extension.
+type sinkFlags struct {
+	bitDepth         int
+	compressionLevel int
+	channelMode      int
+	bitRateMode      string
+	bitRate          int
+	quality          int
+}
+
+// register adds sink flags to the command.
+func (f *sinkFlags) register(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&f.bitDepth, "bitdepth", 24, "bit depth of wav, aiff and flac output")
+	cmd.Flags().IntVar(&f.compressionLevel, "compression", int(flac.DefaultCompressionLevel), "compression level of flac output [0..8]")
+	cmd.Flags().IntVar(&f.channelMode, "channelmode", 2, "channel mode of mp3 output:\n0 - mono\n1 - stereo\n2 - joint stereo")
+	cmd.Flags().StringVar(&f.bitRateMode, "bitratemode", "vbr", "bit rate mode of mp3 output:\ncbr - constant bit rate\nabr - average bit rate\nvbr - variable bit rate")
+	cmd.Flags().IntVar(&f.bitRate, "bitrate", 4, "bit rate of mp3 output:\n[8..320] for cbr and abr\n[0..9] for vbr")
+	cmd.Flags().IntVar(&f.quality, "quality", 5, "quality of mp3 output [0..9]")
+}
+
+// sink validates flags of the output format defined by the path. It must
+// be called after flags are parsed.
+func (f sinkFlags) sink(cmd *cobra.Command, path string) (userinput.Sink, error) {
+	switch fileformat.FormatByPath(path) {
+	case fileformat.WAV():
+		return userinput.WAV.Sink(f.bitDepth)
+	case fileformat.AIFF():
+		return userinput.AIFF.Sink(f.bitDepth)
+	case fileformat.MP3():
+		return userinput.MP3.Sink(f.bitRateMode, f.bitRate, f.channelMode, cmd.Flags().Changed("quality"), f.quality)
+	case fileformat.FLAC():
+		return userinput.FLAC.Sink(f.bitDepth, f.compressionLevel)
+	}
+	return nil, fmt.Errorf("unsupported output format: %s", filepath.Ext(path))
+}
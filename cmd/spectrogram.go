@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"pipelined.dev/phono/encode"
+	"pipelined.dev/phono/process"
+	"pipelined.dev/phono/spectrogram"
+	"pipelined.dev/phono/userinput"
+)
+
+// defaultCompareNameTemplate is used for comparison if name is not
+// provided.
+const defaultCompareNameTemplate = "{base}-compare{ext}"
+
+// spectrogramExtension is the extension of spectrogram images.
+const spectrogramExtension = ".png"
+
+var (
+	spectrogramFlags = struct {
+		outPath    string
+		name       string
+		collision  string
+		recursive  bool
+		mirror     bool
+		bufferSize int
+		windowSize int
+		overlap    float64
+		window     string
+		scale      string
+		width      int
+		height     int
+		dbRange    float64
+		compare    bool
+	}{}
+	spectrogramCmd = &cobra.Command{
+		Use:                   "spectrogram [flags] path...",
+		DisableFlagsInUseLine: true,
+		Short:                 "Render spectrograms of audio files",
+		Args:                  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			analyzer, err := userinput.Spectrogram.Analyzer(spectrogramFlags.windowSize, spectrogramFlags.overlap, spectrogramFlags.window)
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+			img, err := userinput.Spectrogram.Image(spectrogramFlags.width, spectrogramFlags.height, spectrogramFlags.scale, spectrogramFlags.dbRange)
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+			// columns are merged if there are more than twice of pixels.
+			analyzer.MaxColumns = img.Width
+			name := spectrogramFlags.name
+			if spectrogramFlags.compare && !cmd.Flags().Changed("name") {
+				name = defaultCompareNameTemplate
+			}
+			namer, err := newOutNamer(name, spectrogramFlags.collision, spectrogramExtension, nil)
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+			output := cliOutput{
+				dir:      spectrogramFlags.outPath,
+				mirror:   spectrogramFlags.mirror,
+				outNamer: namer,
+			}
+			// create channel for interruption and context for cancellation
+			ctx, cancelFn := context.WithCancel(context.Background())
+			// interrupt signal received, shut down
+			onInterrupt(func() { cancelFn() })
+			if spectrogramFlags.compare {
+				err = compareCLI(ctx, args, output, spectrogramFlags.bufferSize, analyzer, img)
+			} else {
+				err = spectrogramCLI(ctx, args, spectrogramFlags.recursive, output, spectrogramFlags.bufferSize, analyzer, img)
+			}
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(spectrogramCmd)
+	spectrogramCmd.Flags().StringVar(&spectrogramFlags.outPath, "out", "", "output folder, the input folder is used if not specified")
+	spectrogramCmd.Flags().IntVar(&spectrogramFlags.bufferSize, "buffersize", 1024, "buffer size")
+	spectrogramCmd.Flags().IntVar(&spectrogramFlags.windowSize, "window-size", userinput.Spectrogram.DefaultWindowSize, fmt.Sprintf("transform size, power of two [%d..%d]", userinput.Spectrogram.MinWindowSize, userinput.Spectrogram.MaxWindowSize))
+	spectrogramCmd.Flags().Float64Var(&spectrogramFlags.overlap, "overlap", userinput.Spectrogram.DefaultOverlap, fmt.Sprintf("overlap of adjacent windows [%v..%v]", userinput.Spectrogram.MinOverlap, userinput.Spectrogram.MaxOverlap))
+	spectrogramCmd.Flags().StringVar(&spectrogramFlags.window, "window", string(userinput.Spectrogram.DefaultWindow), "window function:\n"+strings.Join(userinput.Spectrogram.WindowNames(), ", "))
+	spectrogramCmd.Flags().StringVar(&spectrogramFlags.scale, "scale", string(userinput.Spectrogram.DefaultScale), "frequency scale:\nlinear or log")
+	spectrogramCmd.Flags().IntVar(&spectrogramFlags.width, "width", userinput.Spectrogram.DefaultWidth, fmt.Sprintf("image width [%d..%d]", userinput.Spectrogram.MinWidth, userinput.Spectrogram.MaxWidth))
+	spectrogramCmd.Flags().IntVar(&spectrogramFlags.height, "height", userinput.Spectrogram.DefaultHeight, fmt.Sprintf("image height [%d..%d]", userinput.Spectrogram.MinHeight, userinput.Spectrogram.MaxHeight))
+	spectrogramCmd.Flags().Float64Var(&spectrogramFlags.dbRange, "range", userinput.Spectrogram.DefaultRange, "displayed range in dB below full scale")
+	spectrogramCmd.Flags().BoolVar(&spectrogramFlags.compare, "compare", false, "render two files side by side into one image named after the first file")
+	spectrogramCmd.Flags().BoolVar(&spectrogramFlags.recursive, "recursive", false, "process paths recursive")
+	spectrogramCmd.Flags().BoolVar(&spectrogramFlags.mirror, "mirror", false, "recreate folders structure of recursive paths in the out folder")
+	spectrogramCmd.Flags().StringVar(&spectrogramFlags.name, "name", defaultNameTemplate, nameFlagUsage+"\ndefault for comparison is "+defaultCompareNameTemplate)
+	spectrogramCmd.Flags().StringVar(&spectrogramFlags.collision, "collision", collisionSuffix, collisionFlagUsage)
+	spectrogramCmd.Flags().SortFlags = false
+}
+
+// spectrogramCLI renders spectrograms of files found in paths and
+// reports the results. Error is returned if input is invalid or any of
+// files failed.
+func spectrogramCLI(ctx context.Context, paths []string, recursive bool, output cliOutput, bufferSize int, analyzer process.SpectrumAnalyzer, img spectrogram.Image) error {
+	if output.mirror && (!recursive || output.dir == "") {
+		return errors.New("mirror requires recursive processing and out path")
+	}
+	if output.dir != "" {
+		if _, err := os.Stat(output.dir); os.IsNotExist(err) {
+			return fmt.Errorf("out path doesn't exist: %w", err)
+		}
+	}
+	var results []encodeResult
+	for _, file := range discover(paths, recursive, output.dir) {
+		result := encodeResult{in: file.path}
+		m, err := renderSpectrogram(ctx, file, bufferSize, analyzer, img)
+		if err != nil {
+			result.err = err
+		} else {
+			result = writeImage(file, output, m)
+		}
+		results = append(results, result)
+	}
+	return report(results)
+}
+
+// compareCLI renders spectrograms of two files side by side. Output is
+// named after the first file.
+func compareCLI(ctx context.Context, paths []string, output cliOutput, bufferSize int, analyzer process.SpectrumAnalyzer, img spectrogram.Image) error {
+	if len(paths) != 2 {
+		return fmt.Errorf("comparison requires two files, got %d", len(paths))
+	}
+	if output.mirror {
+		return errors.New("mirror is not supported by comparison")
+	}
+	if output.dir != "" {
+		if _, err := os.Stat(output.dir); os.IsNotExist(err) {
+			return fmt.Errorf("out path doesn't exist: %w", err)
+		}
+	}
+	files := discover(paths, false, "")
+	if len(files) != 2 {
+		return errors.New("comparison requires two supported files")
+	}
+	images := make([]image.Image, 0, len(files))
+	for _, file := range files {
+		m, err := renderSpectrogram(ctx, file, bufferSize, analyzer, img)
+		if err != nil {
+			return fmt.Errorf("failed %s: %w", file.path, err)
+		}
+		images = append(images, m)
+	}
+	return report([]encodeResult{writeImage(files[0], output, spectrogram.SideBySide(images[0], images[1]))})
+}
+
+// renderSpectrogram measures and renders the spectrogram of a single
+// file.
+func renderSpectrogram(ctx context.Context, file inputFile, bufferSize int, analyzer process.SpectrumAnalyzer, img spectrogram.Image) (image.Image, error) {
+	if file.err != nil {
+		return nil, file.err
+	}
+	in, err := os.Open(file.path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+	defer in.Close()
+	s, err := encode.Spectrogram(ctx, bufferSize, file.format, in, analyzer)
+	if err != nil {
+		return nil, err
+	}
+	return spectrogram.Render(s, img), nil
+}
+
+// writeImage writes png image for the input file. Output file is removed
+// if encoding fails.
+func writeImage(file inputFile, output cliOutput, m image.Image) encodeResult {
+	result := encodeResult{in: file.path}
+	out, err := output.create(file.root, file.path, file.index)
+	if err != nil {
+		result.skipped = errors.Is(err, errOutputExists)
+		result.err = err
+		return result
+	}
+	result.out = out.Name()
+	if err := png.Encode(out, m); err != nil {
+		out.Close()
+		if err := os.Remove(out.Name()); err != nil {
+			log.Printf("Failed to remove output file: %v", err)
+		}
+		result.err = fmt.Errorf("failed to write image: %w", err)
+		return result
+	}
+	result.err = out.Close()
+	return result
+}
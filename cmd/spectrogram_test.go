@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"context"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/phono/userinput"
+)
+
+func TestSpectrogramCLI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phono-spectrogram")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	analyzer, err := userinput.Spectrogram.Analyzer(1024, 0.5, "hann")
+	assert.Nil(t, err)
+	img, err := userinput.Spectrogram.Image(200, 100, "log", 120)
+	assert.Nil(t, err)
+	analyzer.MaxColumns = img.Width
+	newOutput := func(template string) cliOutput {
+		namer, err := newOutNamer(template, collisionSuffix, spectrogramExtension, nil)
+		assert.Nil(t, err)
+		return cliOutput{dir: dir, outNamer: namer}
+	}
+	decode := func(name string) (int, int) {
+		f, err := os.Open(filepath.Join(dir, name))
+		assert.Nil(t, err)
+		defer f.Close()
+		cfg, err := png.DecodeConfig(f)
+		assert.Nil(t, err)
+		return cfg.Width, cfg.Height
+	}
+
+	err = spectrogramCLI(context.Background(), []string{wavSample}, false, newOutput(defaultNameTemplate), 512, analyzer, img)
+	assert.Nil(t, err)
+	width, height := decode("sample.png")
+	assert.Equal(t, 200, width)
+	assert.Equal(t, 100, height)
+
+	err = compareCLI(context.Background(), []string{wavSample, wavSample}, newOutput(defaultCompareNameTemplate), 512, analyzer, img)
+	assert.Nil(t, err)
+	width, height = decode("sample-compare.png")
+	assert.Equal(t, 404, width)
+	assert.Equal(t, 100, height)
+
+	err = compareCLI(context.Background(), []string{wavSample}, newOutput(defaultCompareNameTemplate), 512, analyzer, img)
+	assert.NotNil(t, err)
+	err = compareCLI(context.Background(), []string{wavSample, "../_testdata/missing.wav"}, newOutput(defaultCompareNameTemplate), 512, analyzer, img)
+	assert.NotNil(t, err)
+}
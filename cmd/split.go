@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"pipelined.dev/pipe"
+
+	"pipelined.dev/phono/encode"
+	"pipelined.dev/phono/tag"
+	"pipelined.dev/phono/userinput"
+)
+
+// defaultSplitNameTemplate numbers the parts of the input file.
+const defaultSplitNameTemplate = "{base}-{part}{ext}"
+
+// Usage of split-specific placeholders.
+const splitNameFlagUsage = "\n{part} - zero-padded part number\n{title} - part title from cues"
+
+var (
+	splitCmd = &cobra.Command{
+		Use:   "split",
+		Short: "Split audio files into parts",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(splitCmd)
+}
+
+// splitFlags contains flags shared by split commands.
+type splitFlags struct {
+	outPath    string
+	name       string
+	collision  string
+	bufferSize int
+	atSilence  bool
+	every      string
+	cues       string
+	process    processFlags
+	tags       tagFlags
+}
+
+// register adds split flags to the command. Format-specific placeholders
+// are appended to the usage of name flag.
+func (f *splitFlags) register(cmd *cobra.Command, placeholders string) {
+	cmd.Flags().StringVar(&f.outPath, "out", "", "output folder, the input folder is used if not specified")
+	cmd.Flags().IntVar(&f.bufferSize, "buffersize", 1024, "buffer size")
+	cmd.Flags().BoolVar(&f.atSilence, "at-silence", false, "split at silences defined by silence flags")
+	cmd.Flags().StringVar(&f.every, "every", "", "split into chunks of provided length, same format as start")
+	cmd.Flags().StringVar(&f.cues, "cues", "", "split at cues from .cue sheet or csv file with start and optional title columns")
+	cmd.Flags().StringVar(&f.name, "name", defaultSplitNameTemplate, nameFlagUsage+splitNameFlagUsage+placeholders)
+	cmd.Flags().StringVar(&f.collision, "collision", collisionSuffix, collisionFlagUsage)
+	f.process.register(cmd)
+	f.tags.register(cmd)
+	cmd.Flags().SortFlags = false
+}
+
+// splitter validates flags and returns the splitter. Exactly one split
+// mode must be provided.
+func (f splitFlags) splitter() (func() encode.Splitter, error) {
+	modes := 0
+	for _, set := range []bool{f.atSilence, f.every != "", f.cues != ""} {
+		if set {
+			modes++
+		}
+	}
+	if modes != 1 {
+		return nil, errors.New("provide exactly one of at-silence, every and cues")
+	}
+	switch {
+	case f.atSilence:
+		return userinput.Silence.Splitter(f.process.silenceThreshold, f.process.silenceDuration, f.process.silencePadding)
+	case f.every != "":
+		return userinput.Split.Chunks(f.every)
+	}
+	file, err := os.Open(f.cues)
+	if err != nil {
+		return nil, fmt.Errorf("error opening cues: %w", err)
+	}
+	defer file.Close()
+	return userinput.Split.Cues(file, f.cues)
+}
+
+// run parses flags and splits files found in paths. Format-specific
+// placeholders are passed to the namer.
+func (f splitFlags) run(paths []string, sink func(io.WriteSeeker) pipe.SinkAllocatorFunc, ext string, params map[string]string) error {
+	splitter, err := f.splitter()
+	if err != nil {
+		return err
+	}
+	processing, err := f.process.processing()
+	if err != nil {
+		return err
+	}
+	tags, err := f.tags.edit()
+	if err != nil {
+		return err
+	}
+	if params == nil {
+		params = map[string]string{}
+	}
+	params["part"], params["title"] = "", ""
+	namer, err := newOutNamer(f.name, f.collision, ext, params)
+	if err != nil {
+		return err
+	}
+	// create channel for interruption and context for cancellation
+	ctx, cancelFn := context.WithCancel(context.Background())
+	// interrupt signal received, shut down
+	onInterrupt(func() { cancelFn() })
+	return splitCLI(ctx,
+		paths,
+		cliOutput{
+			dir:      f.outPath,
+			tags:     tags,
+			outNamer: namer,
+		},
+		f.bufferSize,
+		splitter,
+		sink,
+		processing,
+	)
+}
+
+// splitCLI splits files found in paths and reports results of every
+// part. Error is returned if input is invalid or any of parts failed.
+func splitCLI(ctx context.Context, paths []string, output cliOutput, bufferSize int, splitter func() encode.Splitter, sink func(io.WriteSeeker) pipe.SinkAllocatorFunc, processing encode.Processing) error {
+	if output.dir != "" {
+		if _, err := os.Stat(output.dir); os.IsNotExist(err) {
+			return fmt.Errorf("out path doesn't exist: %w", err)
+		}
+	}
+	var results []encodeResult
+	for _, file := range discover(paths, false, output.dir) {
+		results = append(results, splitFile(ctx, file, output, bufferSize, splitter, sink, processing)...)
+	}
+	return report(results)
+}
+
+// splitFile finds parts of a single file and encodes every part to a
+// separate output file.
+func splitFile(ctx context.Context, file inputFile, output cliOutput, bufferSize int, splitter func() encode.Splitter, sink func(io.WriteSeeker) pipe.SinkAllocatorFunc, processing encode.Processing) []encodeResult {
+	if file.err != nil {
+		return []encodeResult{{in: file.path, err: file.err}}
+	}
+	in, err := os.Open(file.path)
+	if err != nil {
+		return []encodeResult{{in: file.path, err: fmt.Errorf("error opening file: %w", err)}}
+	}
+	parts, err := encode.Split(ctx, bufferSize, file.format, in, splitter())
+	in.Close()
+	if err != nil {
+		return []encodeResult{{in: file.path, err: err}}
+	}
+	log.Printf("Split %s into %d parts\n", file.path, len(parts))
+
+	width := len(fmt.Sprint(len(parts)))
+	if width < 2 {
+		width = 2
+	}
+	results := make([]encodeResult, 0, len(parts))
+	for i, part := range parts {
+		partOutput := output
+		partOutput.outNamer = output.with(map[string]string{
+			"part":  fmt.Sprintf("%0*d", width, i+1),
+			"title": titleReplacer.Replace(part.Title),
+		})
+		partOutput.tags = partTags(output.tags, part.Title, i+1, len(parts))
+		results = append(results, encodeFile(ctx, file, partOutput, bufferSize, sink, processing.Part(part)))
+	}
+	return results
+}
+
+// partTags adds title and track number of the part to the edit. Tags
+// and cover provided by user are kept.
+func partTags(edit tag.Edit, title string, number, total int) tag.Edit {
+	set := tag.Tags{
+		tag.TrackNumber: fmt.Sprintf("%d/%d", number, total),
+	}
+	if title != "" {
+		set[tag.Title] = title
+	}
+	for k, v := range edit.Set {
+		set[tag.Key(k)] = v
+	}
+	edit.Set = set
+	return edit
+}
+
+// titleReplacer removes path separators from titles.
+var titleReplacer = strings.NewReplacer("/", "_", string(filepath.Separator), "_")
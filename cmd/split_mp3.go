@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"pipelined.dev/phono/fileformat"
+	"pipelined.dev/phono/userinput"
+)
+
+var (
+	splitMp3 = struct {
+		splitFlags
+		channelMode int
+		bitRateMode string
+		bitRate     int
+		quality     int
+	}{}
+	splitMp3Cmd = &cobra.Command{
+		Use:                   "mp3 [flags] path...",
+		DisableFlagsInUseLine: true,
+		Short:                 "Split audio files into mp3 parts",
+		Args:                  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			sink, err := userinput.MP3.Sink(
+				splitMp3.bitRateMode,
+				splitMp3.bitRate,
+				splitMp3.channelMode,
+				cmd.Flags().Changed("quality"),
+				splitMp3.quality,
+			)
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+			err = splitMp3.run(args,
+				sink,
+				fileformat.MP3().DefaultExtension(),
+				map[string]string{
+					"bitrate": fmt.Sprintf("%s-%d", strings.ToLower(splitMp3.bitRateMode), splitMp3.bitRate),
+				},
+			)
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+func init() {
+	splitCmd.AddCommand(splitMp3Cmd)
+	splitMp3Cmd.Flags().IntVar(&splitMp3.channelMode, "channelmode", 2, "channel mode:\n0 - mono\n1 - stereo\n2 - joint stereo")
+	splitMp3Cmd.Flags().StringVar(&splitMp3.bitRateMode, "bitratemode", "vbr", "bit rate mode:\ncbr - constant bit rate\nabr - average bit rate\nvbr - variable bit rate")
+	splitMp3Cmd.Flags().IntVar(&splitMp3.bitRate, "bitrate", 4, "bit rate:\n[8..320] for cbr and abr\n[0..9] for vbr")
+	splitMp3Cmd.Flags().IntVar(&splitMp3.quality, "quality", 5, "quality [0..9]")
+	splitMp3.register(splitMp3Cmd, "\n{bitrate} - output bit rate mode and value")
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/phono/encode"
+	"pipelined.dev/phono/tag"
+	"pipelined.dev/phono/userinput"
+)
+
+func TestSplitCLI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phono-split")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	splitter, err := userinput.Split.Chunks("3")
+	assert.Nil(t, err)
+	sink, err := userinput.WAV.Sink(16)
+	assert.Nil(t, err)
+	namer, err := newOutNamer(defaultSplitNameTemplate, collisionSuffix, ".wav", map[string]string{"part": "", "title": ""})
+	assert.Nil(t, err)
+	err = splitCLI(context.Background(), []string{wavSample}, cliOutput{dir: dir, outNamer: namer}, 512, splitter, sink, encode.Processing{})
+	assert.Nil(t, err)
+	// sample is 7.5 seconds long.
+	for _, name := range []string{"sample-01.wav", "sample-02.wav", "sample-03.wav"} {
+		fi, err := os.Stat(filepath.Join(dir, name))
+		assert.Nil(t, err)
+		assert.NotZero(t, fi.Size())
+	}
+	_, err = os.Stat(filepath.Join(dir, "sample-04.wav"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestPartTags(t *testing.T) {
+	cover := &tag.Picture{Type: tag.FrontCover, MIME: "image/png"}
+	edit := partTags(tag.Edit{
+		Set:          tag.Tags{"ARTIST": "Artist"},
+		Cover:        cover,
+		MaxCoverSize: 500,
+	}, "Intro", 1, 12)
+	assert.Equal(t, tag.Edit{
+		Set: tag.Tags{
+			tag.Artist:      "Artist",
+			tag.Title:       "Intro",
+			tag.TrackNumber: "1/12",
+		},
+		Cover:        cover,
+		MaxCoverSize: 500,
+	}, edit)
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"pipelined.dev/phono/fileformat"
+	"pipelined.dev/phono/userinput"
+)
+
+var (
+	splitWav = struct {
+		splitFlags
+		bitDepth int
+	}{}
+	splitWavCmd = &cobra.Command{
+		Use:                   "wav [flags] path...",
+		DisableFlagsInUseLine: true,
+		Short:                 "Split audio files into wav parts",
+		Args:                  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			sink, err := userinput.WAV.Sink(splitWav.bitDepth)
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+			err = splitWav.run(args,
+				sink,
+				fileformat.WAV().DefaultExtension(),
+				map[string]string{
+					"bitdepth": strconv.Itoa(splitWav.bitDepth),
+				},
+			)
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+func init() {
+	splitCmd.AddCommand(splitWavCmd)
+	splitWavCmd.Flags().IntVar(&splitWav.bitDepth, "bitdepth", 24, "bit depth")
+	splitWav.register(splitWavCmd, "\n{bitdepth} - output bit depth")
+}
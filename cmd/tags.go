@@ -0,0 +1,278 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"pipelined.dev/phono/fileformat"
+	"pipelined.dev/phono/tag"
+	"pipelined.dev/phono/userinput"
+)
+
+var (
+	tagsFlags = struct {
+		recursive bool
+		json      bool
+		tags      tagFlags
+	}{}
+	tagsCmd = &cobra.Command{
+		Use:                   "tags [flags] path...",
+		DisableFlagsInUseLine: true,
+		Short:                 "Print or edit tags and covers of audio files in place",
+		Args:                  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			edit, err := tagsFlags.tags.edit()
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+			if edit.Strip || len(edit.Set) > 0 || edit.Cover != nil || edit.MaxCoverSize > 0 {
+				if err := editTagsCLI(args, tagsFlags.recursive, edit); err != nil {
+					log.Print(err)
+					os.Exit(1)
+				}
+				return
+			}
+			files := readTags(args, tagsFlags.recursive)
+			if tagsFlags.json {
+				err = printTagsJSON(os.Stdout, files)
+			} else {
+				err = printTags(os.Stdout, files)
+			}
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+			for _, f := range files {
+				if f.Error != "" {
+					os.Exit(1)
+				}
+			}
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(tagsCmd)
+	tagsCmd.Flags().BoolVar(&tagsFlags.recursive, "recursive", false, "process paths recursive")
+	tagsCmd.Flags().BoolVar(&tagsFlags.json, "json", false, "print tags in json format")
+	tagsFlags.tags.register(tagsCmd)
+	tagsCmd.Flags().SortFlags = false
+}
+
+// tagFlags contains flags that change tags and cover of the output.
+type tagFlags struct {
+	tags         []string
+	strip        bool
+	cover        string
+	coverMaxSize int
+}
+
+// register adds tag flags to the command.
+func (f *tagFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&f.tags, "tag", nil, "tag of the output as key=value, empty value removes the tag, can be repeated")
+	cmd.Flags().BoolVar(&f.strip, "strip-tags", false, "remove tags and cover of the input")
+	cmd.Flags().StringVar(&f.cover, "cover", "", "jpeg or png image that replaces the cover of the input")
+	cmd.Flags().IntVar(&f.coverMaxSize, "cover-max-size", 0, "scale down covers with larger width or height in pixels, covers are kept if not specified")
+}
+
+// edit validates flags and returns the edit of tags.
+func (f tagFlags) edit() (tag.Edit, error) {
+	values, err := userinput.Tags.Pairs(f.tags)
+	if err != nil {
+		return tag.Edit{}, err
+	}
+	edit, err := userinput.Tags.Edit(values, f.strip)
+	if err != nil {
+		return tag.Edit{}, err
+	}
+	if edit.MaxCoverSize, err = userinput.Tags.CoverSize(f.coverMaxSize); err != nil {
+		return tag.Edit{}, err
+	}
+	if f.cover == "" {
+		return edit, nil
+	}
+	file, err := os.Open(f.cover)
+	if err != nil {
+		return tag.Edit{}, fmt.Errorf("error opening cover: %w", err)
+	}
+	defer file.Close()
+	if edit.Cover, err = userinput.Tags.Cover(file, edit.MaxCoverSize); err != nil {
+		return tag.Edit{}, err
+	}
+	return edit, nil
+}
+
+// sink wraps the sink of output file, so it writes tags provided by
+// flags. It's used by commands that have no single input to carry tags
+// from.
+func (f tagFlags) sink(path string, sink userinput.Sink) (userinput.Sink, error) {
+	edit, err := f.edit()
+	if err != nil {
+		return nil, err
+	}
+	m, err := edit.Apply(tag.Metadata{})
+	if err != nil {
+		return nil, err
+	}
+	return tag.Sink(fileformat.FormatByPath(path), m, sink), nil
+}
+
+type (
+	// fileTags contains tags and cover of a single file or error if
+	// they cannot be read.
+	fileTags struct {
+		Path  string     `json:"path"`
+		Tags  tag.Tags   `json:"tags,omitempty"`
+		Cover *coverInfo `json:"cover,omitempty"`
+		Error string     `json:"error,omitempty"`
+	}
+
+	// coverInfo describes the cover image.
+	coverInfo struct {
+		MIME   string `json:"mime"`
+		Width  int    `json:"width"`
+		Height int    `json:"height"`
+		Size   int    `json:"size"`
+	}
+)
+
+// readTags reads tags of all supported files in paths.
+func readTags(paths []string, recursive bool) []fileTags {
+	files := discover(paths, recursive, "")
+	result := make([]fileTags, 0, len(files))
+	for _, file := range files {
+		ft := fileTags{Path: file.path}
+		m, err := readFileTags(file)
+		if err != nil {
+			ft.Error = err.Error()
+		} else {
+			ft.Tags = m.Tags
+		}
+		if p := m.Cover; p != nil {
+			width, height := p.Dimensions()
+			ft.Cover = &coverInfo{
+				MIME:   p.MIME,
+				Width:  width,
+				Height: height,
+				Size:   len(p.Data),
+			}
+		}
+		result = append(result, ft)
+	}
+	return result
+}
+
+func readFileTags(file inputFile) (tag.Metadata, error) {
+	if file.err != nil {
+		return tag.Metadata{}, file.err
+	}
+	f, err := os.Open(file.path)
+	if err != nil {
+		return tag.Metadata{}, fmt.Errorf("error opening file: %w", err)
+	}
+	defer f.Close()
+	return tag.Read(file.format, f)
+}
+
+func printTagsJSON(w io.Writer, files []fileTags) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(files)
+}
+
+func printTags(w io.Writer, files []fileTags) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	for _, f := range files {
+		fmt.Fprintln(tw, f.Path)
+		if f.Error != "" {
+			fmt.Fprintf(tw, "  error:\t%s\n", f.Error)
+			continue
+		}
+		keys := make([]string, 0, len(f.Tags))
+		for k := range f.Tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(tw, "  %s:\t%s\n", k, f.Tags[k])
+		}
+		if c := f.Cover; c != nil {
+			fmt.Fprintf(tw, "  cover:\t%s %dx%d, %d bytes\n", c.MIME, c.Width, c.Height, c.Size)
+		}
+	}
+	return tw.Flush()
+}
+
+// editTagsCLI applies the edit to tags of all supported files in paths
+// and reports the results. Error is returned if any of files failed.
+func editTagsCLI(paths []string, recursive bool, edit tag.Edit) error {
+	var failed int
+	for _, file := range discover(paths, recursive, "") {
+		if err := editFileTags(file, edit); err != nil {
+			failed++
+			log.Printf("Failed %s: %v\n", file.path, err)
+			continue
+		}
+		log.Printf("Tagged %s\n", file.path)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to tag %d files", failed)
+	}
+	return nil
+}
+
+// editFileTags rewrites the file with edited tags. The file is replaced
+// only if rewrite succeeds.
+func editFileTags(file inputFile, edit tag.Edit) error {
+	if file.err != nil {
+		return file.err
+	}
+	in, err := os.Open(file.path)
+	if err != nil {
+		return fmt.Errorf("error opening file: %w", err)
+	}
+	defer in.Close()
+	m, err := tag.Read(file.format, in)
+	if err != nil {
+		return fmt.Errorf("error reading tags: %w", err)
+	}
+	if m, err = edit.Apply(m); err != nil {
+		return err
+	}
+	fi, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	// temp file is created next to the input, so it can be renamed.
+	out, err := ioutil.TempFile(filepath.Dir(file.path), ".phono-tags-")
+	if err != nil {
+		return fmt.Errorf("error creating temp file: %w", err)
+	}
+	err = tag.Rewrite(file.format, in, out, m)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(out.Name(), fi.Mode())
+	}
+	if err == nil {
+		err = os.Rename(out.Name(), file.path)
+	}
+	if err != nil {
+		if err := os.Remove(out.Name()); err != nil {
+			log.Printf("Failed to remove temp file: %v", err)
+		}
+		return err
+	}
+	return nil
+}
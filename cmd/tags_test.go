@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"context"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/phono/encode"
+	"pipelined.dev/phono/tag"
+	"pipelined.dev/phono/userinput"
+)
+
+func TestTagsCLI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phono-tags")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	sink, err := userinput.FLAC.Sink(16, 5)
+	assert.Nil(t, err)
+	namer, err := newOutNamer("{base}{ext}", collisionSuffix, ".flac", nil)
+	assert.Nil(t, err)
+	edit, err := tagFlags{tags: []string{"title=Title", "year=2020"}}.edit()
+	assert.Nil(t, err)
+	err = encodeCLI(context.Background(), []string{wavSample}, false, cliOutput{dir: dir, tags: edit, outNamer: namer}, 512, 1, sink, encode.Processing{})
+	assert.Nil(t, err)
+
+	// tags of the input are carried.
+	out := filepath.Join(dir, "sample.flac")
+	files := readTags([]string{out}, false)
+	assert.Equal(t, []fileTags{{
+		Path: out,
+		Tags: tag.Tags{
+			tag.Title:  "Title",
+			tag.Artist: "freewavesamples.com",
+			tag.Date:   "2020",
+		},
+	}}, files)
+
+	edit, err = tagFlags{tags: []string{"album=Album"}, strip: true}.edit()
+	assert.Nil(t, err)
+	assert.Nil(t, editTagsCLI([]string{out}, false, edit))
+	files = readTags([]string{out}, false)
+	assert.Equal(t, tag.Tags{tag.Album: "Album"}, files[0].Tags)
+
+	// cover is scaled down to max size.
+	cover := filepath.Join(dir, "cover.png")
+	f, err := os.Create(cover)
+	assert.Nil(t, err)
+	assert.Nil(t, png.Encode(f, image.NewGray(image.Rect(0, 0, 100, 50))))
+	assert.Nil(t, f.Close())
+	edit, err = tagFlags{cover: cover, coverMaxSize: 40}.edit()
+	assert.Nil(t, err)
+	assert.Nil(t, editTagsCLI([]string{out}, false, edit))
+	files = readTags([]string{out}, false)
+	assert.Equal(t, tag.Tags{tag.Album: "Album"}, files[0].Tags)
+	assert.Equal(t, &coverInfo{
+		MIME:   "image/jpeg",
+		Width:  40,
+		Height: 20,
+		Size:   files[0].Cover.Size,
+	}, files[0].Cover)
+
+	_, err = tagFlags{tags: []string{"title"}}.edit()
+	assert.NotNil(t, err)
+	_, err = tagFlags{cover: out}.edit()
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"pipelined.dev/phono/encode"
+	"pipelined.dev/phono/userinput"
+	"pipelined.dev/phono/waveform"
+)
+
+var (
+	waveformFlags = struct {
+		outPath         string
+		name            string
+		collision       string
+		recursive       bool
+		mirror          bool
+		bufferSize      int
+		samplesPerPixel int
+		format          string
+		bits            int
+		height          int
+		color           string
+		background      string
+	}{}
+	waveformCmd = &cobra.Command{
+		Use:                   "waveform [flags] path...",
+		DisableFlagsInUseLine: true,
+		Short:                 "Generate waveform peaks of audio files",
+		Args:                  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := userinput.Waveform.SamplesPerPixel(waveformFlags.samplesPerPixel); err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+			format, err := userinput.Waveform.Format(
+				waveformFlags.format,
+				waveformFlags.bits,
+				waveformFlags.height,
+				waveformFlags.color,
+				waveformFlags.background,
+			)
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+			namer, err := newOutNamer(waveformFlags.name, waveformFlags.collision, format.Extension, nil)
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+			// create channel for interruption and context for cancellation
+			ctx, cancelFn := context.WithCancel(context.Background())
+			// interrupt signal received, shut down
+			onInterrupt(func() { cancelFn() })
+			err = waveformCLI(ctx,
+				args,
+				waveformFlags.recursive,
+				cliOutput{
+					dir:      waveformFlags.outPath,
+					mirror:   waveformFlags.mirror,
+					outNamer: namer,
+				},
+				waveformFlags.bufferSize,
+				waveformFlags.samplesPerPixel,
+				format,
+			)
+			if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(waveformCmd)
+	waveformCmd.Flags().StringVar(&waveformFlags.outPath, "out", "", "output folder, the input folder is used if not specified")
+	waveformCmd.Flags().IntVar(&waveformFlags.bufferSize, "buffersize", 1024, "buffer size")
+	waveformCmd.Flags().IntVar(&waveformFlags.samplesPerPixel, "samples-per-pixel", userinput.Waveform.DefaultSamplesPerPixel, fmt.Sprintf("number of frames per peak [%d..%d]", userinput.Waveform.MinSamplesPerPixel, userinput.Waveform.MaxSamplesPerPixel))
+	waveformCmd.Flags().StringVar(&waveformFlags.format, "format", userinput.Waveform.DefaultFormat, "output format:\n"+strings.Join(userinput.Waveform.FormatNames(), ", "))
+	waveformCmd.Flags().IntVar(&waveformFlags.bits, "bits", userinput.Waveform.DefaultBits, "bits per value of json and dat formats:\n8 or 16")
+	waveformCmd.Flags().IntVar(&waveformFlags.height, "height", userinput.Waveform.DefaultHeight, fmt.Sprintf("height of png and svg images [%d..%d]", userinput.Waveform.MinHeight, userinput.Waveform.MaxHeight))
+	waveformCmd.Flags().StringVar(&waveformFlags.color, "color", userinput.Waveform.DefaultColor, "color of png and svg images, #rrggbb or #rrggbbaa")
+	waveformCmd.Flags().StringVar(&waveformFlags.background, "background", "", "background of png and svg images, transparent if not specified")
+	waveformCmd.Flags().BoolVar(&waveformFlags.recursive, "recursive", false, "process paths recursive")
+	waveformCmd.Flags().BoolVar(&waveformFlags.mirror, "mirror", false, "recreate folders structure of recursive paths in the out folder")
+	waveformCmd.Flags().StringVar(&waveformFlags.name, "name", defaultNameTemplate, nameFlagUsage)
+	waveformCmd.Flags().StringVar(&waveformFlags.collision, "collision", collisionSuffix, collisionFlagUsage)
+	waveformCmd.Flags().SortFlags = false
+}
+
+// waveformCLI writes waveforms of files found in paths and reports the
+// results. Error is returned if input is invalid or any of files failed.
+func waveformCLI(ctx context.Context, paths []string, recursive bool, output cliOutput, bufferSize, samplesPerPixel int, format waveform.Format) error {
+	if output.mirror && (!recursive || output.dir == "") {
+		return errors.New("mirror requires recursive processing and out path")
+	}
+	if output.dir != "" {
+		if _, err := os.Stat(output.dir); os.IsNotExist(err) {
+			return fmt.Errorf("out path doesn't exist: %w", err)
+		}
+	}
+	var results []encodeResult
+	for _, file := range discover(paths, recursive, output.dir) {
+		results = append(results, waveformFile(ctx, file, output, bufferSize, samplesPerPixel, format))
+	}
+	return report(results)
+}
+
+// waveformFile writes the waveform of a single file. Output file is
+// removed if measurement fails.
+func waveformFile(ctx context.Context, file inputFile, output cliOutput, bufferSize, samplesPerPixel int, format waveform.Format) encodeResult {
+	result := encodeResult{in: file.path}
+	if file.err != nil {
+		result.err = file.err
+		return result
+	}
+	in, err := os.Open(file.path)
+	if err != nil {
+		result.err = fmt.Errorf("error opening file: %w", err)
+		return result
+	}
+	defer in.Close()
+	wf, err := encode.Waveform(ctx, bufferSize, file.format, in, samplesPerPixel)
+	if err != nil {
+		result.err = err
+		return result
+	}
+
+	out, err := output.create(file.root, file.path, file.index)
+	if err != nil {
+		result.skipped = errors.Is(err, errOutputExists)
+		result.err = err
+		return result
+	}
+	result.out = out.Name()
+	if err := format.Encode(out, wf); err != nil {
+		out.Close()
+		if err := os.Remove(out.Name()); err != nil {
+			log.Printf("Failed to remove output file: %v", err)
+		}
+		result.err = fmt.Errorf("failed to write waveform: %w", err)
+		return result
+	}
+	result.err = out.Close()
+	return result
+}
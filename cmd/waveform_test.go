@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/phono/userinput"
+)
+
+func TestWaveformCLI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phono-waveform")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	format, err := userinput.Waveform.Format("json", 8, 128, "#000000", "")
+	assert.Nil(t, err)
+	namer, err := newOutNamer(defaultNameTemplate, collisionSuffix, format.Extension, nil)
+	assert.Nil(t, err)
+	output := cliOutput{dir: dir, outNamer: namer}
+	err = waveformCLI(context.Background(), []string{wavSample}, false, output, 512, 1024, format)
+	assert.Nil(t, err)
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "sample.json"))
+	assert.Nil(t, err)
+	var result struct {
+		Length int   `json:"length"`
+		Data   []int `json:"data"`
+	}
+	assert.Nil(t, json.Unmarshal(b, &result))
+	assert.Equal(t, 323, result.Length)
+	assert.Equal(t, 2*323, len(result.Data))
+
+	// missing files are reported as failed.
+	err = waveformCLI(context.Background(), []string{"../_testdata/missing.wav"}, false, output, 512, 1024, format)
+	assert.NotNil(t, err)
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(files))
+}
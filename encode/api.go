@@ -0,0 +1,164 @@
+package encode
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"pipelined.dev/phono/process"
+	"pipelined.dev/phono/waveform"
+)
+
+// Error codes of JSON API. Codes are stable and can be used by clients
+// to handle errors.
+const (
+	CodeInvalidRequest    = "invalid_request"
+	CodeInvalidSpec       = "invalid_spec"
+	CodeUnsupportedInput  = "unsupported_input_format"
+	CodeUnsupportedOutput = "unsupported_output_format"
+	CodeInvalidParameter  = "invalid_parameter"
+	CodeFileTooLarge      = "file_too_large"
+	CodeEncodingFailed    = "encoding_failed"
+	CodeInternal          = "internal_error"
+	CodeMethodNotAllowed  = "method_not_allowed"
+	CodeNotFound          = "not_found"
+	CodeQueueFull         = "queue_full"
+	CodeJobNotDone        = "job_not_done"
+	CodeJobOnly           = "job_only"
+)
+
+type (
+	// API provides user-input for JSON encoding API.
+	API interface {
+		// Formats returns description of supported formats.
+		Formats() interface{}
+		// ParseRequest returns data of encoding request. Temp dir can be
+		// used to store the request body.
+		ParseRequest(r *http.Request, tempDir string) (FormData, error)
+		// ParseWaveformRequest returns data of waveform request. Output
+		// of returned data is not used.
+		ParseWaveformRequest(r *http.Request, tempDir string) (FormData, error)
+	}
+
+	// Error is returned by JSON API.
+	Error struct {
+		Status  int    `json:"-"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+
+	// errorResponse wraps error in JSON response.
+	errorResponse struct {
+		Error *Error `json:"error"`
+	}
+)
+
+// NewError returns API error with provided status and code.
+func NewError(status int, code string, format string, args ...interface{}) *Error {
+	return &Error{
+		Status:  status,
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// APIHandler serves JSON encoding API. Following endpoints are
+// supported:
+//	GET /api/formats - description of supported formats
+//	POST /api/encode - encode raw or multipart body
+//	POST /api/waveform - waveform peaks of raw or multipart body
+func APIHandler(api API, bufferSize int, tempDir string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/formats", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, NewError(http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method %s is not allowed", r.Method))
+			return
+		}
+		writeJSON(w, http.StatusOK, api.Formats())
+	})
+	mux.HandleFunc("/api/encode", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, NewError(http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method %s is not allowed", r.Method))
+			return
+		}
+		formData, err := api.ParseRequest(r, tempDir)
+		if err != nil {
+			writeError(w, apiError(err))
+			return
+		}
+		defer formData.Close()
+		if formData.Waveform != nil {
+			writeError(w, NewError(http.StatusBadRequest, CodeJobOnly, "Waveform is only provided for jobs, submit the spec to /jobs"))
+			return
+		}
+
+		if err := respond(w, r, bufferSize, tempDir, formData); err != nil {
+			writeError(w, err)
+		}
+	})
+	mux.HandleFunc("/api/waveform", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, NewError(http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method %s is not allowed", r.Method))
+			return
+		}
+		formData, err := api.ParseWaveformRequest(r, tempDir)
+		if err != nil {
+			writeError(w, apiError(err))
+			return
+		}
+		defer formData.Close()
+
+		wf, err := Waveform(r.Context(), bufferSize, formData.Input.Format, formData.File, formData.Processing.Waveform)
+		if err != nil {
+			writeError(w, NewError(http.StatusBadRequest, CodeEncodingFailed, "%v", err))
+			return
+		}
+		sendWaveform(w, wf, *formData.Waveform)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, NewError(http.StatusNotFound, CodeNotFound, "Endpoint %s not found", r.URL.Path))
+	})
+	return mux
+}
+
+// apiError converts any error into API error.
+func apiError(err error) *Error {
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+	return NewError(http.StatusBadRequest, CodeInvalidRequest, "%v", err)
+}
+
+// sendWaveform encodes waveform into response.
+func sendWaveform(w http.ResponseWriter, wf process.Waveform, format waveform.Format) {
+	var b bytes.Buffer
+	if err := format.Encode(&b, wf); err != nil {
+		writeError(w, NewError(http.StatusInternalServerError, CodeInternal, "Failed to encode waveform: %v", err))
+		return
+	}
+	w.Header().Set("Content-Disposition", "attachment; filename="+outFileName("waveform", 1, format.Extension))
+	w.Header().Set("Content-Type", format.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(b.Len()))
+	if _, err := b.WriteTo(w); err != nil {
+		log.Printf("Failed to write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, e *Error) {
+	writeJSON(w, e.Status, errorResponse{Error: e})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to write response: %v", err)
+	}
+}
@@ -0,0 +1,352 @@
+package encode_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/phono/encode"
+	"pipelined.dev/phono/fileformat"
+	"pipelined.dev/phono/userinput"
+)
+
+const wavSample = "../_testdata/sample.wav"
+
+// rawRequest creates API request with file in body and spec in query.
+func rawRequest(contentType, spec, filePath string) *http.Request {
+	file, err := os.Open(filePath)
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+	body := &bytes.Buffer{}
+	if _, err := io.Copy(body, file); err != nil {
+		panic(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/encode?spec="+url.QueryEscape(spec), body)
+	req.Header.Set("Content-Type", contentType)
+	return req
+}
+
+// multipartRequest creates API request with file and spec parts.
+func multipartRequest(spec, filePath string) *http.Request {
+	file, err := os.Open(filePath)
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile(userinput.APIFileKey, "sample.wav")
+	if err != nil {
+		panic(err)
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		panic(err)
+	}
+	if err := writer.WriteField(userinput.APISpecKey, spec); err != nil {
+		panic(err)
+	}
+	if err := writer.Close(); err != nil {
+		panic(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/encode", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+// largeMultipartRequest creates API request with spec and zero-filled
+// file parts. File part goes last, so spec is parsed before the limit is
+// exceeded.
+func largeMultipartRequest(spec string, size int) *http.Request {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	if err := writer.WriteField(userinput.APISpecKey, spec); err != nil {
+		panic(err)
+	}
+	part, err := writer.CreateFormFile(userinput.APIFileKey, "large.wav")
+	if err != nil {
+		panic(err)
+	}
+	if _, err := part.Write(make([]byte, size)); err != nil {
+		panic(err)
+	}
+	if err := writer.Close(); err != nil {
+		panic(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/encode", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestAPIHandler(t *testing.T) {
+	bufferSize := 512
+	testAPI := func(limits userinput.Limits, r *http.Request, expectedStatus int, expectedCode string) func(*testing.T) {
+		return func(t *testing.T) {
+			t.Helper()
+			h := encode.APIHandler(userinput.NewEncodeAPI(limits), bufferSize, "")
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, r)
+			assert.Equal(t, expectedStatus, rr.Code)
+			if expectedCode == "" {
+				return
+			}
+			var resp struct {
+				Error encode.Error `json:"error"`
+			}
+			assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+			assert.Nil(t, json.NewDecoder(rr.Body).Decode(&resp))
+			assert.Equal(t, expectedCode, resp.Error.Code)
+			assert.NotEmpty(t, resp.Error.Message)
+		}
+	}
+	t.Run("formats",
+		testAPI(nil,
+			httptest.NewRequest(http.MethodGet, "/api/formats", nil),
+			http.StatusOK, ""),
+	)
+	t.Run("formats not allowed method",
+		testAPI(nil,
+			httptest.NewRequest(http.MethodPost, "/api/formats", nil),
+			http.StatusMethodNotAllowed, encode.CodeMethodNotAllowed),
+	)
+	t.Run("not found",
+		testAPI(nil,
+			httptest.NewRequest(http.MethodGet, "/api/fake", nil),
+			http.StatusNotFound, encode.CodeNotFound),
+	)
+	t.Run("encode not allowed method",
+		testAPI(nil,
+			httptest.NewRequest(http.MethodGet, "/api/encode", nil),
+			http.StatusMethodNotAllowed, encode.CodeMethodNotAllowed),
+	)
+	t.Run("raw ok",
+		testAPI(nil,
+			rawRequest("audio/wav", `{"format":"mp3","mp3":{"channelMode":2,"bitRateMode":"VBR","bitRate":4}}`, wavSample),
+			http.StatusOK, ""),
+	)
+	t.Run("raw input from spec",
+		testAPI(nil,
+			rawRequest("application/octet-stream", `{"input":"wav","format":"flac","flac":{"bitDepth":16}}`, wavSample),
+			http.StatusOK, ""),
+	)
+	t.Run("raw aiff output",
+		testAPI(nil,
+			rawRequest("audio/wav", `{"format":"aiff","aiff":{"bitDepth":24}}`, wavSample),
+			http.StatusOK, ""),
+	)
+	t.Run("raw aiff invalid bit depth",
+		testAPI(nil,
+			rawRequest("audio/wav", `{"format":"aiff","aiff":{"bitDepth":12}}`, wavSample),
+			http.StatusBadRequest, encode.CodeInvalidParameter),
+	)
+	t.Run("raw unknown input",
+		testAPI(nil,
+			rawRequest("application/octet-stream", `{"format":"wav","wav":{"bitDepth":16}}`, wavSample),
+			http.StatusBadRequest, encode.CodeUnsupportedInput),
+	)
+	t.Run("raw missing spec",
+		testAPI(nil,
+			rawRequest("audio/wav", "", wavSample),
+			http.StatusBadRequest, encode.CodeInvalidSpec),
+	)
+	t.Run("raw invalid spec",
+		testAPI(nil,
+			rawRequest("audio/wav", `{"format":"wav","fake":1}`, wavSample),
+			http.StatusBadRequest, encode.CodeInvalidSpec),
+	)
+	t.Run("raw too large",
+		testAPI(userinput.Limits{fileformat.WAV(): 1024},
+			rawRequest("audio/wav", `{"format":"wav","wav":{"bitDepth":16}}`, wavSample),
+			http.StatusRequestEntityTooLarge, encode.CodeFileTooLarge),
+	)
+	t.Run("multipart too large",
+		testAPI(userinput.Limits{
+			fileformat.WAV():  1024,
+			fileformat.AIFF(): 1024,
+			fileformat.MP3():  1024,
+			fileformat.FLAC(): 1024,
+		},
+			largeMultipartRequest(`{"format":"wav","wav":{"bitDepth":16}}`, 10<<20),
+			http.StatusRequestEntityTooLarge, encode.CodeFileTooLarge),
+	)
+	t.Run("raw not media",
+		testAPI(nil,
+			rawRequest("audio/wav", `{"format":"wav","wav":{"bitDepth":16}}`, "../_testdata/not-media"),
+			http.StatusBadRequest, encode.CodeEncodingFailed),
+	)
+	t.Run("multipart ok",
+		testAPI(nil,
+			multipartRequest(`{"format":"wav","wav":{"bitDepth":24}}`, wavSample),
+			http.StatusOK, ""),
+	)
+	t.Run("multipart resample",
+		testAPI(nil,
+			multipartRequest(`{"format":"wav","wav":{"bitDepth":16},"process":{"sampleRate":22050,"resampleQuality":"low"}}`, wavSample),
+			http.StatusOK, ""),
+	)
+	t.Run("multipart invalid sample rate",
+		testAPI(nil,
+			multipartRequest(`{"format":"wav","wav":{"bitDepth":16},"process":{"sampleRate":1}}`, wavSample),
+			http.StatusBadRequest, encode.CodeInvalidParameter),
+	)
+	t.Run("multipart channels",
+		testAPI(nil,
+			multipartRequest(`{"format":"wav","wav":{"bitDepth":16},"process":{"channels":1}}`, wavSample),
+			http.StatusOK, ""),
+	)
+	t.Run("multipart invalid channel map",
+		testAPI(nil,
+			multipartRequest(`{"format":"wav","wav":{"bitDepth":16},"process":{"channelMap":"0,a"}}`, wavSample),
+			http.StatusBadRequest, encode.CodeInvalidParameter),
+	)
+	t.Run("raw normalize",
+		testAPI(nil,
+			rawRequest("audio/wav", `{"format":"wav","wav":{"bitDepth":16},"process":{"normalize":-16,"truePeak":-2}}`, wavSample),
+			http.StatusOK, ""),
+	)
+	t.Run("multipart normalize",
+		testAPI(nil,
+			multipartRequest(`{"format":"mp3","mp3":{"channelMode":2,"bitRateMode":"VBR","bitRate":4},"process":{"normalize":-16}}`, wavSample),
+			http.StatusOK, ""),
+	)
+	t.Run("multipart invalid normalize",
+		testAPI(nil,
+			multipartRequest(`{"format":"wav","wav":{"bitDepth":16},"process":{"normalize":-100}}`, wavSample),
+			http.StatusBadRequest, encode.CodeInvalidParameter),
+	)
+	t.Run("multipart gain",
+		testAPI(nil,
+			multipartRequest(`{"format":"wav","wav":{"bitDepth":16},"process":{"gain":-3,"peakNormalize":0}}`, wavSample),
+			http.StatusOK, ""),
+	)
+	t.Run("multipart invalid peak",
+		testAPI(nil,
+			multipartRequest(`{"format":"wav","wav":{"bitDepth":16},"process":{"peakNormalize":1}}`, wavSample),
+			http.StatusBadRequest, encode.CodeInvalidParameter),
+	)
+	t.Run("multipart trim",
+		testAPI(nil,
+			multipartRequest(`{"format":"wav","wav":{"bitDepth":16},"process":{"start":"1","end":"00:02.5"}}`, wavSample),
+			http.StatusOK, ""),
+	)
+	t.Run("multipart fade",
+		testAPI(nil,
+			multipartRequest(`{"format":"wav","wav":{"bitDepth":16},"process":{"fadeIn":"1","fadeOut":"500smp","fadeCurve":"log"}}`, wavSample),
+			http.StatusOK, ""),
+	)
+	t.Run("multipart trim silence",
+		testAPI(nil,
+			multipartRequest(`{"format":"wav","wav":{"bitDepth":16},"process":{"trimSilence":{"threshold":-40,"padding":"0"}}}`, wavSample),
+			http.StatusOK, ""),
+	)
+	t.Run("multipart invalid trim",
+		testAPI(nil,
+			multipartRequest(`{"format":"wav","wav":{"bitDepth":16},"process":{"end":"1","duration":"1"}}`, wavSample),
+			http.StatusBadRequest, encode.CodeInvalidParameter),
+	)
+	t.Run("multipart unsupported output",
+		testAPI(nil,
+			multipartRequest(`{"format":"ogg"}`, wavSample),
+			http.StatusBadRequest, encode.CodeUnsupportedOutput),
+	)
+	t.Run("multipart invalid parameter",
+		testAPI(nil,
+			multipartRequest(`{"format":"wav","wav":{"bitDepth":12}}`, wavSample),
+			http.StatusBadRequest, encode.CodeInvalidParameter),
+	)
+	t.Run("multipart tags",
+		testAPI(nil,
+			multipartRequest(`{"format":"flac","flac":{"bitDepth":16},"tags":{"title":"Title"},"stripTags":true,"coverMaxSize":500}`, wavSample),
+			http.StatusOK, ""),
+	)
+	t.Run("multipart invalid tag",
+		testAPI(nil,
+			multipartRequest(`{"format":"flac","flac":{"bitDepth":16},"tags":{"a=b":"c"}}`, wavSample),
+			http.StatusBadRequest, encode.CodeInvalidParameter),
+	)
+	t.Run("raw invalid cover max size",
+		testAPI(nil,
+			rawRequest("audio/wav", `{"format":"wav","wav":{"bitDepth":16},"coverMaxSize":1}`, wavSample),
+			http.StatusBadRequest, encode.CodeInvalidParameter),
+	)
+	t.Run("multipart missing parameters",
+		testAPI(nil,
+			multipartRequest(`{"format":"mp3"}`, wavSample),
+			http.StatusBadRequest, encode.CodeInvalidParameter),
+	)
+	t.Run("encode waveform",
+		testAPI(nil,
+			rawRequest("audio/wav", `{"format":"wav","wav":{"bitDepth":16},"waveform":{}}`, wavSample),
+			http.StatusBadRequest, encode.CodeJobOnly),
+	)
+	t.Run("waveform not allowed method",
+		testAPI(nil,
+			httptest.NewRequest(http.MethodGet, "/api/waveform", nil),
+			http.StatusMethodNotAllowed, encode.CodeMethodNotAllowed),
+	)
+	t.Run("waveform raw ok",
+		testAPI(nil,
+			waveformRequest(rawRequest("audio/wav", `{"samplesPerPixel":512,"format":"dat","bits":16}`, wavSample)),
+			http.StatusOK, ""),
+	)
+	t.Run("waveform multipart ok",
+		testAPI(nil,
+			waveformRequest(multipartRequest(`{"format":"svg","height":64,"background":"#ffffff80"}`, wavSample)),
+			http.StatusOK, ""),
+	)
+	t.Run("waveform invalid samples per pixel",
+		testAPI(nil,
+			waveformRequest(rawRequest("audio/wav", `{"samplesPerPixel":1}`, wavSample)),
+			http.StatusBadRequest, encode.CodeInvalidParameter),
+	)
+	t.Run("waveform invalid color",
+		testAPI(nil,
+			waveformRequest(multipartRequest(`{"format":"png","color":"red"}`, wavSample)),
+			http.StatusBadRequest, encode.CodeInvalidParameter),
+	)
+	t.Run("waveform invalid spec",
+		testAPI(nil,
+			waveformRequest(rawRequest("audio/wav", `{"format":"json","wav":{}}`, wavSample)),
+			http.StatusBadRequest, encode.CodeInvalidSpec),
+	)
+	t.Run("waveform not media",
+		testAPI(nil,
+			waveformRequest(rawRequest("audio/wav", `{}`, "../_testdata/not-media")),
+			http.StatusBadRequest, encode.CodeEncodingFailed),
+	)
+}
+
+// waveformRequest sends the request to waveform endpoint.
+func waveformRequest(r *http.Request) *http.Request {
+	r.URL.Path = "/api/waveform"
+	return r
+}
+
+func TestAPIWaveform(t *testing.T) {
+	h := encode.APIHandler(userinput.NewEncodeAPI(nil), 512, "")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, waveformRequest(rawRequest("audio/wav", `{"samplesPerPixel":1024}`, wavSample)))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	var result struct {
+		SamplesPerPixel int   `json:"samples_per_pixel"`
+		Bits            int   `json:"bits"`
+		Length          int   `json:"length"`
+		Data            []int `json:"data"`
+	}
+	assert.Nil(t, json.NewDecoder(rr.Body).Decode(&result))
+	assert.Equal(t, 1024, result.SamplesPerPixel)
+	assert.Equal(t, 8, result.Bits)
+	// sample has 330534 frames.
+	assert.Equal(t, 323, result.Length)
+	assert.Equal(t, 2*323, len(result.Data))
+}
@@ -11,8 +11,11 @@ import (
 	"os"
 	"strconv"
 
-	"pipelined.dev/audio/fileformat"
 	"pipelined.dev/pipe"
+
+	"pipelined.dev/phono/fileformat"
+	"pipelined.dev/phono/tag"
+	"pipelined.dev/phono/waveform"
 )
 
 type (
@@ -26,6 +29,13 @@ type (
 	FormData struct {
 		Input
 		Output
+		Processing
+		// Waveform encodes peaks measured by processing. It's nil if
+		// waveform is not requested.
+		Waveform *waveform.Format
+		// Tags changes tags and cover of the input written to the
+		// output.
+		Tags tag.Edit
 	}
 
 	// Input is user-provided input for encoding.
@@ -34,10 +44,12 @@ type (
 		multipart.File
 	}
 
-	// Output is user-provided output for encoding.
+	// Output is user-provided output for encoding. Stream is nil if sink
+	// needs to seek.
 	Output struct {
 		*fileformat.Format
-		Sink func(io.WriteSeeker) pipe.SinkAllocatorFunc
+		Sink   func(io.WriteSeeker) pipe.SinkAllocatorFunc
+		Stream func(io.Writer) pipe.SinkAllocatorFunc
 	}
 )
 
@@ -46,7 +58,7 @@ type (
 //	1. Retrieve userinput format from URL
 //	2. Use http.MaxBytesReader to avoid memory abuse
 //	3. Parse output configuration
-//	4. Create temp file
+//	4. Stream result if sink doesn't need to seek, otherwise create temp file
 //	5. Run conversion
 //	6. Send result file
 func Handler(f Form, bufferSize int, tempDir string) http.Handler {
@@ -65,39 +77,8 @@ func Handler(f Form, bufferSize int, tempDir string) http.Handler {
 			}
 			defer formData.Close()
 
-			// create temp file
-			tempFile, err := ioutil.TempFile(tempDir, "")
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			defer cleanUp(tempFile)
-
-			// encode file using temp file
-			if err = Run(r.Context(), bufferSize, formData.Input.Source(formData.File), formData.Output.Sink(tempFile)); err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-				return
-			}
-			// reset temp file
-			_, err = tempFile.Seek(0, 0)
-			if err != nil {
-				http.Error(w, fmt.Sprintf("Failed to reset temp file: %v", err), http.StatusInternalServerError)
-				return
-			}
-			// get temp file stats for headers
-			stat, err := tempFile.Stat()
-			if err != nil {
-				http.Error(w, fmt.Sprintf("Failed to get file stats: %v", err), http.StatusInternalServerError)
-				return
-			}
-			fileSize := strconv.FormatInt(stat.Size(), 10)
-			//Send the headers
-			w.Header().Set("Content-Disposition", "attachment; filename="+outFileName("result", 1, formData.Output.DefaultExtension()))
-			w.Header().Set("Content-Type", mime.TypeByExtension(formData.Output.DefaultExtension()))
-			w.Header().Set("Content-Length", fileSize)
-			_, err = io.Copy(w, tempFile) // send file to a client
-			if err != nil {
-				http.Error(w, fmt.Sprintf("Failed to transfer file: %v", err), http.StatusInternalServerError)
+			if err := respond(w, r, bufferSize, tempDir, formData); err != nil {
+				http.Error(w, err.Message, err.Status)
 			}
 			return
 		default:
@@ -106,6 +87,120 @@ func Handler(f Form, bufferSize int, tempDir string) http.Handler {
 	})
 }
 
+// respond encodes the input and sends it to the client. If output can
+// be streamed, it's written directly into response. Otherwise temp file
+// is used. Error is returned only if nothing was sent yet.
+func respond(w http.ResponseWriter, r *http.Request, bufferSize int, tempDir string, formData FormData) *Error {
+	output, err := tagged(formData.Input, formData.Output, formData.Tags)
+	if err != nil {
+		return NewError(http.StatusBadRequest, CodeEncodingFailed, "%v", err)
+	}
+	formData.Output = output
+	if formData.Output.Stream != nil {
+		return stream(w, r, bufferSize, formData)
+	}
+	// create temp file
+	tempFile, err := ioutil.TempFile(tempDir, "")
+	if err != nil {
+		return NewError(http.StatusInternalServerError, CodeInternal, "%v", err)
+	}
+	defer cleanUp(tempFile)
+
+	// encode file using temp file
+	report, err := Run(r.Context(), bufferSize, formData.Input.Format, formData.File, formData.Output.Sink(tempFile), formData.Processing)
+	if err != nil {
+		return NewError(http.StatusBadRequest, CodeEncodingFailed, "%v", err)
+	}
+	// reset temp file
+	_, err = tempFile.Seek(0, 0)
+	if err != nil {
+		return NewError(http.StatusInternalServerError, CodeInternal, "Failed to reset temp file: %v", err)
+	}
+	// get temp file stats for headers
+	stat, err := tempFile.Stat()
+	if err != nil {
+		return NewError(http.StatusInternalServerError, CodeInternal, "Failed to get file stats: %v", err)
+	}
+	fileSize := strconv.FormatInt(stat.Size(), 10)
+	//Send the headers
+	w.Header().Set("Content-Disposition", "attachment; filename="+outFileName("result", 1, formData.Output.DefaultExtension()))
+	w.Header().Set("Content-Type", mime.TypeByExtension(formData.Output.DefaultExtension()))
+	w.Header().Set("Content-Length", fileSize)
+	for _, warning := range report.Warnings() {
+		w.Header().Add("Warning", fmt.Sprintf("199 phono %q", warning))
+	}
+	_, err = io.Copy(w, tempFile) // send file to a client
+	if err != nil {
+		// headers are already sent, so just log the error
+		log.Printf("Failed to transfer file: %v", err)
+	}
+	return nil
+}
+
+// stream encodes the input directly into response. Content length is
+// not known, so chunked transfer is used. If encoding fails after
+// response was started, the connection is aborted so the client doesn't
+// receive truncated result as a valid one.
+func stream(w http.ResponseWriter, r *http.Request, bufferSize int, formData FormData) *Error {
+	sw := streamWriter{
+		ResponseWriter: w,
+		ext:            formData.Output.DefaultExtension(),
+	}
+	// headers are sent before the end of encoding, so warnings are not
+	// reported.
+	_, err := Run(r.Context(), bufferSize, formData.Input.Format, formData.File, formData.Output.Stream(&sw), formData.Processing)
+	if err == nil {
+		// make sure headers are sent even for empty result.
+		sw.start()
+		return nil
+	}
+	if !sw.started {
+		return NewError(http.StatusBadRequest, CodeEncodingFailed, "%v", err)
+	}
+	log.Printf("Failed to stream file: %v", err)
+	panic(http.ErrAbortHandler)
+}
+
+// tagged returns output that writes metadata of the input changed by
+// the edit. Invalid metadata of the input is ignored. Stream is nil if
+// metadata can't be written without seeking.
+func tagged(input Input, output Output, edit tag.Edit) (Output, error) {
+	m, err := tag.Read(input.Format, input.File)
+	if err != nil {
+		// invalid tags of the input are not carried.
+		log.Printf("Tags are not carried: %v", err)
+	}
+	if m, err = edit.Apply(m); err != nil {
+		return Output{}, err
+	}
+	output.Sink = tag.Sink(output.Format, m, output.Sink)
+	output.Stream = tag.Stream(output.Format, m, output.Stream)
+	return output, nil
+}
+
+// streamWriter sends headers with the first chunk of data.
+type streamWriter struct {
+	http.ResponseWriter
+	ext     string
+	started bool
+}
+
+func (w *streamWriter) Write(b []byte) (int, error) {
+	w.start()
+	return w.ResponseWriter.Write(b)
+}
+
+// start sends headers if they weren't sent yet.
+func (w *streamWriter) start() {
+	if w.started {
+		return
+	}
+	w.started = true
+	w.Header().Set("Content-Disposition", "attachment; filename="+outFileName("result", 1, w.ext))
+	w.Header().Set("Content-Type", mime.TypeByExtension(w.ext))
+	w.WriteHeader(http.StatusOK)
+}
+
 // outFileName return output file name. It replaces userinput format extension with output.
 func outFileName(prefix string, idx int, ext string) string {
 	return fmt.Sprintf("%v_%d%v", prefix, idx, ext)
@@ -2,6 +2,8 @@ package encode_test
 
 import (
 	"bytes"
+	"image"
+	"image/png"
 	"io"
 	"mime/multipart"
 	"net/http"
@@ -13,7 +15,13 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+
 	"pipelined.dev/phono/encode"
+	"pipelined.dev/phono/fileformat"
+	"pipelined.dev/phono/tag"
 	"pipelined.dev/phono/userinput"
 )
 
@@ -113,4 +121,160 @@ func TestHandler(t *testing.T) {
 			}),
 			http.StatusOK),
 	)
+	t.Run("flac ok",
+		testHandler(f,
+			wavUploadRequest(map[string]string{
+				"format":                 ".flac",
+				"flac-bit-depth":         "16",
+				"flac-compression-level": "5",
+			}),
+			http.StatusOK),
+	)
+	t.Run("corrupt tags", func(t *testing.T) {
+		h := encode.Handler(f, bufferSize, "")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, fileUploadRequest("test/.mp3", map[string]string{
+			"format":        ".wav",
+			"wav-bit-depth": "16",
+		}, "../_testdata/corrupt-tags.mp3"))
+		// input is encoded without its tags.
+		assert.Equal(t, http.StatusOK, rr.Code)
+		m, err := tag.Read(fileformat.WAV(), bytes.NewReader(rr.Body.Bytes()))
+		assert.Nil(t, err)
+		assert.Empty(t, m.Tags)
+	})
+	t.Run("flac tags", func(t *testing.T) {
+		h := encode.Handler(f, bufferSize, "")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, coverUploadRequest(t, map[string]string{
+			"format":                 ".flac",
+			"flac-bit-depth":         "16",
+			"flac-compression-level": "5",
+			"tag-title":              "Title",
+			"cover-max-size":         "32",
+		}))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		m, err := tag.Read(fileformat.FLAC(), bytes.NewReader(rr.Body.Bytes()))
+		assert.Nil(t, err)
+		// tags of the input are carried.
+		assert.Equal(t, tag.Tags{
+			tag.Title:  "Title",
+			tag.Artist: "freewavesamples.com",
+			tag.Date:   "2017",
+		}, m.Tags)
+		// cover is scaled down.
+		assert.NotNil(t, m.Cover)
+		width, height := m.Cover.Dimensions()
+		assert.Equal(t, 32, width)
+		assert.Equal(t, 16, height)
+	})
+}
+
+// coverUploadRequest returns wav upload request with 64x32 png cover.
+func coverUploadRequest(t *testing.T, params map[string]string) *http.Request {
+	t.Helper()
+	sample, err := os.Open("../_testdata/sample.wav")
+	assert.Nil(t, err)
+	defer sample.Close()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile(userinput.FormFileKey, "sample.wav")
+	assert.Nil(t, err)
+	_, err = io.Copy(part, sample)
+	assert.Nil(t, err)
+	part, err = writer.CreateFormFile(userinput.FormCoverKey, "cover.png")
+	assert.Nil(t, err)
+	assert.Nil(t, png.Encode(part, image.NewGray(image.Rect(0, 0, 64, 32))))
+	for key, val := range params {
+		assert.Nil(t, writer.WriteField(key, val))
+	}
+	assert.Nil(t, writer.Close())
+
+	r := httptest.NewRequest(http.MethodPost, "/.wav", body)
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+	return r
+}
+
+// streamForm returns form data with output that writes one byte per
+// sample. If fail is set, output fails after provided number of bytes.
+type streamForm struct {
+	fail  bool
+	after int
+}
+
+func (streamForm) Bytes() []byte { return nil }
+
+func (f streamForm) Parse(*http.Request) (encode.FormData, error) {
+	file, err := os.Open("../_testdata/sample.wav")
+	if err != nil {
+		return encode.FormData{}, err
+	}
+	return encode.FormData{
+		Input: encode.Input{
+			Format: fileformat.WAV(),
+			File:   file,
+		},
+		Output: encode.Output{
+			Format: fileformat.MP3(),
+			Stream: func(w io.Writer) pipe.SinkAllocatorFunc {
+				return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Sink, error) {
+					written := 0
+					return pipe.Sink{
+						SinkFunc: func(in signal.Floating) error {
+							if f.fail && written >= f.after {
+								return io.ErrShortWrite
+							}
+							n, err := w.Write(make([]byte, in.Len()))
+							written += n
+							return err
+						},
+					}, nil
+				}
+			},
+		},
+	}, nil
+}
+
+func TestHandlerStream(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		h := encode.Handler(streamForm{}, 512, "")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/.wav", nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "", rr.Header().Get("Content-Length"))
+		assert.Equal(t, "attachment; filename=result_1.mp3", rr.Header().Get("Content-Disposition"))
+		// tags of the sample are written before the samples.
+		body := rr.Body.Bytes()
+		assert.Equal(t, "ID3", string(body[:3]))
+		tagSize := 10 + (int(body[6])<<21 | int(body[7])<<14 | int(body[8])<<7 | int(body[9]))
+		assert.Equal(t, 330534*2, len(body)-tagSize)
+	})
+	t.Run("fail before first byte", func(t *testing.T) {
+		h := encode.Handler(streamForm{fail: true}, 512, "")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/.wav", nil))
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Equal(t, "", rr.Header().Get("Content-Disposition"))
+	})
+	t.Run("fail after first byte", func(t *testing.T) {
+		h := encode.Handler(streamForm{fail: true, after: 1024}, 512, "")
+		rr := httptest.NewRecorder()
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/.wav", nil))
+		})
+	})
+	t.Run("mp3 form", func(t *testing.T) {
+		h := encode.Handler(userinput.NewEncodeForm(userinput.Limits{}), 512, "")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, wavUploadRequest(map[string]string{
+			"format":            ".mp3",
+			"mp3-channel-mode":  "2",
+			"mp3-bit-rate-mode": "VBR",
+			"mp3-vbr-quality":   "4",
+		}))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "", rr.Header().Get("Content-Length"))
+		assert.Equal(t, "attachment; filename=result_1.mp3", rr.Header().Get("Content-Disposition"))
+	})
 }
@@ -0,0 +1,500 @@
+package encode
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"pipelined.dev/phono/fileformat"
+	"pipelined.dev/phono/process"
+	"pipelined.dev/phono/tag"
+	"pipelined.dev/phono/waveform"
+)
+
+// Statuses of encoding jobs.
+const (
+	StatusQueued  = "queued"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+var (
+	// ErrQueueFull is returned when job cannot be submitted because
+	// all queue slots are taken.
+	ErrQueueFull = errors.New("queue is full")
+	// ErrJobNotFound is returned when job doesn't exist or it's result
+	// is already cleaned up.
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobNotDone is returned when result of unfinished or failed job
+	// is requested.
+	ErrJobNotDone = errors.New("job is not done")
+	// ErrNoWaveform is returned when waveform of the job that didn't
+	// request it is requested.
+	ErrNoWaveform = errors.New("waveform is not requested")
+)
+
+type (
+	// Queue runs encoding jobs with bounded pool of workers. Results are
+	// stored in temp dir and removed after retention period.
+	Queue struct {
+		bufferSize int
+		tempDir    string
+		retention  time.Duration
+		pending    chan *job
+		ctx        context.Context
+		cancelFn   context.CancelFunc
+		wg         sync.WaitGroup
+
+		mu   sync.Mutex
+		jobs map[string]*job
+	}
+
+	// JobInfo reports the state of the job.
+	JobInfo struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+		// Progress is a share of processed input between 0 and 1.
+		Progress float64    `json:"progress"`
+		Error    string     `json:"error,omitempty"`
+		Created  time.Time  `json:"created"`
+		Finished *time.Time `json:"finished,omitempty"`
+		// Expires is the time when result will be removed.
+		Expires *time.Time `json:"expires,omitempty"`
+		// Warnings are issues detected during encoding.
+		Warnings []string `json:"warnings,omitempty"`
+		// Silence contains trimmed silence in seconds.
+		Silence *SilenceInfo `json:"trimmedSilence,omitempty"`
+	}
+
+	// SilenceInfo reports trimmed silence in seconds.
+	SilenceInfo struct {
+		Start float64 `json:"start"`
+		End   float64 `json:"end"`
+	}
+
+	job struct {
+		// read is accessed atomically, so it goes first for alignment.
+		read      int64
+		inputSize int64
+		id        string
+		input     *os.File
+		format    *fileformat.Format
+		output    Output
+		process   Processing
+		waveform  *waveform.Format
+		tags      tag.Edit
+		result    string
+		report    Report
+		status    string
+		err       error
+		created   time.Time
+		finished  time.Time
+	}
+
+	// progressReader tracks the position of the input.
+	progressReader struct {
+		io.ReadSeeker
+		pos *int64
+	}
+)
+
+// NewQueue creates new queue and starts workers. Capacity limits the
+// number of jobs waiting for a worker. Finished jobs are removed after
+// retention period, cleanup is executed with provided interval. Zero
+// retention means jobs are kept until queue is closed.
+func NewQueue(bufferSize, workers, capacity int, tempDir string, retention, cleanup time.Duration) *Queue {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	q := Queue{
+		bufferSize: bufferSize,
+		tempDir:    tempDir,
+		retention:  retention,
+		pending:    make(chan *job, capacity),
+		ctx:        ctx,
+		cancelFn:   cancelFn,
+		jobs:       make(map[string]*job),
+	}
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			for {
+				select {
+				case <-q.ctx.Done():
+					return
+				case j := <-q.pending:
+					q.run(j)
+				}
+			}
+		}()
+	}
+	if retention > 0 && cleanup > 0 {
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			ticker := time.NewTicker(cleanup)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-q.ctx.Done():
+					return
+				case now := <-ticker.C:
+					q.cleanUp(now)
+				}
+			}
+		}()
+	}
+	return &q
+}
+
+// Submit copies the input into temp dir and adds a new job to the queue.
+func (q *Queue) Submit(data FormData) (JobInfo, error) {
+	id, err := newJobID()
+	if err != nil {
+		return JobInfo{}, err
+	}
+	// input is copied, because form data is released with request.
+	input, err := ioutil.TempFile(q.tempDir, "")
+	if err != nil {
+		return JobInfo{}, err
+	}
+	if _, err := data.File.Seek(0, io.SeekStart); err != nil {
+		removeTemp(input)
+		return JobInfo{}, fmt.Errorf("failed to reset input: %w", err)
+	}
+	size, err := io.Copy(input, data.File)
+	if err == nil {
+		_, err = input.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		removeTemp(input)
+		return JobInfo{}, fmt.Errorf("failed to store input: %w", err)
+	}
+
+	j := job{
+		inputSize: size,
+		id:        id,
+		input:     input,
+		format:    data.Input.Format,
+		output:    data.Output,
+		process:   data.Processing,
+		waveform:  data.Waveform,
+		tags:      data.Tags,
+		status:    StatusQueued,
+		created:   time.Now(),
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	select {
+	case q.pending <- &j:
+		q.jobs[id] = &j
+		return q.info(&j), nil
+	default:
+		removeTemp(input)
+		return JobInfo{}, ErrQueueFull
+	}
+}
+
+// Job returns the state of the job.
+func (q *Queue) Job(id string) (JobInfo, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return JobInfo{}, ErrJobNotFound
+	}
+	return q.info(j), nil
+}
+
+// Result opens the result of finished job. Returned file must be closed
+// by the caller.
+func (q *Queue) Result(id string) (*os.File, *fileformat.Format, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return nil, nil, ErrJobNotFound
+	}
+	if j.status != StatusDone {
+		return nil, nil, ErrJobNotDone
+	}
+	f, err := os.Open(j.result)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, j.output.Format, nil
+}
+
+// Waveform returns the waveform of finished job and the format it was
+// requested in.
+func (q *Queue) Waveform(id string) (process.Waveform, waveform.Format, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return process.Waveform{}, waveform.Format{}, ErrJobNotFound
+	}
+	if j.waveform == nil {
+		return process.Waveform{}, waveform.Format{}, ErrNoWaveform
+	}
+	if j.status != StatusDone {
+		return process.Waveform{}, waveform.Format{}, ErrJobNotDone
+	}
+	return *j.report.Waveform, *j.waveform, nil
+}
+
+// Close cancels running jobs, stops workers and removes all files.
+func (q *Queue) Close() {
+	q.cancelFn()
+	q.wg.Wait()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for id, j := range q.jobs {
+		q.remove(j)
+		delete(q.jobs, id)
+	}
+}
+
+func (q *Queue) run(j *job) {
+	q.mu.Lock()
+	j.status = StatusRunning
+	q.mu.Unlock()
+
+	result, report, err := q.encode(j)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j.finished = time.Now()
+	if err != nil {
+		j.status = StatusFailed
+		j.err = err
+		return
+	}
+	j.status = StatusDone
+	j.result = result
+	j.report = report
+}
+
+// encode runs the job and returns path of the result file.
+func (q *Queue) encode(j *job) (string, Report, error) {
+	defer func() {
+		removeTemp(j.input)
+	}()
+	output, err := tagged(Input{Format: j.format, File: j.input}, j.output, j.tags)
+	if err != nil {
+		return "", Report{}, err
+	}
+	result, err := ioutil.TempFile(q.tempDir, "")
+	if err != nil {
+		return "", Report{}, err
+	}
+	input := progressReader{ReadSeeker: j.input, pos: &j.read}
+	report, err := Run(q.ctx, q.bufferSize, j.format, input, output.Sink(result), j.process)
+	if err != nil {
+		removeTemp(result)
+		return "", Report{}, err
+	}
+	if err := result.Close(); err != nil {
+		os.Remove(result.Name())
+		return "", Report{}, err
+	}
+	return result.Name(), report, nil
+}
+
+// cleanUp removes jobs finished before retention period.
+func (q *Queue) cleanUp(now time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for id, j := range q.jobs {
+		if j.finished.IsZero() || now.Sub(j.finished) < q.retention {
+			continue
+		}
+		q.remove(j)
+		delete(q.jobs, id)
+	}
+}
+
+// remove deletes files of the job. Must be called under lock.
+func (q *Queue) remove(j *job) {
+	if j.status == StatusQueued {
+		removeTemp(j.input)
+	}
+	if j.result != "" {
+		if err := os.Remove(j.result); err != nil {
+			log.Printf("Failed to delete job result: %v", err)
+		}
+	}
+}
+
+// info returns state of the job. Must be called under lock.
+func (q *Queue) info(j *job) JobInfo {
+	info := JobInfo{
+		ID:      j.id,
+		Status:  j.status,
+		Created: j.created,
+	}
+	if j.inputSize > 0 {
+		info.Progress = float64(atomic.LoadInt64(&j.read)) / float64(j.inputSize)
+	}
+	if info.Progress > 1 || j.status == StatusDone {
+		info.Progress = 1
+	}
+	if !j.finished.IsZero() {
+		finished := j.finished
+		info.Finished = &finished
+		if q.retention > 0 {
+			expires := j.finished.Add(q.retention)
+			info.Expires = &expires
+		}
+	}
+	if j.err != nil {
+		info.Error = j.err.Error()
+	}
+	info.Warnings = j.report.Warnings()
+	if s := j.report.Silence; s != nil {
+		info.Silence = &SilenceInfo{
+			Start: s.Start.Seconds(),
+			End:   s.End.Seconds(),
+		}
+	}
+	return info
+}
+
+func (r progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadSeeker.Read(p)
+	atomic.AddInt64(r.pos, int64(n))
+	return n, err
+}
+
+func (r progressReader) Seek(offset int64, whence int) (int64, error) {
+	pos, err := r.ReadSeeker.Seek(offset, whence)
+	if err == nil {
+		atomic.StoreInt64(r.pos, pos)
+	}
+	return pos, err
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate job id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// removeTemp closes and removes temp file. Errors are only logged.
+func removeTemp(f *os.File) {
+	f.Close()
+	if err := os.Remove(f.Name()); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to delete temp file: %v", err)
+	}
+}
+
+// JobsHandler serves asynchronous encoding API. Following endpoints are
+// supported:
+//
+//	POST /jobs - submit new job, accepts the same body as /api/encode
+//	GET /jobs/{id} - state of the job
+//	GET /jobs/{id}/result - download the result of finished job
+//	GET /jobs/{id}/waveform - waveform of the result if it was requested
+func JobsHandler(api API, q *Queue) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/jobs"), "/")
+		if path == "" {
+			if r.Method != http.MethodPost {
+				writeError(w, NewError(http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method %s is not allowed", r.Method))
+				return
+			}
+			submitJob(w, r, api, q)
+			return
+		}
+		if r.Method != http.MethodGet {
+			writeError(w, NewError(http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method %s is not allowed", r.Method))
+			return
+		}
+		parts := strings.Split(path, "/")
+		switch {
+		case len(parts) == 1:
+			info, err := q.Job(parts[0])
+			if err != nil {
+				writeError(w, jobError(err))
+				return
+			}
+			writeJSON(w, http.StatusOK, info)
+		case len(parts) == 2 && parts[1] == "result":
+			sendResult(w, q, parts[0])
+		case len(parts) == 2 && parts[1] == "waveform":
+			wf, format, err := q.Waveform(parts[0])
+			if err != nil {
+				writeError(w, jobError(err))
+				return
+			}
+			sendWaveform(w, wf, format)
+		default:
+			writeError(w, NewError(http.StatusNotFound, CodeNotFound, "Endpoint %s not found", r.URL.Path))
+		}
+	})
+}
+
+func submitJob(w http.ResponseWriter, r *http.Request, api API, q *Queue) {
+	formData, err := api.ParseRequest(r, q.tempDir)
+	if err != nil {
+		writeError(w, apiError(err))
+		return
+	}
+	defer formData.Close()
+
+	info, err := q.Submit(formData)
+	if err != nil {
+		writeError(w, jobError(err))
+		return
+	}
+	w.Header().Set("Location", "/jobs/"+info.ID)
+	writeJSON(w, http.StatusAccepted, info)
+}
+
+func sendResult(w http.ResponseWriter, q *Queue, id string) {
+	f, format, err := q.Result(id)
+	if err != nil {
+		writeError(w, jobError(err))
+		return
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		writeError(w, NewError(http.StatusInternalServerError, CodeInternal, "Failed to get file stats: %v", err))
+		return
+	}
+	w.Header().Set("Content-Disposition", "attachment; filename="+outFileName("result", 1, format.DefaultExtension()))
+	w.Header().Set("Content-Type", mime.TypeByExtension(format.DefaultExtension()))
+	w.Header().Set("Content-Length", strconv.FormatInt(stat.Size(), 10))
+	if _, err := io.Copy(w, f); err != nil {
+		log.Printf("Failed to transfer file: %v", err)
+	}
+}
+
+// jobError converts queue errors into API errors.
+func jobError(err error) *Error {
+	switch err {
+	case ErrJobNotFound, ErrNoWaveform:
+		return NewError(http.StatusNotFound, CodeNotFound, "%v", err)
+	case ErrJobNotDone:
+		return NewError(http.StatusConflict, CodeJobNotDone, "%v", err)
+	case ErrQueueFull:
+		return NewError(http.StatusServiceUnavailable, CodeQueueFull, "%v", err)
+	}
+	return NewError(http.StatusInternalServerError, CodeInternal, "%v", err)
+}
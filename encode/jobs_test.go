@@ -0,0 +1,160 @@
+package encode_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/phono/encode"
+	"pipelined.dev/phono/userinput"
+)
+
+// waitJob polls the job until it's finished.
+func waitJob(t *testing.T, h http.Handler, id string) encode.JobInfo {
+	t.Helper()
+	for i := 0; i < 500; i++ {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/jobs/"+id, nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var info encode.JobInfo
+		assert.Nil(t, json.NewDecoder(rr.Body).Decode(&info))
+		if info.Status == encode.StatusDone || info.Status == encode.StatusFailed {
+			return info
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s is not finished", id)
+	return encode.JobInfo{}
+}
+
+func submitJob(t *testing.T, h http.Handler, spec, filePath string) encode.JobInfo {
+	t.Helper()
+	r := rawRequest("audio/wav", spec, filePath)
+	r.URL.Path = "/jobs"
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+	var info encode.JobInfo
+	assert.Nil(t, json.NewDecoder(rr.Body).Decode(&info))
+	assert.Equal(t, "/jobs/"+info.ID, rr.Header().Get("Location"))
+	return info
+}
+
+func TestJobs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phono-jobs")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	q := encode.NewQueue(512, 2, 10, dir, 0, 0)
+	defer q.Close()
+	h := encode.JobsHandler(userinput.NewEncodeAPI(userinput.Limits{}), q)
+
+	t.Run("done", func(t *testing.T) {
+		info := submitJob(t, h, `{"format":"wav","wav":{"bitDepth":16}}`, wavSample)
+		info = waitJob(t, h, info.ID)
+		assert.Equal(t, encode.StatusDone, info.Status)
+		assert.Equal(t, 1.0, info.Progress)
+		assert.NotNil(t, info.Finished)
+		assert.Nil(t, info.Expires)
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/jobs/"+info.ID+"/result", nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.NotZero(t, rr.Body.Len())
+	})
+	t.Run("waveform", func(t *testing.T) {
+		info := submitJob(t, h, `{"format":"wav","wav":{"bitDepth":16},"waveform":{"samplesPerPixel":1024,"format":"png"}}`, wavSample)
+		info = waitJob(t, h, info.ID)
+		assert.Equal(t, encode.StatusDone, info.Status)
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/jobs/"+info.ID+"/waveform", nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "image/png", rr.Header().Get("Content-Type"))
+		assert.NotZero(t, rr.Body.Len())
+	})
+	t.Run("waveform not requested", func(t *testing.T) {
+		info := submitJob(t, h, `{"format":"wav","wav":{"bitDepth":16}}`, wavSample)
+		info = waitJob(t, h, info.ID)
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/jobs/"+info.ID+"/waveform", nil))
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+	t.Run("failed", func(t *testing.T) {
+		info := submitJob(t, h, `{"format":"wav","wav":{"bitDepth":16}}`, "../_testdata/not-media")
+		info = waitJob(t, h, info.ID)
+		assert.Equal(t, encode.StatusFailed, info.Status)
+		assert.NotEmpty(t, info.Error)
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/jobs/"+info.ID+"/result", nil))
+		assert.Equal(t, http.StatusConflict, rr.Code)
+	})
+	t.Run("not found", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/jobs/fake", nil))
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+	t.Run("invalid spec", func(t *testing.T) {
+		r := rawRequest("audio/wav", `{"format":"wav"}`, wavSample)
+		r.URL.Path = "/jobs"
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, r)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+	t.Run("not allowed method", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/jobs", nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+	})
+}
+
+func TestJobsRetention(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phono-jobs")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	q := encode.NewQueue(512, 1, 10, dir, 50*time.Millisecond, 10*time.Millisecond)
+	defer q.Close()
+	h := encode.JobsHandler(userinput.NewEncodeAPI(userinput.Limits{}), q)
+
+	info := submitJob(t, h, `{"format":"wav","wav":{"bitDepth":16}}`, wavSample)
+	info = waitJob(t, h, info.ID)
+	assert.Equal(t, encode.StatusDone, info.Status)
+	assert.NotNil(t, info.Expires)
+
+	_, err = q.Job(info.ID)
+	for i := 0; i < 100 && err == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+		_, err = q.Job(info.ID)
+	}
+	assert.Equal(t, encode.ErrJobNotFound, err)
+	// all files are removed
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Empty(t, files)
+}
+
+func TestQueueFull(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phono-jobs")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	// no workers, so jobs are never taken from the queue
+	q := encode.NewQueue(512, 0, 1, dir, 0, 0)
+	defer q.Close()
+	h := encode.JobsHandler(userinput.NewEncodeAPI(userinput.Limits{}), q)
+
+	info := submitJob(t, h, `{"format":"wav","wav":{"bitDepth":16}}`, wavSample)
+	assert.Equal(t, encode.StatusQueued, info.Status)
+
+	r := rawRequest("audio/wav", `{"format":"wav","wav":{"bitDepth":16}}`, wavSample)
+	r.URL.Path = "/jobs"
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+}
@@ -3,19 +3,212 @@ package encode
 import (
 	"context"
 	"fmt"
+	"io"
+	"time"
 
 	"pipelined.dev/pipe"
+
+	"pipelined.dev/phono/fileformat"
+	"pipelined.dev/phono/process"
+)
+
+type (
+	// Processing contains optional stages applied between source and sink.
+	Processing struct {
+		// Sources wrap the source in provided order. They are used for
+		// stages that change the number of samples, e.g. resampling.
+		// Stages can add their results to the report of the run.
+		Sources []func(pipe.SourceAllocatorFunc, *Report) pipe.SourceAllocatorFunc
+		// Processors are executed after all sources.
+		Processors []pipe.ProcessorAllocatorFunc
+		// Analyzers create two-pass stages. New analyzer is created for
+		// every run, because processing can be shared by concurrent
+		// runs.
+		Analyzers []func() Analyzer
+		// Analyze enables measurement of the encoded signal.
+		Analyze bool
+		// Waveform is the number of samples per pixel of the encoded
+		// signal waveform. Zero disables the waveform.
+		Waveform int
+	}
+
+	// Analyzer measures the signal in a separate pass before encoding.
+	Analyzer interface {
+		// Sink measures the signal.
+		Sink() pipe.SinkAllocatorFunc
+		// Processor returns the processor based on the measurement. It
+		// is appended to processors of all following passes.
+		Processor() (pipe.ProcessorAllocatorFunc, error)
+	}
+
+	// InputFunc returns the source of the signal. It's called once per
+	// pass, so every call must start from the beginning.
+	InputFunc func() (pipe.SourceAllocatorFunc, error)
+
+	// Splitter finds parts of the input in a separate pass.
+	Splitter interface {
+		// Sink measures the signal.
+		Sink() pipe.SinkAllocatorFunc
+		// Parts returns parts based on the measurement.
+		Parts() ([]process.Part, error)
+	}
+
+	// Report contains results of encoding.
+	Report struct {
+		// Overs is the number of samples that exceed full scale and are
+		// clipped by encoding.
+		Overs int
+		// Silence is nil if silence isn't trimmed.
+		Silence *TrimmedSilence
+		// Analysis is nil if analysis is not enabled.
+		Analysis *process.Analysis
+		// Waveform is nil if waveform is not enabled.
+		Waveform *process.Waveform
+	}
+
+	// TrimmedSilence contains durations of trimmed silence.
+	TrimmedSilence struct {
+		Start time.Duration
+		End   time.Duration
+	}
 )
 
-// Run encoding using Pump as the source and Sinks as destination.
-func Run(ctx context.Context, bufferSize int, pump pipe.SourceAllocatorFunc, sink pipe.SinkAllocatorFunc) error {
-	// run conversion
-	err := pipe.Run(ctx, bufferSize, pipe.Line{
-		Source: pump,
-		Sink:   sink,
+// Warnings returns the issues in human-readable form.
+func (r Report) Warnings() []string {
+	var warnings []string
+	if r.Overs > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d samples exceed full scale", r.Overs))
+	}
+	return warnings
+}
+
+// Run encoding of the input in provided format to the sink. If processing
+// contains analyzers, the input is read once per analyzer before the
+// encoding. Returned report is valid only if error is nil.
+func Run(ctx context.Context, bufferSize int, format *fileformat.Format, input io.ReadSeeker, sink pipe.SinkAllocatorFunc, processing Processing) (Report, error) {
+	return RunInput(ctx, bufferSize, FileInput(format, input), sink, processing)
+}
+
+// RunInput is like Run, but the signal is provided by the input function.
+// It allows to encode signal of multiple files.
+func RunInput(ctx context.Context, bufferSize int, input InputFunc, sink pipe.SinkAllocatorFunc, processing Processing) (Report, error) {
+	var report Report
+	processors := processing.Processors
+	for _, analyzer := range processing.Analyzers {
+		a := analyzer()
+		if err := runPass(ctx, bufferSize, input, a.Sink(), processing.Sources, processors, &report); err != nil {
+			return Report{}, fmt.Errorf("failed to analyze: %w", err)
+		}
+		processor, err := a.Processor()
+		if err != nil {
+			return Report{}, fmt.Errorf("failed to analyze: %w", err)
+		}
+		// copy to avoid appending to shared slice.
+		processors = append(processors[:len(processors):len(processors)], processor)
+	}
+	var (
+		clip  process.ClipDetector
+		meter process.Meter
+		peaks = process.PeakMeter{SamplesPerPixel: processing.Waveform}
+	)
+	processors = append(processors[:len(processors):len(processors)], clip.Processor())
+	if processing.Analyze {
+		processors = append(processors, meter.Processor())
+	}
+	if processing.Waveform != 0 {
+		processors = append(processors, peaks.Processor())
+	}
+	if err := runPass(ctx, bufferSize, input, sink, processing.Sources, processors, &report); err != nil {
+		return Report{}, fmt.Errorf("failed to execute pipe: %w", err)
+	}
+	report.Overs = clip.Overs()
+	if processing.Analyze {
+		analysis := meter.Analysis()
+		report.Analysis = &analysis
+	}
+	if processing.Waveform != 0 {
+		waveform := peaks.Waveform()
+		report.Waveform = &waveform
+	}
+	return report, nil
+}
+
+// Analyze measures quality metrics of the input in provided format.
+func Analyze(ctx context.Context, bufferSize int, format *fileformat.Format, input io.ReadSeeker) (process.Analysis, error) {
+	var meter process.Meter
+	if err := runPass(ctx, bufferSize, FileInput(format, input), meter.Sink(), nil, nil, &Report{}); err != nil {
+		return process.Analysis{}, fmt.Errorf("failed to analyze: %w", err)
+	}
+	return meter.Analysis(), nil
+}
+
+// Waveform measures peaks of the input in provided format with provided
+// number of samples per pixel.
+func Waveform(ctx context.Context, bufferSize int, format *fileformat.Format, input io.ReadSeeker, samplesPerPixel int) (process.Waveform, error) {
+	peaks := process.PeakMeter{SamplesPerPixel: samplesPerPixel}
+	if err := runPass(ctx, bufferSize, FileInput(format, input), peaks.Sink(), nil, nil, &Report{}); err != nil {
+		return process.Waveform{}, fmt.Errorf("failed to measure waveform: %w", err)
+	}
+	return peaks.Waveform(), nil
+}
+
+// Spectrogram measures spectrogram of the input in provided format with
+// provided analyzer configuration.
+func Spectrogram(ctx context.Context, bufferSize int, format *fileformat.Format, input io.ReadSeeker, analyzer process.SpectrumAnalyzer) (process.Spectrogram, error) {
+	if err := runPass(ctx, bufferSize, FileInput(format, input), analyzer.Sink(), nil, nil, &Report{}); err != nil {
+		return process.Spectrogram{}, fmt.Errorf("failed to measure spectrogram: %w", err)
+	}
+	return analyzer.Spectrogram(), nil
+}
+
+// FileInput returns input that reads the file in provided format from
+// the beginning.
+func FileInput(format *fileformat.Format, rs io.ReadSeeker) InputFunc {
+	return func() (pipe.SourceAllocatorFunc, error) {
+		if _, err := rs.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return format.Source(rs), nil
+	}
+}
+
+// Part returns processing of the part of the input. The part is trimmed
+// before all other sources, so its range is defined by the input.
+func (p Processing) Part(part process.Part) Processing {
+	sources := make([]func(pipe.SourceAllocatorFunc, *Report) pipe.SourceAllocatorFunc, 0, len(p.Sources)+1)
+	sources = append(sources, func(source pipe.SourceAllocatorFunc, _ *Report) pipe.SourceAllocatorFunc {
+		return process.Trim(source, part.Range)
 	})
+	p.Sources = append(sources, p.Sources...)
+	return p
+}
+
+// Split reads the input with the splitter and returns found parts.
+// Parts can be encoded with the processing returned by Processing.Part.
+func Split(ctx context.Context, bufferSize int, format *fileformat.Format, input io.ReadSeeker, splitter Splitter) ([]process.Part, error) {
+	if err := runPass(ctx, bufferSize, FileInput(format, input), splitter.Sink(), nil, nil, &Report{}); err != nil {
+		return nil, fmt.Errorf("failed to split: %w", err)
+	}
+	parts, err := splitter.Parts()
 	if err != nil {
-		return fmt.Errorf("failed to execute pipe: %w", err)
+		return nil, fmt.Errorf("failed to split: %w", err)
 	}
-	return nil
+	return parts, nil
+}
+
+// runPass reads the input from the beginning and runs the line. Every
+// pass overwrites results of the source stages in the report.
+func runPass(ctx context.Context, bufferSize int, input InputFunc, sink pipe.SinkAllocatorFunc, sources []func(pipe.SourceAllocatorFunc, *Report) pipe.SourceAllocatorFunc, processors []pipe.ProcessorAllocatorFunc, report *Report) error {
+	pump, err := input()
+	if err != nil {
+		return err
+	}
+	for _, wrap := range sources {
+		pump = wrap(pump, report)
+	}
+	return pipe.Run(ctx, bufferSize, pipe.Line{
+		Source:     pump,
+		Processors: processors,
+		Sink:       sink,
+	})
 }
@@ -0,0 +1,219 @@
+package encode_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/pipe"
+
+	"pipelined.dev/phono/encode"
+	"pipelined.dev/phono/fileformat"
+	"pipelined.dev/phono/process"
+	"pipelined.dev/phono/userinput"
+)
+
+func TestRunAnalyzers(t *testing.T) {
+	bufferSize := 512
+	// measure returns loudness of wav file.
+	measure := func(t *testing.T, input io.ReadSeeker) process.Loudness {
+		t.Helper()
+		n := process.NewNormalizer(0, 0)
+		_, err := input.Seek(0, io.SeekStart)
+		assert.Nil(t, err)
+		err = pipe.Run(context.Background(), bufferSize, pipe.Line{
+			Source: fileformat.WAV().Source(input),
+			Sink:   n.Sink(),
+		})
+		assert.Nil(t, err)
+		return n.Loudness()
+	}
+
+	testNormalize := func(target, ceiling, integrated, truePeak float64) func(*testing.T) {
+		return func(t *testing.T) {
+			in, err := os.Open(wavSample)
+			assert.Nil(t, err)
+			defer in.Close()
+			out, err := ioutil.TempFile("", "")
+			assert.Nil(t, err)
+			defer os.Remove(out.Name())
+			defer out.Close()
+
+			analyzer, err := userinput.Normalize.Analyzer(target, ceiling)
+			assert.Nil(t, err)
+			sink, err := userinput.WAV.Sink(24)
+			assert.Nil(t, err)
+			_, err = encode.Run(context.Background(), bufferSize, fileformat.WAV(), in, sink(out), encode.Processing{
+				Analyzers: []func() encode.Analyzer{analyzer},
+			})
+			assert.Nil(t, err)
+
+			l := measure(t, out)
+			assert.InDelta(t, integrated, l.Integrated, 0.1)
+			assert.InDelta(t, truePeak, l.TruePeak, 0.1)
+		}
+	}
+	// sample is -18.8 LUFS with +0.1 dBTP.
+	t.Run("target", testNormalize(-20, -1, -20, -1.1))
+	t.Run("ceiling", testNormalize(-10, -1, -19.9, -1))
+}
+
+func TestRunReport(t *testing.T) {
+	testReport := func(gain float64, clipped bool) func(*testing.T) {
+		return func(t *testing.T) {
+			in, err := os.Open(wavSample)
+			assert.Nil(t, err)
+			defer in.Close()
+			out, err := ioutil.TempFile("", "")
+			assert.Nil(t, err)
+			defer os.Remove(out.Name())
+			defer out.Close()
+
+			processor, err := userinput.Gain.Processor(gain)
+			assert.Nil(t, err)
+			sink, err := userinput.WAV.Sink(16)
+			assert.Nil(t, err)
+			report, err := encode.Run(context.Background(), 512, fileformat.WAV(), in, sink(out), encode.Processing{
+				Processors: []pipe.ProcessorAllocatorFunc{processor},
+			})
+			assert.Nil(t, err)
+			if clipped {
+				assert.NotZero(t, report.Overs)
+				assert.Len(t, report.Warnings(), 1)
+			} else {
+				assert.Zero(t, report.Overs)
+				assert.Empty(t, report.Warnings())
+			}
+		}
+	}
+	t.Run("clipped", testReport(12, true))
+	t.Run("not clipped", testReport(-3, false))
+}
+
+func TestRunSilenceReport(t *testing.T) {
+	in, err := os.Open(wavSample)
+	assert.Nil(t, err)
+	defer in.Close()
+	out, err := ioutil.TempFile("", "")
+	assert.Nil(t, err)
+	defer os.Remove(out.Name())
+	defer out.Close()
+
+	stage, err := userinput.Silence.Source(-20, "0.01", "0")
+	assert.Nil(t, err)
+	sink, err := userinput.WAV.Sink(16)
+	assert.Nil(t, err)
+	report, err := encode.Run(context.Background(), 512, fileformat.WAV(), in, sink(out), encode.Processing{
+		Sources: []func(pipe.SourceAllocatorFunc, *encode.Report) pipe.SourceAllocatorFunc{stage},
+	})
+	assert.Nil(t, err)
+	assert.NotNil(t, report.Silence)
+}
+
+func TestSplit(t *testing.T) {
+	in, err := os.Open(wavSample)
+	assert.Nil(t, err)
+	defer in.Close()
+	out, err := ioutil.TempFile("", "")
+	assert.Nil(t, err)
+	defer os.Remove(out.Name())
+	defer out.Close()
+
+	splitter, err := userinput.Split.Chunks("100000smp")
+	assert.Nil(t, err)
+	parts, err := encode.Split(context.Background(), 512, fileformat.WAV(), in, splitter())
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(parts))
+
+	sink, err := userinput.WAV.Sink(16)
+	assert.Nil(t, err)
+	_, err = encode.Run(context.Background(), 512, fileformat.WAV(), in, sink(out), encode.Processing{}.Part(parts[3]))
+	assert.Nil(t, err)
+
+	// the whole output is a single chunk.
+	parts, err = encode.Split(context.Background(), 512, fileformat.WAV(), out, process.NewChunkSplitter(process.AtFrame(1000000)))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(parts))
+	assert.Equal(t, int64(330534-300000), parts[0].End.Frame(44100))
+}
+
+func TestAnalyze(t *testing.T) {
+	in, err := os.Open(wavSample)
+	assert.Nil(t, err)
+	defer in.Close()
+
+	a, err := encode.Analyze(context.Background(), 512, fileformat.WAV(), in)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(330534), a.Frames)
+	assert.Equal(t, 2, len(a.Channels))
+	// sample is -18.8 LUFS with +0.1 dBTP.
+	assert.InDelta(t, -18.8, a.Integrated, 0.1)
+	assert.InDelta(t, 0.1, a.TruePeak, 0.1)
+
+	out, err := ioutil.TempFile("", "")
+	assert.Nil(t, err)
+	defer os.Remove(out.Name())
+	defer out.Close()
+	processor, err := userinput.Gain.Processor(-6)
+	assert.Nil(t, err)
+	sink, err := userinput.WAV.Sink(16)
+	assert.Nil(t, err)
+	report, err := encode.Run(context.Background(), 512, fileformat.WAV(), in, sink(out), encode.Processing{
+		Processors: []pipe.ProcessorAllocatorFunc{processor},
+		Analyze:    true,
+	})
+	assert.Nil(t, err)
+	// encoded signal is analyzed.
+	assert.NotNil(t, report.Analysis)
+	assert.InDelta(t, -24.8, report.Analysis.Integrated, 0.1)
+}
+
+func TestWaveform(t *testing.T) {
+	in, err := os.Open(wavSample)
+	assert.Nil(t, err)
+	defer in.Close()
+
+	w, err := encode.Waveform(context.Background(), 512, fileformat.WAV(), in, 1000)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(330534), w.Frames)
+	assert.Equal(t, 331, len(w.Peaks))
+
+	out, err := ioutil.TempFile("", "")
+	assert.Nil(t, err)
+	defer os.Remove(out.Name())
+	defer out.Close()
+	trim, err := userinput.Trim.Source("", "", "10000smp")
+	assert.Nil(t, err)
+	sink, err := userinput.WAV.Sink(16)
+	assert.Nil(t, err)
+	report, err := encode.Run(context.Background(), 512, fileformat.WAV(), in, sink(out), encode.Processing{
+		Sources:  []func(pipe.SourceAllocatorFunc, *encode.Report) pipe.SourceAllocatorFunc{trim},
+		Waveform: 256,
+	})
+	assert.Nil(t, err)
+	// waveform of encoded signal is measured.
+	assert.NotNil(t, report.Waveform)
+	assert.Equal(t, int64(10000), report.Waveform.Frames)
+	assert.Equal(t, 40, len(report.Waveform.Peaks))
+}
+
+func TestSpectrogram(t *testing.T) {
+	in, err := os.Open(wavSample)
+	assert.Nil(t, err)
+	defer in.Close()
+
+	s, err := encode.Spectrogram(context.Background(), 512, fileformat.WAV(), in, process.SpectrumAnalyzer{
+		Size:       2048,
+		Overlap:    0.5,
+		MaxColumns: 100,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(330534), s.Frames)
+	assert.Equal(t, 2048, s.Size)
+	assert.True(t, len(s.Columns) <= 200)
+	assert.Equal(t, 1025, len(s.Columns[0]))
+}
@@ -0,0 +1,356 @@
+// Package flac provides pipe components that allow to write signal
+// encoded in flac format.
+package flac
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+	"github.com/mewkiz/flac/meta"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+// blockSize is the number of samples per channel in a single frame.
+const blockSize = 4096
+
+// maxChannels is the maximum number of channels supported by flac.
+const maxChannels = 8
+
+// CompressionLevel determines how hard encoder tries to compress the
+// signal. Higher levels produce smaller files, but take more time to
+// encode. Values: [0..8]
+type CompressionLevel int
+
+const (
+	// MinCompressionLevel stores samples without prediction.
+	MinCompressionLevel CompressionLevel = 0
+	// MaxCompressionLevel uses the widest search of prediction parameters.
+	MaxCompressionLevel CompressionLevel = 8
+	// DefaultCompressionLevel is a trade-off between speed and size.
+	DefaultCompressionLevel CompressionLevel = 5
+)
+
+// predictionSearch defines the range of parameters that encoder searches
+// through to find the smallest subframe.
+type predictionSearch struct {
+	verbatim     bool
+	maxOrder     int
+	maxPartOrder int
+}
+
+var searchByLevel = [...]predictionSearch{
+	0: {verbatim: true},
+	1: {maxOrder: 1, maxPartOrder: 0},
+	2: {maxOrder: 2, maxPartOrder: 2},
+	3: {maxOrder: 2, maxPartOrder: 3},
+	4: {maxOrder: 3, maxPartOrder: 3},
+	5: {maxOrder: 4, maxPartOrder: 4},
+	6: {maxOrder: 4, maxPartOrder: 5},
+	7: {maxOrder: 4, maxPartOrder: 6},
+	8: {maxOrder: 4, maxPartOrder: 8},
+}
+
+// Sink writes flac data to WriteSeeker. BitDepth is output bit depth.
+// Supported values: 8, 16 and 24.
+func Sink(ws io.WriteSeeker, bitDepth signal.BitDepth, level CompressionLevel) pipe.SinkAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Sink, error) {
+		if props.Channels < 1 || props.Channels > maxChannels {
+			return pipe.Sink{}, fmt.Errorf("FLAC doesn't support %d channels", props.Channels)
+		}
+		if level < MinCompressionLevel || level > MaxCompressionLevel {
+			return pipe.Sink{}, fmt.Errorf("FLAC doesn't support compression level %d", level)
+		}
+		// encoder closes writer if it implements io.Closer, so hide it.
+		encoder, err := flac.NewEncoder(
+			struct{ io.WriteSeeker }{ws},
+			&meta.StreamInfo{
+				BlockSizeMin:  blockSize,
+				BlockSizeMax:  blockSize,
+				SampleRate:    uint32(props.SampleRate),
+				NChannels:     uint8(props.Channels),
+				BitsPerSample: uint8(bitDepth),
+			},
+		)
+		if err != nil {
+			return pipe.Sink{}, fmt.Errorf("error creating FLAC encoder: %w", err)
+		}
+		ints := signal.Allocator{
+			Channels: props.Channels,
+			Capacity: bufferSize,
+			Length:   bufferSize,
+		}.Int32(bitDepth)
+		fw := newFrameWriter(encoder, props, bitDepth, searchByLevel[level])
+		return pipe.Sink{
+			SinkFunc:  sink(fw, ints),
+			FlushFunc: encoderFlusher(fw),
+		}, nil
+	}
+}
+
+func sink(fw *frameWriter, ints signal.Signed) pipe.SinkFunc {
+	return func(floats signal.Floating) error {
+		if n := signal.FloatingAsSigned(floats, ints); n != ints.Length() {
+			ints = ints.Slice(0, n)
+			// defer because it must be done after write
+			defer func() {
+				ints = ints.Slice(0, ints.Capacity())
+			}()
+		}
+		if err := fw.write(ints); err != nil {
+			return fmt.Errorf("error writing FLAC frame: %w", err)
+		}
+		return nil
+	}
+}
+
+func encoderFlusher(fw *frameWriter) pipe.FlushFunc {
+	return func(context.Context) error {
+		if err := fw.flush(); err != nil {
+			return fmt.Errorf("error writing FLAC frame: %w", err)
+		}
+		if err := fw.encoder.Close(); err != nil {
+			return fmt.Errorf("error flushing FLAC encoder: %w", err)
+		}
+		return nil
+	}
+}
+
+// frameWriter accumulates samples until the block is full and encodes
+// them as a single frame.
+type frameWriter struct {
+	encoder *flac.Encoder
+	frame   frame.Frame
+	search  predictionSearch
+	// buffered samples per channel.
+	samples [][]int32
+}
+
+func newFrameWriter(encoder *flac.Encoder, props pipe.SignalProperties, bitDepth signal.BitDepth, search predictionSearch) *frameWriter {
+	samples := make([][]int32, props.Channels)
+	subframes := make([]*frame.Subframe, props.Channels)
+	for i := range samples {
+		samples[i] = make([]int32, 0, blockSize)
+		subframes[i] = &frame.Subframe{}
+	}
+	return &frameWriter{
+		encoder: encoder,
+		search:  search,
+		samples: samples,
+		frame: frame.Frame{
+			Header: frame.Header{
+				HasFixedBlockSize: true,
+				SampleRate:        uint32(props.SampleRate),
+				Channels:          frame.Channels(props.Channels - 1),
+				BitsPerSample:     uint8(bitDepth),
+			},
+			Subframes: subframes,
+		},
+	}
+}
+
+// write appends interleaved samples to the block and encodes it each
+// time it's full.
+func (fw *frameWriter) write(ints signal.Signed) error {
+	channels := ints.Channels()
+	for i := 0; i < ints.Length(); i++ {
+		for c := 0; c < channels; c++ {
+			fw.samples[c] = append(fw.samples[c], int32(ints.Sample(i*channels+c)))
+		}
+		if len(fw.samples[0]) == blockSize {
+			if err := fw.flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// flush encodes buffered samples as a frame.
+func (fw *frameWriter) flush() error {
+	n := len(fw.samples[0])
+	if n == 0 {
+		return nil
+	}
+	fw.frame.BlockSize = uint16(n)
+	for c := range fw.samples {
+		fw.frame.Subframes[c] = fw.search.subframe(fw.samples[c], uint(fw.frame.BitsPerSample))
+	}
+	if err := fw.encoder.WriteFrame(&fw.frame); err != nil {
+		return err
+	}
+	// frame is already encoded, buffers can be reused.
+	for c := range fw.samples {
+		fw.samples[c] = fw.samples[c][:0]
+	}
+	return nil
+}
+
+// subframe returns the smallest subframe found within search range.
+func (s predictionSearch) subframe(samples []int32, bps uint) *frame.Subframe {
+	n := len(samples)
+	if isConstant(samples) {
+		return &frame.Subframe{
+			SubHeader: frame.SubHeader{Pred: frame.PredConstant},
+			Samples:   samples,
+			NSamples:  n,
+		}
+	}
+	verbatim := &frame.Subframe{
+		SubHeader: frame.SubHeader{Pred: frame.PredVerbatim},
+		Samples:   samples,
+		NSamples:  n,
+	}
+	if s.verbatim {
+		return verbatim
+	}
+
+	best, bestBits := verbatim, n*int(bps)
+	for order := 0; order <= s.maxOrder && order < n; order++ {
+		residuals := fixedResiduals(samples, order)
+		rice, method, bits := riceSubframe(residuals, n, order, s.maxPartOrder)
+		bits += order * int(bps)
+		if bits < bestBits {
+			bestBits = bits
+			best = &frame.Subframe{
+				SubHeader: frame.SubHeader{
+					Pred:                 frame.PredFixed,
+					Order:                order,
+					ResidualCodingMethod: method,
+					RiceSubframe:         rice,
+				},
+				Samples:  samples,
+				NSamples: n,
+			}
+		}
+	}
+	return best
+}
+
+func isConstant(samples []int32) bool {
+	for i := 1; i < len(samples); i++ {
+		if samples[i] != samples[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// fixedResiduals returns prediction errors of fixed polynomial predictor
+// of provided order. Warm-up samples are not included.
+func fixedResiduals(samples []int32, order int) []int64 {
+	coeffs := frame.FixedCoeffs[order]
+	residuals := make([]int64, 0, len(samples)-order)
+	for i := order; i < len(samples); i++ {
+		var prediction int64
+		for j, c := range coeffs {
+			prediction += int64(c) * int64(samples[i-j-1])
+		}
+		residuals = append(residuals, int64(samples[i])-prediction)
+	}
+	return residuals
+}
+
+const (
+	// maximum rice parameters for each coding method, bigger values are
+	// reserved for escape codes.
+	maxRice1Param = 14
+	maxRice2Param = 30
+)
+
+// riceSubframe finds the partition order and rice parameters that
+// produce the smallest estimated size of encoded residuals.
+func riceSubframe(residuals []int64, n, order, maxPartOrder int) (*frame.RiceSubframe, frame.ResidualCodingMethod, int) {
+	var (
+		best     []frame.RicePartition
+		bestBits = -1
+		bestPart int
+	)
+	for partOrder := 0; partOrder <= maxPartOrder; partOrder++ {
+		nparts := 1 << uint(partOrder)
+		// partitions must be of equal size and first partition must hold
+		// warm-up samples.
+		if n%nparts != 0 || n/nparts < order {
+			break
+		}
+		partitions := make([]frame.RicePartition, nparts)
+		bits, start := 0, 0
+		for i := range partitions {
+			size := n / nparts
+			if i == 0 {
+				size -= order
+			}
+			param, partBits := riceParam(residuals[start : start+size])
+			partitions[i].Param = param
+			// parameter itself takes at least 4 bits.
+			bits += partBits + 4
+			start += size
+		}
+		if bestBits < 0 || bits < bestBits {
+			best, bestBits, bestPart = partitions, bits, partOrder
+		}
+	}
+
+	method, paramSize := frame.ResidualCodingMethodRice1, 4
+	for _, p := range best {
+		if p.Param > maxRice1Param {
+			method, paramSize = frame.ResidualCodingMethodRice2, 5
+			break
+		}
+	}
+	// 2 bits for method and 4 bits for partition order.
+	bits := 6 + bestBits + len(best)*(paramSize-4)
+	return &frame.RiceSubframe{
+		PartOrder:  bestPart,
+		Partitions: best,
+	}, method, bits
+}
+
+// riceParam estimates the optimal rice parameter from the mean of
+// zigzag-encoded residuals and refines it with neighbouring values. Size
+// of encoded residuals in bits is returned as well.
+func riceParam(residuals []int64) (uint, int) {
+	if len(residuals) == 0 {
+		return 0, 0
+	}
+	var sum uint64
+	for _, r := range residuals {
+		sum += zigZag(r)
+	}
+	var estimate uint
+	for mean := sum / uint64(len(residuals)); mean > 1 && estimate < maxRice2Param; mean >>= 1 {
+		estimate++
+	}
+
+	bestParam, bestBits := estimate, riceBits(residuals, estimate)
+	for _, param := range []uint{estimate - 1, estimate + 1} {
+		if param > maxRice2Param {
+			continue
+		}
+		if bits := riceBits(residuals, param); bits < bestBits {
+			bestParam, bestBits = param, bits
+		}
+	}
+	return bestParam, bestBits
+}
+
+// riceBits returns the size of residuals encoded with rice parameter.
+func riceBits(residuals []int64, param uint) int {
+	bits := len(residuals) * (int(param) + 1)
+	for _, r := range residuals {
+		bits += int(zigZag(r) >> param)
+	}
+	return bits
+}
+
+func zigZag(v int64) uint64 {
+	if v < 0 {
+		return uint64(-v)*2 - 1
+	}
+	return uint64(v) * 2
+}
@@ -0,0 +1,96 @@
+package flac_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/audio/flac"
+	"pipelined.dev/audio/wav"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+
+	phonoflac "pipelined.dev/phono/flac"
+)
+
+const (
+	bufferSize = 512
+	wavSample  = "../_testdata/sample.wav"
+)
+
+// samplesSink collects all samples of the signal.
+func samplesSink(samples *[]float64) pipe.SinkAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Sink, error) {
+		return pipe.Sink{
+			SinkFunc: func(in signal.Floating) error {
+				for i := 0; i < in.Len(); i++ {
+					*samples = append(*samples, in.Sample(i))
+				}
+				return nil
+			},
+		}, nil
+	}
+}
+
+func TestSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phono-flac")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	var expected []float64
+	in, err := os.Open(wavSample)
+	assert.Nil(t, err)
+	defer in.Close()
+	err = pipe.Run(context.Background(), bufferSize, pipe.Line{
+		Source: wav.Source(in),
+		Sink:   samplesSink(&expected),
+	})
+	assert.Nil(t, err)
+
+	testLevel := func(level phonoflac.CompressionLevel) func(*testing.T) {
+		return func(t *testing.T) {
+			_, err := in.Seek(0, 0)
+			assert.Nil(t, err)
+			out, err := os.Create(filepath.Join(dir, "out.flac"))
+			assert.Nil(t, err)
+			defer out.Close()
+
+			err = pipe.Run(context.Background(), bufferSize, pipe.Line{
+				Source: wav.Source(in),
+				Sink:   phonoflac.Sink(out, signal.BitDepth16, level),
+			})
+			assert.Nil(t, err)
+
+			_, err = out.Seek(0, 0)
+			assert.Nil(t, err)
+			var result []float64
+			err = pipe.Run(context.Background(), bufferSize, pipe.Line{
+				Source: flac.Source(out),
+				Sink:   samplesSink(&result),
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, len(expected), len(result))
+			assert.Equal(t, expected, result)
+		}
+	}
+	t.Run("min level", testLevel(phonoflac.MinCompressionLevel))
+	t.Run("default level", testLevel(phonoflac.DefaultCompressionLevel))
+	t.Run("max level", testLevel(phonoflac.MaxCompressionLevel))
+	t.Run("invalid level", func(t *testing.T) {
+		_, err := in.Seek(0, 0)
+		assert.Nil(t, err)
+		out, err := os.Create(filepath.Join(dir, "invalid.flac"))
+		assert.Nil(t, err)
+		defer out.Close()
+		err = pipe.Run(context.Background(), bufferSize, pipe.Line{
+			Source: wav.Source(in),
+			Sink:   phonoflac.Sink(out, signal.BitDepth16, phonoflac.MaxCompressionLevel+1),
+		})
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,95 @@
+// Package info provides properties of audio files.
+package info
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/mewkiz/flac"
+
+	"pipelined.dev/phono/aiff"
+	"pipelined.dev/phono/fileformat"
+)
+
+// ErrFormat is returned when properties of the format can't be read.
+var ErrFormat = errors.New("unsupported format")
+
+// Info contains properties of the audio file.
+type Info struct {
+	Format     string `json:"format"`
+	SampleRate int    `json:"sampleRate"`
+	Channels   int    `json:"channels"`
+	// BitDepth is not defined for lossy formats.
+	BitDepth int `json:"bitDepth,omitempty"`
+	// Frames is the number of samples per channel.
+	Frames int64 `json:"frames"`
+	// Duration in seconds.
+	Duration float64 `json:"duration"`
+	// BitRateMode is defined for mp3 only.
+	BitRateMode string `json:"bitRateMode,omitempty"`
+	// BitRate is an average bit rate in kbps, defined for mp3 only.
+	BitRate int `json:"bitRate,omitempty"`
+}
+
+// Read returns properties of audio data encoded in provided format.
+func Read(format *fileformat.Format, rs io.ReadSeeker) (Info, error) {
+	var (
+		info Info
+		err  error
+	)
+	switch format {
+	case fileformat.WAV():
+		info, err = readWAV(rs)
+	case fileformat.MP3():
+		info, err = readMP3(rs)
+	case fileformat.FLAC():
+		info, err = readFLAC(rs)
+	case fileformat.AIFF():
+		info, err = readAIFF(rs)
+	default:
+		return Info{}, ErrFormat
+	}
+	if err != nil {
+		return Info{}, err
+	}
+	info.Format = strings.TrimPrefix(format.DefaultExtension(), ".")
+	if info.SampleRate > 0 {
+		info.Duration = float64(info.Frames) / float64(info.SampleRate)
+	}
+	return info, nil
+}
+
+// DurationTime returns duration as time.Duration value.
+func (i Info) DurationTime() time.Duration {
+	return time.Duration(i.Duration * float64(time.Second))
+}
+
+func readFLAC(r io.Reader) (Info, error) {
+	// hide closer, so the stream doesn't close the reader.
+	stream, err := flac.New(struct{ io.Reader }{r})
+	if err != nil {
+		return Info{}, fmt.Errorf("error reading FLAC stream info: %w", err)
+	}
+	return Info{
+		SampleRate: int(stream.Info.SampleRate),
+		Channels:   int(stream.Info.NChannels),
+		BitDepth:   int(stream.Info.BitsPerSample),
+		Frames:     int64(stream.Info.NSamples),
+	}, nil
+}
+
+func readAIFF(rs io.ReadSeeker) (Info, error) {
+	h, err := aiff.ReadHeader(rs)
+	if err != nil {
+		return Info{}, fmt.Errorf("error reading AIFF header: %w", err)
+	}
+	return Info{
+		SampleRate: h.SampleRate,
+		Channels:   h.Channels,
+		BitDepth:   h.BitDepth,
+		Frames:     h.Frames,
+	}, nil
+}
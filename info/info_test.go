@@ -0,0 +1,186 @@
+package info_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/audio/wav"
+	"pipelined.dev/pipe"
+	"pipelined.dev/signal"
+
+	"pipelined.dev/phono/aiff"
+	"pipelined.dev/phono/fileformat"
+	"pipelined.dev/phono/flac"
+	"pipelined.dev/phono/info"
+)
+
+const wavSample = "../_testdata/sample.wav"
+
+func TestWAV(t *testing.T) {
+	f, err := os.Open(wavSample)
+	assert.Nil(t, err)
+	defer f.Close()
+
+	i, err := info.Read(fileformat.WAV(), f)
+	assert.Nil(t, err)
+	assert.Equal(t, "wav", i.Format)
+	assert.Equal(t, 44100, i.SampleRate)
+	assert.Equal(t, 2, i.Channels)
+	assert.Equal(t, 16, i.BitDepth)
+	assert.Equal(t, int64(330534), i.Frames)
+	assert.InDelta(t, 7.495, i.Duration, 0.001)
+	assert.Equal(t, "", i.BitRateMode)
+}
+
+func TestEmptyWAV(t *testing.T) {
+	// header of 16-bit stereo wav without samples.
+	var b bytes.Buffer
+	b.WriteString("RIFF")
+	binary.Write(&b, binary.LittleEndian, uint32(36))
+	b.WriteString("WAVEfmt ")
+	binary.Write(&b, binary.LittleEndian, []uint32{16, 0x00020001, 44100, 44100 * 4, 0x00100004})
+	b.WriteString("data")
+	binary.Write(&b, binary.LittleEndian, uint32(0))
+
+	i, err := info.Read(fileformat.WAV(), bytes.NewReader(b.Bytes()))
+	assert.Nil(t, err)
+	assert.Equal(t, 44100, i.SampleRate)
+	assert.Equal(t, 2, i.Channels)
+	assert.Equal(t, 16, i.BitDepth)
+	assert.Equal(t, int64(0), i.Frames)
+	assert.Equal(t, 0.0, i.Duration)
+}
+
+func TestFLAC(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phono-info")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	in, err := os.Open(wavSample)
+	assert.Nil(t, err)
+	defer in.Close()
+	out, err := os.Create(filepath.Join(dir, "sample.flac"))
+	assert.Nil(t, err)
+	defer out.Close()
+	err = pipe.Run(context.Background(), 512, pipe.Line{
+		Source: wav.Source(in),
+		Sink:   flac.Sink(out, signal.BitDepth24, flac.DefaultCompressionLevel),
+	})
+	assert.Nil(t, err)
+	_, err = out.Seek(0, 0)
+	assert.Nil(t, err)
+
+	i, err := info.Read(fileformat.FLAC(), out)
+	assert.Nil(t, err)
+	assert.Equal(t, "flac", i.Format)
+	assert.Equal(t, 44100, i.SampleRate)
+	assert.Equal(t, 2, i.Channels)
+	assert.Equal(t, 24, i.BitDepth)
+	assert.Equal(t, int64(330534), i.Frames)
+}
+
+func TestAIFF(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phono-info")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	in, err := os.Open(wavSample)
+	assert.Nil(t, err)
+	defer in.Close()
+	out, err := os.Create(filepath.Join(dir, "sample.aiff"))
+	assert.Nil(t, err)
+	defer out.Close()
+	err = pipe.Run(context.Background(), 512, pipe.Line{
+		Source: wav.Source(in),
+		Sink:   aiff.Sink(out, signal.BitDepth24),
+	})
+	assert.Nil(t, err)
+
+	i, err := info.Read(fileformat.AIFF(), out)
+	assert.Nil(t, err)
+	assert.Equal(t, "aiff", i.Format)
+	assert.Equal(t, 44100, i.SampleRate)
+	assert.Equal(t, 2, i.Channels)
+	assert.Equal(t, 24, i.BitDepth)
+	assert.Equal(t, int64(330534), i.Frames)
+	assert.InDelta(t, 7.495, i.Duration, 0.001)
+}
+
+// mp3Frame returns mpeg 1 layer III stereo frame with 44100 sample rate.
+func mp3Frame(bitRateIdx byte, bitRate int, payload []byte) []byte {
+	frame := make([]byte, 144*bitRate*1000/44100)
+	copy(frame, []byte{0xFF, 0xFB, bitRateIdx << 4, 0x00})
+	copy(frame[4+32:], payload)
+	return frame
+}
+
+// lameHeader returns Info header with LAME extension.
+func lameHeader(method byte) []byte {
+	var b bytes.Buffer
+	b.WriteString("Info")
+	binary.Write(&b, binary.BigEndian, uint32(1))
+	binary.Write(&b, binary.BigEndian, uint32(3))
+	b.WriteString("LAME3.100")
+	b.WriteByte(method)
+	return b.Bytes()
+}
+
+func TestMP3(t *testing.T) {
+	testMP3 := func(expectedMode string, expectedBitRate int, frames ...[]byte) func(*testing.T) {
+		return func(t *testing.T) {
+			// ID3v2 tag of 5 bytes and some garbage
+			data := []byte{'I', 'D', '3', 3, 0, 0, 0, 0, 0, 5, 1, 2, 3, 4, 5, 0, 0}
+			for _, f := range frames {
+				data = append(data, f...)
+			}
+			i, err := info.Read(fileformat.MP3(), bytes.NewReader(data))
+			assert.Nil(t, err)
+			assert.Equal(t, "mp3", i.Format)
+			assert.Equal(t, 44100, i.SampleRate)
+			assert.Equal(t, 2, i.Channels)
+			assert.Equal(t, 0, i.BitDepth)
+			assert.Equal(t, int64(3*1152), i.Frames)
+			assert.Equal(t, expectedMode, i.BitRateMode)
+			assert.Equal(t, expectedBitRate, i.BitRate)
+		}
+	}
+	t.Run("cbr",
+		testMP3(info.CBR, 128,
+			mp3Frame(9, 128, nil),
+			mp3Frame(9, 128, nil),
+			mp3Frame(9, 128, nil),
+		),
+	)
+	t.Run("vbr",
+		testMP3(info.VBR, 160,
+			mp3Frame(9, 128, nil),
+			mp3Frame(11, 192, nil),
+			mp3Frame(10, 160, nil),
+		),
+	)
+	t.Run("abr lame",
+		testMP3(info.ABR, 128,
+			mp3Frame(9, 128, lameHeader(2)),
+			mp3Frame(9, 128, nil),
+			mp3Frame(9, 128, nil),
+			mp3Frame(9, 128, nil),
+		),
+	)
+	t.Run("xing",
+		testMP3(info.VBR, 128,
+			mp3Frame(9, 128, []byte("Xing\x00\x00\x00\x00")),
+			mp3Frame(9, 128, nil),
+			mp3Frame(9, 128, nil),
+			mp3Frame(9, 128, nil),
+		),
+	)
+	t.Run("not mp3", func(t *testing.T) {
+		_, err := info.Read(fileformat.MP3(), bytes.NewReader([]byte("not mp3 data")))
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,218 @@
+package info
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Bit rate modes of mp3 files.
+const (
+	CBR = "CBR"
+	ABR = "ABR"
+	VBR = "VBR"
+)
+
+var (
+	// bit rates in kbps of layer III, indexed by header value.
+	mpeg1BitRates = [...]int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320}
+	mpeg2BitRates = [...]int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160}
+	// sample rates of mpeg 1, other versions divide them.
+	mpeg1SampleRates = [...]int{44100, 48000, 32000}
+)
+
+// mpeg versions as encoded in frame header.
+const (
+	mpeg25 = 0
+	mpeg2  = 2
+	mpeg1  = 3
+)
+
+// mp3Header contains parsed frame header.
+type mp3Header struct {
+	version    int
+	protected  bool
+	bitRate    int
+	sampleRate int
+	channels   int
+	// length of the frame in bytes, including header.
+	length int
+	// number of samples per channel in the frame.
+	samples int
+}
+
+// parseMP3Header parses first 4 bytes of layer III frame. Returns false
+// if bytes are not a valid header.
+func parseMP3Header(b []byte) (mp3Header, bool) {
+	// 11 bits of sync word and layer III.
+	if b[0] != 0xFF || b[1]&0xE0 != 0xE0 || (b[1]>>1)&0x3 != 1 {
+		return mp3Header{}, false
+	}
+	h := mp3Header{
+		version:   int(b[1]>>3) & 0x3,
+		protected: b[1]&0x1 == 0,
+	}
+	bitRateIdx := int(b[2] >> 4)
+	sampleRateIdx := int(b[2]>>2) & 0x3
+	// free format and reserved values are not supported.
+	if h.version == 1 || bitRateIdx == 0 || bitRateIdx == 0xF || sampleRateIdx == 3 {
+		return mp3Header{}, false
+	}
+	padding := int(b[2]>>1) & 0x1
+	h.sampleRate = mpeg1SampleRates[sampleRateIdx]
+	h.channels = 2
+	if b[3]>>6 == 3 {
+		h.channels = 1
+	}
+	switch h.version {
+	case mpeg1:
+		h.bitRate = mpeg1BitRates[bitRateIdx]
+		h.samples = 1152
+		h.length = 144*h.bitRate*1000/h.sampleRate + padding
+	case mpeg2:
+		h.sampleRate /= 2
+	case mpeg25:
+		h.sampleRate /= 4
+	}
+	if h.version != mpeg1 {
+		h.bitRate = mpeg2BitRates[bitRateIdx]
+		h.samples = 576
+		h.length = 72*h.bitRate*1000/h.sampleRate + padding
+	}
+	return h, true
+}
+
+// readMP3 scans all frame headers of mp3 stream.
+func readMP3(r io.Reader) (Info, error) {
+	br := bufio.NewReader(r)
+	if err := skipID3v2(br); err != nil {
+		return Info{}, fmt.Errorf("error reading ID3 tag: %w", err)
+	}
+	var (
+		info        Info
+		first       *mp3Header
+		frames      int
+		bitRateSum  int
+		variable    bool
+		bitRateMode string
+	)
+	for {
+		b, err := br.Peek(4)
+		if err != nil {
+			break
+		}
+		h, ok := parseMP3Header(b)
+		if !ok {
+			// ID3v1 tag at the end of file.
+			if string(b[:3]) == "TAG" {
+				break
+			}
+			// skip garbage until next frame.
+			br.Discard(1)
+			continue
+		}
+		if first == nil {
+			first = &h
+			// first frame might contain vbr header instead of audio.
+			frame, _ := br.Peek(h.length)
+			if mode, ok := vbrHeader(frame, h); ok {
+				bitRateMode = mode
+				br.Discard(h.length)
+				continue
+			}
+		}
+		frames++
+		bitRateSum += h.bitRate
+		if h.bitRate != first.bitRate {
+			variable = true
+		}
+		info.Frames += int64(h.samples)
+		if _, err := br.Discard(h.length); err != nil {
+			break
+		}
+	}
+	if frames == 0 {
+		return Info{}, errors.New("MP3 frames not found")
+	}
+	if bitRateMode == "" {
+		bitRateMode = CBR
+		if variable {
+			bitRateMode = VBR
+		}
+	}
+	info.SampleRate = first.sampleRate
+	info.Channels = first.channels
+	info.BitRateMode = bitRateMode
+	info.BitRate = bitRateSum / frames
+	return info, nil
+}
+
+// skipID3v2 skips ID3v2 tag if it's present.
+func skipID3v2(br *bufio.Reader) error {
+	b, err := br.Peek(10)
+	if err != nil || string(b[:3]) != "ID3" {
+		return nil
+	}
+	// size is a syncsafe integer.
+	size := int(b[6])<<21 | int(b[7])<<14 | int(b[8])<<7 | int(b[9])
+	size += 10
+	// footer is present.
+	if b[5]&0x10 != 0 {
+		size += 10
+	}
+	_, err = br.Discard(size)
+	return err
+}
+
+// vbrHeader checks if the frame contains Xing, Info or VBRI header and
+// returns bit rate mode. LAME extension is used to detect ABR mode.
+func vbrHeader(frame []byte, h mp3Header) (string, bool) {
+	// side information size
+	side := 32
+	switch {
+	case h.version == mpeg1 && h.channels == 1:
+		side = 17
+	case h.version != mpeg1 && h.channels == 1:
+		side = 9
+	case h.version != mpeg1:
+		side = 17
+	}
+	pos := 4 + side
+	if h.protected {
+		pos += 2
+	}
+	if len(frame) >= 4+32+4 && string(frame[4+32:4+32+4]) == "VBRI" {
+		return VBR, true
+	}
+	if len(frame) < pos+8 {
+		return "", false
+	}
+	tag := string(frame[pos : pos+4])
+	if tag != "Xing" && tag != "Info" {
+		return "", false
+	}
+	flags := binary.BigEndian.Uint32(frame[pos+4:])
+	lame := pos + 8
+	// frames, bytes, toc and quality fields.
+	for flag, size := range map[uint32]int{1: 4, 2: 4, 4: 100, 8: 4} {
+		if flags&flag != 0 {
+			lame += size
+		}
+	}
+	if len(frame) >= lame+10 && string(frame[lame:lame+4]) == "LAME" {
+		switch frame[lame+9] & 0xF {
+		case 1, 8:
+			return CBR, true
+		case 2, 9:
+			return ABR, true
+		case 3, 4, 5, 6:
+			return VBR, true
+		}
+	}
+	if tag == "Info" {
+		return CBR, true
+	}
+	return VBR, true
+}
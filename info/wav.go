@@ -0,0 +1,78 @@
+package info
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// wavHeaderSize is the size of RIFF chunk header and form type.
+const wavHeaderSize = 12
+
+// readWAV returns properties from fmt chunk and the size of data chunk.
+// Files without samples are valid.
+func readWAV(rs io.ReadSeeker) (Info, error) {
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return Info{}, err
+	}
+	var header [wavHeaderSize]byte
+	if _, err := io.ReadFull(rs, header[:]); err != nil {
+		return Info{}, fmt.Errorf("error reading WAV header: %w", err)
+	}
+	if string(header[:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return Info{}, errors.New("invalid WAV")
+	}
+	end, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return Info{}, err
+	}
+	var (
+		info      Info
+		fmtChunk  bool
+		blockSize int64
+		dataSize  int64
+	)
+	for offset := int64(wavHeaderSize); offset+8 <= end; {
+		if _, err := rs.Seek(offset, io.SeekStart); err != nil {
+			return Info{}, err
+		}
+		var b [8]byte
+		if _, err := io.ReadFull(rs, b[:]); err != nil {
+			return Info{}, fmt.Errorf("error reading WAV chunk: %w", err)
+		}
+		size := int64(binary.LittleEndian.Uint32(b[4:8]))
+		// size of data chunk isn't updated by some streaming encoders.
+		if offset+8+size > end {
+			size = end - offset - 8
+		}
+		switch string(b[:4]) {
+		case "fmt ":
+			if size < 16 {
+				return Info{}, errors.New("invalid fmt chunk")
+			}
+			var f [16]byte
+			if _, err := io.ReadFull(rs, f[:]); err != nil {
+				return Info{}, fmt.Errorf("error reading fmt chunk: %w", err)
+			}
+			info.Channels = int(binary.LittleEndian.Uint16(f[2:4]))
+			info.SampleRate = int(binary.LittleEndian.Uint32(f[4:8]))
+			blockSize = int64(binary.LittleEndian.Uint16(f[12:14]))
+			info.BitDepth = int(binary.LittleEndian.Uint16(f[14:16]))
+			fmtChunk = true
+		case "data":
+			dataSize = size
+		}
+		offset += 8 + size + size%2
+	}
+	if !fmtChunk {
+		return Info{}, errors.New("missing fmt chunk")
+	}
+	if blockSize == 0 {
+		blockSize = int64(info.Channels * ((info.BitDepth + 7) / 8))
+	}
+	if blockSize > 0 {
+		info.Frames = dataSize / blockSize
+	}
+	return info, nil
+}
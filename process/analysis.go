@@ -0,0 +1,153 @@
+package process
+
+import (
+	"math"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+// fullScale is the level of full scale samples. Decoded integer samples
+// never exceed it, so samples that reach it are counted as clipped.
+// Processing can push samples above it, such samples are overs.
+const fullScale = 1.0
+
+type (
+	// Analysis contains quality metrics of the signal. Levels are in dB
+	// relative to full scale, negative infinity means silence.
+	Analysis struct {
+		SampleRate signal.Frequency
+		Frames     int64
+		Channels   []ChannelAnalysis
+		Loudness
+	}
+
+	// ChannelAnalysis contains metrics of a single channel.
+	ChannelAnalysis struct {
+		// Peak is the maximum absolute sample value in dBFS.
+		Peak float64
+		// RMS level in dBFS.
+		RMS float64
+		// DCOffset is the mean sample value.
+		DCOffset float64
+		// Crest factor is the ratio of peak and RMS levels in dB.
+		Crest float64
+		// Clipped is the number of samples at or above full scale.
+		Clipped int
+	}
+
+	// Meter measures quality metrics of the signal.
+	Meter struct {
+		loudness   *LoudnessMeter
+		sampleRate signal.Frequency
+		frames     int64
+		peak       []float64
+		sum        []float64
+		sumSquares []float64
+		clipped    []int
+	}
+)
+
+// Sink returns the sink that measures the signal.
+func (m *Meter) Sink() pipe.SinkAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Sink, error) {
+		m.reset(props)
+		return pipe.Sink{
+			SinkFunc: func(in signal.Floating) error {
+				m.write(in)
+				return nil
+			},
+		}, nil
+	}
+}
+
+// Processor returns the processor that passes the signal through and
+// measures it.
+func (m *Meter) Processor() pipe.ProcessorAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Processor, error) {
+		m.reset(props)
+		return pipe.Processor{
+			SignalProperties: props,
+			ProcessFunc: func(in, out signal.Floating) (int, error) {
+				m.write(in)
+				for i := 0; i < in.Len(); i++ {
+					out.SetSample(i, in.Sample(i))
+				}
+				return in.Length(), nil
+			},
+		}, nil
+	}
+}
+
+func (m *Meter) reset(props pipe.SignalProperties) {
+	m.loudness = NewLoudnessMeter(props.SampleRate, props.Channels)
+	m.sampleRate = props.SampleRate
+	m.frames = 0
+	m.peak = make([]float64, props.Channels)
+	m.sum = make([]float64, props.Channels)
+	m.sumSquares = make([]float64, props.Channels)
+	m.clipped = make([]int, props.Channels)
+}
+
+func (m *Meter) write(in signal.Floating) {
+	m.loudness.Write(in)
+	channels := len(m.peak)
+	for i := 0; i < in.Len(); i++ {
+		c, v := i%channels, in.Sample(i)
+		m.peak[c] = math.Max(m.peak[c], math.Abs(v))
+		m.sum[c] += v
+		m.sumSquares[c] += v * v
+		if math.Abs(v) >= fullScale {
+			m.clipped[c]++
+		}
+	}
+	m.frames += int64(in.Length())
+}
+
+// Analysis returns the metrics of the measured signal.
+func (m *Meter) Analysis() Analysis {
+	if m.loudness == nil {
+		return Analysis{
+			Loudness: Loudness{
+				Integrated: math.Inf(-1),
+				TruePeak:   math.Inf(-1),
+			},
+		}
+	}
+	a := Analysis{
+		SampleRate: m.sampleRate,
+		Frames:     m.frames,
+		Channels:   make([]ChannelAnalysis, len(m.peak)),
+		Loudness: Loudness{
+			Integrated: m.loudness.Integrated(),
+			TruePeak:   m.loudness.TruePeak(),
+		},
+	}
+	for c := range a.Channels {
+		ca := ChannelAnalysis{
+			Peak:    20 * math.Log10(m.peak[c]),
+			RMS:     math.Inf(-1),
+			Clipped: m.clipped[c],
+		}
+		if m.frames > 0 {
+			ca.DCOffset = m.sum[c] / float64(m.frames)
+			ca.RMS = 10 * math.Log10(m.sumSquares[c]/float64(m.frames))
+		}
+		// crest factor of silence is not defined.
+		if m.peak[c] > 0 {
+			ca.Crest = ca.Peak - ca.RMS
+		}
+		a.Channels[c] = ca
+	}
+	return a
+}
+
+// Clipped returns the total number of clipped samples.
+func (a Analysis) Clipped() int {
+	var clipped int
+	for _, c := range a.Channels {
+		clipped += c.Clipped
+	}
+	return clipped
+}
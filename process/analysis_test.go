@@ -0,0 +1,93 @@
+package process_test
+
+import (
+	"context"
+	"io/ioutil"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/audio/wav"
+	"pipelined.dev/pipe"
+	"pipelined.dev/signal"
+
+	"pipelined.dev/phono/process"
+)
+
+// measureWAV encodes the signal to wav of provided bit depth and measures
+// decoded signal. First channel is a sine with dc offset, second one is a
+// full scale square that clips, third one is silent.
+func measureWAV(t *testing.T, bitDepth signal.BitDepth) process.Analysis {
+	t.Helper()
+	f, err := ioutil.TempFile("", "phono-analysis")
+	assert.Nil(t, err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+	err = pipe.Run(context.Background(), bufferSize, pipe.Line{
+		Source: samples(48000, 3, 48000, func(c, i int) float64 {
+			switch c {
+			case 0:
+				return 0.1 + 0.5*math.Sin(2*math.Pi*1000*float64(i)/48000)
+			case 1:
+				if i%2 == 0 {
+					return 1
+				}
+				return -1
+			}
+			return 0
+		}),
+		Sink: wav.Sink(f, bitDepth),
+	})
+	assert.Nil(t, err)
+	_, err = f.Seek(0, 0)
+	assert.Nil(t, err)
+
+	var m process.Meter
+	err = pipe.Run(context.Background(), bufferSize, pipe.Line{
+		Source: wav.Source(f),
+		Sink:   m.Sink(),
+	})
+	assert.Nil(t, err)
+	return m.Analysis()
+}
+
+func TestMeter(t *testing.T) {
+	a := measureWAV(t, signal.BitDepth16)
+	assert.Equal(t, int64(48000), a.Frames)
+	assert.Equal(t, 3, len(a.Channels))
+
+	sine := a.Channels[0]
+	assert.InDelta(t, 20*math.Log10(0.6), sine.Peak, 0.01)
+	// rms of dc and sine sum.
+	assert.InDelta(t, 10*math.Log10(0.01+0.125), sine.RMS, 0.01)
+	assert.InDelta(t, 0.1, sine.DCOffset, 1e-4)
+	assert.InDelta(t, sine.Peak-sine.RMS, sine.Crest, 1e-9)
+	assert.Equal(t, 0, sine.Clipped)
+
+	square := a.Channels[1]
+	assert.InDelta(t, 0, square.Peak, 1e-9)
+	assert.InDelta(t, 0, square.Crest, 1e-9)
+	assert.Equal(t, 48000, square.Clipped)
+	assert.Equal(t, 48000, a.Clipped())
+
+	silent := a.Channels[2]
+	assert.True(t, math.IsInf(silent.Peak, -1))
+	assert.True(t, math.IsInf(silent.RMS, -1))
+	assert.Equal(t, 0.0, silent.Crest)
+	assert.False(t, math.IsInf(a.Integrated, 0))
+
+	// processor passes the signal through.
+	var p process.Meter
+	r := run(t, generator(44100, 2, 1000, 440, 0.5), p.Processor())
+	assert.Equal(t, 1000, len(r.samples[0]))
+	assert.Equal(t, int64(1000), p.Analysis().Frames)
+
+	// full scale samples are clipped regardless of bit depth.
+	for _, bitDepth := range []signal.BitDepth{signal.BitDepth8, signal.BitDepth24} {
+		a := measureWAV(t, bitDepth)
+		assert.Equal(t, 0, a.Channels[0].Clipped)
+		assert.Equal(t, 48000, a.Channels[1].Clipped)
+	}
+}
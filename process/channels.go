@@ -0,0 +1,151 @@
+package process
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+type (
+	// ChannelWeight is a weighted input channel.
+	ChannelWeight struct {
+		Channel int
+		Weight  float64
+	}
+
+	// ChannelMap defines every output channel as a weighted sum of input
+	// channels.
+	ChannelMap [][]ChannelWeight
+)
+
+// ParseChannelMap parses channel map. Output channels are separated by
+// comma, every output channel is a sum of zero-based input channels with
+// optional weights. Examples:
+//
+//	1,0 - swap stereo channels
+//	0,0 - duplicate mono channel
+//	0.5*0+0.5*1 - downmix stereo to mono
+func ParseChannelMap(s string) (ChannelMap, error) {
+	var m ChannelMap
+	for _, output := range strings.Split(s, ",") {
+		var weights []ChannelWeight
+		for _, term := range strings.Split(output, "+") {
+			term = strings.TrimSpace(term)
+			w := ChannelWeight{Weight: 1}
+			if i := strings.Index(term, "*"); i >= 0 {
+				weight, err := strconv.ParseFloat(strings.TrimSpace(term[:i]), 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid weight %q: %w", term[:i], err)
+				}
+				w.Weight = weight
+				term = strings.TrimSpace(term[i+1:])
+			}
+			channel, err := strconv.Atoi(term)
+			if err != nil || channel < 0 {
+				return nil, fmt.Errorf("invalid channel %q", term)
+			}
+			w.Channel = channel
+			weights = append(weights, w)
+		}
+		m = append(m, weights)
+	}
+	return m, nil
+}
+
+// String returns channel map in the format accepted by ParseChannelMap.
+func (m ChannelMap) String() string {
+	outputs := make([]string, 0, len(m))
+	for _, weights := range m {
+		terms := make([]string, 0, len(weights))
+		for _, w := range weights {
+			if w.Weight == 1 {
+				terms = append(terms, strconv.Itoa(w.Channel))
+				continue
+			}
+			terms = append(terms, fmt.Sprintf("%s*%d", strconv.FormatFloat(w.Weight, 'g', -1, 64), w.Channel))
+		}
+		outputs = append(outputs, strings.Join(terms, "+"))
+	}
+	return strings.Join(outputs, ",")
+}
+
+// Channels returns processor that converts the signal into provided
+// number of channels. Zero value keeps the number of input channels.
+// If map is nil, default mapping is used:
+//
+//	mono output is an average of all input channels
+//	mono input is duplicated into all output channels
+//	otherwise input channels are distributed over output channels
+//	and averaged
+func Channels(channels int, m ChannelMap) pipe.ProcessorAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Processor, error) {
+		// allocator is reused for different inputs, so captured values
+		// must not be changed.
+		cm := m
+		if cm == nil {
+			n := channels
+			if n <= 0 {
+				n = props.Channels
+			}
+			cm = defaultChannelMap(props.Channels, n)
+		}
+		if channels > 0 && len(cm) != channels {
+			return pipe.Processor{}, fmt.Errorf("channel map has %d channels, expected %d", len(cm), channels)
+		}
+		for _, weights := range cm {
+			for _, w := range weights {
+				if w.Channel >= props.Channels {
+					return pipe.Processor{}, fmt.Errorf("channel %d is not present in input with %d channels", w.Channel, props.Channels)
+				}
+			}
+		}
+		return pipe.Processor{
+			SignalProperties: pipe.SignalProperties{
+				SampleRate: props.SampleRate,
+				Channels:   len(cm),
+			},
+			ProcessFunc: func(in, out signal.Floating) (int, error) {
+				inChannels, outChannels := props.Channels, len(cm)
+				for f := 0; f < in.Length(); f++ {
+					for o, weights := range cm {
+						var v float64
+						for _, w := range weights {
+							v += w.Weight * in.Sample(f*inChannels+w.Channel)
+						}
+						out.SetSample(f*outChannels+o, v)
+					}
+				}
+				return in.Length(), nil
+			},
+		}, nil
+	}
+}
+
+// defaultChannelMap returns map to convert between channel counts.
+func defaultChannelMap(in, out int) ChannelMap {
+	m := make(ChannelMap, out)
+	switch {
+	case out == 1:
+		for i := 0; i < in; i++ {
+			m[0] = append(m[0], ChannelWeight{Channel: i, Weight: 1 / float64(in)})
+		}
+	case in <= out:
+		for o := 0; o < out; o++ {
+			m[o] = []ChannelWeight{{Channel: o % in, Weight: 1}}
+		}
+	default:
+		counts := make([]int, out)
+		for i := 0; i < in; i++ {
+			counts[i%out]++
+		}
+		for i := 0; i < in; i++ {
+			o := i % out
+			m[o] = append(m[o], ChannelWeight{Channel: i, Weight: 1 / float64(counts[o])})
+		}
+	}
+	return m
+}
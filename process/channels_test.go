@@ -0,0 +1,99 @@
+package process_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/pipe"
+
+	"pipelined.dev/phono/process"
+)
+
+func TestParseChannelMap(t *testing.T) {
+	var tests = []struct {
+		in       string
+		expected process.ChannelMap
+		negative bool
+	}{
+		{
+			in: "1,0",
+			expected: process.ChannelMap{
+				{{Channel: 1, Weight: 1}},
+				{{Channel: 0, Weight: 1}},
+			},
+		},
+		{
+			in: "0.5*0 + 0.5*1",
+			expected: process.ChannelMap{
+				{{Channel: 0, Weight: 0.5}, {Channel: 1, Weight: 0.5}},
+			},
+		},
+		{in: "", negative: true},
+		{in: "0,", negative: true},
+		{in: "-1", negative: true},
+		{in: "x*0", negative: true},
+	}
+	for _, test := range tests {
+		m, err := process.ParseChannelMap(test.in)
+		if test.negative {
+			assert.NotNil(t, err, test.in)
+			continue
+		}
+		assert.Nil(t, err, test.in)
+		assert.Equal(t, test.expected, m)
+		// must be parsed back into same map.
+		parsed, err := process.ParseChannelMap(m.String())
+		assert.Nil(t, err)
+		assert.Equal(t, m, parsed)
+	}
+}
+
+func TestChannels(t *testing.T) {
+	// every channel has samples equal to its number plus one.
+	source := func(channels int) pipe.SourceAllocatorFunc {
+		return samples(44100, channels, 1000, func(c, i int) float64 {
+			return float64(c + 1)
+		})
+	}
+	testChannels := func(in, out int, m string, expected []float64) func(*testing.T) {
+		return func(t *testing.T) {
+			var cm process.ChannelMap
+			if m != "" {
+				var err error
+				cm, err = process.ParseChannelMap(m)
+				assert.Nil(t, err)
+			}
+			r := run(t, source(in), process.Channels(out, cm))
+			assert.Equal(t, len(expected), r.Channels)
+			for c := range expected {
+				assert.Equal(t, 1000, len(r.samples[c]))
+				for _, v := range r.samples[c] {
+					assert.InDelta(t, expected[c], v, 1e-12)
+				}
+			}
+		}
+	}
+	t.Run("swap", testChannels(2, 0, "1,0", []float64{2, 1}))
+	t.Run("downmix map", testChannels(2, 1, "0.5*0+0.5*1", []float64{1.5}))
+	t.Run("downmix", testChannels(2, 1, "", []float64{1.5}))
+	t.Run("upmix", testChannels(1, 2, "", []float64{1, 1}))
+	t.Run("stereo to quad", testChannels(2, 4, "", []float64{1, 2, 1, 2}))
+	t.Run("quad to stereo", testChannels(4, 2, "", []float64{2, 3}))
+	t.Run("same", testChannels(2, 2, "", []float64{1, 2}))
+
+	testFail := func(in, out int, m process.ChannelMap) func(*testing.T) {
+		return func(t *testing.T) {
+			var r result
+			err := pipe.Run(context.Background(), bufferSize, pipe.Line{
+				Source:     source(in),
+				Processors: []pipe.ProcessorAllocatorFunc{process.Channels(out, m)},
+				Sink:       r.sink(),
+			})
+			assert.NotNil(t, err)
+		}
+	}
+	t.Run("missing input channel", testFail(1, 0, process.ChannelMap{{{Channel: 1, Weight: 1}}}))
+	t.Run("map length mismatch", testFail(2, 2, process.ChannelMap{{{Channel: 1, Weight: 1}}}))
+}
@@ -0,0 +1,257 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+// Join defines how sources are concatenated. Only one of gap and
+// crossfade can be provided.
+type Join struct {
+	// Gap is the silence inserted between sources.
+	Gap Position
+	// Crossfade overlaps the end of every source with the beginning of
+	// the next one. The curve defines the fade-in, fade-out is mirrored.
+	Crossfade Ramp
+	// Convert sample rate and channels of all sources to the first one.
+	// If false, sources must have the same sample rate and channels.
+	Convert bool
+	// Quality of sample rate conversion, high quality is used if empty.
+	Quality ResampleQuality
+}
+
+// Concat returns source that reads provided sources one after another.
+// Crossfade is limited by the length of the sources.
+func Concat(fns []pipe.SourceAllocatorFunc, j Join) pipe.SourceAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int) (pipe.Source, error) {
+		if len(fns) == 0 {
+			return pipe.Source{}, errors.New("no sources to concatenate")
+		}
+		if !j.Gap.IsZero() && !j.Crossfade.Length.IsZero() {
+			return pipe.Source{}, errors.New("both gap and crossfade are provided")
+		}
+		if err := j.Crossfade.Curve.validate(); err != nil {
+			return pipe.Source{}, err
+		}
+		source, inputs, err := wrapAll(fns, mctx, bufferSize, j.Convert, j.Quality)
+		if err != nil {
+			return pipe.Source{}, err
+		}
+		c := concatenation{
+			inputs:    inputs,
+			gap:       j.Gap.Frame(source.SampleRate),
+			crossfade: j.Crossfade.Length.Frame(source.SampleRate),
+			curve:     j.Crossfade.Curve,
+		}
+		source.SourceFunc = c.read
+		return source, nil
+	}
+}
+
+// wrapAll allocates all sources and returns fifos that read from them.
+// Returned source has properties of the first source and calls hooks of
+// all sources. If convert is true, sample rate and channels of all other
+// sources are converted to the first one, otherwise they must match.
+func wrapAll(fns []pipe.SourceAllocatorFunc, mctx mutable.Context, bufferSize int, convert bool, quality ResampleQuality) (pipe.Source, []*fifo, error) {
+	source, first, err := wrap(fns[0], mctx, bufferSize)
+	if err != nil {
+		return pipe.Source{}, nil, err
+	}
+	props := source.SignalProperties
+	if quality == "" {
+		quality = ResampleHigh
+	}
+	inputs := []*fifo{first}
+	starts, flushes := []pipe.StartFunc{source.StartFunc}, []pipe.FlushFunc{source.FlushFunc}
+	for i, fn := range fns[1:] {
+		if convert {
+			fn = remix(Resample(fn, props.SampleRate, quality), props.Channels)
+		}
+		s, f, err := wrap(fn, mctx, bufferSize)
+		if err != nil {
+			return pipe.Source{}, nil, err
+		}
+		if s.SampleRate != props.SampleRate || s.Channels != props.Channels {
+			return pipe.Source{}, nil, fmt.Errorf("source %d has %v Hz and %d channels, expected %v Hz and %d channels", i+2, s.SampleRate, s.Channels, props.SampleRate, props.Channels)
+		}
+		inputs = append(inputs, f)
+		starts, flushes = append(starts, s.StartFunc), append(flushes, s.FlushFunc)
+	}
+	return pipe.Source{
+		SignalProperties: props,
+		StartFunc: func(ctx context.Context) error {
+			for _, fn := range starts {
+				if fn == nil {
+					continue
+				}
+				if err := fn(ctx); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		FlushFunc: func(ctx context.Context) error {
+			var result error
+			for _, fn := range flushes {
+				if fn == nil {
+					continue
+				}
+				if err := fn(ctx); err != nil && result == nil {
+					result = err
+				}
+			}
+			return result
+		},
+	}, inputs, nil
+}
+
+// remix wraps the source, so its output has provided number of channels.
+// Default channel map is used.
+func remix(fn pipe.SourceAllocatorFunc, channels int) pipe.SourceAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int) (pipe.Source, error) {
+		source, f, err := wrap(fn, mctx, bufferSize)
+		if err != nil {
+			return pipe.Source{}, err
+		}
+		if source.Channels == channels {
+			return f.source, nil
+		}
+		m := defaultChannelMap(source.Channels, channels)
+		source.Channels = channels
+		source.SourceFunc = func(out signal.Floating) (int, error) {
+			if err := f.fill(out.Length()); err != nil {
+				return 0, err
+			}
+			n := f.frames()
+			if n > out.Length() {
+				n = out.Length()
+			}
+			if n == 0 {
+				return 0, io.EOF
+			}
+			for i := 0; i < n; i++ {
+				for o, weights := range m {
+					var v float64
+					for _, w := range weights {
+						v += w.Weight * f.data[i*f.channels+w.Channel]
+					}
+					out.SetSample(i*channels+o, v)
+				}
+			}
+			f.discard(n)
+			return n, nil
+		}
+		return source, nil
+	}
+}
+
+type concatenation struct {
+	inputs []*fifo
+	// current is the index of the input that is read.
+	current   int
+	gap       int64
+	crossfade int64
+	curve     Curve
+	// silence is the number of gap frames left to send.
+	silence int64
+	// overlap is the number of crossfaded frames of current and next
+	// inputs, position is the index of the next crossfaded frame.
+	overlap  int64
+	position int64
+}
+
+func (c *concatenation) read(out signal.Floating) (int, error) {
+	n := int64(out.Length())
+	for {
+		cur := c.inputs[c.current]
+		if c.silence > 0 {
+			if n > c.silence {
+				n = c.silence
+			}
+			for i := 0; i < int(n)*cur.channels; i++ {
+				out.SetSample(i, 0)
+			}
+			c.silence -= n
+			return int(n), nil
+		}
+		if c.overlap > 0 {
+			return c.mix(out, cur, c.inputs[c.current+1]), nil
+		}
+		last := c.current == len(c.inputs)-1
+		// frames of crossfade are kept until the end is known.
+		var lookahead int64
+		if !last {
+			lookahead = c.crossfade
+		}
+		if err := cur.fill(int(n + lookahead)); err != nil {
+			return 0, err
+		}
+		available, overlap := int64(cur.frames()), int64(0)
+		switch {
+		case !cur.eof:
+			available -= lookahead
+		case lookahead > 0:
+			next := c.inputs[c.current+1]
+			if err := next.fill(cur.frames()); err != nil {
+				return 0, err
+			}
+			overlap = lookahead
+			if overlap > available {
+				overlap = available
+			}
+			if overlap > int64(next.frames()) {
+				overlap = int64(next.frames())
+			}
+			available -= overlap
+		}
+		if available > 0 {
+			if n > available {
+				n = available
+			}
+			for i := 0; i < int(n)*cur.channels; i++ {
+				out.SetSample(i, cur.data[i])
+			}
+			cur.discard(int(n))
+			return int(n), nil
+		}
+		if last {
+			return 0, io.EOF
+		}
+		if overlap > 0 {
+			c.overlap, c.position = overlap, 0
+			continue
+		}
+		c.current++
+		c.silence = c.gap
+	}
+}
+
+// mix sends crossfaded frames of two inputs.
+func (c *concatenation) mix(out signal.Floating, cur, next *fifo) int {
+	n := int64(out.Length())
+	if n > c.overlap-c.position {
+		n = c.overlap - c.position
+	}
+	for i := 0; i < int(n); i++ {
+		x := float64(c.position+int64(i)) / float64(c.overlap)
+		in, fadeOut := c.curve.Gain(x), c.curve.Gain(1-x)
+		for ch := 0; ch < cur.channels; ch++ {
+			idx := i*cur.channels + ch
+			out.SetSample(idx, cur.data[idx]*fadeOut+next.data[idx]*in)
+		}
+	}
+	cur.discard(int(n))
+	next.discard(int(n))
+	c.position += n
+	if c.position == c.overlap {
+		c.overlap, c.position = 0, 0
+		c.current++
+	}
+	return int(n)
+}
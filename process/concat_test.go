@@ -0,0 +1,104 @@
+package process_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/signal"
+
+	"pipelined.dev/phono/process"
+)
+
+func TestConcat(t *testing.T) {
+	// constant returns source where all samples have provided value.
+	constant := func(channels, frames int, v float64) pipe.SourceAllocatorFunc {
+		return samples(1000, channels, frames, func(c, i int) float64 {
+			return v
+		})
+	}
+	testConcat := func(j process.Join, expected []float64, sources ...pipe.SourceAllocatorFunc) func(*testing.T) {
+		return func(t *testing.T) {
+			r := run(t, process.Concat(sources, j))
+			assert.Equal(t, 2, r.Channels)
+			for c := range r.samples {
+				assert.Equal(t, len(expected), len(r.samples[c]))
+				for i, v := range r.samples[c] {
+					if !assert.InDelta(t, expected[i], v, 1e-9, "frame %d", i) {
+						return
+					}
+				}
+			}
+		}
+	}
+	// repeat returns expected values.
+	repeat := func(values ...interface{}) []float64 {
+		var result []float64
+		for i := 0; i < len(values); i += 2 {
+			for n := 0; n < values[i].(int); n++ {
+				result = append(result, values[i+1].(float64))
+			}
+		}
+		return result
+	}
+	t.Run("plain", testConcat(process.Join{},
+		repeat(1000, 0.1, 700, 0.2, 300, 0.3),
+		constant(2, 1000, 0.1), constant(2, 700, 0.2), constant(2, 300, 0.3),
+	))
+	t.Run("gap", testConcat(process.Join{Gap: process.AtFrame(600)},
+		repeat(1000, 0.1, 600, 0.0, 700, 0.2),
+		constant(2, 1000, 0.1), constant(2, 700, 0.2),
+	))
+	t.Run("crossfade", func(t *testing.T) {
+		r := run(t, process.Concat([]pipe.SourceAllocatorFunc{
+			constant(2, 1000, 1), constant(2, 1000, 1),
+		}, process.Join{Crossfade: process.Ramp{Length: process.AtFrame(400), Curve: process.CurveLinear}}))
+		assert.Equal(t, 1600, len(r.samples[0]))
+		// linear crossfade of equal signals keeps the level.
+		for i, v := range r.samples[0] {
+			if !assert.InDelta(t, 1, v, 1e-9, "frame %d", i) {
+				return
+			}
+		}
+	})
+	t.Run("crossfade longer than source", func(t *testing.T) {
+		r := run(t, process.Concat([]pipe.SourceAllocatorFunc{
+			constant(2, 1000, 1), constant(2, 200, 1), constant(2, 1000, 1),
+		}, process.Join{Crossfade: process.Ramp{Length: process.AtFrame(400)}}))
+		// the second source is crossfaded with the first one only.
+		assert.Equal(t, 1000+200+1000-200, len(r.samples[0]))
+	})
+	t.Run("different channels", func(t *testing.T) {
+		var r result
+		err := pipe.Run(context.Background(), bufferSize, pipe.Line{
+			Source: process.Concat([]pipe.SourceAllocatorFunc{constant(2, 100, 1), constant(1, 100, 1)}, process.Join{}),
+			Sink:   r.sink(),
+		})
+		assert.NotNil(t, err)
+	})
+	t.Run("convert", testConcat(process.Join{Convert: true},
+		repeat(1000, 0.1, 500, 0.2),
+		constant(2, 1000, 0.1), constant(1, 500, 0.2),
+	))
+	t.Run("convert sample rate", func(t *testing.T) {
+		r := run(t, process.Concat([]pipe.SourceAllocatorFunc{
+			constant(2, 1000, 0.1),
+			samples(2000, 2, 2000, func(c, i int) float64 { return 0 }),
+		}, process.Join{Convert: true, Quality: process.ResampleLow}))
+		assert.Equal(t, signal.Frequency(1000), r.SampleRate)
+		assert.Equal(t, 2000, len(r.samples[0]))
+	})
+	t.Run("gap and crossfade", func(t *testing.T) {
+		var r result
+		err := pipe.Run(context.Background(), bufferSize, pipe.Line{
+			Source: process.Concat([]pipe.SourceAllocatorFunc{constant(2, 100, 1)}, process.Join{
+				Gap:       process.AtFrame(1),
+				Crossfade: process.Ramp{Length: process.AtFrame(1)},
+			}),
+			Sink: r.sink(),
+		})
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,110 @@
+package process
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+// Curve defines the shape of the fade.
+type Curve string
+
+// Fade curves. Empty curve is linear. Logarithmic curve changes the
+// level linearly in dB, equal-power curve keeps the sum of powers of two
+// crossfaded signals constant.
+const (
+	CurveLinear      Curve = "linear"
+	CurveLogarithmic Curve = "log"
+	CurveEqualPower  Curve = "equal-power"
+)
+
+// logarithmicRange is the level in dB where logarithmic fade starts.
+const logarithmicRange = 60
+
+// Gain returns the gain of the curve at position between 0 and 1.
+func (c Curve) Gain(x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	switch c {
+	case CurveLogarithmic:
+		return math.Pow(10, logarithmicRange*(x-1)/20)
+	case CurveEqualPower:
+		return math.Sin(x * math.Pi / 2)
+	default:
+		return x
+	}
+}
+
+func (c Curve) validate() error {
+	switch c {
+	case "", CurveLinear, CurveLogarithmic, CurveEqualPower:
+		return nil
+	}
+	return fmt.Errorf("unknown fade curve %q", c)
+}
+
+// Ramp is a fade with provided length and curve.
+type Ramp struct {
+	Length Position
+	Curve  Curve
+}
+
+// Fade returns source that applies fade-in and fade-out to the wrapped
+// source. Zero length disables the fade. The length of the signal isn't
+// known in advance, so frames of fade-out length are read ahead.
+func Fade(fn pipe.SourceAllocatorFunc, in, out Ramp) pipe.SourceAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int) (pipe.Source, error) {
+		if err := in.Curve.validate(); err != nil {
+			return pipe.Source{}, err
+		}
+		if err := out.Curve.validate(); err != nil {
+			return pipe.Source{}, err
+		}
+		source, f, err := wrap(fn, mctx, bufferSize)
+		if err != nil {
+			return pipe.Source{}, err
+		}
+		sampleRate := source.SignalProperties.SampleRate
+		inLength, outLength := in.Length.Frame(sampleRate), out.Length.Frame(sampleRate)
+		source.SourceFunc = func(buf signal.Floating) (int, error) {
+			if err := f.fill(buf.Length() + int(outLength)); err != nil {
+				return 0, err
+			}
+			n := f.frames()
+			// fade-out frames are released only when the end is known.
+			if !f.eof {
+				n -= int(outLength)
+			}
+			if n > buf.Length() {
+				n = buf.Length()
+			}
+			if n <= 0 {
+				return 0, io.EOF
+			}
+			for i := 0; i < n; i++ {
+				frame := f.offset + int64(i)
+				gain := 1.0
+				if frame < inLength {
+					gain *= in.Curve.Gain(float64(frame) / float64(inLength))
+				}
+				if f.eof && frame >= f.end()-outLength {
+					gain *= out.Curve.Gain(float64(f.end()-1-frame) / float64(outLength))
+				}
+				for c := 0; c < f.channels; c++ {
+					buf.SetSample(i*f.channels+c, f.data[i*f.channels+c]*gain)
+				}
+			}
+			f.discard(n)
+			return n, nil
+		}
+		return source, nil
+	}
+}
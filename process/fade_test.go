@@ -0,0 +1,98 @@
+package process_test
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/pipe"
+
+	"pipelined.dev/phono/process"
+)
+
+func TestCurve(t *testing.T) {
+	for _, c := range []process.Curve{process.CurveLinear, process.CurveLogarithmic, process.CurveEqualPower} {
+		assert.Equal(t, 0.0, c.Gain(0), c)
+		assert.Equal(t, 1.0, c.Gain(1), c)
+		assert.Equal(t, 0.0, c.Gain(-1), c)
+		assert.Equal(t, 1.0, c.Gain(2), c)
+		// curves are monotonic.
+		for x := 0.0; x < 1; x += 0.01 {
+			assert.LessOrEqual(t, c.Gain(x), c.Gain(x+0.01), c)
+		}
+	}
+	assert.InDelta(t, 0.5, process.CurveLinear.Gain(0.5), 1e-12)
+	assert.InDelta(t, math.Pow(10, -30.0/20), process.CurveLogarithmic.Gain(0.5), 1e-12)
+	// equal power crossfade keeps power constant.
+	for x := 0.0; x <= 1; x += 0.1 {
+		in, out := process.CurveEqualPower.Gain(x), process.CurveEqualPower.Gain(1-x)
+		assert.InDelta(t, 1, in*in+out*out, 1e-12)
+	}
+}
+
+func TestFade(t *testing.T) {
+	// constant signal shows the gain.
+	source := samples(1000, 2, 10000, func(c, i int) float64 {
+		return 1
+	})
+	testFade := func(source pipe.SourceAllocatorFunc, in, out process.Ramp, length int, expected func(i int) float64) func(*testing.T) {
+		return func(t *testing.T) {
+			r := run(t, process.Fade(source, in, out))
+			for c := range r.samples {
+				assert.Equal(t, length, len(r.samples[c]))
+				for i, v := range r.samples[c] {
+					if !assert.InDelta(t, expected(i), v, 1e-12, "frame %d", i) {
+						return
+					}
+				}
+			}
+		}
+	}
+	t.Run("none", testFade(source, process.Ramp{}, process.Ramp{}, 10000, func(i int) float64 { return 1 }))
+	t.Run("in", testFade(source,
+		process.Ramp{Length: process.AtTime(time.Second)},
+		process.Ramp{},
+		10000,
+		func(i int) float64 {
+			return math.Min(1, float64(i)/1000)
+		}))
+	t.Run("out", testFade(source,
+		process.Ramp{},
+		process.Ramp{Length: process.AtFrame(2000), Curve: process.CurveEqualPower},
+		10000,
+		func(i int) float64 {
+			return process.CurveEqualPower.Gain(float64(9999-i) / 2000)
+		}))
+	t.Run("in and out overlap", testFade(source,
+		process.Ramp{Length: process.AtFrame(8000), Curve: process.CurveLogarithmic},
+		process.Ramp{Length: process.AtFrame(8000)},
+		10000,
+		func(i int) float64 {
+			return process.CurveLogarithmic.Gain(float64(i)/8000) * process.CurveLinear.Gain(float64(9999-i)/8000)
+		}))
+	t.Run("out longer than signal", testFade(samples(1000, 1, 100, func(c, i int) float64 { return 1 }),
+		process.Ramp{},
+		process.Ramp{Length: process.AtFrame(1000)},
+		100,
+		func(i int) float64 {
+			return float64(99-i) / 1000
+		}))
+	t.Run("after trim", testFade(process.Trim(source, process.Range{Start: process.AtFrame(1000), Duration: process.AtFrame(3000)}),
+		process.Ramp{Length: process.AtFrame(100)},
+		process.Ramp{Length: process.AtFrame(100)},
+		3000,
+		func(i int) float64 {
+			return math.Min(1, float64(i)/100) * math.Min(1, float64(2999-i)/100)
+		}))
+	t.Run("invalid curve", func(t *testing.T) {
+		var r result
+		err := pipe.Run(context.Background(), bufferSize, pipe.Line{
+			Source: process.Fade(source, process.Ramp{Curve: "fake"}, process.Ramp{}),
+			Sink:   r.sink(),
+		})
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,114 @@
+package process
+
+import (
+	"fmt"
+	"math"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+// Gain returns processor that changes the level of the signal by provided
+// value in dB.
+func Gain(db float64) pipe.ProcessorAllocatorFunc {
+	ratio := math.Pow(10, db/20)
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Processor, error) {
+		return pipe.Processor{
+			SignalProperties: props,
+			ProcessFunc: func(in, out signal.Floating) (int, error) {
+				for i := 0; i < in.Len(); i++ {
+					out.SetSample(i, in.Sample(i)*ratio)
+				}
+				return in.Length(), nil
+			},
+		}, nil
+	}
+}
+
+// ClipDetector counts overs, the samples above full scale. Gain and
+// normalization create overs and they are clipped when the signal is
+// encoded. Samples at full scale are not overs, but Meter counts them as
+// clipped.
+type ClipDetector struct {
+	overs int
+}
+
+// Processor returns processor that passes the signal through and counts
+// overs.
+func (d *ClipDetector) Processor() pipe.ProcessorAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Processor, error) {
+		return pipe.Processor{
+			SignalProperties: props,
+			ProcessFunc: func(in, out signal.Floating) (int, error) {
+				for i := 0; i < in.Len(); i++ {
+					v := in.Sample(i)
+					if math.Abs(v) > fullScale {
+						d.overs++
+					}
+					out.SetSample(i, v)
+				}
+				return in.Length(), nil
+			},
+		}, nil
+	}
+}
+
+// Overs returns the number of samples above full scale.
+func (d *ClipDetector) Overs() int {
+	return d.overs
+}
+
+// PeakNormalizer is a two-pass stage that measures the peak of the signal
+// and then applies gain to reach the target peak.
+type PeakNormalizer struct {
+	target float64
+	peak   float64
+	// measured is set when the sink is allocated.
+	measured bool
+}
+
+// NewPeakNormalizer returns normalizer with target peak in dBFS.
+func NewPeakNormalizer(target float64) *PeakNormalizer {
+	return &PeakNormalizer{
+		target: target,
+	}
+}
+
+// Sink returns the sink that measures the peak.
+func (n *PeakNormalizer) Sink() pipe.SinkAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Sink, error) {
+		n.measured = true
+		return pipe.Sink{
+			SinkFunc: func(in signal.Floating) error {
+				for i := 0; i < in.Len(); i++ {
+					n.peak = math.Max(n.peak, math.Abs(in.Sample(i)))
+				}
+				return nil
+			},
+		}, nil
+	}
+}
+
+// Peak returns the measured peak of the signal in dBFS.
+func (n *PeakNormalizer) Peak() float64 {
+	return 20 * math.Log10(n.peak)
+}
+
+// Gain returns the gain in dB that is applied to the signal. Silent
+// signal is not amplified.
+func (n *PeakNormalizer) Gain() float64 {
+	if n.peak == 0 {
+		return 0
+	}
+	return n.target - n.Peak()
+}
+
+// Processor returns the processor that applies the gain. It must be
+// called after the signal is measured.
+func (n *PeakNormalizer) Processor() (pipe.ProcessorAllocatorFunc, error) {
+	if !n.measured {
+		return nil, fmt.Errorf("peak is not measured")
+	}
+	return Gain(n.Gain()), nil
+}
@@ -0,0 +1,58 @@
+package process_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/phono/process"
+)
+
+func TestGain(t *testing.T) {
+	r := run(t, samples(44100, 2, 1000, func(c, i int) float64 { return 0.5 }), process.Gain(-6.0206))
+	for c := range r.samples {
+		for _, v := range r.samples[c] {
+			assert.InDelta(t, 0.25, v, 1e-4)
+		}
+	}
+}
+
+func TestClipDetector(t *testing.T) {
+	var d process.ClipDetector
+	// every 10th sample of the first channel is over, full scale samples
+	// are not counted.
+	run(t, samples(44100, 2, 1000, func(c, i int) float64 {
+		if c == 0 && i%10 == 0 {
+			return -1.5
+		}
+		return 1
+	}), d.Processor())
+	assert.Equal(t, 100, d.Overs())
+}
+
+func TestPeakNormalizer(t *testing.T) {
+	testPeakNormalizer := func(amplitude, target, expected float64) func(*testing.T) {
+		return func(t *testing.T) {
+			source := generator(44100, 2, 1000, 100, amplitude)
+			n := process.NewPeakNormalizer(target)
+			_, err := n.Processor()
+			assert.NotNil(t, err, "processor must be measured")
+			runLine(t, source, n.Sink())
+			processor, err := n.Processor()
+			assert.Nil(t, err)
+			r := run(t, source, processor)
+			var peak float64
+			for c := range r.samples {
+				for _, v := range r.samples[c] {
+					peak = math.Max(peak, math.Abs(v))
+				}
+			}
+			assert.InDelta(t, expected, peak, 1e-6)
+		}
+	}
+	// second channel has doubled amplitude.
+	t.Run("amplify", testPeakNormalizer(0.1, 0, 1))
+	t.Run("attenuate", testPeakNormalizer(0.4, -6.0206, 0.5))
+	t.Run("silence", testPeakNormalizer(0, -1, 0))
+}
@@ -0,0 +1,353 @@
+package process
+
+import (
+	"fmt"
+	"math"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+const (
+	// absoluteGate is the loudness below which blocks are ignored.
+	absoluteGate = -70.0
+	// relativeGate is the offset of relative gate from the loudness of
+	// blocks above the absolute gate.
+	relativeGate = -10.0
+	// loudnessBlockSteps is the number of 100ms steps in 400ms gating
+	// block.
+	loudnessBlockSteps = 4
+	// surroundWeight is applied to surround channels.
+	surroundWeight = 1.41
+)
+
+// true peak oversampling factor and interpolation kernel parameters.
+const (
+	oversampling      = 4
+	peakZeroCrossings = 8
+	peakKaiserBeta    = 7.0
+)
+
+// LoudnessMeter measures integrated loudness and true peak of the signal
+// as defined by ITU-R BS.1770 and EBU R128.
+type LoudnessMeter struct {
+	channels int
+	weights  []float64
+	filters  []kWeighting
+	// block is split into steps of 100ms.
+	stepLength int
+	stepPos    int
+	steps      [loudnessBlockSteps][]float64
+	stepCount  int
+	// blocks contains mean square of every gating block.
+	blocks []float64
+	peak   truePeak
+}
+
+// NewLoudnessMeter returns meter for the signal with provided sample rate
+// and number of channels.
+func NewLoudnessMeter(sampleRate signal.Frequency, channels int) *LoudnessMeter {
+	m := LoudnessMeter{
+		channels:   channels,
+		weights:    channelWeights(channels),
+		filters:    make([]kWeighting, channels),
+		stepLength: int(math.Round(float64(sampleRate) / 10)),
+		peak:       newTruePeak(channels),
+	}
+	for c := range m.filters {
+		m.filters[c] = newKWeighting(float64(sampleRate))
+	}
+	for i := range m.steps {
+		m.steps[i] = make([]float64, channels)
+	}
+	return &m
+}
+
+// Write measures the interleaved samples.
+func (m *LoudnessMeter) Write(in signal.Floating) {
+	for f := 0; f < in.Length(); f++ {
+		step := m.steps[m.stepCount%loudnessBlockSteps]
+		for c := 0; c < m.channels; c++ {
+			v := in.Sample(f*m.channels + c)
+			m.peak.write(c, v)
+			w := m.filters[c].process(v)
+			step[c] += w * w
+		}
+		m.stepPos++
+		if m.stepPos == m.stepLength {
+			m.nextStep()
+		}
+	}
+}
+
+// nextStep completes the current step and starts new gating block if
+// enough steps are measured.
+func (m *LoudnessMeter) nextStep() {
+	m.stepPos = 0
+	m.stepCount++
+	if m.stepCount >= loudnessBlockSteps {
+		var sum float64
+		for c := 0; c < m.channels; c++ {
+			var channel float64
+			for _, step := range m.steps {
+				channel += step[c]
+			}
+			sum += m.weights[c] * channel
+		}
+		m.blocks = append(m.blocks, sum/float64(loudnessBlockSteps*m.stepLength))
+	}
+	next := m.steps[m.stepCount%loudnessBlockSteps]
+	for c := range next {
+		next[c] = 0
+	}
+}
+
+// Integrated returns integrated loudness in LUFS. Negative infinity is
+// returned if the signal is shorter than one block or silent.
+func (m *LoudnessMeter) Integrated() float64 {
+	absolute := energy(absoluteGate)
+	var sum float64
+	var n int
+	for _, b := range m.blocks {
+		if b > absolute {
+			sum += b
+			n++
+		}
+	}
+	if n == 0 {
+		return math.Inf(-1)
+	}
+	relative := energy(loudness(sum/float64(n)) + relativeGate)
+	sum, n = 0, 0
+	for _, b := range m.blocks {
+		if b > absolute && b > relative {
+			sum += b
+			n++
+		}
+	}
+	if n == 0 {
+		return math.Inf(-1)
+	}
+	return loudness(sum / float64(n))
+}
+
+// TruePeak returns the maximum true peak of all channels in dBTP.
+func (m *LoudnessMeter) TruePeak() float64 {
+	var max float64
+	for _, v := range m.peak.max {
+		max = math.Max(max, v)
+	}
+	return 20 * math.Log10(max)
+}
+
+// loudness converts weighted mean square to LUFS.
+func loudness(energy float64) float64 {
+	return -0.691 + 10*math.Log10(energy)
+}
+
+// energy converts LUFS to weighted mean square.
+func energy(loudness float64) float64 {
+	return math.Pow(10, (loudness+0.691)/10)
+}
+
+// channelWeights returns weights of channels. Surround channels of 5.0
+// and 5.1 layouts are amplified and LFE channel is ignored.
+func channelWeights(channels int) []float64 {
+	weights := make([]float64, channels)
+	for c := range weights {
+		weights[c] = 1
+	}
+	switch channels {
+	case 5:
+		weights[3], weights[4] = surroundWeight, surroundWeight
+	case 6:
+		weights[3] = 0
+		weights[4], weights[5] = surroundWeight, surroundWeight
+	}
+	return weights
+}
+
+// biquad is a second order IIR filter.
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x2, f.x1 = f.x1, x
+	f.y2, f.y1 = f.y1, y
+	return y
+}
+
+// kWeighting is a filter that consists of high shelf filter, which
+// models the head, and high pass filter.
+type kWeighting struct {
+	shelf, highPass biquad
+}
+
+// newKWeighting returns filter for provided sample rate. Coefficients
+// are derived from the analog prototype, so they match the ones defined
+// by the standard for 48kHz.
+func newKWeighting(sampleRate float64) kWeighting {
+	var w kWeighting
+	{
+		f0, gain, q := 1681.974450955533, 3.999843853973347, 0.7071752369554196
+		k := math.Tan(math.Pi * f0 / sampleRate)
+		vh := math.Pow(10, gain/20)
+		vb := math.Pow(vh, 0.4996667741545416)
+		a0 := 1 + k/q + k*k
+		w.shelf = biquad{
+			b0: (vh + vb*k/q + k*k) / a0,
+			b1: 2 * (k*k - vh) / a0,
+			b2: (vh - vb*k/q + k*k) / a0,
+			a1: 2 * (k*k - 1) / a0,
+			a2: (1 - k/q + k*k) / a0,
+		}
+	}
+	{
+		f0, q := 38.13547087602444, 0.5003270373238773
+		k := math.Tan(math.Pi * f0 / sampleRate)
+		a0 := 1 + k/q + k*k
+		w.highPass = biquad{
+			b0: 1,
+			b1: -2,
+			b2: 1,
+			a1: 2 * (k*k - 1) / a0,
+			a2: (1 - k/q + k*k) / a0,
+		}
+	}
+	return w
+}
+
+func (w *kWeighting) process(x float64) float64 {
+	return w.highPass.process(w.shelf.process(x))
+}
+
+// truePeak measures peaks of the oversampled signal.
+type truePeak struct {
+	// phases contains interpolation coefficients for every fractional
+	// position between samples.
+	phases [oversampling - 1][]float64
+	// history of every channel, the newest sample goes last.
+	history [][]float64
+	max     []float64
+}
+
+func newTruePeak(channels int) truePeak {
+	p := truePeak{
+		history: make([][]float64, channels),
+		max:     make([]float64, channels),
+	}
+	norm := besselI0(peakKaiserBeta)
+	for i := range p.phases {
+		frac := float64(i+1) / oversampling
+		coefs := make([]float64, 2*peakZeroCrossings)
+		// coefficient j is applied to history sample at distance
+		// j-peakZeroCrossings+1 from the interpolated position.
+		for j := range coefs {
+			x := float64(j-peakZeroCrossings+1) - frac
+			ratio := x / peakZeroCrossings
+			coefs[j] = sinc(x) * besselI0(peakKaiserBeta*math.Sqrt(1-ratio*ratio)) / norm
+		}
+		p.phases[i] = coefs
+	}
+	for c := range p.history {
+		p.history[c] = make([]float64, 2*peakZeroCrossings)
+	}
+	return p
+}
+
+// write adds the sample of the channel and updates its peak. Positions
+// between samples are interpolated with the delay of the kernel.
+func (p *truePeak) write(channel int, v float64) {
+	h := p.history[channel]
+	copy(h, h[1:])
+	h[len(h)-1] = v
+	max := math.Max(p.max[channel], math.Abs(v))
+	for _, coefs := range p.phases {
+		var sum float64
+		for j, c := range coefs {
+			sum += c * h[j]
+		}
+		max = math.Max(max, math.Abs(sum))
+	}
+	p.max[channel] = max
+}
+
+// Loudness contains results of loudness measurement.
+type Loudness struct {
+	// Integrated loudness in LUFS.
+	Integrated float64
+	// TruePeak in dBTP.
+	TruePeak float64
+}
+
+// Normalizer is a two-pass stage that measures the loudness of the signal
+// and then applies gain to reach the target loudness. Gain is limited,
+// so true peak doesn't exceed the ceiling.
+type Normalizer struct {
+	target  float64
+	ceiling float64
+	meter   *LoudnessMeter
+}
+
+// NewNormalizer returns normalizer with target loudness in LUFS and true
+// peak ceiling in dBTP.
+func NewNormalizer(target, ceiling float64) *Normalizer {
+	return &Normalizer{
+		target:  target,
+		ceiling: ceiling,
+	}
+}
+
+// Sink returns the sink that measures the loudness.
+func (n *Normalizer) Sink() pipe.SinkAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Sink, error) {
+		n.meter = NewLoudnessMeter(props.SampleRate, props.Channels)
+		return pipe.Sink{
+			SinkFunc: func(in signal.Floating) error {
+				n.meter.Write(in)
+				return nil
+			},
+		}, nil
+	}
+}
+
+// Loudness returns the measured loudness of the signal.
+func (n *Normalizer) Loudness() Loudness {
+	if n.meter == nil {
+		return Loudness{
+			Integrated: math.Inf(-1),
+			TruePeak:   math.Inf(-1),
+		}
+	}
+	return Loudness{
+		Integrated: n.meter.Integrated(),
+		TruePeak:   n.meter.TruePeak(),
+	}
+}
+
+// Gain returns the gain in dB that is applied to the signal. Silent
+// signal is not amplified.
+func (n *Normalizer) Gain() float64 {
+	l := n.Loudness()
+	if math.IsInf(l.Integrated, -1) {
+		return 0
+	}
+	gain := n.target - l.Integrated
+	if l.TruePeak+gain > n.ceiling {
+		gain = n.ceiling - l.TruePeak
+	}
+	return gain
+}
+
+// Processor returns the processor that applies the gain. It must be
+// called after the signal is measured.
+func (n *Normalizer) Processor() (pipe.ProcessorAllocatorFunc, error) {
+	if n.meter == nil {
+		return nil, fmt.Errorf("loudness is not measured")
+	}
+	return Gain(n.Gain()), nil
+}
@@ -0,0 +1,80 @@
+package process_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/pipe"
+
+	"pipelined.dev/phono/process"
+)
+
+// measure returns the loudness of the source.
+func measure(t *testing.T, source pipe.SourceAllocatorFunc, processors ...pipe.ProcessorAllocatorFunc) process.Loudness {
+	t.Helper()
+	n := process.NewNormalizer(-23, 0)
+	runLine(t, source, n.Sink(), processors...)
+	return n.Loudness()
+}
+
+func TestLoudness(t *testing.T) {
+	testLoudness := func(source pipe.SourceAllocatorFunc, integrated, truePeak float64) func(*testing.T) {
+		return func(t *testing.T) {
+			l := measure(t, source)
+			assert.InDelta(t, integrated, l.Integrated, 0.1)
+			assert.InDelta(t, truePeak, l.TruePeak, 0.1)
+		}
+	}
+	t.Run("stereo -20dBFS", testLoudness(sine(48000, 2, 48000*5, 1000, equal(0.1)), -20, -20))
+	t.Run("stereo 44100", testLoudness(sine(44100, 2, 44100*5, 1000, equal(0.1)), -20, -20))
+	// single channel reads 3dB lower.
+	t.Run("mono 0dBFS", testLoudness(sine(48000, 1, 48000*5, 1000, equal(1)), -3.01, 0))
+	t.Run("silence gated", testLoudness(
+		samples(48000, 2, 48000*10, func(c, i int) float64 {
+			if i > 48000*5 {
+				return 0
+			}
+			return 0.1 * math.Sin(2*math.Pi*1000*float64(i)/48000)
+		}),
+		// partially silent blocks pass the relative gate.
+		-20.1, -20))
+	t.Run("inter-sample peak", func(t *testing.T) {
+		// samples of this sine never exceed 0.354. It fades in to avoid
+		// overshoot of interpolation at the start.
+		l := measure(t, samples(44100, 1, 44100, func(c, i int) float64 {
+			return math.Min(1, float64(i)/1000) * 0.5 * math.Sin(2*math.Pi*float64(i)/4+math.Pi/4)
+		}))
+		assert.InDelta(t, -6.02, l.TruePeak, 0.1)
+	})
+	t.Run("silence", func(t *testing.T) {
+		l := measure(t, samples(48000, 2, 48000, func(c, i int) float64 { return 0 }))
+		assert.True(t, math.IsInf(l.Integrated, -1))
+		assert.True(t, math.IsInf(l.TruePeak, -1))
+	})
+}
+
+func TestNormalizer(t *testing.T) {
+	testNormalizer := func(source pipe.SourceAllocatorFunc, target, ceiling, integrated, truePeak float64) func(*testing.T) {
+		return func(t *testing.T) {
+			n := process.NewNormalizer(target, ceiling)
+			_, err := n.Processor()
+			assert.NotNil(t, err, "processor must be measured")
+			runLine(t, source, n.Sink())
+			processor, err := n.Processor()
+			assert.Nil(t, err)
+			l := measure(t, source, processor)
+			assert.InDelta(t, integrated, l.Integrated, 0.1)
+			assert.InDelta(t, truePeak, l.TruePeak, 0.1)
+		}
+	}
+	t.Run("amplify", testNormalizer(sine(48000, 2, 48000*5, 1000, equal(0.01)), -16, -1, -16, -16))
+	t.Run("attenuate", testNormalizer(sine(48000, 2, 48000*5, 1000, equal(0.5)), -16, -1, -16, -16))
+	t.Run("ceiling", testNormalizer(sine(48000, 2, 48000*5, 1000, equal(0.01)), -5, -6, -6, -6))
+	t.Run("silence", func(t *testing.T) {
+		n := process.NewNormalizer(-16, -1)
+		runLine(t, samples(48000, 2, 48000, func(c, i int) float64 { return 0 }), n.Sink())
+		assert.Equal(t, 0.0, n.Gain())
+	})
+}
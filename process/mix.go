@@ -0,0 +1,102 @@
+package process
+
+import (
+	"errors"
+	"io"
+	"math"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+// Track is a source of the mix.
+type Track struct {
+	Source pipe.SourceAllocatorFunc
+	// Gain of the track in dB.
+	Gain float64
+	// Offset of the track start in the mix.
+	Offset Position
+}
+
+// Mix returns source that sums provided tracks. The mix lasts until the
+// end of the longest track. If convert is true, sample rate and channels
+// of all tracks are converted to the first one, otherwise they must
+// match. High resample quality is used if quality is empty.
+func Mix(tracks []Track, convert bool, quality ResampleQuality) pipe.SourceAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int) (pipe.Source, error) {
+		if len(tracks) == 0 {
+			return pipe.Source{}, errors.New("no tracks to mix")
+		}
+		fns := make([]pipe.SourceAllocatorFunc, 0, len(tracks))
+		for _, t := range tracks {
+			fns = append(fns, t.Source)
+		}
+		source, inputs, err := wrapAll(fns, mctx, bufferSize, convert, quality)
+		if err != nil {
+			return pipe.Source{}, err
+		}
+		m := mixer{
+			inputs:  inputs,
+			gains:   make([]float64, len(tracks)),
+			offsets: make([]int64, len(tracks)),
+		}
+		for i, t := range tracks {
+			m.gains[i] = math.Pow(10, t.Gain/20)
+			m.offsets[i] = t.Offset.Frame(source.SampleRate)
+		}
+		source.SourceFunc = m.read
+		return source, nil
+	}
+}
+
+type mixer struct {
+	inputs  []*fifo
+	gains   []float64
+	offsets []int64
+	// position is the index of the next output frame.
+	position int64
+}
+
+func (m *mixer) read(out signal.Floating) (int, error) {
+	n := int64(out.Length())
+	for i := 0; i < out.Len(); i++ {
+		out.SetSample(i, 0)
+	}
+	// produced is the number of output frames that contain any track or
+	// precede the start of any track.
+	var produced int64
+	for i, in := range m.inputs {
+		// from is the output frame where the track starts.
+		from := m.offsets[i] - m.position
+		if from >= n {
+			produced = n
+			continue
+		}
+		if from < 0 {
+			from = 0
+		}
+		if err := in.fill(int(n - from)); err != nil {
+			return 0, err
+		}
+		available := int64(in.frames())
+		if available > n-from {
+			available = n - from
+		}
+		for f := int64(0); f < available; f++ {
+			for c := 0; c < in.channels; c++ {
+				idx := int(from+f)*in.channels + c
+				out.SetSample(idx, out.Sample(idx)+in.data[int(f)*in.channels+c]*m.gains[i])
+			}
+		}
+		in.discard(int(available))
+		if available > 0 && from+available > produced {
+			produced = from + available
+		}
+	}
+	if produced == 0 {
+		return 0, io.EOF
+	}
+	m.position += produced
+	return int(produced), nil
+}
@@ -0,0 +1,80 @@
+package process_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/pipe"
+
+	"pipelined.dev/phono/process"
+)
+
+func TestMix(t *testing.T) {
+	// constant returns source where all samples have provided value.
+	constant := func(channels, frames int, v float64) pipe.SourceAllocatorFunc {
+		return samples(1000, channels, frames, func(c, i int) float64 {
+			return v
+		})
+	}
+	testMix := func(tracks []process.Track, convert bool, length int, expected func(frame int) float64) func(*testing.T) {
+		return func(t *testing.T) {
+			r := run(t, process.Mix(tracks, convert, process.ResampleLow))
+			for c := range r.samples {
+				assert.Equal(t, length, len(r.samples[c]))
+				for i, v := range r.samples[c] {
+					if !assert.InDelta(t, expected(i), v, 1e-6, "frame %d", i) {
+						return
+					}
+				}
+			}
+		}
+	}
+	t.Run("sum", testMix([]process.Track{
+		{Source: constant(2, 1000, 0.1)},
+		{Source: constant(2, 600, 0.2)},
+	}, false, 1000, func(i int) float64 {
+		if i < 600 {
+			return 0.3
+		}
+		return 0.1
+	}))
+	t.Run("gain and offset", testMix([]process.Track{
+		{Source: constant(2, 1000, 0.5), Gain: -6.0206},
+		{Source: constant(2, 1000, 0.1), Offset: process.AtFrame(1500)},
+	}, false, 2500, func(i int) float64 {
+		switch {
+		case i < 1000:
+			return 0.25
+		case i < 1500:
+			return 0
+		}
+		return 0.1
+	}))
+	t.Run("offset in time", testMix([]process.Track{
+		{Source: constant(2, 100, 0.1), Offset: process.AtTime(1e9)},
+	}, false, 1100, func(i int) float64 {
+		if i < 1000 {
+			return 0
+		}
+		return 0.1
+	}))
+	t.Run("convert channels", testMix([]process.Track{
+		{Source: constant(2, 1000, 0.1)},
+		{Source: constant(1, 1000, 0.2)},
+	}, true, 1000, func(i int) float64 {
+		return 0.3
+	}))
+	t.Run("different channels", func(t *testing.T) {
+		var r result
+		err := pipe.Run(context.Background(), bufferSize, pipe.Line{
+			Source: process.Mix([]process.Track{
+				{Source: constant(2, 100, 1)},
+				{Source: constant(1, 100, 1)},
+			}, false, ""),
+			Sink: r.sink(),
+		})
+		assert.NotNil(t, err)
+	})
+}
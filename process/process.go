@@ -0,0 +1,117 @@
+// Package process provides DSP stages that can be inserted between
+// source and sink of encoding line.
+//
+// Stages that change the number of samples, like resampling or trimming,
+// wrap the source allocator and pull the signal from it. Other stages
+// are regular pipe processors.
+package process
+
+import (
+	"io"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+// fifo pulls the signal from the source and keeps frames that weren't
+// consumed yet.
+type fifo struct {
+	source   pipe.Source
+	in       signal.Floating
+	channels int
+	// buf keeps interleaved frames, consumed ones are dropped only when
+	// they take more than half of it.
+	buf []float64
+	// data is the part of buf that wasn't consumed.
+	data []float64
+	// offset is the index of the first frame in data.
+	offset int64
+	eof    bool
+}
+
+// wrap allocates the source and returns the fifo that reads from it.
+// Returned source has the same hooks and properties as the wrapped one.
+func wrap(fn pipe.SourceAllocatorFunc, mctx mutable.Context, bufferSize int) (pipe.Source, *fifo, error) {
+	source, err := fn(mctx, bufferSize)
+	if err != nil {
+		return pipe.Source{}, nil, err
+	}
+	channels := source.SignalProperties.Channels
+	return pipe.Source{
+			StartFunc:        source.StartFunc,
+			FlushFunc:        source.FlushFunc,
+			SignalProperties: source.SignalProperties,
+		},
+		&fifo{
+			source: source,
+			in: signal.Allocator{
+				Channels: channels,
+				Length:   bufferSize,
+				Capacity: bufferSize,
+			}.Float64(),
+			channels: channels,
+		}, nil
+}
+
+// frames returns the number of buffered frames.
+func (f *fifo) frames() int {
+	return len(f.data) / f.channels
+}
+
+// end returns the index of the frame after the last buffered one.
+func (f *fifo) end() int64 {
+	return f.offset + int64(f.frames())
+}
+
+// fill reads the source until fifo contains at least n frames or the
+// source is done.
+func (f *fifo) fill(n int) error {
+	for !f.eof && f.frames() < n {
+		read, err := f.source.SourceFunc(f.in)
+		if err != nil {
+			if err == io.EOF {
+				f.eof = true
+				return nil
+			}
+			return err
+		}
+		consumed := len(f.buf) - len(f.data)
+		for i := 0; i < read*f.channels; i++ {
+			f.buf = append(f.buf, f.in.Sample(i))
+		}
+		f.data = f.buf[consumed:]
+	}
+	return nil
+}
+
+// sample returns the sample of absolute frame index. Zero is returned for
+// frames out of buffered range.
+func (f *fifo) sample(frame int64, channel int) float64 {
+	i := frame - f.offset
+	if i < 0 || i >= int64(f.frames()) {
+		return 0
+	}
+	return f.data[int(i)*f.channels+channel]
+}
+
+// discard drops n frames from the beginning.
+func (f *fifo) discard(n int) {
+	if n <= 0 {
+		return
+	}
+	if n > f.frames() {
+		n = f.frames()
+	}
+	f.data = f.data[n*f.channels:]
+	f.offset += int64(n)
+	if consumed := len(f.buf) - len(f.data); consumed > len(f.buf)/2 {
+		f.buf = f.buf[:copy(f.buf, f.data)]
+		f.data = f.buf
+	}
+}
+
+// discardBefore drops all frames before provided absolute index.
+func (f *fifo) discardBefore(frame int64) {
+	f.discard(int(frame - f.offset))
+}
@@ -0,0 +1,105 @@
+package process_test
+
+import (
+	"context"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+const bufferSize = 512
+
+// generator returns source of sine with provided frequency. Every next
+// channel has doubled amplitude.
+func generator(sampleRate signal.Frequency, channels, frames int, freq, amplitude float64) pipe.SourceAllocatorFunc {
+	return sine(sampleRate, channels, frames, freq, func(c int) float64 {
+		return amplitude * float64(c+1)
+	})
+}
+
+// sine returns source of sine with provided frequency and amplitude of
+// every channel.
+func sine(sampleRate signal.Frequency, channels, frames int, freq float64, amplitude func(channel int) float64) pipe.SourceAllocatorFunc {
+	return samples(sampleRate, channels, frames, func(c, i int) float64 {
+		return amplitude(c) * math.Sin(2*math.Pi*freq*float64(i)/float64(sampleRate))
+	})
+}
+
+// equal returns the same amplitude for all channels.
+func equal(amplitude float64) func(int) float64 {
+	return func(int) float64 { return amplitude }
+}
+
+// samples returns source of signal defined by provided function.
+func samples(sampleRate signal.Frequency, channels, frames int, fn func(channel, frame int) float64) pipe.SourceAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int) (pipe.Source, error) {
+		pos := 0
+		return pipe.Source{
+			SignalProperties: pipe.SignalProperties{
+				SampleRate: sampleRate,
+				Channels:   channels,
+			},
+			SourceFunc: func(out signal.Floating) (int, error) {
+				if pos == frames {
+					return 0, io.EOF
+				}
+				n := 0
+				for ; n < out.Length() && pos < frames; n++ {
+					for c := 0; c < channels; c++ {
+						out.SetSample(n*channels+c, fn(c, pos))
+					}
+					pos++
+				}
+				return n, nil
+			},
+		}, nil
+	}
+}
+
+// result is collected by the sink.
+type result struct {
+	pipe.SignalProperties
+	// samples per channel.
+	samples [][]float64
+}
+
+func (r *result) sink() pipe.SinkAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Sink, error) {
+		r.SignalProperties = props
+		r.samples = make([][]float64, props.Channels)
+		return pipe.Sink{
+			SinkFunc: func(in signal.Floating) error {
+				for i := 0; i < in.Len(); i++ {
+					c := i % props.Channels
+					r.samples[c] = append(r.samples[c], in.Sample(i))
+				}
+				return nil
+			},
+		}, nil
+	}
+}
+
+// run executes the line and returns the result.
+func run(t *testing.T, source pipe.SourceAllocatorFunc, processors ...pipe.ProcessorAllocatorFunc) result {
+	t.Helper()
+	var r result
+	runLine(t, source, r.sink(), processors...)
+	return r
+}
+
+// runLine executes the line with provided sink.
+func runLine(t *testing.T, source pipe.SourceAllocatorFunc, sink pipe.SinkAllocatorFunc, processors ...pipe.ProcessorAllocatorFunc) {
+	t.Helper()
+	err := pipe.Run(context.Background(), bufferSize, pipe.Line{
+		Source:     source,
+		Processors: processors,
+		Sink:       sink,
+	})
+	assert.Nil(t, err)
+}
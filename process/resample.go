@@ -0,0 +1,193 @@
+package process
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+// ResampleQuality defines the trade-off between speed and quality of
+// resampling.
+type ResampleQuality string
+
+// Resampling qualities. Low quality uses linear interpolation, medium and
+// high use windowed sinc interpolation with different kernel lengths.
+const (
+	ResampleLow    ResampleQuality = "low"
+	ResampleMedium ResampleQuality = "medium"
+	ResampleHigh   ResampleQuality = "high"
+)
+
+// sinc kernel parameters.
+type kernel struct {
+	// zeroCrossings on each side of the kernel.
+	zeroCrossings int
+	// beta of kaiser window.
+	beta float64
+	// rolloff of cutoff frequency relative to nyquist.
+	rolloff float64
+	// table contains kernel values from zero to last zero crossing.
+	table []float64
+}
+
+// tableResolution is a number of table values per zero crossing.
+const tableResolution = 512
+
+var kernels = map[ResampleQuality]kernel{
+	ResampleMedium: {zeroCrossings: 8, beta: 6, rolloff: 0.9},
+	ResampleHigh:   {zeroCrossings: 32, beta: 9, rolloff: 0.95},
+}
+
+// Resample wraps the source, so its output has provided sample rate.
+// Source is not changed if it already has provided sample rate.
+func Resample(fn pipe.SourceAllocatorFunc, sampleRate signal.Frequency, quality ResampleQuality) pipe.SourceAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int) (pipe.Source, error) {
+		if sampleRate <= 0 {
+			return pipe.Source{}, fmt.Errorf("invalid sample rate: %v", sampleRate)
+		}
+		k, ok := kernels[quality]
+		if !ok && quality != ResampleLow {
+			return pipe.Source{}, fmt.Errorf("unsupported resample quality: %v", quality)
+		}
+		source, in, err := wrap(fn, mctx, bufferSize)
+		if err != nil {
+			return pipe.Source{}, err
+		}
+		if source.SampleRate == sampleRate {
+			return in.source, nil
+		}
+		r := resampler{
+			fifo:    in,
+			inRate:  int64(source.SampleRate),
+			outRate: int64(sampleRate),
+			total:   -1,
+		}
+		if quality == ResampleLow {
+			r.width = 1
+		} else {
+			k.table = kaiserSinc(k.zeroCrossings, k.beta)
+			r.kernel = &k
+			// cutoff relative to input nyquist.
+			r.cutoff = k.rolloff * math.Min(1, float64(sampleRate)/float64(source.SampleRate))
+			r.width = int(math.Ceil(float64(k.zeroCrossings) / r.cutoff))
+			r.weights = make([]float64, 2*r.width)
+		}
+		source.SampleRate = sampleRate
+		source.SourceFunc = r.read
+		return source, nil
+	}
+}
+
+type resampler struct {
+	*fifo
+	*kernel
+	inRate  int64
+	outRate int64
+	cutoff  float64
+	// width is the number of input frames used on each side.
+	width   int
+	weights []float64
+	// next output frame.
+	next int64
+	// total number of output frames, known when input is done.
+	total int64
+}
+
+func (r *resampler) read(out signal.Floating) (int, error) {
+	n := 0
+	for n < out.Length() {
+		pos := r.next * r.inRate
+		idx := pos / r.outRate
+		frac := float64(pos%r.outRate) / float64(r.outRate)
+		if err := r.fill(int(idx - r.offset + int64(r.width) + 1)); err != nil {
+			return 0, err
+		}
+		if r.eof && r.total < 0 {
+			r.total = (r.end()*r.outRate + r.inRate - 1) / r.inRate
+		}
+		if r.total >= 0 && r.next >= r.total {
+			break
+		}
+		if r.kernel == nil {
+			for c := 0; c < r.channels; c++ {
+				v := r.sample(idx, c)*(1-frac) + r.sample(idx+1, c)*frac
+				out.SetSample(n*r.channels+c, v)
+			}
+		} else {
+			r.interpolate(out, n, idx, frac)
+		}
+		r.next++
+		n++
+		r.discardBefore(idx - int64(r.width))
+	}
+	if n == 0 {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+// interpolate calculates output frame at input position idx+frac.
+func (r *resampler) interpolate(out signal.Floating, n int, idx int64, frac float64) {
+	first := idx - int64(r.width) + 1
+	for i := range r.weights {
+		// distance from input frame to output position.
+		d := float64(idx-first-int64(i)) + frac
+		r.weights[i] = r.cutoff * r.lookup(math.Abs(d)*r.cutoff)
+	}
+	for c := 0; c < r.channels; c++ {
+		var v float64
+		for i, w := range r.weights {
+			v += w * r.sample(first+int64(i), c)
+		}
+		out.SetSample(n*r.channels+c, v)
+	}
+}
+
+// lookup returns kernel value at provided number of zero crossings.
+func (k *kernel) lookup(x float64) float64 {
+	if x >= float64(k.zeroCrossings) {
+		return 0
+	}
+	pos := x * tableResolution
+	i := int(pos)
+	frac := pos - float64(i)
+	return k.table[i]*(1-frac) + k.table[i+1]*frac
+}
+
+// kaiserSinc returns table of sinc function multiplied by kaiser window.
+func kaiserSinc(zeroCrossings int, beta float64) []float64 {
+	size := zeroCrossings*tableResolution + 1
+	table := make([]float64, size+1)
+	norm := besselI0(beta)
+	for i := 0; i < size; i++ {
+		x := float64(i) / tableResolution
+		ratio := x / float64(zeroCrossings)
+		window := besselI0(beta*math.Sqrt(1-ratio*ratio)) / norm
+		table[i] = sinc(x) * window
+	}
+	return table
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// besselI0 is a zero order modified bessel function of the first kind.
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; k < 50; k++ {
+		term *= (x / (2 * float64(k))) * (x / (2 * float64(k)))
+		sum += term
+		if term < sum*1e-12 {
+			break
+		}
+	}
+	return sum
+}
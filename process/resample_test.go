@@ -0,0 +1,62 @@
+package process_test
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/signal"
+
+	"pipelined.dev/phono/process"
+)
+
+func TestResample(t *testing.T) {
+	testResample := func(quality process.ResampleQuality, inRate, outRate int, frames int, maxError float64) func(*testing.T) {
+		return func(t *testing.T) {
+			freq := 1000.0
+			r := run(t, process.Resample(generator(signal.Frequency(inRate), 2, frames, freq, 0.4), signal.Frequency(outRate), quality))
+			assert.Equal(t, signal.Frequency(outRate), r.SampleRate)
+			assert.Equal(t, 2, r.Channels)
+			expectedFrames := int(math.Ceil(float64(frames) * float64(outRate) / float64(inRate)))
+			assert.Equal(t, expectedFrames, len(r.samples[0]))
+			assert.Equal(t, expectedFrames, len(r.samples[1]))
+			// skip edges, they are affected by kernel.
+			var maxDiff float64
+			for c := range r.samples {
+				for i := 100; i < len(r.samples[c])-100; i++ {
+					expected := 0.4 * float64(c+1) * math.Sin(2*math.Pi*freq*float64(i)/float64(outRate))
+					maxDiff = math.Max(maxDiff, math.Abs(expected-r.samples[c][i]))
+				}
+			}
+			assert.Less(t, maxDiff, maxError)
+		}
+	}
+	t.Run("low up", testResample(process.ResampleLow, 44100, 48000, 10000, 0.005))
+	t.Run("medium up", testResample(process.ResampleMedium, 44100, 48000, 10000, 0.001))
+	t.Run("high up", testResample(process.ResampleHigh, 44100, 48000, 10000, 1e-4))
+	t.Run("high down", testResample(process.ResampleHigh, 48000, 44100, 10000, 1e-4))
+	t.Run("high down 2x", testResample(process.ResampleHigh, 44100, 22050, 10000, 1e-4))
+	t.Run("same rate", testResample(process.ResampleHigh, 44100, 44100, 1000, 1e-12))
+
+	t.Run("anti-aliasing", func(t *testing.T) {
+		// tone above output nyquist must be filtered out.
+		r := run(t, process.Resample(generator(48000, 1, 48000, 15000, 0.5), 22050, process.ResampleHigh))
+		var sum float64
+		for i := 100; i < len(r.samples[0])-100; i++ {
+			sum += r.samples[0][i] * r.samples[0][i]
+		}
+		rms := math.Sqrt(sum / float64(len(r.samples[0])-200))
+		assert.Less(t, rms, 0.001)
+	})
+	t.Run("invalid quality", func(t *testing.T) {
+		var r result
+		err := pipe.Run(context.Background(), bufferSize, pipe.Line{
+			Source: process.Resample(generator(44100, 1, 100, 1000, 0.5), 48000, "fake"),
+			Sink:   r.sink(),
+		})
+		assert.NotNil(t, err)
+	})
+}
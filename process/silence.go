@@ -0,0 +1,174 @@
+package process
+
+import (
+	"io"
+	"math"
+	"time"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+// Silence defines what is considered as silence.
+type Silence struct {
+	// Threshold in dBFS. Frame is silent if all channels are below it.
+	Threshold float64
+	// MinDuration of silence that is trimmed. Shorter silence is kept.
+	MinDuration Position
+	// Padding of silence kept next to the sound.
+	Padding Position
+}
+
+// TrimSilence returns source that drops leading and trailing silence of
+// the wrapped source. Report is called when the source is done with
+// durations of trimmed silence. Trailing silence can be detected only at
+// the end, so silent frames are kept in memory until the next sound.
+func TrimSilence(fn pipe.SourceAllocatorFunc, s Silence, report func(start, end time.Duration)) pipe.SourceAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int) (pipe.Source, error) {
+		source, f, err := wrap(fn, mctx, bufferSize)
+		if err != nil {
+			return pipe.Source{}, err
+		}
+		sampleRate := source.SignalProperties.SampleRate
+		t := silenceTrimmer{
+			fifo:        f,
+			threshold:   math.Pow(10, s.Threshold/20),
+			minDuration: s.MinDuration.Frame(sampleRate),
+			padding:     s.Padding.Frame(sampleRate),
+			lastSound:   -1,
+		}
+		source.SourceFunc = func(out signal.Floating) (int, error) {
+			n, err := t.read(out)
+			if err == io.EOF && report != nil {
+				report(frameDuration(t.start, sampleRate), frameDuration(t.trimmedEnd, sampleRate))
+			}
+			return n, err
+		}
+		return source, nil
+	}
+}
+
+func frameDuration(frames int64, sampleRate signal.Frequency) time.Duration {
+	return time.Duration(float64(frames) / float64(sampleRate) * float64(time.Second))
+}
+
+type silenceTrimmer struct {
+	*fifo
+	threshold   float64
+	minDuration int64
+	padding     int64
+	// scanned is the index of the first frame that isn't checked for
+	// sound yet.
+	scanned   int64
+	started   bool
+	lastSound int64
+	// start is the first frame of the output.
+	start int64
+	// stop is the frame after the last frame of the output. It's known
+	// only after the source is done.
+	stop       int64
+	trimmedEnd int64
+	done       bool
+}
+
+func (t *silenceTrimmer) read(out signal.Floating) (int, error) {
+	for {
+		limit := t.limit()
+		if limit > t.offset {
+			n := out.Length()
+			if int64(n) > limit-t.offset {
+				n = int(limit - t.offset)
+			}
+			for i := 0; i < n*t.channels; i++ {
+				out.SetSample(i, t.data[i])
+			}
+			t.discard(n)
+			return n, nil
+		}
+		if t.done {
+			return 0, io.EOF
+		}
+		if err := t.fill(t.frames() + out.Length()); err != nil {
+			return 0, err
+		}
+		t.scan()
+		if t.eof {
+			t.finish()
+		}
+	}
+}
+
+// limit returns the index of frame before which all frames can be sent.
+func (t *silenceTrimmer) limit() int64 {
+	var limit int64
+	switch {
+	case t.done:
+		limit = t.stop
+	case t.started:
+		// frames next to the sound are kept anyway.
+		limit = t.lastSound + t.padding + 1
+	default:
+		return t.offset
+	}
+	if limit > t.end() {
+		limit = t.end()
+	}
+	return limit
+}
+
+// scan checks new frames for sound.
+func (t *silenceTrimmer) scan() {
+	for ; t.scanned < t.end(); t.scanned++ {
+		i := int(t.scanned-t.offset) * t.channels
+		for c := 0; c < t.channels; c++ {
+			if math.Abs(t.data[i+c]) >= t.threshold {
+				t.sound(t.scanned)
+				break
+			}
+		}
+	}
+	// leading silence is long enough to be trimmed, so only padding is
+	// kept.
+	if !t.started && t.scanned >= t.minDuration {
+		t.discardBefore(t.scanned - t.padding)
+	}
+}
+
+// sound is called for every frame with sound.
+func (t *silenceTrimmer) sound(frame int64) {
+	t.lastSound = frame
+	if t.started {
+		return
+	}
+	t.started = true
+	if frame >= t.minDuration {
+		t.start = frame - t.padding
+		if t.start < 0 {
+			t.start = 0
+		}
+		t.discardBefore(t.start)
+	}
+}
+
+// finish defines the end when the source is done.
+func (t *silenceTrimmer) finish() {
+	t.done = true
+	total := t.end()
+	t.stop = total
+	if !t.started {
+		// the whole signal is silent.
+		if total >= t.minDuration {
+			t.start = total
+			t.discardBefore(total)
+		}
+		return
+	}
+	if trailing := total - t.lastSound - 1; trailing >= t.minDuration {
+		t.stop = t.lastSound + t.padding + 1
+		if t.stop > total {
+			t.stop = total
+		}
+	}
+	t.trimmedEnd = total - t.stop
+}
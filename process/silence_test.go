@@ -0,0 +1,71 @@
+package process_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/phono/process"
+)
+
+func TestTrimSilence(t *testing.T) {
+	// sound is the value of every frame between silences, second channel
+	// is always silent.
+	testTrimSilence := func(lead, sound, tail int, s process.Silence, first, length int, start, end time.Duration) func(*testing.T) {
+		return func(t *testing.T) {
+			source := samples(1000, 2, lead+sound+tail, func(c, i int) float64 {
+				if c == 0 && i >= lead && i < lead+sound {
+					return float64(i)
+				}
+				return 0.0001
+			})
+			var trimmedStart, trimmedEnd time.Duration
+			reported := false
+			r := run(t, process.TrimSilence(source, s, func(start, end time.Duration) {
+				trimmedStart, trimmedEnd, reported = start, end, true
+			}))
+			assert.True(t, reported)
+			assert.Equal(t, start, trimmedStart)
+			assert.Equal(t, end, trimmedEnd)
+			assert.Equal(t, length, len(r.samples[0]))
+			for i, v := range r.samples[0] {
+				frame := first + i
+				expected := 0.0001
+				if frame >= lead && frame < lead+sound {
+					expected = float64(frame)
+				}
+				if !assert.Equal(t, expected, v, "frame %d", i) {
+					return
+				}
+			}
+		}
+	}
+	silence := process.Silence{
+		Threshold:   -60,
+		MinDuration: process.AtTime(500 * time.Millisecond),
+		Padding:     process.AtFrame(100),
+	}
+	t.Run("both", testTrimSilence(2000, 3000, 4000, silence, 1900, 3200, 1900*time.Millisecond, 3900*time.Millisecond))
+	t.Run("short lead", testTrimSilence(400, 3000, 4000, silence, 0, 3500, 0, 3900*time.Millisecond))
+	t.Run("short tail", testTrimSilence(2000, 3000, 499, silence, 1900, 3599, 1900*time.Millisecond, 0))
+	t.Run("no silence", testTrimSilence(0, 3000, 0, silence, 0, 3000, 0, 0))
+	t.Run("padding longer than silence", testTrimSilence(600, 3000, 600, process.Silence{
+		Threshold:   -60,
+		MinDuration: process.AtFrame(500),
+		Padding:     process.AtFrame(1000),
+	}, 0, 4200, 0, 0))
+	t.Run("all silent", testTrimSilence(5000, 0, 0, silence, 0, 0, 5*time.Second, 0))
+	t.Run("short all silent", testTrimSilence(100, 0, 0, silence, 0, 100, 0, 0))
+	// silence in the middle is kept, long buffers are sent in parts.
+	t.Run("middle", func(t *testing.T) {
+		source := samples(1000, 1, 10000, func(c, i int) float64 {
+			if i < 1000 || (i >= 4000 && i < 9000) {
+				return 0
+			}
+			return 1
+		})
+		r := run(t, process.TrimSilence(source, silence, nil))
+		assert.Equal(t, 10000-900, len(r.samples[0]))
+	})
+}
@@ -0,0 +1,267 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/bits"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+// Window functions applied before the transform.
+const (
+	WindowRectangular Window = "rectangular"
+	WindowHann        Window = "hann"
+	WindowHamming     Window = "hamming"
+	WindowBlackman    Window = "blackman"
+)
+
+// minMagnitude limits magnitudes of silent bins, so they are finite.
+const minMagnitude = 1e-12
+
+type (
+	// Window is a window function.
+	Window string
+
+	// Spectrogram contains magnitudes of frequency bins over time.
+	Spectrogram struct {
+		SampleRate signal.Frequency
+		// Size of the transform, every column contains Size/2+1 bins.
+		Size   int
+		Frames int64
+		// Columns are magnitudes in dBFS. Every column covers the same
+		// number of frames, except the last one.
+		Columns [][]float64
+	}
+
+	// SpectrumAnalyzer measures spectrogram of the signal with short-time
+	// Fourier transform. Channels are merged.
+	SpectrumAnalyzer struct {
+		// Size of the transform in frames, must be a power of two.
+		Size int
+		// Overlap of adjacent windows, between 0 and 1.
+		Overlap float64
+		Window  Window
+		// MaxColumns limits memory used by long signals. If it's
+		// exceeded, adjacent columns are merged by maximum. Zero means
+		// no limit.
+		MaxColumns int
+
+		spectrogram  Spectrogram
+		coefficients []float64
+		// gain scales magnitudes, so full scale sine has 0 dBFS.
+		gain float64
+		hop  int
+		// buffer contains frames of the current window, fresh is the
+		// number of frames that weren't transformed yet.
+		buffer []float64
+		filled int
+		fresh  int
+		re, im []float64
+		// merged is the number of windows per column, pending is the
+		// incomplete column that consists of count windows.
+		merged  int
+		pending []float64
+		count   int
+	}
+)
+
+// Sink returns the sink that measures the spectrogram.
+func (a *SpectrumAnalyzer) Sink() pipe.SinkAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Sink, error) {
+		if err := a.reset(props); err != nil {
+			return pipe.Sink{}, err
+		}
+		return pipe.Sink{
+			SinkFunc: func(in signal.Floating) error {
+				a.write(in)
+				return nil
+			},
+			FlushFunc: func(context.Context) error {
+				a.flush()
+				return nil
+			},
+		}, nil
+	}
+}
+
+// Spectrogram returns the measured spectrogram.
+func (a *SpectrumAnalyzer) Spectrogram() Spectrogram {
+	return a.spectrogram
+}
+
+func (a *SpectrumAnalyzer) reset(props pipe.SignalProperties) error {
+	if a.Size < 2 || bits.OnesCount(uint(a.Size)) != 1 {
+		return fmt.Errorf("transform size must be a power of two: %d", a.Size)
+	}
+	if a.Overlap < 0 || a.Overlap >= 1 {
+		return fmt.Errorf("overlap must be between 0 and 1: %v", a.Overlap)
+	}
+	coefficients, err := a.Window.coefficients(a.Size)
+	if err != nil {
+		return err
+	}
+	var sum float64
+	for _, c := range coefficients {
+		sum += c
+	}
+	a.coefficients = coefficients
+	a.gain = 2 / sum
+	a.hop = int(math.Round(float64(a.Size) * (1 - a.Overlap)))
+	if a.hop < 1 {
+		a.hop = 1
+	}
+	a.spectrogram = Spectrogram{
+		SampleRate: props.SampleRate,
+		Size:       a.Size,
+	}
+	a.buffer = make([]float64, a.Size)
+	a.re, a.im = make([]float64, a.Size), make([]float64, a.Size)
+	a.filled, a.fresh = 0, 0
+	a.merged, a.pending, a.count = 1, nil, 0
+	return nil
+}
+
+func (a *SpectrumAnalyzer) write(in signal.Floating) {
+	channels := in.Channels()
+	for i := 0; i < in.Length(); i++ {
+		var v float64
+		for c := 0; c < channels; c++ {
+			v += in.Sample(i*channels + c)
+		}
+		a.buffer[a.filled] = v / float64(channels)
+		a.filled++
+		a.fresh++
+		if a.filled == a.Size {
+			a.transform()
+			copy(a.buffer, a.buffer[a.hop:])
+			a.filled -= a.hop
+		}
+	}
+	a.spectrogram.Frames += int64(in.Length())
+}
+
+// flush transforms remaining frames padded with zeros and adds
+// incomplete column.
+func (a *SpectrumAnalyzer) flush() {
+	if a.fresh > 0 {
+		for i := a.filled; i < a.Size; i++ {
+			a.buffer[i] = 0
+		}
+		a.transform()
+	}
+	if a.count > 0 {
+		a.spectrogram.Columns = append(a.spectrogram.Columns, a.pending)
+		a.pending, a.count = nil, 0
+	}
+}
+
+// transform adds the spectrum of the buffer to the spectrogram.
+func (a *SpectrumAnalyzer) transform() {
+	for i, v := range a.buffer {
+		a.re[i], a.im[i] = v*a.coefficients[i], 0
+	}
+	fft(a.re, a.im)
+	column := make([]float64, a.Size/2+1)
+	for i := range column {
+		magnitude := math.Hypot(a.re[i], a.im[i]) * a.gain
+		column[i] = 20 * math.Log10(math.Max(magnitude, minMagnitude))
+	}
+	a.fresh = 0
+	a.add(column)
+}
+
+// add merges the column into pending one. Complete columns are appended
+// to the spectrogram.
+func (a *SpectrumAnalyzer) add(column []float64) {
+	if a.count == 0 {
+		a.pending = column
+	} else {
+		mergeColumn(a.pending, column)
+	}
+	a.count++
+	if a.count < a.merged {
+		return
+	}
+	a.spectrogram.Columns = append(a.spectrogram.Columns, a.pending)
+	a.pending, a.count = nil, 0
+	if a.MaxColumns == 0 || len(a.spectrogram.Columns) < 2*a.MaxColumns {
+		return
+	}
+	// merge pairs of columns, so every column covers twice more windows.
+	columns := a.spectrogram.Columns
+	for i := 0; i < len(columns)/2; i++ {
+		mergeColumn(columns[2*i], columns[2*i+1])
+		columns[i] = columns[2*i]
+	}
+	a.spectrogram.Columns = columns[:len(columns)/2]
+	a.merged *= 2
+}
+
+// mergeColumn keeps maximum magnitudes of two columns in the first one.
+func mergeColumn(dst, src []float64) {
+	for i, v := range src {
+		if v > dst[i] {
+			dst[i] = v
+		}
+	}
+}
+
+// coefficients returns coefficients of the window with provided size.
+func (w Window) coefficients(size int) ([]float64, error) {
+	var fn func(x float64) float64
+	switch w {
+	case WindowRectangular:
+		fn = func(float64) float64 { return 1 }
+	case WindowHann, "":
+		fn = func(x float64) float64 { return 0.5 - 0.5*math.Cos(2*math.Pi*x) }
+	case WindowHamming:
+		fn = func(x float64) float64 { return 0.54 - 0.46*math.Cos(2*math.Pi*x) }
+	case WindowBlackman:
+		fn = func(x float64) float64 {
+			return 0.42 - 0.5*math.Cos(2*math.Pi*x) + 0.08*math.Cos(4*math.Pi*x)
+		}
+	default:
+		return nil, fmt.Errorf("unknown window: %v", w)
+	}
+	result := make([]float64, size)
+	for i := range result {
+		result[i] = fn(float64(i) / float64(size))
+	}
+	return result, nil
+}
+
+// fft is in-place iterative radix-2 transform. Length must be a power of
+// two.
+func fft(re, im []float64) {
+	n := len(re)
+	// bit-reversal permutation.
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			re[i], re[j] = re[j], re[i]
+			im[i], im[j] = im[j], im[i]
+		}
+	}
+	for length := 2; length <= n; length <<= 1 {
+		sin, cos := math.Sincos(-2 * math.Pi / float64(length))
+		for start := 0; start < n; start += length {
+			wr, wi := 1.0, 0.0
+			for k := 0; k < length/2; k++ {
+				a, b := start+k, start+k+length/2
+				tr := re[b]*wr - im[b]*wi
+				ti := re[b]*wi + im[b]*wr
+				re[b], im[b] = re[a]-tr, im[a]-ti
+				re[a], im[a] = re[a]+tr, im[a]+ti
+				wr, wi = wr*cos-wi*sin, wr*sin+wi*cos
+			}
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package process_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/pipe"
+
+	"pipelined.dev/phono/process"
+)
+
+func TestSpectrumAnalyzer(t *testing.T) {
+	testAnalyzer := func(a process.SpectrumAnalyzer, columns int) func(*testing.T) {
+		return func(t *testing.T) {
+			t.Helper()
+			// 1500 Hz is exactly at bin 32 of 1024 transform.
+			err := pipe.Run(context.Background(), bufferSize, pipe.Line{
+				Source: generator(48000, 1, 48000, 1500, 0.5),
+				Sink:   a.Sink(),
+			})
+			assert.Nil(t, err)
+			s := a.Spectrogram()
+			assert.Equal(t, int64(48000), s.Frames)
+			assert.Equal(t, 1024, s.Size)
+			assert.Equal(t, columns, len(s.Columns))
+			for _, column := range s.Columns {
+				assert.Equal(t, 513, len(column))
+				peak := 0
+				for i, v := range column {
+					if v > column[peak] {
+						peak = i
+					}
+				}
+				assert.Equal(t, 32, peak)
+			}
+			// full windows of half scale sine have -6 dBFS.
+			assert.InDelta(t, -6.02, s.Columns[1][32], 0.01)
+			assert.Less(t, s.Columns[1][100], -60.0)
+		}
+	}
+	t.Run("hann", testAnalyzer(process.SpectrumAnalyzer{
+		Size:    1024,
+		Overlap: 0.5,
+		Window:  process.WindowHann,
+	}, 93))
+	t.Run("blackman", testAnalyzer(process.SpectrumAnalyzer{
+		Size:    1024,
+		Overlap: 0.75,
+		Window:  process.WindowBlackman,
+	}, 185))
+	t.Run("merged columns", testAnalyzer(process.SpectrumAnalyzer{
+		Size:       1024,
+		Overlap:    0.5,
+		Window:     process.WindowHamming,
+		MaxColumns: 10,
+	}, 12))
+	t.Run("short signal", func(t *testing.T) {
+		a := process.SpectrumAnalyzer{Size: 4096}
+		err := pipe.Run(context.Background(), bufferSize, pipe.Line{
+			Source: generator(48000, 2, 1000, 1500, 0.5),
+			Sink:   a.Sink(),
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(a.Spectrogram().Columns))
+	})
+	testInvalid := func(a process.SpectrumAnalyzer) func(*testing.T) {
+		return func(t *testing.T) {
+			t.Helper()
+			err := pipe.Run(context.Background(), bufferSize, pipe.Line{
+				Source: generator(48000, 1, 1000, 1500, 0.5),
+				Sink:   a.Sink(),
+			})
+			assert.NotNil(t, err)
+		}
+	}
+	t.Run("invalid size", testInvalid(process.SpectrumAnalyzer{Size: 1000}))
+	t.Run("invalid overlap", testInvalid(process.SpectrumAnalyzer{Size: 1024, Overlap: 1}))
+	t.Run("invalid window", testInvalid(process.SpectrumAnalyzer{Size: 1024, Window: "fake"}))
+}
@@ -0,0 +1,258 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+// errNotMeasured is returned if parts are requested before the signal is
+// measured.
+var errNotMeasured = errors.New("signal is not measured")
+
+type (
+	// Part of the signal found by splitter. Range of the part is always
+	// defined in frames.
+	Part struct {
+		Range
+		// Title is optional, it's provided only by cues.
+		Title string
+	}
+
+	// Cue defines the start of the part. Part lasts until the next cue
+	// or the end of the signal.
+	Cue struct {
+		Start Position
+		Title string
+	}
+
+	// SilenceSplitter splits the signal at silences. Silence that is
+	// long enough is dropped except padding, so leading and trailing
+	// silences are dropped too.
+	SilenceSplitter struct {
+		counter
+		silence Silence
+		// threshold is linear.
+		threshold   float64
+		minDuration int64
+		heard       bool
+		// silentFrom is the first frame of current silence, negative if
+		// the current frame has sound.
+		silentFrom int64
+		gaps       []gap
+	}
+
+	// ChunkSplitter splits the signal into parts of the same length. The
+	// last part can be shorter.
+	ChunkSplitter struct {
+		counter
+		length Position
+	}
+
+	// CueSplitter splits the signal at provided cues. Signal before the
+	// first cue is dropped.
+	CueSplitter struct {
+		counter
+		cues []Cue
+	}
+
+	// counter measures the length of the signal.
+	counter struct {
+		sampleRate signal.Frequency
+		frames     int64
+		measured   bool
+	}
+
+	// gap is a silent range of frames.
+	gap struct {
+		start, end int64
+	}
+)
+
+// NewSilenceSplitter returns splitter for provided silence. Minimum
+// duration defines the shortest silence to split at.
+func NewSilenceSplitter(s Silence) *SilenceSplitter {
+	return &SilenceSplitter{
+		silence:   s,
+		threshold: math.Pow(10, s.Threshold/20),
+	}
+}
+
+// NewChunkSplitter returns splitter for provided length of parts.
+func NewChunkSplitter(length Position) *ChunkSplitter {
+	return &ChunkSplitter{
+		length: length,
+	}
+}
+
+// NewCueSplitter returns splitter for provided cues. Cues must be in
+// ascending order.
+func NewCueSplitter(cues []Cue) *CueSplitter {
+	return &CueSplitter{
+		cues: cues,
+	}
+}
+
+// sink returns sink that counts frames and passes the signal to the
+// function.
+func (c *counter) sink(fn func(props pipe.SignalProperties), measure func(in signal.Floating)) pipe.SinkAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Sink, error) {
+		c.sampleRate, c.frames, c.measured = props.SampleRate, 0, true
+		if fn != nil {
+			fn(props)
+		}
+		return pipe.Sink{
+			SinkFunc: func(in signal.Floating) error {
+				if measure != nil {
+					measure(in)
+				}
+				c.frames += int64(in.Length())
+				return nil
+			},
+		}, nil
+	}
+}
+
+// part returns the part between two frames.
+func part(start, end int64, title string) Part {
+	return Part{
+		Range: Range{
+			Start: AtFrame(start),
+			End:   AtFrame(end),
+		},
+		Title: title,
+	}
+}
+
+// Sink returns the sink that finds silences.
+func (s *SilenceSplitter) Sink() pipe.SinkAllocatorFunc {
+	return s.sink(
+		func(props pipe.SignalProperties) {
+			s.minDuration = s.silence.MinDuration.Frame(props.SampleRate)
+			s.heard, s.silentFrom, s.gaps = false, -1, nil
+		},
+		func(in signal.Floating) {
+			channels := in.Channels()
+			for i := 0; i < in.Length(); i++ {
+				frame := s.frames + int64(i)
+				silent := true
+				for c := 0; c < channels; c++ {
+					if math.Abs(in.Sample(i*channels+c)) >= s.threshold {
+						silent = false
+						break
+					}
+				}
+				switch {
+				case silent && s.silentFrom < 0:
+					s.silentFrom = frame
+				case !silent:
+					s.heard = true
+					s.endSilence(frame)
+				}
+			}
+		},
+	)
+}
+
+// endSilence is called when the sound starts or the signal ends.
+func (s *SilenceSplitter) endSilence(frame int64) {
+	if s.silentFrom >= 0 && frame-s.silentFrom >= s.minDuration {
+		s.gaps = append(s.gaps, gap{start: s.silentFrom, end: frame})
+	}
+	s.silentFrom = -1
+}
+
+// Parts returns the parts of sound between silences.
+func (s *SilenceSplitter) Parts() ([]Part, error) {
+	if !s.measured {
+		return nil, errNotMeasured
+	}
+	if !s.heard {
+		return nil, errors.New("signal is silent")
+	}
+	s.endSilence(s.frames)
+	padding := s.silence.Padding.Frame(s.sampleRate)
+	var (
+		parts []Part
+		start int64
+	)
+	for _, g := range s.gaps {
+		leading, trailing := g.start == 0, g.end == s.frames
+		pad := padding
+		// padding of two parts can't overlap.
+		if !leading && !trailing && 2*pad > g.end-g.start {
+			pad = (g.end - g.start) / 2
+		}
+		if pad > g.end-g.start {
+			pad = g.end - g.start
+		}
+		if !leading {
+			parts = append(parts, part(start, g.start+pad, ""))
+		}
+		start = g.end - pad
+	}
+	if len(s.gaps) == 0 || s.gaps[len(s.gaps)-1].end != s.frames {
+		parts = append(parts, part(start, s.frames, ""))
+	}
+	return parts, nil
+}
+
+// Sink returns the sink that measures the length of the signal.
+func (s *ChunkSplitter) Sink() pipe.SinkAllocatorFunc {
+	return s.sink(nil, nil)
+}
+
+// Parts returns the chunks of the signal.
+func (s *ChunkSplitter) Parts() ([]Part, error) {
+	if !s.measured {
+		return nil, errNotMeasured
+	}
+	length := s.length.Frame(s.sampleRate)
+	if length <= 0 {
+		return nil, fmt.Errorf("chunk length %v is too short", s.length)
+	}
+	var parts []Part
+	for start := int64(0); start < s.frames; start += length {
+		end := start + length
+		if end > s.frames {
+			end = s.frames
+		}
+		parts = append(parts, part(start, end, ""))
+	}
+	return parts, nil
+}
+
+// Sink returns the sink that measures the length of the signal.
+func (s *CueSplitter) Sink() pipe.SinkAllocatorFunc {
+	return s.sink(nil, nil)
+}
+
+// Parts returns the parts between cues. Error is returned if cues are
+// not in ascending order or any cue is after the end of the signal.
+func (s *CueSplitter) Parts() ([]Part, error) {
+	if !s.measured {
+		return nil, errNotMeasured
+	}
+	if len(s.cues) == 0 {
+		return nil, errors.New("no cues provided")
+	}
+	parts := make([]Part, 0, len(s.cues))
+	for i, cue := range s.cues {
+		start, end := cue.Start.Frame(s.sampleRate), s.frames
+		if start >= s.frames {
+			return nil, fmt.Errorf("cue %v is after the end of the signal", cue.Start)
+		}
+		if i < len(s.cues)-1 {
+			next := s.cues[i+1].Start
+			if end = next.Frame(s.sampleRate); end <= start {
+				return nil, fmt.Errorf("cue %v must be after cue %v", next, cue.Start)
+			}
+		}
+		parts = append(parts, part(start, end, cue.Title))
+	}
+	return parts, nil
+}
@@ -0,0 +1,121 @@
+package process_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/pipe"
+
+	"pipelined.dev/phono/process"
+)
+
+// splitter is implemented by all splitters.
+type splitter interface {
+	Sink() pipe.SinkAllocatorFunc
+	Parts() ([]process.Part, error)
+}
+
+func TestSplit(t *testing.T) {
+	// sound has value 1 in provided ranges of frames, the rest is silent.
+	sound := func(frames int, ranges ...[2]int) pipe.SourceAllocatorFunc {
+		return samples(1000, 2, frames, func(c, i int) float64 {
+			for _, r := range ranges {
+				if i >= r[0] && i < r[1] {
+					return 1
+				}
+			}
+			return 0
+		})
+	}
+	testSplit := func(source pipe.SourceAllocatorFunc, s splitter, expected [][2]int64, titles ...string) func(*testing.T) {
+		return func(t *testing.T) {
+			err := pipe.Run(context.Background(), bufferSize, pipe.Line{
+				Source: source,
+				Sink:   s.Sink(),
+			})
+			assert.Nil(t, err)
+			parts, err := s.Parts()
+			if expected == nil {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, len(expected), len(parts))
+			for i, p := range parts {
+				assert.Equal(t, expected[i][0], p.Start.Frame(1000), "start %d", i)
+				assert.Equal(t, expected[i][1], p.End.Frame(1000), "end %d", i)
+				if len(titles) > 0 {
+					assert.Equal(t, titles[i], p.Title)
+				}
+			}
+		}
+	}
+	silence := process.Silence{
+		Threshold:   -60,
+		MinDuration: process.AtTime(500 * time.Millisecond),
+		Padding:     process.AtFrame(100),
+	}
+	t.Run("silence", testSplit(
+		sound(10000, [2]int{1000, 3000}, [2]int{3200, 4000}, [2]int{5000, 9000}),
+		process.NewSilenceSplitter(silence),
+		[][2]int64{{900, 4100}, {4900, 9100}},
+	))
+	t.Run("short gap padding", testSplit(
+		sound(3000, [2]int{0, 1000}, [2]int{1600, 3000}),
+		process.NewSilenceSplitter(process.Silence{
+			Threshold:   -60,
+			MinDuration: process.AtFrame(500),
+			Padding:     process.AtFrame(1000),
+		}),
+		[][2]int64{{0, 1300}, {1300, 3000}},
+	))
+	t.Run("no silence", testSplit(
+		sound(3000, [2]int{0, 3000}),
+		process.NewSilenceSplitter(silence),
+		[][2]int64{{0, 3000}},
+	))
+	t.Run("all silent", testSplit(sound(3000), process.NewSilenceSplitter(silence), nil))
+	t.Run("chunks", testSplit(
+		sound(2500),
+		process.NewChunkSplitter(process.AtTime(time.Second)),
+		[][2]int64{{0, 1000}, {1000, 2000}, {2000, 2500}},
+	))
+	t.Run("chunks in frames", testSplit(
+		sound(2000),
+		process.NewChunkSplitter(process.AtFrame(1000)),
+		[][2]int64{{0, 1000}, {1000, 2000}},
+	))
+	t.Run("zero chunk", testSplit(sound(2000), process.NewChunkSplitter(process.AtFrame(0)), nil))
+	t.Run("cues", testSplit(
+		sound(3000),
+		process.NewCueSplitter([]process.Cue{
+			{Start: process.AtTime(500 * time.Millisecond), Title: "first"},
+			{Start: process.AtFrame(2000), Title: "second"},
+		}),
+		[][2]int64{{500, 2000}, {2000, 3000}},
+		"first", "second",
+	))
+	t.Run("cues not ordered", testSplit(
+		sound(3000),
+		process.NewCueSplitter([]process.Cue{
+			{Start: process.AtFrame(2000)},
+			{Start: process.AtFrame(1000)},
+		}),
+		nil,
+	))
+	t.Run("cue after end", testSplit(
+		sound(3000),
+		process.NewCueSplitter([]process.Cue{
+			{Start: process.AtFrame(0)},
+			{Start: process.AtTime(3 * time.Second)},
+		}),
+		nil,
+	))
+	t.Run("not measured", func(t *testing.T) {
+		_, err := process.NewChunkSplitter(process.AtFrame(1)).Parts()
+		assert.NotNil(t, err)
+	})
+}
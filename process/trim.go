@@ -0,0 +1,177 @@
+package process
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+// Position in the signal defined either by time or by number of frames.
+// Zero value is the beginning of the signal.
+type Position struct {
+	time     time.Duration
+	frames   int64
+	isFrames bool
+}
+
+// sampleSuffixes are used to define position in frames.
+var sampleSuffixes = []string{"samples", "smp"}
+
+// AtTime returns position defined by time.
+func AtTime(d time.Duration) Position {
+	return Position{time: d}
+}
+
+// AtFrame returns position defined by number of frames.
+func AtFrame(n int64) Position {
+	return Position{frames: n, isFrames: true}
+}
+
+// ParsePosition parses position in one of the formats:
+//
+//	1.5 - seconds
+//	01:02:03.500 - hours, minutes and seconds, hours are optional
+//	44100smp or 44100samples - number of frames
+func ParsePosition(s string) (Position, error) {
+	v := strings.TrimSpace(s)
+	for _, suffix := range sampleSuffixes {
+		if strings.HasSuffix(v, suffix) {
+			n, err := strconv.ParseInt(strings.TrimSpace(v[:len(v)-len(suffix)]), 10, 64)
+			if err != nil || n < 0 {
+				return Position{}, fmt.Errorf("invalid number of samples %q", s)
+			}
+			return AtFrame(n), nil
+		}
+	}
+	parts := strings.Split(v, ":")
+	if len(parts) > 3 {
+		return Position{}, fmt.Errorf("invalid time %q", s)
+	}
+	seconds, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+	if err != nil || seconds < 0 || math.IsInf(seconds, 0) || (len(parts) > 1 && seconds >= 60) {
+		return Position{}, fmt.Errorf("invalid time %q", s)
+	}
+	// minutes and hours are integers.
+	for i, multiplier := len(parts)-2, 60.0; i >= 0; i, multiplier = i-1, multiplier*60 {
+		n, err := strconv.ParseUint(parts[i], 10, 32)
+		if err != nil || (i > 0 && n >= 60) {
+			return Position{}, fmt.Errorf("invalid time %q", s)
+		}
+		seconds += float64(n) * multiplier
+	}
+	return AtTime(time.Duration(math.Round(seconds * float64(time.Second)))), nil
+}
+
+// IsZero returns true if position is the beginning of the signal.
+func (p Position) IsZero() bool {
+	return p.time == 0 && p.frames == 0
+}
+
+// Frame returns the index of frame for provided sample rate.
+func (p Position) Frame(sampleRate signal.Frequency) int64 {
+	if p.isFrames {
+		return p.frames
+	}
+	return int64(math.Round(p.time.Seconds() * float64(sampleRate)))
+}
+
+// String returns position in the format accepted by ParsePosition.
+func (p Position) String() string {
+	if p.isFrames {
+		return strconv.FormatInt(p.frames, 10) + sampleSuffixes[1]
+	}
+	return strconv.FormatFloat(p.time.Seconds(), 'f', -1, 64)
+}
+
+// Range of the signal. Zero end and duration mean the end of the signal.
+// Only one of end and duration can be provided.
+type Range struct {
+	Start    Position
+	End      Position
+	Duration Position
+}
+
+// Validate checks the range without the sample rate. End and start are
+// compared only if they have the same units. Unused field of position is
+// always zero, so both fields can be compared.
+func (r Range) Validate() error {
+	if !r.End.IsZero() && !r.Duration.IsZero() {
+		return fmt.Errorf("both end and duration are provided")
+	}
+	if !r.End.IsZero() && r.End.isFrames == r.Start.isFrames && r.End.frames <= r.Start.frames && r.End.time <= r.Start.time {
+		return fmt.Errorf("end %v must be after start %v", r.End, r.Start)
+	}
+	return nil
+}
+
+// frames returns start and end frames of the range. End is negative if
+// the range isn't limited.
+func (r Range) frames(sampleRate signal.Frequency) (int64, int64, error) {
+	if err := r.Validate(); err != nil {
+		return 0, 0, err
+	}
+	start, end := r.Start.Frame(sampleRate), int64(-1)
+	switch {
+	case !r.End.IsZero():
+		end = r.End.Frame(sampleRate)
+		if end <= start {
+			return 0, 0, fmt.Errorf("end %v must be after start %v", r.End, r.Start)
+		}
+	case !r.Duration.IsZero():
+		end = start + r.Duration.Frame(sampleRate)
+	}
+	return start, end, nil
+}
+
+// Trim returns source that reads only provided range of the wrapped
+// source. Frames before the start are read and dropped, so the range is
+// sample-accurate for any source.
+func Trim(fn pipe.SourceAllocatorFunc, r Range) pipe.SourceAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int) (pipe.Source, error) {
+		source, f, err := wrap(fn, mctx, bufferSize)
+		if err != nil {
+			return pipe.Source{}, err
+		}
+		start, end, err := r.frames(source.SignalProperties.SampleRate)
+		if err != nil {
+			return pipe.Source{}, err
+		}
+		source.SourceFunc = func(out signal.Floating) (int, error) {
+			for f.offset < start {
+				if err := f.fill(1); err != nil {
+					return 0, err
+				}
+				if f.frames() == 0 {
+					return 0, io.EOF
+				}
+				f.discardBefore(start)
+			}
+			n := out.Length()
+			if end >= 0 && f.offset+int64(n) > end {
+				n = int(end - f.offset)
+			}
+			if err := f.fill(n); err != nil {
+				return 0, err
+			}
+			if n > f.frames() {
+				n = f.frames()
+			}
+			if n <= 0 {
+				return 0, io.EOF
+			}
+			for i := 0; i < n*f.channels; i++ {
+				out.SetSample(i, f.data[i])
+			}
+			f.discard(n)
+			return n, nil
+		}
+		return source, nil
+	}
+}
@@ -0,0 +1,97 @@
+package process_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/pipe"
+
+	"pipelined.dev/phono/process"
+)
+
+func TestParsePosition(t *testing.T) {
+	var tests = []struct {
+		in       string
+		expected process.Position
+		negative bool
+	}{
+		{in: "1.5", expected: process.AtTime(1500 * time.Millisecond)},
+		{in: "0", expected: process.AtTime(0)},
+		{in: "01:02.250", expected: process.AtTime(62250 * time.Millisecond)},
+		{in: "1:02:03.001", expected: process.AtTime(time.Hour + 2*time.Minute + 3001*time.Millisecond)},
+		{in: "44100smp", expected: process.AtFrame(44100)},
+		{in: "100 samples", expected: process.AtFrame(100)},
+		{in: "", negative: true},
+		{in: "-1", negative: true},
+		{in: "1:60", negative: true},
+		{in: "1:60:00", negative: true},
+		{in: "1:2:3:4", negative: true},
+		{in: "1.5smp", negative: true},
+		{in: "abc", negative: true},
+	}
+	for _, test := range tests {
+		p, err := process.ParsePosition(test.in)
+		if test.negative {
+			assert.NotNil(t, err, test.in)
+			continue
+		}
+		assert.Nil(t, err, test.in)
+		assert.Equal(t, test.expected, p, test.in)
+		parsed, err := process.ParsePosition(p.String())
+		assert.Nil(t, err)
+		assert.Equal(t, p, parsed)
+	}
+}
+
+func TestRangeValidate(t *testing.T) {
+	assert.Nil(t, process.Range{Start: process.AtFrame(10), End: process.AtFrame(11)}.Validate())
+	assert.Nil(t, process.Range{Start: process.AtFrame(2000), End: process.AtTime(time.Second)}.Validate())
+	assert.NotNil(t, process.Range{Start: process.AtFrame(10), End: process.AtFrame(10)}.Validate())
+	assert.NotNil(t, process.Range{Start: process.AtTime(time.Second), End: process.AtTime(time.Millisecond)}.Validate())
+	assert.NotNil(t, process.Range{End: process.AtTime(time.Second), Duration: process.AtFrame(10)}.Validate())
+}
+
+func TestTrim(t *testing.T) {
+	// every sample is equal to its frame index.
+	source := samples(1000, 2, 10000, func(c, i int) float64 {
+		return float64(i)
+	})
+	testTrim := func(r process.Range, first, length int) func(*testing.T) {
+		return func(t *testing.T) {
+			result := run(t, process.Trim(source, r))
+			for c := range result.samples {
+				assert.Equal(t, length, len(result.samples[c]))
+				for i, v := range result.samples[c] {
+					if !assert.Equal(t, float64(first+i), v) {
+						return
+					}
+				}
+			}
+		}
+	}
+	t.Run("full", testTrim(process.Range{}, 0, 10000))
+	t.Run("start time", testTrim(process.Range{Start: process.AtTime(1500 * time.Millisecond)}, 1500, 8500))
+	t.Run("start frame", testTrim(process.Range{Start: process.AtFrame(777)}, 777, 9223))
+	t.Run("end", testTrim(process.Range{End: process.AtFrame(1001)}, 0, 1001))
+	t.Run("start end", testTrim(process.Range{Start: process.AtFrame(513), End: process.AtTime(2 * time.Second)}, 513, 1487))
+	t.Run("duration", testTrim(process.Range{Start: process.AtFrame(10), Duration: process.AtFrame(3)}, 10, 3))
+	t.Run("end after eof", testTrim(process.Range{Start: process.AtFrame(9000), End: process.AtFrame(20000)}, 9000, 1000))
+	t.Run("start after eof", testTrim(process.Range{Start: process.AtFrame(20000)}, 0, 0))
+
+	testFail := func(r process.Range) func(*testing.T) {
+		return func(t *testing.T) {
+			var res result
+			err := pipe.Run(context.Background(), bufferSize, pipe.Line{
+				Source: process.Trim(source, r),
+				Sink:   res.sink(),
+			})
+			assert.NotNil(t, err)
+		}
+	}
+	t.Run("end before start", testFail(process.Range{Start: process.AtFrame(10), End: process.AtFrame(5)}))
+	t.Run("end before start different units", testFail(process.Range{Start: process.AtFrame(2000), End: process.AtTime(time.Second)}))
+	t.Run("end and duration", testFail(process.Range{End: process.AtFrame(10), Duration: process.AtFrame(5)}))
+}
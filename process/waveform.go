@@ -0,0 +1,128 @@
+package process
+
+import (
+	"fmt"
+	"math"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+type (
+	// Waveform contains peaks of the signal. Every peak covers the same
+	// number of frames, except the last one that can be shorter. Channels
+	// are merged.
+	Waveform struct {
+		SampleRate      signal.Frequency
+		SamplesPerPixel int
+		Frames          int64
+		Peaks           []Peak
+	}
+
+	// Peak is the range of sample values of a single pixel.
+	Peak struct {
+		Min float64
+		Max float64
+	}
+
+	// PeakMeter measures peaks of the signal with provided number of
+	// frames per peak.
+	PeakMeter struct {
+		SamplesPerPixel int
+		sampleRate      signal.Frequency
+		frames          int64
+		peaks           []Peak
+		// current is the peak of the last incomplete pixel, count is the
+		// number of its frames.
+		current Peak
+		count   int
+	}
+)
+
+// Sink returns the sink that measures peaks of the signal.
+func (m *PeakMeter) Sink() pipe.SinkAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Sink, error) {
+		if err := m.reset(props); err != nil {
+			return pipe.Sink{}, err
+		}
+		return pipe.Sink{
+			SinkFunc: func(in signal.Floating) error {
+				m.write(in)
+				return nil
+			},
+		}, nil
+	}
+}
+
+// Processor returns the processor that passes the signal through and
+// measures its peaks.
+func (m *PeakMeter) Processor() pipe.ProcessorAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Processor, error) {
+		if err := m.reset(props); err != nil {
+			return pipe.Processor{}, err
+		}
+		return pipe.Processor{
+			SignalProperties: props,
+			ProcessFunc: func(in, out signal.Floating) (int, error) {
+				m.write(in)
+				for i := 0; i < in.Len(); i++ {
+					out.SetSample(i, in.Sample(i))
+				}
+				return in.Length(), nil
+			},
+		}, nil
+	}
+}
+
+func (m *PeakMeter) reset(props pipe.SignalProperties) error {
+	if m.SamplesPerPixel < 1 {
+		return fmt.Errorf("samples per pixel must be positive: %d", m.SamplesPerPixel)
+	}
+	m.sampleRate = props.SampleRate
+	m.frames = 0
+	m.peaks = nil
+	m.count = 0
+	m.current = emptyPeak()
+	return nil
+}
+
+func (m *PeakMeter) write(in signal.Floating) {
+	channels := in.Channels()
+	for i := 0; i < in.Length(); i++ {
+		for c := 0; c < channels; c++ {
+			v := in.Sample(i*channels + c)
+			m.current.Min = math.Min(m.current.Min, v)
+			m.current.Max = math.Max(m.current.Max, v)
+		}
+		m.count++
+		if m.count == m.SamplesPerPixel {
+			m.peaks = append(m.peaks, m.current)
+			m.current, m.count = emptyPeak(), 0
+		}
+	}
+	m.frames += int64(in.Length())
+}
+
+// Waveform returns the peaks of the measured signal.
+func (m *PeakMeter) Waveform() Waveform {
+	w := Waveform{
+		SampleRate:      m.sampleRate,
+		SamplesPerPixel: m.SamplesPerPixel,
+		Frames:          m.frames,
+		Peaks:           make([]Peak, len(m.peaks), len(m.peaks)+1),
+	}
+	copy(w.Peaks, m.peaks)
+	if m.count > 0 {
+		w.Peaks = append(w.Peaks, m.current)
+	}
+	return w
+}
+
+// emptyPeak returns the peak that is expanded by any sample.
+func emptyPeak() Peak {
+	return Peak{
+		Min: math.Inf(1),
+		Max: math.Inf(-1),
+	}
+}
@@ -0,0 +1,57 @@
+package process_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/pipe"
+
+	"pipelined.dev/phono/process"
+)
+
+func TestPeakMeter(t *testing.T) {
+	// ramp in the first channel and its inverse in the second one, so
+	// peaks are symmetric.
+	source := samples(1000, 2, 1050, func(c, i int) float64 {
+		v := float64(i%100) / 100
+		if c == 1 {
+			return -v
+		}
+		return v
+	})
+	m := process.PeakMeter{SamplesPerPixel: 100}
+	err := pipe.Run(context.Background(), bufferSize, pipe.Line{
+		Source: source,
+		Sink:   m.Sink(),
+	})
+	assert.Nil(t, err)
+	w := m.Waveform()
+	assert.Equal(t, int64(1050), w.Frames)
+	assert.Equal(t, 100, w.SamplesPerPixel)
+	assert.Equal(t, 11, len(w.Peaks))
+	for _, p := range w.Peaks[:10] {
+		assert.InDelta(t, -0.99, p.Min, 1e-9)
+		assert.InDelta(t, 0.99, p.Max, 1e-9)
+	}
+	// last pixel is incomplete.
+	assert.InDelta(t, -0.49, w.Peaks[10].Min, 1e-9)
+	assert.InDelta(t, 0.49, w.Peaks[10].Max, 1e-9)
+
+	// processor passes the signal through.
+	p := process.PeakMeter{SamplesPerPixel: 256}
+	r := run(t, generator(44100, 2, 1000, 440, 0.5), p.Processor())
+	assert.Equal(t, 1000, len(r.samples[0]))
+	assert.Equal(t, 4, len(p.Waveform().Peaks))
+	// second channel has double amplitude.
+	assert.InDelta(t, 1, p.Waveform().Peaks[0].Max, 0.01)
+
+	// samples per pixel must be positive.
+	var invalid process.PeakMeter
+	err = pipe.Run(context.Background(), bufferSize, pipe.Line{
+		Source: generator(44100, 1, 10, 440, 0.5),
+		Sink:   invalid.Sink(),
+	})
+	assert.NotNil(t, err)
+}
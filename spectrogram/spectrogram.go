@@ -0,0 +1,163 @@
+// Package spectrogram renders spectrograms measured by
+// process.SpectrumAnalyzer. Time goes from left to right and frequency
+// goes from bottom to top.
+package spectrogram
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"pipelined.dev/phono/process"
+)
+
+// Frequency scales of the image.
+const (
+	Linear Scale = "linear"
+	Log    Scale = "log"
+)
+
+// MinFrequency is the lowest frequency of log scale.
+const MinFrequency = 20
+
+// gap is the width of separator between compared images.
+const gap = 4
+
+type (
+	// Scale defines how frequencies are mapped to rows.
+	Scale string
+
+	// Image defines how spectrogram is rendered.
+	Image struct {
+		Width  int
+		Height int
+		Scale  Scale
+		// Range of magnitudes in dB below full scale. Quieter bins have
+		// the first color of the palette.
+		Range float64
+	}
+
+	// span is a range of indices, end is exclusive.
+	span struct {
+		start, end int
+	}
+)
+
+// palette is a perceptually ordered color map from silence to full
+// scale.
+var palette = []color.RGBA{
+	{R: 0, G: 0, B: 4, A: 255},
+	{R: 40, G: 11, B: 84, A: 255},
+	{R: 101, G: 21, B: 110, A: 255},
+	{R: 159, G: 42, B: 99, A: 255},
+	{R: 212, G: 72, B: 66, A: 255},
+	{R: 245, G: 125, B: 21, A: 255},
+	{R: 250, G: 193, B: 39, A: 255},
+	{R: 252, G: 255, B: 164, A: 255},
+}
+
+// Render returns the image of the spectrogram. If spectrogram has more
+// columns or bins than pixels, maximum magnitude is used.
+func Render(s process.Spectrogram, img Image) *image.RGBA {
+	m := image.NewRGBA(image.Rect(0, 0, img.Width, img.Height))
+	draw.Draw(m, m.Bounds(), image.NewUniform(palette[0]), image.Point{}, draw.Src)
+	if len(s.Columns) == 0 || s.Size == 0 {
+		return m
+	}
+	columns := spans(len(s.Columns), img.Width)
+	rows := img.rows(s)
+	for x, c := range columns {
+		for y, r := range rows {
+			v := math.Inf(-1)
+			for _, column := range s.Columns[c.start:c.end] {
+				for _, bin := range column[r.start:r.end] {
+					v = math.Max(v, bin)
+				}
+			}
+			m.SetRGBA(x, y, img.color(v))
+		}
+	}
+	return m
+}
+
+// SideBySide returns two images next to each other separated by a gap.
+// Shorter image is aligned to the top.
+func SideBySide(left, right image.Image) *image.RGBA {
+	lb, rb := left.Bounds(), right.Bounds()
+	height := lb.Dy()
+	if rb.Dy() > height {
+		height = rb.Dy()
+	}
+	m := image.NewRGBA(image.Rect(0, 0, lb.Dx()+gap+rb.Dx(), height))
+	draw.Draw(m, m.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(m, image.Rect(0, 0, lb.Dx(), lb.Dy()), left, lb.Min, draw.Src)
+	draw.Draw(m, image.Rect(lb.Dx()+gap, 0, lb.Dx()+gap+rb.Dx(), rb.Dy()), right, rb.Min, draw.Src)
+	return m
+}
+
+// rows returns the range of bins for every row, the first row has the
+// highest frequencies.
+func (img Image) rows(s process.Spectrogram) []span {
+	bins := s.Size/2 + 1
+	binWidth := float64(s.SampleRate) / float64(s.Size)
+	nyquist := float64(s.SampleRate) / 2
+	frequency := func(t float64) float64 {
+		if img.Scale == Log && nyquist > MinFrequency {
+			return MinFrequency * math.Pow(nyquist/MinFrequency, t)
+		}
+		return t * nyquist
+	}
+	result := make([]span, img.Height)
+	for y := range result {
+		top := frequency(1 - float64(y)/float64(img.Height))
+		bottom := frequency(1 - float64(y+1)/float64(img.Height))
+		start := int(math.Round(bottom / binWidth))
+		end := int(math.Round(top/binWidth)) + 1
+		if start >= bins {
+			start = bins - 1
+		}
+		if end > bins {
+			end = bins
+		}
+		if end <= start {
+			end = start + 1
+		}
+		result[y] = span{start: start, end: end}
+	}
+	return result
+}
+
+// color returns the color of magnitude in dB.
+func (img Image) color(v float64) color.RGBA {
+	t := (v + img.Range) / img.Range
+	if t <= 0 || math.IsNaN(t) {
+		return palette[0]
+	}
+	if t >= 1 {
+		return palette[len(palette)-1]
+	}
+	pos := t * float64(len(palette)-1)
+	i := int(pos)
+	frac := pos - float64(i)
+	from, to := palette[i], palette[i+1]
+	mix := func(a, b uint8) uint8 {
+		return uint8(math.Round(float64(a) + (float64(b)-float64(a))*frac))
+	}
+	return color.RGBA{R: mix(from.R, to.R), G: mix(from.G, to.G), B: mix(from.B, to.B), A: 255}
+}
+
+// spans splits n items into provided number of non-empty spans. If there
+// are less items than spans, items are repeated.
+func spans(n, count int) []span {
+	result := make([]span, count)
+	for i := range result {
+		start := i * n / count
+		end := (i + 1) * n / count
+		if end <= start {
+			end = start + 1
+		}
+		result[i] = span{start: start, end: end}
+	}
+	return result
+}
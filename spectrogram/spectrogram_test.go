@@ -0,0 +1,91 @@
+package spectrogram_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/phono/process"
+	"pipelined.dev/phono/spectrogram"
+)
+
+// testSpectrogram has full scale top bin in the first column and full
+// scale bottom bin in the second one.
+var testSpectrogram = process.Spectrogram{
+	SampleRate: 8000,
+	Size:       8,
+	Frames:     16,
+	Columns: [][]float64{
+		{-120, -120, -120, -120, 0},
+		{0, -120, -120, -120, -120},
+	},
+}
+
+func TestRender(t *testing.T) {
+	bright := func(m image.Image, x, y int) bool {
+		r, g, b, _ := m.At(x, y).RGBA()
+		return r > 0xf000 && g > 0xf000 && b > 0x9000
+	}
+	dark := func(m image.Image, x, y int) bool {
+		r, g, b, _ := m.At(x, y).RGBA()
+		return r < 0x100 && g < 0x100 && b < 0x800
+	}
+	t.Run("linear", func(t *testing.T) {
+		m := spectrogram.Render(testSpectrogram, spectrogram.Image{
+			Width:  4,
+			Height: 10,
+			Scale:  spectrogram.Linear,
+			Range:  60,
+		})
+		assert.Equal(t, image.Rect(0, 0, 4, 10), m.Bounds())
+		// columns are repeated.
+		for x := 0; x < 2; x++ {
+			assert.True(t, bright(m, x, 0))
+			assert.True(t, dark(m, x, 5))
+			assert.True(t, dark(m, x, 9))
+		}
+		for x := 2; x < 4; x++ {
+			assert.True(t, dark(m, x, 0))
+			assert.True(t, bright(m, x, 9))
+		}
+	})
+	t.Run("log", func(t *testing.T) {
+		m := spectrogram.Render(testSpectrogram, spectrogram.Image{
+			Width:  2,
+			Height: 100,
+			Scale:  spectrogram.Log,
+			Range:  60,
+		})
+		assert.True(t, bright(m, 0, 0))
+		// top bin covers the upper part of linear scale, but only a
+		// few rows of log scale.
+		assert.True(t, dark(m, 0, 20))
+		// lowest bin covers the bottom of log scale.
+		assert.True(t, bright(m, 1, 99))
+		assert.True(t, bright(m, 1, 60))
+	})
+	t.Run("empty", func(t *testing.T) {
+		m := spectrogram.Render(process.Spectrogram{}, spectrogram.Image{
+			Width:  3,
+			Height: 3,
+			Scale:  spectrogram.Log,
+			Range:  60,
+		})
+		assert.True(t, dark(m, 1, 1))
+	})
+}
+
+func TestSideBySide(t *testing.T) {
+	left := image.NewRGBA(image.Rect(0, 0, 10, 20))
+	right := image.NewRGBA(image.Rect(0, 0, 5, 30))
+	m := spectrogram.SideBySide(left, right)
+	assert.Equal(t, 19, m.Bounds().Dx())
+	assert.Equal(t, 30, m.Bounds().Dy())
+	assert.Equal(t, color.RGBA{}, m.RGBAAt(0, 0))
+	assert.Equal(t, color.RGBA{R: 255, G: 255, B: 255, A: 255}, m.RGBAAt(12, 0))
+	assert.Equal(t, color.RGBA{}, m.RGBAAt(14, 29))
+	// shorter image is aligned to the top.
+	assert.Equal(t, color.RGBA{R: 255, G: 255, B: 255, A: 255}, m.RGBAAt(0, 25))
+}
@@ -0,0 +1,196 @@
+package tag
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// aiffHeaderSize is the size of FORM chunk header and form type.
+const aiffHeaderSize = 12
+
+var (
+	// aiffTextIDs maps ids of text chunks to keys.
+	aiffTextIDs = map[string]string{
+		"NAME": Title,
+		"AUTH": Artist,
+		"ANNO": Comment,
+		"(c) ": Copyright,
+	}
+	// aiffTextKeys maps keys to ids of written text chunks. Other keys
+	// are not supported by aiff.
+	aiffTextKeys = map[string]string{
+		Title:     "NAME",
+		Artist:    "AUTH",
+		Comment:   "ANNO",
+		Copyright: "(c) ",
+	}
+)
+
+// readAIFF returns tags of text chunks.
+func readAIFF(rs io.ReadSeeker) (Tags, error) {
+	_, chunks, err := aiffChunks(rs)
+	if err != nil {
+		return nil, err
+	}
+	tags := make(Tags)
+	for _, c := range chunks {
+		key, ok := aiffTextIDs[c.id]
+		if !ok {
+			continue
+		}
+		if _, err := rs.Seek(c.offset+8, io.SeekStart); err != nil {
+			return nil, err
+		}
+		value := make([]byte, c.size)
+		if _, err := io.ReadFull(rs, value); err != nil {
+			return nil, fmt.Errorf("error reading %s chunk: %w", c.id, err)
+		}
+		tags.add(key, string(value))
+	}
+	return tags, nil
+}
+
+// aiffChunks returns form type and chunks of AIFF or AIFF-C stream.
+func aiffChunks(rs io.ReadSeeker) (string, []riffChunk, error) {
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return "", nil, err
+	}
+	var header [aiffHeaderSize]byte
+	if _, err := io.ReadFull(rs, header[:]); err != nil {
+		return "", nil, fmt.Errorf("error reading AIFF header: %w", err)
+	}
+	form := string(header[8:12])
+	if string(header[:4]) != "FORM" || (form != "AIFF" && form != "AIFC") {
+		return "", nil, errors.New("invalid AIFF")
+	}
+	end, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return "", nil, err
+	}
+	var chunks []riffChunk
+	for offset := int64(aiffHeaderSize); offset+8 <= end; {
+		if _, err := rs.Seek(offset, io.SeekStart); err != nil {
+			return "", nil, err
+		}
+		var b [8]byte
+		if _, err := io.ReadFull(rs, b[:]); err != nil {
+			return "", nil, fmt.Errorf("error reading AIFF chunk: %w", err)
+		}
+		c := riffChunk{
+			id:     string(b[:4]),
+			offset: offset,
+			size:   int64(binary.BigEndian.Uint32(b[4:8])),
+		}
+		if offset+8+c.size > end {
+			c.size = end - offset - 8
+		}
+		chunks = append(chunks, c)
+		offset += 8 + c.size + c.size%2
+	}
+	return form, chunks, nil
+}
+
+// encodeAIFFText returns text chunks with supported tags. Nil is
+// returned if there are no supported tags.
+func encodeAIFFText(tags Tags) []byte {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		if _, ok := aiffTextKeys[k]; ok {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	sort.Strings(keys)
+	var b []byte
+	for _, k := range keys {
+		value := []byte(tags[k])
+		var header [8]byte
+		copy(header[:4], aiffTextKeys[k])
+		binary.BigEndian.PutUint32(header[4:], uint32(len(value)))
+		b = append(b, header[:]...)
+		b = append(b, value...)
+		if len(value)%2 == 1 {
+			b = append(b, 0)
+		}
+	}
+	return b
+}
+
+// appendAIFFText appends text chunks to the end of complete aiff stream
+// and updates the size of FORM chunk.
+func appendAIFFText(ws io.WriteSeeker, tags Tags) error {
+	text := encodeAIFFText(tags)
+	if text == nil {
+		return nil
+	}
+	end, err := ws.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	// previous chunk must be padded to even size.
+	if end%2 == 1 {
+		text = append([]byte{0}, text...)
+	}
+	if _, err := ws.Write(text); err != nil {
+		return fmt.Errorf("error writing AIFF text chunks: %w", err)
+	}
+	if _, err := ws.Seek(4, io.SeekStart); err != nil {
+		return err
+	}
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(end+int64(len(text))-8))
+	if _, err := ws.Write(size[:]); err != nil {
+		return fmt.Errorf("error writing FORM size: %w", err)
+	}
+	return nil
+}
+
+// rewriteAIFF copies all chunks except text chunks and appends text
+// chunks with provided tags.
+func rewriteAIFF(rs io.ReadSeeker, w io.Writer, tags Tags) error {
+	form, chunks, err := aiffChunks(rs)
+	if err != nil {
+		return err
+	}
+	kept := chunks[:0]
+	size := int64(4)
+	for _, c := range chunks {
+		if _, ok := aiffTextIDs[c.id]; ok {
+			continue
+		}
+		kept = append(kept, c)
+		size += 8 + c.size + c.size%2
+	}
+	text := encodeAIFFText(tags)
+	header := []byte("FORM\x00\x00\x00\x00" + form)
+	binary.BigEndian.PutUint32(header[4:8], uint32(size+int64(len(text))))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	for _, c := range kept {
+		var header [8]byte
+		copy(header[:4], c.id)
+		binary.BigEndian.PutUint32(header[4:], uint32(c.size))
+		if _, err := w.Write(header[:]); err != nil {
+			return err
+		}
+		if _, err := rs.Seek(c.offset+8, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(w, rs, c.size); err != nil {
+			return fmt.Errorf("error copying AIFF chunk %s: %w", c.id, err)
+		}
+		if c.size%2 == 1 {
+			if _, err := w.Write([]byte{0}); err != nil {
+				return err
+			}
+		}
+	}
+	_, err = w.Write(text)
+	return err
+}
@@ -0,0 +1,368 @@
+package tag
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// flac metadata block types.
+const (
+	flacStreamInfo    = 0
+	flacPadding       = 1
+	flacVorbisComment = 4
+	flacPicture       = 6
+)
+
+const (
+	flacSignature       = "fLaC"
+	flacBlockHeaderSize = 4
+	// streamInfoEnd is the offset of the first block after stream info.
+	streamInfoEnd = len(flacSignature) + flacBlockHeaderSize + 34
+	// flacLastBlock is the flag of the last metadata block.
+	flacLastBlock = 0x80
+	// flacMaxBlockSize is the max size of metadata block data.
+	flacMaxBlockSize = 1<<24 - 1
+)
+
+// vendor is written into Vorbis comments.
+const vendor = "pipelined.dev/phono"
+
+// flacBlock is a raw metadata block of flac stream.
+type flacBlock struct {
+	typ  byte
+	data []byte
+}
+
+// readFLAC returns tags of Vorbis comment block and the cover of
+// picture blocks.
+func readFLAC(rs io.ReadSeeker) (Metadata, error) {
+	blocks, err := readFLACBlocks(rs)
+	if err != nil {
+		return Metadata{}, err
+	}
+	tags := make(Tags)
+	var pictures []*Picture
+	for _, b := range blocks {
+		switch b.typ {
+		case flacVorbisComment:
+			if err := decodeVorbisComment(b.data, tags); err != nil {
+				return Metadata{}, err
+			}
+		case flacPicture:
+			p, err := decodeFLACPicture(b.data)
+			if err != nil {
+				return Metadata{}, err
+			}
+			pictures = append(pictures, p)
+		}
+	}
+	return Metadata{Tags: tags, Cover: cover(pictures)}, nil
+}
+
+// readFLACBlocks reads all metadata blocks. Reader is positioned at the
+// first frame after the call.
+func readFLACBlocks(r io.ReadSeeker) ([]flacBlock, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var signature [4]byte
+	if _, err := io.ReadFull(r, signature[:]); err != nil {
+		return nil, fmt.Errorf("error reading FLAC signature: %w", err)
+	}
+	if string(signature[:]) != flacSignature {
+		return nil, errors.New("invalid FLAC")
+	}
+	var blocks []flacBlock
+	for {
+		var header [flacBlockHeaderSize]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil, fmt.Errorf("error reading FLAC metadata: %w", err)
+		}
+		size := int(header[1])<<16 | int(header[2])<<8 | int(header[3])
+		b := flacBlock{
+			typ:  header[0] &^ flacLastBlock,
+			data: make([]byte, size),
+		}
+		if _, err := io.ReadFull(r, b.data); err != nil {
+			return nil, fmt.Errorf("error reading FLAC metadata: %w", err)
+		}
+		blocks = append(blocks, b)
+		if header[0]&flacLastBlock != 0 {
+			return blocks, nil
+		}
+	}
+}
+
+// decodeVorbisComment adds comments of the block to the tags.
+func decodeVorbisComment(b []byte, tags Tags) error {
+	errInvalid := errors.New("invalid FLAC Vorbis comment")
+	next := func() (string, error) {
+		if len(b) < 4 {
+			return "", errInvalid
+		}
+		size := binary.LittleEndian.Uint32(b)
+		if uint64(size) > uint64(len(b)-4) {
+			return "", errInvalid
+		}
+		s := string(b[4 : 4+size])
+		b = b[4+size:]
+		return s, nil
+	}
+	if _, err := next(); err != nil {
+		return err
+	}
+	if len(b) < 4 {
+		return errInvalid
+	}
+	n := binary.LittleEndian.Uint32(b)
+	b = b[4:]
+	for i := uint32(0); i < n; i++ {
+		comment, err := next()
+		if err != nil {
+			return err
+		}
+		if i := strings.IndexByte(comment, '='); i > 0 {
+			tags.add(comment[:i], comment[i+1:])
+		}
+	}
+	return nil
+}
+
+// encodeVorbisComment returns the body of Vorbis comment block. Keys are
+// upper case.
+func encodeVorbisComment(tags Tags) []byte {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	appendString := func(b []byte, s string) []byte {
+		var size [4]byte
+		binary.LittleEndian.PutUint32(size[:], uint32(len(s)))
+		return append(append(b, size[:]...), s...)
+	}
+	b := appendString(nil, vendor)
+	var n [4]byte
+	binary.LittleEndian.PutUint32(n[:], uint32(len(keys)))
+	b = append(b, n[:]...)
+	for _, k := range keys {
+		b = appendString(b, strings.ToUpper(k)+"="+tags[k])
+	}
+	return b
+}
+
+// decodeFLACPicture decodes the body of picture block.
+func decodeFLACPicture(b []byte) (*Picture, error) {
+	errInvalid := errors.New("invalid FLAC picture")
+	next := func(size int) ([]byte, error) {
+		if size > len(b) {
+			return nil, errInvalid
+		}
+		v := b[:size]
+		b = b[size:]
+		return v, nil
+	}
+	field := func() ([]byte, error) {
+		size, err := next(4)
+		if err != nil {
+			return nil, err
+		}
+		if uint64(binary.BigEndian.Uint32(size)) > uint64(len(b)) {
+			return nil, errInvalid
+		}
+		return next(int(binary.BigEndian.Uint32(size)))
+	}
+	typ, err := next(4)
+	if err != nil {
+		return nil, err
+	}
+	mime, err := field()
+	if err != nil {
+		return nil, err
+	}
+	desc, err := field()
+	if err != nil {
+		return nil, err
+	}
+	// width, height, color depth and number of colors.
+	if _, err := next(16); err != nil {
+		return nil, err
+	}
+	data, err := field()
+	if err != nil {
+		return nil, err
+	}
+	return &Picture{
+		Type:        byte(binary.BigEndian.Uint32(typ)),
+		MIME:        string(mime),
+		Description: string(desc),
+		Data:        data,
+	}, nil
+}
+
+// encodeFLACPicture returns the body of picture block. Color depth and
+// number of colors are unknown.
+func encodeFLACPicture(p *Picture) []byte {
+	appendUint32 := func(b []byte, v int) []byte {
+		var n [4]byte
+		binary.BigEndian.PutUint32(n[:], uint32(v))
+		return append(b, n[:]...)
+	}
+	width, height := p.Dimensions()
+	b := appendUint32(nil, int(p.Type))
+	b = appendUint32(b, len(p.MIME))
+	b = append(b, p.MIME...)
+	b = appendUint32(b, len(p.Description))
+	b = append(b, p.Description...)
+	b = appendUint32(b, width)
+	b = appendUint32(b, height)
+	b = appendUint32(b, 0)
+	b = appendUint32(b, 0)
+	b = appendUint32(b, len(p.Data))
+	return append(b, p.Data...)
+}
+
+// metadataBlocks returns blocks of provided metadata. Cover is skipped
+// if it doesn't fit into metadata block.
+func metadataBlocks(m Metadata) []flacBlock {
+	var blocks []flacBlock
+	if len(m.Tags) > 0 {
+		blocks = append(blocks, flacBlock{typ: flacVorbisComment, data: encodeVorbisComment(m.Tags)})
+	}
+	if m.Cover != nil {
+		if data := encodeFLACPicture(m.Cover); len(data) <= flacMaxBlockSize {
+			blocks = append(blocks, flacBlock{typ: flacPicture, data: data})
+		}
+	}
+	return blocks
+}
+
+// encodeFLACBlocks returns encoded metadata blocks with provided
+// metadata. The last block is flagged.
+func encodeFLACBlocks(m Metadata) []byte {
+	return encodeBlocks(metadataBlocks(m))
+}
+
+// encodeBlocks encodes metadata blocks and flags the last one.
+func encodeBlocks(blocks []flacBlock) []byte {
+	var b []byte
+	for i, block := range blocks {
+		typ := block.typ
+		if i == len(blocks)-1 {
+			typ |= flacLastBlock
+		}
+		size := len(block.data)
+		b = append(b, typ, byte(size>>16), byte(size>>8), byte(size))
+		b = append(b, block.data...)
+	}
+	return b
+}
+
+// rewriteFLAC copies flac stream and replaces Vorbis comments and
+// pictures with provided metadata. Padding is removed.
+func rewriteFLAC(rs io.ReadSeeker, w io.Writer, m Metadata) error {
+	blocks, err := readFLACBlocks(rs)
+	if err != nil {
+		return err
+	}
+	// stream info must be the first block.
+	if len(blocks) == 0 || blocks[0].typ != flacStreamInfo {
+		return errors.New("invalid FLAC stream info")
+	}
+	kept := blocks[:0]
+	for _, b := range blocks {
+		switch b.typ {
+		case flacVorbisComment, flacPicture, flacPadding:
+		default:
+			kept = append(kept, b)
+		}
+	}
+	kept = append(kept, metadataBlocks(m)...)
+	if _, err := io.WriteString(w, flacSignature); err != nil {
+		return err
+	}
+	if _, err := w.Write(encodeBlocks(kept)); err != nil {
+		return err
+	}
+	_, err = io.Copy(w, rs)
+	return err
+}
+
+// flacMetadataWriter inserts metadata blocks after the stream info block
+// written by flac encoder. Positions of writes after the stream info are
+// shifted by the size of inserted blocks.
+type flacMetadataWriter struct {
+	io.WriteSeeker
+	blocks   []byte
+	pos      int64
+	inserted bool
+}
+
+func (w *flacMetadataWriter) Write(b []byte) (int, error) {
+	n := 0
+	if w.pos < int64(streamInfoEnd) {
+		head := b
+		if rest := int64(streamInfoEnd) - w.pos; int64(len(head)) > rest {
+			head = head[:rest]
+		}
+		// stream info isn't the last block anymore.
+		if w.pos <= int64(len(flacSignature)) && w.pos+int64(len(head)) > int64(len(flacSignature)) {
+			head = append([]byte(nil), head...)
+			head[int64(len(flacSignature))-w.pos] &^= flacLastBlock
+		}
+		written, err := w.WriteSeeker.Write(head)
+		n += written
+		w.pos += int64(written)
+		if err != nil {
+			return n, err
+		}
+		b = b[len(head):]
+	}
+	if len(b) == 0 {
+		return n, nil
+	}
+	if !w.inserted {
+		if _, err := w.WriteSeeker.Write(w.blocks); err != nil {
+			return n, err
+		}
+		w.inserted = true
+	}
+	written, err := w.WriteSeeker.Write(b)
+	n += written
+	w.pos += int64(written)
+	return n, err
+}
+
+func (w *flacMetadataWriter) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += w.pos
+	case io.SeekEnd:
+		end, err := w.WriteSeeker.Seek(0, io.SeekEnd)
+		if err != nil {
+			return 0, err
+		}
+		offset += w.logical(end)
+	}
+	physical := offset
+	if offset >= int64(streamInfoEnd) && w.inserted {
+		physical += int64(len(w.blocks))
+	}
+	if _, err := w.WriteSeeker.Seek(physical, io.SeekStart); err != nil {
+		return 0, err
+	}
+	w.pos = offset
+	return offset, nil
+}
+
+// logical returns the position without inserted blocks.
+func (w *flacMetadataWriter) logical(physical int64) int64 {
+	if w.inserted && physical >= int64(streamInfoEnd+len(w.blocks)) {
+		return physical - int64(len(w.blocks))
+	}
+	return physical
+}
@@ -0,0 +1,570 @@
+package tag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	id3v2HeaderSize = 10
+	id3v1Size       = 128
+)
+
+// id3v2 header flags.
+const (
+	id3Unsynchronisation = 0x80
+	id3ExtendedHeader    = 0x40
+	id3Footer            = 0x10
+)
+
+// text encodings of id3v2 frames.
+const (
+	encodingLatin1  = 0
+	encodingUTF16   = 1
+	encodingUTF16BE = 2
+	encodingUTF8    = 3
+)
+
+var (
+	// id3Frames maps ids of id3v2.3 and id3v2.4 text frames to keys.
+	id3Frames = map[string]string{
+		"TIT2": Title,
+		"TPE1": Artist,
+		"TALB": Album,
+		"TPE2": AlbumArtist,
+		"TDRC": Date,
+		"TYER": Date,
+		"TCON": Genre,
+		"TRCK": TrackNumber,
+		"TPOS": DiscNumber,
+		"TCOM": Composer,
+		"TCOP": Copyright,
+	}
+	// id3v22Frames maps ids of id3v2.2 frames to ids of id3v2.3 frames.
+	id3v22Frames = map[string]string{
+		"TT2": "TIT2",
+		"TP1": "TPE1",
+		"TAL": "TALB",
+		"TP2": "TPE2",
+		"TYE": "TYER",
+		"TCO": "TCON",
+		"TRK": "TRCK",
+		"TPA": "TPOS",
+		"TCM": "TCOM",
+		"TCR": "TCOP",
+		"COM": "COMM",
+		"TXX": "TXXX",
+		"PIC": "PIC",
+	}
+	// id3Keys maps keys to ids of written id3v2.4 frames.
+	id3Keys = map[string]string{
+		Title:       "TIT2",
+		Artist:      "TPE1",
+		Album:       "TALB",
+		AlbumArtist: "TPE2",
+		Date:        "TDRC",
+		Genre:       "TCON",
+		TrackNumber: "TRCK",
+		DiscNumber:  "TPOS",
+		Composer:    "TCOM",
+		Copyright:   "TCOP",
+	}
+)
+
+// id3Genres are genres of id3v1, referenced by index.
+var id3Genres = [...]string{
+	"Blues", "Classic Rock", "Country", "Dance", "Disco", "Funk", "Grunge",
+	"Hip-Hop", "Jazz", "Metal", "New Age", "Oldies", "Other", "Pop", "R&B",
+	"Rap", "Reggae", "Rock", "Techno", "Industrial", "Alternative", "Ska",
+	"Death Metal", "Pranks", "Soundtrack", "Euro-Techno", "Ambient",
+	"Trip-Hop", "Vocal", "Jazz+Funk", "Fusion", "Trance", "Classical",
+	"Instrumental", "Acid", "House", "Game", "Sound Clip", "Gospel",
+	"Noise", "AlternRock", "Bass", "Soul", "Punk", "Space", "Meditative",
+	"Instrumental Pop", "Instrumental Rock", "Ethnic", "Gothic",
+	"Darkwave", "Techno-Industrial", "Electronic", "Pop-Folk", "Eurodance",
+	"Dream", "Southern Rock", "Comedy", "Cult", "Gangsta", "Top 40",
+	"Christian Rap", "Pop/Funk", "Jungle", "Native American", "Cabaret",
+	"New Wave", "Psychadelic", "Rave", "Showtunes", "Trailer", "Lo-Fi",
+	"Tribal", "Acid Punk", "Acid Jazz", "Polka", "Retro", "Musical",
+	"Rock & Roll", "Hard Rock",
+}
+
+// id3Frame is a raw frame of id3v2 tag.
+type id3Frame struct {
+	id   string
+	data []byte
+}
+
+// readMP3 returns metadata of id3v2 tag at the beginning of the stream.
+// Tags of id3v1 are used for values missing in id3v2.
+func readMP3(rs io.ReadSeeker) (Metadata, error) {
+	tags := make(Tags)
+	frames, err := readID3v2(rs)
+	if err != nil {
+		return Metadata{}, err
+	}
+	var pictures []*Picture
+	for _, f := range frames {
+		if p := f.picture(); p != nil {
+			pictures = append(pictures, p)
+			continue
+		}
+		f.addTo(tags)
+	}
+	v1, err := readID3v1(rs)
+	if err != nil {
+		return Metadata{}, err
+	}
+	for k, v := range v1 {
+		if _, ok := tags[k]; !ok {
+			tags[k] = v
+		}
+	}
+	return Metadata{Tags: tags, Cover: cover(pictures)}, nil
+}
+
+// readID3v2 returns frames of id3v2 tag at the beginning of the stream.
+// Compressed and encrypted frames are skipped.
+func readID3v2(rs io.ReadSeeker) ([]id3Frame, error) {
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var header [id3v2HeaderSize]byte
+	if _, err := io.ReadFull(rs, header[:]); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if string(header[:3]) != "ID3" {
+		return nil, nil
+	}
+	version, flags := header[3], header[5]
+	if version < 2 || version > 4 {
+		return nil, fmt.Errorf("unsupported ID3v2 version: 2.%d", version)
+	}
+	body := make([]byte, syncsafe(header[6:10]))
+	if _, err := io.ReadFull(rs, body); err != nil {
+		return nil, fmt.Errorf("error reading ID3v2 tag: %w", err)
+	}
+	// version 2.4 unsynchronises frames separately.
+	if flags&id3Unsynchronisation != 0 && version < 4 {
+		body = resync(body)
+	}
+	if flags&id3ExtendedHeader != 0 && version > 2 {
+		if len(body) < 4 {
+			return nil, errors.New("invalid ID3v2 extended header")
+		}
+		size := int(binary.BigEndian.Uint32(body))
+		if version == 3 {
+			size += 4
+		} else {
+			size = syncsafe(body[:4])
+		}
+		if size > len(body) {
+			return nil, errors.New("invalid ID3v2 extended header")
+		}
+		body = body[size:]
+	}
+
+	idSize, headerSize := 4, 10
+	if version == 2 {
+		idSize, headerSize = 3, 6
+	}
+	var frames []id3Frame
+	for len(body) >= headerSize && body[0] != 0 {
+		id := string(body[:idSize])
+		var size int
+		switch version {
+		case 2:
+			size = int(body[3])<<16 | int(body[4])<<8 | int(body[5])
+		case 3:
+			size = int(binary.BigEndian.Uint32(body[4:8]))
+		default:
+			size = syncsafe(body[4:8])
+		}
+		if size > len(body)-headerSize {
+			return nil, fmt.Errorf("invalid size of ID3v2 frame %s", id)
+		}
+		data := body[headerSize : headerSize+size]
+		if version == 2 {
+			id, ok := id3v22Frames[id]
+			if ok {
+				frames = append(frames, id3Frame{id: id, data: data})
+			}
+		} else if data, ok := frameData(version, flags, body[9], data); ok {
+			frames = append(frames, id3Frame{id: id, data: data})
+		}
+		body = body[headerSize+size:]
+	}
+	return frames, nil
+}
+
+// frameData returns the content of the frame without additional data
+// defined by frame format flags. False is returned if frame is
+// compressed or encrypted.
+func frameData(version, tagFlags, frameFlags byte, data []byte) ([]byte, bool) {
+	if version == 3 {
+		if frameFlags&0xC0 != 0 {
+			return nil, false
+		}
+		// group identifier.
+		if frameFlags&0x20 != 0 && len(data) > 0 {
+			data = data[1:]
+		}
+		return data, true
+	}
+	if frameFlags&0x0C != 0 {
+		return nil, false
+	}
+	// group identifier and data length indicator.
+	if frameFlags&0x40 != 0 && len(data) > 0 {
+		data = data[1:]
+	}
+	if frameFlags&0x01 != 0 && len(data) >= 4 {
+		data = data[4:]
+	}
+	if frameFlags&0x02 != 0 || tagFlags&id3Unsynchronisation != 0 {
+		data = resync(data)
+	}
+	return data, true
+}
+
+// readID3v1 returns tags of id3v1 tag at the end of the stream.
+func readID3v1(rs io.ReadSeeker) (Tags, error) {
+	size, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if size < id3v1Size {
+		return nil, nil
+	}
+	if _, err := rs.Seek(-id3v1Size, io.SeekEnd); err != nil {
+		return nil, err
+	}
+	var b [id3v1Size]byte
+	if _, err := io.ReadFull(rs, b[:]); err != nil {
+		return nil, err
+	}
+	if string(b[:3]) != "TAG" {
+		return nil, nil
+	}
+	field := func(b []byte) string {
+		if i := bytes.IndexByte(b, 0); i >= 0 {
+			b = b[:i]
+		}
+		return latin1(b)
+	}
+	tags := make(Tags)
+	tags.add(Title, field(b[3:33]))
+	tags.add(Artist, field(b[33:63]))
+	tags.add(Album, field(b[63:93]))
+	tags.add(Date, field(b[93:97]))
+	// id3v1.1 stores track number in the last byte of comment.
+	comment := b[97:127]
+	if comment[28] == 0 && comment[29] != 0 {
+		tags.add(TrackNumber, strconv.Itoa(int(comment[29])))
+		comment = comment[:28]
+	}
+	tags.add(Comment, field(comment))
+	if genre := int(b[127]); genre < len(id3Genres) {
+		tags.add(Genre, id3Genres[genre])
+	}
+	return tags, nil
+}
+
+// addTo adds the value of supported frame to the tags.
+func (f id3Frame) addTo(tags Tags) {
+	switch f.id {
+	case "COMM":
+		// encoding, language, description and text.
+		if len(f.data) < 4 {
+			return
+		}
+		desc, text := splitText(f.data[0], f.data[4:])
+		if desc == "" {
+			tags.add(Comment, text)
+		}
+	case "TXXX":
+		if len(f.data) < 1 {
+			return
+		}
+		desc, value := splitText(f.data[0], f.data[1:])
+		if desc != "" && ValidKey(Key(desc)) {
+			tags.add(desc, value)
+		}
+	default:
+		key, ok := id3Frames[f.id]
+		if !ok || len(f.data) < 1 {
+			return
+		}
+		value := decodeText(f.data[0], f.data[1:])
+		if key == Genre {
+			value = genre(value)
+		}
+		// version 2.4 separates multiple values with null.
+		for _, v := range strings.Split(value, "\x00") {
+			tags.add(key, v)
+		}
+	}
+}
+
+// picture returns the picture of APIC frame or PIC frame of id3v2.2.
+// Nil is returned for other frames.
+func (f id3Frame) picture() *Picture {
+	if (f.id != "APIC" && f.id != "PIC") || len(f.data) < 1 {
+		return nil
+	}
+	encoding, b := f.data[0], f.data[1:]
+	var mime string
+	if f.id == "PIC" {
+		// id3v2.2 has three characters of image format instead of
+		// mime type, it's detected from the data.
+		if len(b) < 3 {
+			return nil
+		}
+		b = b[3:]
+	} else {
+		i := bytes.IndexByte(b, 0)
+		if i < 0 {
+			return nil
+		}
+		mime, b = latin1(b[:i]), b[i+1:]
+	}
+	if len(b) < 1 {
+		return nil
+	}
+	typ := b[0]
+	desc, data := cutText(encoding, b[1:])
+	if len(data) == 0 {
+		return nil
+	}
+	// mime type can be omitted or contain only image format.
+	if !strings.Contains(mime, "/") {
+		mime = http.DetectContentType(data)
+	}
+	return &Picture{
+		Type:        typ,
+		MIME:        mime,
+		Description: desc,
+		Data:        data,
+	}
+}
+
+// genre resolves references to id3v1 genres, e.g. (17) or 17.
+func genre(value string) string {
+	ref := value
+	if strings.HasPrefix(ref, "(") {
+		end := strings.IndexByte(ref, ')')
+		if end < 0 {
+			return value
+		}
+		// text after reference refines the genre.
+		if rest := ref[end+1:]; rest != "" {
+			return rest
+		}
+		ref = ref[1:end]
+	}
+	if i, err := strconv.Atoi(ref); err == nil && i >= 0 && i < len(id3Genres) {
+		return id3Genres[i]
+	}
+	return value
+}
+
+// splitText splits null-terminated description from the text.
+func splitText(encoding byte, b []byte) (string, string) {
+	desc, rest := cutText(encoding, b)
+	return desc, decodeText(encoding, rest)
+}
+
+// cutText returns null-terminated text and the bytes after it.
+func cutText(encoding byte, b []byte) (string, []byte) {
+	terminator := []byte{0}
+	width := 1
+	if encoding == encodingUTF16 || encoding == encodingUTF16BE {
+		terminator, width = []byte{0, 0}, 2
+	}
+	for i := 0; i+width <= len(b); i += width {
+		if bytes.Equal(b[i:i+width], terminator) {
+			return decodeText(encoding, b[:i]), b[i+width:]
+		}
+	}
+	return decodeText(encoding, b), nil
+}
+
+// decodeText decodes text of id3v2 frame. Trailing nulls are removed.
+func decodeText(encoding byte, b []byte) string {
+	var s string
+	switch encoding {
+	case encodingLatin1:
+		s = latin1(b)
+	case encodingUTF16, encodingUTF16BE:
+		var order binary.ByteOrder = binary.BigEndian
+		if encoding == encodingUTF16 && len(b) >= 2 {
+			switch {
+			case b[0] == 0xFF && b[1] == 0xFE:
+				order, b = binary.LittleEndian, b[2:]
+			case b[0] == 0xFE && b[1] == 0xFF:
+				b = b[2:]
+			}
+		}
+		units := make([]uint16, len(b)/2)
+		for i := range units {
+			units[i] = order.Uint16(b[2*i:])
+		}
+		s = string(utf16.Decode(units))
+	default:
+		s = string(b)
+	}
+	return strings.TrimRight(s, "\x00")
+}
+
+func latin1(b []byte) string {
+	runes := make([]rune, len(b))
+	for i := range b {
+		runes[i] = rune(b[i])
+	}
+	return string(runes)
+}
+
+// syncsafe decodes 28-bit integer stored in 4 bytes.
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7F)<<21 | int(b[1]&0x7F)<<14 | int(b[2]&0x7F)<<7 | int(b[3]&0x7F)
+}
+
+// putSyncsafe encodes 28-bit integer into 4 bytes.
+func putSyncsafe(b []byte, v int) {
+	b[0] = byte(v>>21) & 0x7F
+	b[1] = byte(v>>14) & 0x7F
+	b[2] = byte(v>>7) & 0x7F
+	b[3] = byte(v) & 0x7F
+}
+
+// resync removes zero bytes inserted after 0xFF by unsynchronisation.
+func resync(b []byte) []byte {
+	result := make([]byte, 0, len(b))
+	for i := 0; i < len(b); i++ {
+		result = append(result, b[i])
+		if b[i] == 0xFF && i+1 < len(b) && b[i+1] == 0 {
+			i++
+		}
+	}
+	return result
+}
+
+// encodeID3v2 returns id3v2.4 tag with provided metadata. Text is
+// encoded in UTF-8. Keys without dedicated frames are stored in TXXX
+// frames, cover is stored in APIC frame.
+func encodeID3v2(m Metadata) []byte {
+	tags := m.Tags
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var frames []id3Frame
+	for _, k := range keys {
+		v := tags[k]
+		switch id, ok := id3Keys[k]; {
+		case ok:
+			frames = append(frames, id3Frame{id: id, data: append([]byte{encodingUTF8}, v...)})
+		case k == Comment:
+			// empty description of english comment.
+			data := append([]byte{encodingUTF8}, "eng\x00"...)
+			frames = append(frames, id3Frame{id: "COMM", data: append(data, v...)})
+		default:
+			data := append([]byte{encodingUTF8}, strings.ToUpper(k)...)
+			data = append(data, 0)
+			frames = append(frames, id3Frame{id: "TXXX", data: append(data, v...)})
+		}
+	}
+	if p := m.Cover; p != nil {
+		data := append([]byte{encodingUTF8}, p.MIME...)
+		data = append(data, 0, p.Type)
+		data = append(data, p.Description...)
+		data = append(data, 0)
+		frames = append(frames, id3Frame{id: "APIC", data: append(data, p.Data...)})
+	}
+	return encodeID3v2Frames(frames)
+}
+
+// encodeID3v2Frames returns id3v2.4 tag with provided frames.
+func encodeID3v2Frames(frames []id3Frame) []byte {
+	size := 0
+	for _, f := range frames {
+		size += id3v2HeaderSize + len(f.data)
+	}
+	b := make([]byte, id3v2HeaderSize, id3v2HeaderSize+size)
+	copy(b, "ID3\x04\x00\x00")
+	putSyncsafe(b[6:10], size)
+	for _, f := range frames {
+		var header [id3v2HeaderSize]byte
+		copy(header[:4], f.id)
+		putSyncsafe(header[4:8], len(f.data))
+		b = append(b, header[:]...)
+		b = append(b, f.data...)
+	}
+	return b
+}
+
+// rewriteMP3 copies mp3 frames and replaces id3 tags with provided
+// metadata.
+func rewriteMP3(rs io.ReadSeeker, w io.Writer, m Metadata) error {
+	start, end, err := mp3Range(rs)
+	if err != nil {
+		return err
+	}
+	if !m.empty() {
+		if _, err := w.Write(encodeID3v2(m)); err != nil {
+			return err
+		}
+	}
+	if _, err := rs.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+	_, err = io.CopyN(w, rs, end-start)
+	return err
+}
+
+// mp3Range returns the range of the stream without id3 tags.
+func mp3Range(rs io.ReadSeeker) (int64, int64, error) {
+	end, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, 0, err
+	}
+	if end >= id3v1Size {
+		if _, err := rs.Seek(-id3v1Size, io.SeekEnd); err != nil {
+			return 0, 0, err
+		}
+		var b [3]byte
+		if _, err := io.ReadFull(rs, b[:]); err != nil {
+			return 0, 0, err
+		}
+		if string(b[:]) == "TAG" {
+			end -= id3v1Size
+		}
+	}
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return 0, 0, err
+	}
+	var header [id3v2HeaderSize]byte
+	if _, err := io.ReadFull(rs, header[:]); err != nil || string(header[:3]) != "ID3" {
+		return 0, end, nil
+	}
+	start := int64(id3v2HeaderSize + syncsafe(header[6:10]))
+	if header[5]&id3Footer != 0 {
+		start += id3v2HeaderSize
+	}
+	if start > end {
+		return 0, 0, errors.New("invalid size of ID3v2 tag")
+	}
+	return start, end, nil
+}
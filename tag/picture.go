@@ -0,0 +1,153 @@
+package tag
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	// png decoder is registered for image.Decode.
+	_ "image/png"
+	"net/http"
+)
+
+// FrontCover is the type of front cover picture.
+const FrontCover = 3
+
+// coverQuality is the quality of resized covers.
+const coverQuality = 90
+
+// maxCoverPixels limits the number of pixels of covers decoded for
+// resizing, because small files may declare huge images.
+const maxCoverPixels = 8192 * 4096
+
+// Picture is an image embedded into audio file. Type is defined by
+// ID3v2 APIC frame and FLAC picture block, e.g. FrontCover.
+type Picture struct {
+	Type        byte
+	MIME        string
+	Description string
+	Data        []byte
+}
+
+// NewPicture returns the front cover with provided image data. Only
+// JPEG and PNG images are supported.
+func NewPicture(data []byte) (*Picture, error) {
+	mime := http.DetectContentType(data)
+	if mime != "image/jpeg" && mime != "image/png" {
+		return nil, fmt.Errorf("unsupported image type: %s", mime)
+	}
+	if _, _, err := image.DecodeConfig(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("invalid image: %w", err)
+	}
+	return &Picture{
+		Type: FrontCover,
+		MIME: mime,
+		Data: data,
+	}, nil
+}
+
+// Resize scales the picture down, so its largest dimension doesn't
+// exceed max. Resized picture is encoded as JPEG. Picture is returned
+// unchanged if it's small enough or its image format is not supported.
+func (p *Picture) Resize(max int) (*Picture, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(p.Data))
+	if err != nil {
+		if errors.Is(err, image.ErrFormat) {
+			return p, nil
+		}
+		return nil, fmt.Errorf("error decoding cover: %w", err)
+	}
+	if config.Width <= max && config.Height <= max {
+		return p, nil
+	}
+	if int64(config.Width)*int64(config.Height) > maxCoverPixels {
+		return nil, fmt.Errorf("cover is too large: %dx%d", config.Width, config.Height)
+	}
+	src, _, err := image.Decode(bytes.NewReader(p.Data))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding cover: %w", err)
+	}
+	width, height := max, max
+	if config.Width > config.Height {
+		height = config.Height * max / config.Width
+	} else {
+		width = config.Width * max / config.Height
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scale(src, width, height), &jpeg.Options{Quality: coverQuality}); err != nil {
+		return nil, fmt.Errorf("error encoding cover: %w", err)
+	}
+	return &Picture{
+		Type:        p.Type,
+		MIME:        "image/jpeg",
+		Description: p.Description,
+		Data:        buf.Bytes(),
+	}, nil
+}
+
+// Dimensions returns width and height of the picture. Zeros are returned
+// if image format is not supported.
+func (p *Picture) Dimensions() (int, int) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(p.Data))
+	if err != nil {
+		return 0, 0
+	}
+	return config.Width, config.Height
+}
+
+// scale downscales the image by averaging source pixels covered by every
+// pixel of the result. Transparent pixels are blended with white, because
+// JPEG has no alpha channel.
+func scale(src image.Image, width, height int) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := b.Min.Y+y*b.Dy()/height, b.Min.Y+(y+1)*b.Dy()/height
+		if y1 == y0 {
+			y1++
+		}
+		for x := 0; x < width; x++ {
+			x0, x1 := b.Min.X+x*b.Dx()/width, b.Min.X+(x+1)*b.Dx()/width
+			if x1 == x0 {
+				x1++
+			}
+			var sr, sg, sb, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					r, g, b, a := src.At(sx, sy).RGBA()
+					sr += uint64(r + 0xFFFF - a)
+					sg += uint64(g + 0xFFFF - a)
+					sb += uint64(b + 0xFFFF - a)
+					n++
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(sr / n >> 8)
+			dst.Pix[i+1] = uint8(sg / n >> 8)
+			dst.Pix[i+2] = uint8(sb / n >> 8)
+			dst.Pix[i+3] = 0xFF
+		}
+	}
+	return dst
+}
+
+// cover returns the front cover or the first picture if there is no
+// front cover.
+func cover(pictures []*Picture) *Picture {
+	for _, p := range pictures {
+		if p.Type == FrontCover {
+			return p
+		}
+	}
+	if len(pictures) > 0 {
+		return pictures[0]
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package tag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// riffHeaderSize is the size of RIFF chunk header and WAVE form type.
+const riffHeaderSize = 12
+
+var (
+	// riffInfoIDs maps ids of LIST/INFO chunks to keys.
+	riffInfoIDs = map[string]string{
+		"INAM": Title,
+		"IART": Artist,
+		"IPRD": Album,
+		"ICRD": Date,
+		"IGNR": Genre,
+		"ITRK": TrackNumber,
+		"IPRT": TrackNumber,
+		"ICMT": Comment,
+		"IMUS": Composer,
+		"ICOP": Copyright,
+	}
+	// riffInfoKeys maps keys to ids of written LIST/INFO chunks. Other
+	// keys are not supported by wav.
+	riffInfoKeys = map[string]string{
+		Title:       "INAM",
+		Artist:      "IART",
+		Album:       "IPRD",
+		Date:        "ICRD",
+		Genre:       "IGNR",
+		TrackNumber: "ITRK",
+		Comment:     "ICMT",
+		Composer:    "IMUS",
+		Copyright:   "ICOP",
+	}
+)
+
+// riffChunk is the position of chunk in the stream.
+type riffChunk struct {
+	id     string
+	offset int64
+	// size of data without padding.
+	size int64
+}
+
+// readRIFF returns tags of LIST/INFO chunks.
+func readRIFF(rs io.ReadSeeker) (Tags, error) {
+	chunks, err := riffChunks(rs)
+	if err != nil {
+		return nil, err
+	}
+	tags := make(Tags)
+	for _, c := range chunks {
+		if !c.isInfo(rs) {
+			continue
+		}
+		data := make([]byte, c.size-4)
+		if _, err := io.ReadFull(rs, data); err != nil {
+			return nil, fmt.Errorf("error reading LIST chunk: %w", err)
+		}
+		for len(data) >= 8 {
+			id := string(data[:4])
+			size := int(binary.LittleEndian.Uint32(data[4:8]))
+			if size > len(data)-8 {
+				return nil, fmt.Errorf("invalid size of INFO chunk %s", id)
+			}
+			if key, ok := riffInfoIDs[id]; ok {
+				value := data[8 : 8+size]
+				if i := bytes.IndexByte(value, 0); i >= 0 {
+					value = value[:i]
+				}
+				tags.add(key, string(value))
+			}
+			data = data[8+size:]
+			if size%2 == 1 && len(data) > 0 {
+				data = data[1:]
+			}
+		}
+	}
+	return tags, nil
+}
+
+// riffChunks returns chunks of RIFF WAVE stream.
+func riffChunks(rs io.ReadSeeker) ([]riffChunk, error) {
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var header [riffHeaderSize]byte
+	if _, err := io.ReadFull(rs, header[:]); err != nil {
+		return nil, fmt.Errorf("error reading RIFF header: %w", err)
+	}
+	if string(header[:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return nil, errors.New("invalid WAV")
+	}
+	end, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	var chunks []riffChunk
+	for offset := int64(riffHeaderSize); offset+8 <= end; {
+		if _, err := rs.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		var b [8]byte
+		if _, err := io.ReadFull(rs, b[:]); err != nil {
+			return nil, fmt.Errorf("error reading RIFF chunk: %w", err)
+		}
+		c := riffChunk{
+			id:     string(b[:4]),
+			offset: offset,
+			size:   int64(binary.LittleEndian.Uint32(b[4:8])),
+		}
+		// size of data chunk isn't updated by some streaming encoders.
+		if offset+8+c.size > end {
+			c.size = end - offset - 8
+		}
+		chunks = append(chunks, c)
+		offset += 8 + c.size + c.size%2
+	}
+	return chunks, nil
+}
+
+// isInfo returns true if chunk is a LIST with INFO type. If it is, the
+// stream is positioned after the list type.
+func (c riffChunk) isInfo(rs io.ReadSeeker) bool {
+	if c.id != "LIST" || c.size < 4 {
+		return false
+	}
+	if _, err := rs.Seek(c.offset+8, io.SeekStart); err != nil {
+		return false
+	}
+	var b [4]byte
+	if _, err := io.ReadFull(rs, b[:]); err != nil {
+		return false
+	}
+	return string(b[:]) == "INFO"
+}
+
+// encodeRIFFInfo returns LIST/INFO chunk with supported tags. Nil is
+// returned if there are no supported tags.
+func encodeRIFFInfo(tags Tags) []byte {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		if _, ok := riffInfoKeys[k]; ok {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	sort.Strings(keys)
+	b := []byte("LIST\x00\x00\x00\x00INFO")
+	for _, k := range keys {
+		// values are null-terminated and padded to even size.
+		value := append([]byte(tags[k]), 0)
+		var header [8]byte
+		copy(header[:4], riffInfoKeys[k])
+		binary.LittleEndian.PutUint32(header[4:], uint32(len(value)))
+		b = append(b, header[:]...)
+		b = append(b, value...)
+		if len(value)%2 == 1 {
+			b = append(b, 0)
+		}
+	}
+	binary.LittleEndian.PutUint32(b[4:8], uint32(len(b)-8))
+	return b
+}
+
+// appendRIFFInfo appends LIST/INFO chunk to the end of complete wav
+// stream and updates the size of RIFF chunk.
+func appendRIFFInfo(ws io.WriteSeeker, tags Tags) error {
+	info := encodeRIFFInfo(tags)
+	if info == nil {
+		return nil
+	}
+	end, err := ws.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	// previous chunk must be padded to even size.
+	if end%2 == 1 {
+		info = append([]byte{0}, info...)
+	}
+	if _, err := ws.Write(info); err != nil {
+		return fmt.Errorf("error writing LIST chunk: %w", err)
+	}
+	if _, err := ws.Seek(4, io.SeekStart); err != nil {
+		return err
+	}
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(end+int64(len(info))-8))
+	if _, err := ws.Write(size[:]); err != nil {
+		return fmt.Errorf("error writing RIFF size: %w", err)
+	}
+	return nil
+}
+
+// rewriteRIFF copies all chunks except LIST/INFO and appends LIST/INFO
+// chunk with provided tags.
+func rewriteRIFF(rs io.ReadSeeker, w io.Writer, tags Tags) error {
+	chunks, err := riffChunks(rs)
+	if err != nil {
+		return err
+	}
+	kept := chunks[:0]
+	size := int64(4)
+	for _, c := range chunks {
+		if c.isInfo(rs) {
+			continue
+		}
+		kept = append(kept, c)
+		size += 8 + c.size + c.size%2
+	}
+	info := encodeRIFFInfo(tags)
+	riff := []byte("RIFF\x00\x00\x00\x00WAVE")
+	binary.LittleEndian.PutUint32(riff[4:8], uint32(size+int64(len(info))))
+	if _, err := w.Write(riff); err != nil {
+		return err
+	}
+	for _, c := range kept {
+		var header [8]byte
+		copy(header[:4], c.id)
+		binary.LittleEndian.PutUint32(header[4:], uint32(c.size))
+		if _, err := w.Write(header[:]); err != nil {
+			return err
+		}
+		if _, err := rs.Seek(c.offset+8, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(w, rs, c.size); err != nil {
+			return fmt.Errorf("error copying RIFF chunk %s: %w", c.id, err)
+		}
+		if c.size%2 == 1 {
+			if _, err := w.Write([]byte{0}); err != nil {
+				return err
+			}
+		}
+	}
+	_, err = w.Write(info)
+	return err
+}
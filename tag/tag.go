@@ -0,0 +1,299 @@
+// Package tag reads and writes metadata tags of audio files. Tags are
+// stored in ID3v2 of mp3 files, RIFF LIST/INFO chunk of wav files, text
+// chunks of aiff files and Vorbis comments of flac files. Keys of tags
+// are lower case Vorbis comment names, so tags can be carried across
+// formats. Cover pictures are stored in ID3v2 APIC frames and flac
+// picture blocks.
+package tag
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+
+	"pipelined.dev/phono/fileformat"
+)
+
+// Keys of common tags. Other keys are carried only to formats that
+// support custom fields.
+const (
+	Title       = "title"
+	Artist      = "artist"
+	Album       = "album"
+	AlbumArtist = "albumartist"
+	Date        = "date"
+	Genre       = "genre"
+	TrackNumber = "tracknumber"
+	DiscNumber  = "discnumber"
+	Comment     = "comment"
+	Composer    = "composer"
+	Copyright   = "copyright"
+)
+
+// separator joins multiple values of the same key.
+const separator = "; "
+
+// ErrFormat is returned when tags of the format can't be read or
+// written.
+var ErrFormat = errors.New("unsupported format")
+
+// aliases maps alternative names to the keys of common tags.
+var aliases = map[string]string{
+	"album artist": AlbumArtist,
+	"album_artist": AlbumArtist,
+	"year":         Date,
+	"track":        TrackNumber,
+	"disc":         DiscNumber,
+	"description":  Comment,
+}
+
+type (
+	// Tags maps keys to values.
+	Tags map[string]string
+
+	// Metadata contains tags and cover picture of audio file.
+	Metadata struct {
+		Tags  Tags
+		Cover *Picture
+	}
+
+	// Edit changes metadata of the input. Strip removes all input tags
+	// and the cover before Set is applied. Empty values of Set remove
+	// the tags. Cover replaces the cover of the input. If MaxCoverSize
+	// is positive, larger covers are scaled down to it.
+	Edit struct {
+		Strip        bool
+		Set          Tags
+		Cover        *Picture
+		MaxCoverSize int
+	}
+)
+
+// Key returns the key of tag with provided name.
+func Key(name string) string {
+	key := strings.ToLower(strings.TrimSpace(name))
+	if alias, ok := aliases[key]; ok {
+		return alias
+	}
+	return key
+}
+
+// ValidKey returns true if key can be stored in Vorbis comment: it must
+// be non-empty printable ASCII without equals sign.
+func ValidKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7D || key[i] == '=' {
+			return false
+		}
+	}
+	return true
+}
+
+// add appends the value to the tag with provided name. Empty values are
+// ignored.
+func (t Tags) add(name, value string) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+	key := Key(name)
+	if v, ok := t[key]; ok && v != value {
+		t[key] = v + separator + value
+		return
+	}
+	t[key] = value
+}
+
+// Apply returns metadata changed by the edit. Provided metadata is not
+// modified.
+func (e Edit) Apply(m Metadata) (Metadata, error) {
+	result := Metadata{Tags: make(Tags)}
+	if !e.Strip {
+		for k, v := range m.Tags {
+			result.Tags[k] = v
+		}
+		result.Cover = m.Cover
+	}
+	for k, v := range e.Set {
+		if v == "" {
+			delete(result.Tags, Key(k))
+			continue
+		}
+		result.Tags[Key(k)] = v
+	}
+	if e.Cover != nil {
+		result.Cover = e.Cover
+	}
+	if result.Cover != nil && e.MaxCoverSize > 0 {
+		cover, err := result.Cover.Resize(e.MaxCoverSize)
+		if err != nil {
+			return Metadata{}, err
+		}
+		result.Cover = cover
+	}
+	return result, nil
+}
+
+// empty returns true if there are no tags and cover.
+func (m Metadata) empty() bool {
+	return len(m.Tags) == 0 && m.Cover == nil
+}
+
+// Read returns metadata of audio data encoded in provided format. Reader
+// is returned to the beginning, even if metadata is invalid.
+func Read(format *fileformat.Format, rs io.ReadSeeker) (Metadata, error) {
+	var (
+		m   Metadata
+		err error
+	)
+	switch format {
+	case fileformat.WAV():
+		m.Tags, err = readRIFF(rs)
+	case fileformat.MP3():
+		m, err = readMP3(rs)
+	case fileformat.FLAC():
+		m, err = readFLAC(rs)
+	case fileformat.AIFF():
+		m.Tags, err = readAIFF(rs)
+	default:
+		return Metadata{}, ErrFormat
+	}
+	if _, seekErr := rs.Seek(0, io.SeekStart); err == nil {
+		err = seekErr
+	}
+	if err != nil {
+		return Metadata{}, err
+	}
+	return m, nil
+}
+
+// Rewrite copies audio data encoded in provided format from the reader
+// to the writer and replaces all metadata with provided one. Cover is
+// not written to wav and aiff.
+func Rewrite(format *fileformat.Format, rs io.ReadSeeker, w io.Writer, m Metadata) error {
+	switch format {
+	case fileformat.WAV():
+		return rewriteRIFF(rs, w, m.Tags)
+	case fileformat.MP3():
+		return rewriteMP3(rs, w, m)
+	case fileformat.FLAC():
+		return rewriteFLAC(rs, w, m)
+	case fileformat.AIFF():
+		return rewriteAIFF(rs, w, m.Tags)
+	}
+	return ErrFormat
+}
+
+// Sink wraps the sink of provided format, so it writes the metadata.
+// Sink is returned unchanged if there is no metadata or format is not
+// supported. Cover is not written to wav and aiff.
+func Sink(format *fileformat.Format, m Metadata, sink func(io.WriteSeeker) pipe.SinkAllocatorFunc) func(io.WriteSeeker) pipe.SinkAllocatorFunc {
+	if m.empty() {
+		return sink
+	}
+	switch format {
+	case fileformat.WAV():
+		if len(m.Tags) == 0 {
+			return sink
+		}
+		return func(ws io.WriteSeeker) pipe.SinkAllocatorFunc {
+			return onFlush(sink(ws), func() error {
+				return appendRIFFInfo(ws, m.Tags)
+			})
+		}
+	case fileformat.AIFF():
+		if len(m.Tags) == 0 {
+			return sink
+		}
+		return func(ws io.WriteSeeker) pipe.SinkAllocatorFunc {
+			return onFlush(sink(ws), func() error {
+				return appendAIFFText(ws, m.Tags)
+			})
+		}
+	case fileformat.MP3():
+		return func(ws io.WriteSeeker) pipe.SinkAllocatorFunc {
+			return prepend(func(w io.Writer) pipe.SinkAllocatorFunc {
+				return sink(struct {
+					io.Writer
+					io.Seeker
+				}{w, ws})
+			}, ws, encodeID3v2(m))
+		}
+	case fileformat.FLAC():
+		blocks := encodeFLACBlocks(m)
+		if len(blocks) == 0 {
+			return sink
+		}
+		return func(ws io.WriteSeeker) pipe.SinkAllocatorFunc {
+			return sink(&flacMetadataWriter{
+				WriteSeeker: ws,
+				blocks:      blocks,
+			})
+		}
+	}
+	return sink
+}
+
+// Stream wraps the stream of provided format, so it writes the metadata.
+// Stream is returned unchanged if there is no metadata. Nil is returned
+// if metadata of the format can't be written without seeking.
+func Stream(format *fileformat.Format, m Metadata, stream func(io.Writer) pipe.SinkAllocatorFunc) func(io.Writer) pipe.SinkAllocatorFunc {
+	if m.empty() || stream == nil {
+		return stream
+	}
+	if format != fileformat.MP3() {
+		return nil
+	}
+	return func(w io.Writer) pipe.SinkAllocatorFunc {
+		return prepend(stream, w, encodeID3v2(m))
+	}
+}
+
+// prepend writes data before the first write of the sink, so nothing is
+// written if sink fails before it produces any output.
+func prepend(sink func(io.Writer) pipe.SinkAllocatorFunc, w io.Writer, data []byte) pipe.SinkAllocatorFunc {
+	return sink(&prefixWriter{Writer: w, prefix: data})
+}
+
+// prefixWriter writes the prefix before the first write.
+type prefixWriter struct {
+	io.Writer
+	prefix []byte
+}
+
+func (w *prefixWriter) Write(b []byte) (int, error) {
+	if w.prefix != nil {
+		if _, err := w.Writer.Write(w.prefix); err != nil {
+			return 0, err
+		}
+		w.prefix = nil
+	}
+	return w.Writer.Write(b)
+}
+
+// onFlush executes provided function after the sink is flushed.
+func onFlush(sink pipe.SinkAllocatorFunc, fn func() error) pipe.SinkAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Sink, error) {
+		s, err := sink(mctx, bufferSize, props)
+		if err != nil {
+			return pipe.Sink{}, err
+		}
+		flush := s.FlushFunc
+		s.FlushFunc = func(ctx context.Context) error {
+			if flush != nil {
+				if err := flush(ctx); err != nil {
+					return err
+				}
+			}
+			return fn()
+		}
+		return s, nil
+	}
+}
@@ -0,0 +1,302 @@
+package tag_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/audio/wav"
+	"pipelined.dev/pipe"
+	"pipelined.dev/signal"
+
+	"pipelined.dev/phono/aiff"
+	"pipelined.dev/phono/fileformat"
+	"pipelined.dev/phono/flac"
+	"pipelined.dev/phono/info"
+	"pipelined.dev/phono/tag"
+)
+
+const (
+	wavSample       = "../_testdata/sample.wav"
+	wavSampleFrames = 330534
+)
+
+var testTags = tag.Tags{
+	tag.Title:       "Título",
+	tag.Artist:      "Artist",
+	tag.Album:       "Album",
+	tag.TrackNumber: "3/12",
+	tag.Comment:     "Comment",
+	"mood":          "calm",
+}
+
+func TestEdit(t *testing.T) {
+	cover := testCover(t, 8, 8)
+	m := tag.Metadata{
+		Tags:  tag.Tags{tag.Title: "Title", tag.Artist: "Artist"},
+		Cover: cover,
+	}
+	t.Run("set", func(t *testing.T) {
+		edited, err := tag.Edit{Set: tag.Tags{"TITLE": "New", tag.Artist: "", "year": "2020"}}.Apply(m)
+		assert.Nil(t, err)
+		assert.Equal(t, tag.Tags{tag.Title: "New", tag.Date: "2020"}, edited.Tags)
+		assert.Equal(t, cover, edited.Cover)
+		// input is not changed.
+		assert.Equal(t, "Title", m.Tags[tag.Title])
+	})
+	t.Run("strip", func(t *testing.T) {
+		edited, err := tag.Edit{Strip: true, Set: tag.Tags{tag.Album: "Album"}}.Apply(m)
+		assert.Nil(t, err)
+		assert.Equal(t, tag.Metadata{Tags: tag.Tags{tag.Album: "Album"}}, edited)
+	})
+	t.Run("cover", func(t *testing.T) {
+		replaced := testCover(t, 40, 20)
+		edited, err := tag.Edit{Cover: replaced}.Apply(m)
+		assert.Nil(t, err)
+		assert.Equal(t, replaced, edited.Cover)
+
+		edited, err = tag.Edit{Cover: replaced, MaxCoverSize: 10}.Apply(m)
+		assert.Nil(t, err)
+		assert.Equal(t, "image/jpeg", edited.Cover.MIME)
+		config, _, err := image.DecodeConfig(bytes.NewReader(edited.Cover.Data))
+		assert.Nil(t, err)
+		assert.Equal(t, 10, config.Width)
+		assert.Equal(t, 5, config.Height)
+
+		// small covers are not changed.
+		edited, err = tag.Edit{MaxCoverSize: 10}.Apply(m)
+		assert.Nil(t, err)
+		assert.Equal(t, cover, edited.Cover)
+	})
+}
+
+func TestNewPicture(t *testing.T) {
+	p, err := tag.NewPicture(testCover(t, 4, 4).Data)
+	assert.Nil(t, err)
+	assert.Equal(t, "image/png", p.MIME)
+	assert.Equal(t, byte(tag.FrontCover), p.Type)
+	_, err = tag.NewPicture([]byte("not an image"))
+	assert.NotNil(t, err)
+}
+
+func TestResizeLarge(t *testing.T) {
+	// png header declares 60000x60000 image, but data is tiny.
+	data := testCover(t, 1, 1).Data
+	binary.BigEndian.PutUint32(data[16:], 60000)
+	binary.BigEndian.PutUint32(data[20:], 60000)
+	// crc of IHDR chunk covers its type and data.
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	p, err := tag.NewPicture(data)
+	assert.Nil(t, err)
+	_, err = p.Resize(500)
+	assert.NotNil(t, err)
+}
+
+func TestValidKey(t *testing.T) {
+	assert.True(t, tag.ValidKey("replaygain_track_gain"))
+	assert.False(t, tag.ValidKey(""))
+	assert.False(t, tag.ValidKey("a=b"))
+	assert.False(t, tag.ValidKey("ключ"))
+}
+
+func TestWAV(t *testing.T) {
+	sink := func(ws io.WriteSeeker) pipe.SinkAllocatorFunc {
+		return wav.Sink(ws, signal.BitDepth16)
+	}
+	// cover is not written to wav.
+	testFormat(t, fileformat.WAV(), sink, tag.Metadata{
+		Tags: tag.Tags{
+			tag.Title:       "Título",
+			tag.Artist:      "Artist",
+			tag.Album:       "Album",
+			tag.TrackNumber: "3/12",
+			tag.Comment:     "Comment",
+		},
+	})
+}
+
+func TestFLAC(t *testing.T) {
+	sink := func(ws io.WriteSeeker) pipe.SinkAllocatorFunc {
+		return flac.Sink(ws, signal.BitDepth16, flac.DefaultCompressionLevel)
+	}
+	testFormat(t, fileformat.FLAC(), sink, testMetadata(t))
+}
+
+func TestAIFF(t *testing.T) {
+	sink := func(ws io.WriteSeeker) pipe.SinkAllocatorFunc {
+		return aiff.Sink(ws, signal.BitDepth16)
+	}
+	// cover, album and track number are not written to aiff.
+	testFormat(t, fileformat.AIFF(), sink, tag.Metadata{
+		Tags: tag.Tags{
+			tag.Title:   "Título",
+			tag.Artist:  "Artist",
+			tag.Comment: "Comment",
+		},
+	})
+}
+
+// testFormat encodes the sample with metadata, reads it back and
+// replaces it in place. Expected metadata is supported by the format.
+func testFormat(t *testing.T, format *fileformat.Format, sink func(io.WriteSeeker) pipe.SinkAllocatorFunc, expected tag.Metadata) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "tag")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	in, err := os.Open(wavSample)
+	assert.Nil(t, err)
+	defer in.Close()
+	out, err := ioutil.TempFile(dir, "")
+	assert.Nil(t, err)
+	defer out.Close()
+	err = pipe.Run(context.Background(), 1024, pipe.Line{
+		Source: wav.Source(in),
+		Sink:   tag.Sink(format, testMetadata(t), sink)(out),
+	})
+	assert.Nil(t, err)
+	assertFile(t, format, out, expected)
+
+	rewritten, err := ioutil.TempFile(dir, "")
+	assert.Nil(t, err)
+	defer rewritten.Close()
+	replaced := tag.Metadata{Tags: tag.Tags{tag.Title: "Rewritten"}}
+	err = tag.Rewrite(format, out, rewritten, replaced)
+	assert.Nil(t, err)
+	assertFile(t, format, rewritten, replaced)
+
+	stripped, err := ioutil.TempFile(dir, "")
+	assert.Nil(t, err)
+	defer stripped.Close()
+	err = tag.Rewrite(format, rewritten, stripped, tag.Metadata{})
+	assert.Nil(t, err)
+	assertFile(t, format, stripped, tag.Metadata{Tags: tag.Tags{}})
+}
+
+// assertFile checks metadata and the length of the signal.
+func assertFile(t *testing.T, format *fileformat.Format, f *os.File, expected tag.Metadata) {
+	t.Helper()
+	m, err := tag.Read(format, f)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, m)
+	i, err := info.Read(format, f)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(wavSampleFrames), i.Frames)
+	_, err = f.Seek(0, io.SeekStart)
+	assert.Nil(t, err)
+}
+
+func TestMP3(t *testing.T) {
+	frames := []byte{0xFF, 0xFB, 0x90, 0x64, 0x00, 0x01, 0x02, 0x03}
+	cover := testCover(t, 8, 8)
+	cover.Description = "Cover"
+	var input bytes.Buffer
+	input.Write(id3v23(
+		id3Frame("TIT2", []byte{1, 0xFF, 0xFE, 'T', 0, 0xED, 0, 't', 0, 'u', 0, 'l', 0, 'o', 0}),
+		id3Frame("TPE1", []byte("\x00Artist")),
+		id3Frame("TCON", []byte("\x00(17)")),
+		id3Frame("TXXX", []byte("\x00MOOD\x00calm")),
+		id3Frame("COMM", []byte("\x00eng\x00Comment")),
+		id3Frame("APIC", append([]byte("\x00image/png\x00\x03Cover\x00"), cover.Data...)),
+	))
+	input.Write(frames)
+	input.Write(id3v1("Old title", "Album", 3))
+
+	t.Run("read", func(t *testing.T) {
+		m, err := tag.Read(fileformat.MP3(), bytes.NewReader(input.Bytes()))
+		assert.Nil(t, err)
+		assert.Equal(t, cover, m.Cover)
+		assert.Equal(t, tag.Tags{
+			tag.Title:       "Título",
+			tag.Artist:      "Artist",
+			tag.Album:       "Album",
+			tag.Genre:       "Rock",
+			tag.TrackNumber: "3",
+			tag.Comment:     "Comment",
+			"mood":          "calm",
+		}, m.Tags)
+	})
+	t.Run("rewrite", func(t *testing.T) {
+		var out bytes.Buffer
+		expected := testMetadata(t)
+		err := tag.Rewrite(fileformat.MP3(), bytes.NewReader(input.Bytes()), &out, expected)
+		assert.Nil(t, err)
+		m, err := tag.Read(fileformat.MP3(), bytes.NewReader(out.Bytes()))
+		assert.Nil(t, err)
+		assert.Equal(t, expected, m)
+		assert.True(t, bytes.HasSuffix(out.Bytes(), frames))
+	})
+	t.Run("strip", func(t *testing.T) {
+		var out bytes.Buffer
+		err := tag.Rewrite(fileformat.MP3(), bytes.NewReader(input.Bytes()), &out, tag.Metadata{})
+		assert.Nil(t, err)
+		assert.Equal(t, frames, out.Bytes())
+	})
+}
+
+func TestStream(t *testing.T) {
+	var stream func(io.Writer) pipe.SinkAllocatorFunc = func(w io.Writer) pipe.SinkAllocatorFunc {
+		return nil
+	}
+	assert.NotNil(t, tag.Stream(fileformat.MP3(), testMetadata(t), stream))
+	assert.NotNil(t, tag.Stream(fileformat.WAV(), tag.Metadata{}, stream))
+	// wav needs to seek to write tags.
+	assert.Nil(t, tag.Stream(fileformat.WAV(), testMetadata(t), stream))
+}
+
+// testMetadata returns test tags with the cover.
+func testMetadata(t *testing.T) tag.Metadata {
+	return tag.Metadata{
+		Tags:  testTags,
+		Cover: testCover(t, 16, 16),
+	}
+}
+
+// testCover returns the front cover with png image of provided size.
+func testCover(t *testing.T, width, height int) *tag.Picture {
+	t.Helper()
+	m := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(m, m.Bounds(), &image.Uniform{color.RGBA{R: 0xFF, A: 0xFF}}, image.Point{}, draw.Src)
+	var buf bytes.Buffer
+	assert.Nil(t, png.Encode(&buf, m))
+	p, err := tag.NewPicture(buf.Bytes())
+	assert.Nil(t, err)
+	return p
+}
+
+func id3v23(frames ...[]byte) []byte {
+	var body []byte
+	for _, f := range frames {
+		body = append(body, f...)
+	}
+	size := len(body)
+	header := []byte{'I', 'D', '3', 3, 0, 0, byte(size>>21) & 0x7F, byte(size>>14) & 0x7F, byte(size>>7) & 0x7F, byte(size) & 0x7F}
+	return append(header, body...)
+}
+
+func id3Frame(id string, data []byte) []byte {
+	size := len(data)
+	header := append([]byte(id), byte(size>>24), byte(size>>16), byte(size>>8), byte(size), 0, 0)
+	return append(header, data...)
+}
+
+func id3v1(title, album string, track byte) []byte {
+	b := make([]byte, 128)
+	copy(b, "TAG")
+	copy(b[3:], title)
+	copy(b[63:], album)
+	b[126] = track
+	b[127] = 0xFF
+	return b
+}
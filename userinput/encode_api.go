@@ -0,0 +1,806 @@
+package userinput
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/signal"
+
+	"pipelined.dev/phono/encode"
+	"pipelined.dev/phono/fileformat"
+	"pipelined.dev/phono/flac"
+	"pipelined.dev/phono/tag"
+	"pipelined.dev/phono/waveform"
+)
+
+const (
+	// APIFileKey is the name of the file part in multipart API request.
+	APIFileKey = "file"
+	// APISpecKey is the name of the spec part in multipart API request
+	// or the query parameter with spec for raw body request.
+	APISpecKey = "spec"
+	// APICoverKey is the name of the optional cover part in multipart
+	// API request.
+	APICoverKey = "cover"
+	// maxSpecSize limits the size of JSON spec.
+	maxSpecSize = 1 << 16
+)
+
+type (
+	// EncodeAPI provides user interaction via JSON API.
+	EncodeAPI struct {
+		limits  Limits
+		formats Formats
+	}
+
+	// EncodeSpec is a JSON specification of encoding request.
+	EncodeSpec struct {
+		// Input format. Optional if file name or content type is
+		// provided.
+		Input string `json:"input,omitempty"`
+		// Output format.
+		Format string    `json:"format"`
+		WAV    *WAVSpec  `json:"wav,omitempty"`
+		AIFF   *AIFFSpec `json:"aiff,omitempty"`
+		MP3    *MP3Spec  `json:"mp3,omitempty"`
+		FLAC   *FLACSpec `json:"flac,omitempty"`
+		// Process contains optional processing parameters.
+		Process *ProcessSpec `json:"process,omitempty"`
+		// Waveform of the encoded signal is measured if provided. It's
+		// only available for asynchronous jobs.
+		Waveform *WaveformSpec `json:"waveform,omitempty"`
+		// Tags override tags of the input, empty values remove them.
+		// StripTags removes all tags of the input.
+		Tags      map[string]string `json:"tags,omitempty"`
+		StripTags bool              `json:"stripTags,omitempty"`
+		// CoverMaxSize scales down covers with larger dimensions.
+		CoverMaxSize int `json:"coverMaxSize,omitempty"`
+	}
+
+	// WaveformSpec contains parameters of waveform. Defaults are used
+	// for zero values.
+	WaveformSpec struct {
+		SamplesPerPixel int `json:"samplesPerPixel,omitempty"`
+		// Format is one of json, dat, png and svg.
+		Format string `json:"format,omitempty"`
+		// Bits per value of json and dat formats, 8 or 16.
+		Bits int `json:"bits,omitempty"`
+		// Height, Color and Background define images. Colors have
+		// #rrggbb or #rrggbbaa format, background is transparent if
+		// empty.
+		Height     int    `json:"height,omitempty"`
+		Color      string `json:"color,omitempty"`
+		Background string `json:"background,omitempty"`
+	}
+
+	// WaveformRequestSpec is a JSON specification of waveform request.
+	WaveformRequestSpec struct {
+		// Input format. Optional if file name or content type is
+		// provided.
+		Input string `json:"input,omitempty"`
+		WaveformSpec
+	}
+
+	// inputSpec is a spec of request with input file.
+	inputSpec interface {
+		input() string
+	}
+
+	// ProcessSpec contains optional processing parameters. Zero values
+	// disable processing.
+	ProcessSpec struct {
+		// Start, End and Duration define the range of the input in
+		// seconds, hh:mm:ss.mmm or samples, e.g. 44100smp.
+		Start    string `json:"start,omitempty"`
+		End      string `json:"end,omitempty"`
+		Duration string `json:"duration,omitempty"`
+		// TrimSilence enables silence trimming.
+		TrimSilence *SilenceSpec `json:"trimSilence,omitempty"`
+		// FadeIn and FadeOut lengths have the same format as range.
+		FadeIn          string `json:"fadeIn,omitempty"`
+		FadeOut         string `json:"fadeOut,omitempty"`
+		FadeCurve       string `json:"fadeCurve,omitempty"`
+		SampleRate      int    `json:"sampleRate,omitempty"`
+		ResampleQuality string `json:"resampleQuality,omitempty"`
+		Channels        int    `json:"channels,omitempty"`
+		ChannelMap      string `json:"channelMap,omitempty"`
+		// Normalize is a target loudness in LUFS.
+		Normalize float64 `json:"normalize,omitempty"`
+		// TruePeak is a ceiling of normalization in dBTP. Default
+		// value is used if not provided.
+		TruePeak *float64 `json:"truePeak,omitempty"`
+		// Gain in dB.
+		Gain float64 `json:"gain,omitempty"`
+		// PeakNormalize is a target peak in dBFS.
+		PeakNormalize *float64 `json:"peakNormalize,omitempty"`
+	}
+
+	// SilenceSpec contains parameters of silence trimming. Durations
+	// have the same format as range. Defaults are used for zero values.
+	SilenceSpec struct {
+		Threshold   *float64 `json:"threshold,omitempty"`
+		MinDuration string   `json:"minDuration,omitempty"`
+		Padding     string   `json:"padding,omitempty"`
+	}
+
+	// WAVSpec contains parameters of wav output.
+	WAVSpec struct {
+		BitDepth int `json:"bitDepth"`
+	}
+
+	// AIFFSpec contains parameters of aiff output.
+	AIFFSpec struct {
+		BitDepth int `json:"bitDepth"`
+	}
+
+	// MP3Spec contains parameters of mp3 output. Bit rate is a VBR
+	// quality for VBR mode. Quality is optional.
+	MP3Spec struct {
+		ChannelMode int    `json:"channelMode"`
+		BitRateMode string `json:"bitRateMode"`
+		BitRate     int    `json:"bitRate"`
+		Quality     *int   `json:"quality,omitempty"`
+	}
+
+	// FLACSpec contains parameters of flac output. Compression level is
+	// optional.
+	FLACSpec struct {
+		BitDepth         int  `json:"bitDepth"`
+		CompressionLevel *int `json:"compressionLevel,omitempty"`
+	}
+
+	// Formats describes supported inputs and outputs.
+	Formats struct {
+		Inputs  []string `json:"inputs"`
+		Outputs struct {
+			WAV  WAVFormat  `json:"wav"`
+			AIFF AIFFFormat `json:"aiff"`
+			MP3  MP3Format  `json:"mp3"`
+			FLAC FLACFormat `json:"flac"`
+		} `json:"outputs"`
+		Process struct {
+			Silence   SilenceFormat   `json:"trimSilence"`
+			Fade      FadeFormat      `json:"fade"`
+			Resample  ResampleFormat  `json:"resample"`
+			Channels  Range           `json:"channels"`
+			Normalize NormalizeFormat `json:"normalize"`
+			Gain      FloatRange      `json:"gain"`
+			Peak      FloatRange      `json:"peakNormalize"`
+		} `json:"process"`
+		Waveform WaveformFormat `json:"waveform"`
+		Tags     TagsFormat     `json:"tags"`
+	}
+
+	// TagsFormat describes tags parameters. Keys are common tags, other
+	// keys are written only to formats that support custom tags.
+	TagsFormat struct {
+		Keys          []string `json:"keys"`
+		MaxLength     int      `json:"maxLength"`
+		MaxCoverBytes int64    `json:"maxCoverBytes"`
+		CoverMaxSize  Range    `json:"coverMaxSize"`
+	}
+
+	// WaveformFormat describes waveform parameters.
+	WaveformFormat struct {
+		SamplesPerPixel        Range    `json:"samplesPerPixel"`
+		DefaultSamplesPerPixel int      `json:"defaultSamplesPerPixel"`
+		Formats                []string `json:"formats"`
+		DefaultFormat          string   `json:"defaultFormat"`
+		Bits                   []int    `json:"bits"`
+		DefaultBits            int      `json:"defaultBits"`
+		Height                 Range    `json:"height"`
+		DefaultHeight          int      `json:"defaultHeight"`
+		DefaultColor           string   `json:"defaultColor"`
+	}
+
+	// Range of allowed values, both ends are inclusive.
+	Range struct {
+		Min int `json:"min"`
+		Max int `json:"max"`
+	}
+
+	// FloatRange of allowed values, both ends are inclusive.
+	FloatRange struct {
+		Min float64 `json:"min"`
+		Max float64 `json:"max"`
+	}
+
+	// WAVFormat describes wav output parameters.
+	WAVFormat struct {
+		Extension string `json:"extension"`
+		BitDepths []int  `json:"bitDepths"`
+	}
+
+	// AIFFFormat describes aiff output parameters.
+	AIFFFormat struct {
+		Extension string `json:"extension"`
+		BitDepths []int  `json:"bitDepths"`
+	}
+
+	// MP3Format describes mp3 output parameters. Bit rate range is
+	// provided for each bit rate mode.
+	MP3Format struct {
+		Extension    string           `json:"extension"`
+		ChannelModes map[string]int   `json:"channelModes"`
+		BitRateModes map[string]Range `json:"bitRateModes"`
+		Quality      Range            `json:"quality"`
+	}
+
+	// ResampleFormat describes sample rate conversion parameters.
+	ResampleFormat struct {
+		SampleRate     Range    `json:"sampleRate"`
+		Qualities      []string `json:"qualities"`
+		DefaultQuality string   `json:"defaultQuality"`
+	}
+
+	// SilenceFormat describes silence trimming parameters.
+	SilenceFormat struct {
+		Threshold          FloatRange `json:"threshold"`
+		DefaultThreshold   float64    `json:"defaultThreshold"`
+		DefaultMinDuration string     `json:"defaultMinDuration"`
+		DefaultPadding     string     `json:"defaultPadding"`
+	}
+
+	// FadeFormat describes fade parameters.
+	FadeFormat struct {
+		Curves       []string `json:"curves"`
+		DefaultCurve string   `json:"defaultCurve"`
+	}
+
+	// NormalizeFormat describes loudness normalization parameters.
+	NormalizeFormat struct {
+		Target          FloatRange `json:"target"`
+		TruePeak        FloatRange `json:"truePeak"`
+		DefaultTruePeak float64    `json:"defaultTruePeak"`
+	}
+
+	// FLACFormat describes flac output parameters.
+	FLACFormat struct {
+		Extension        string `json:"extension"`
+		BitDepths        []int  `json:"bitDepths"`
+		CompressionLevel Range  `json:"compressionLevel"`
+		// DefaultCompressionLevel is used if level is not provided.
+		DefaultCompressionLevel int `json:"defaultCompressionLevel"`
+	}
+)
+
+// contentTypes maps media types of raw request body to input formats.
+var contentTypes = map[string]*fileformat.Format{
+	"audio/wav":    fileformat.WAV(),
+	"audio/wave":   fileformat.WAV(),
+	"audio/x-wav":  fileformat.WAV(),
+	"audio/aiff":   fileformat.AIFF(),
+	"audio/x-aiff": fileformat.AIFF(),
+	"audio/mpeg":   fileformat.MP3(),
+	"audio/mp3":    fileformat.MP3(),
+	"audio/flac":   fileformat.FLAC(),
+	"audio/x-flac": fileformat.FLAC(),
+}
+
+// NewEncodeAPI creates new API with provided limits.
+func NewEncodeAPI(limits Limits) EncodeAPI {
+	return EncodeAPI{
+		limits:  limits,
+		formats: describeFormats(),
+	}
+}
+
+// Formats returns description of supported formats.
+func (a EncodeAPI) Formats() interface{} {
+	return a.formats
+}
+
+// ParseRequest returns the data provided by the user via API request.
+// Multipart request must contain file and spec parts. Any other request
+// contains raw file in the body and spec in query parameter.
+func (a EncodeAPI) ParseRequest(r *http.Request, tempDir string) (encode.FormData, error) {
+	var (
+		spec EncodeSpec
+		data encode.FormData
+	)
+	input, err := a.parseInput(r, tempDir, &spec, func() (err error) {
+		data, err = spec.formData()
+		return
+	})
+	if err != nil {
+		return encode.FormData{}, err
+	}
+	if data.Tags.Cover, err = multipartCover(r.MultipartForm, APICoverKey, data.Tags.MaxCoverSize); err != nil {
+		input.Close()
+		return encode.FormData{}, invalidParameter(err)
+	}
+	data.Input = input
+	return data, nil
+}
+
+// ParseWaveformRequest returns the data of waveform request. Request
+// has the same structure as encoding request, but spec only contains
+// waveform parameters.
+func (a EncodeAPI) ParseWaveformRequest(r *http.Request, tempDir string) (encode.FormData, error) {
+	var (
+		spec WaveformRequestSpec
+		data encode.FormData
+	)
+	input, err := a.parseInput(r, tempDir, &spec, func() error {
+		samplesPerPixel, format, err := spec.WaveformSpec.parse()
+		if err != nil {
+			return err
+		}
+		data.Processing.Waveform = samplesPerPixel
+		data.Waveform = &format
+		return nil
+	})
+	if err != nil {
+		return encode.FormData{}, err
+	}
+	data.Input = input
+	return data, nil
+}
+
+// parseInput decodes the spec of the request and returns the input file.
+// Parse function is called after spec is decoded, but before the input
+// is read, so invalid requests are rejected early.
+func (a EncodeAPI) parseInput(r *http.Request, tempDir string, spec inputSpec, parse func() error) (encode.Input, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		return a.parseMultipart(r, spec, parse)
+	}
+	return a.parseRaw(r, mediaType, tempDir, spec, parse)
+}
+
+func (a EncodeAPI) parseMultipart(r *http.Request, spec inputSpec, parse func() error) (encode.Input, error) {
+	maxSize := a.maxSize()
+	if maxSize > 0 {
+		r.Body = http.MaxBytesReader(nil, r.Body, maxSize+maxSpecSize+Tags.MaxCoverBytes)
+	}
+	if err := r.ParseMultipartForm(maxSpecSize); err != nil {
+		// error of MaxBytesReader is not typed in older versions of go.
+		if maxSize > 0 && strings.Contains(err.Error(), "http: request body too large") {
+			return encode.Input{}, encode.NewError(http.StatusRequestEntityTooLarge, encode.CodeFileTooLarge, "File exceeds %d bytes", maxSize)
+		}
+		return encode.Input{}, encode.NewError(http.StatusBadRequest, encode.CodeInvalidRequest, "Failed to parse multipart request: %v", err)
+	}
+	if err := parseSpec(multipartSpec(r.MultipartForm), spec); err != nil {
+		return encode.Input{}, err
+	}
+	file, header, err := r.FormFile(APIFileKey)
+	if err != nil {
+		return encode.Input{}, encode.NewError(http.StatusBadRequest, encode.CodeInvalidRequest, "File not provided: %v", err)
+	}
+
+	format, err := inputFormat(spec.input(), header.Filename)
+	if err != nil {
+		file.Close()
+		return encode.Input{}, err
+	}
+	if maxSize := a.limits[format]; maxSize > 0 && header.Size > maxSize {
+		file.Close()
+		return encode.Input{}, encode.NewError(http.StatusRequestEntityTooLarge, encode.CodeFileTooLarge, "File exceeds %d bytes", maxSize)
+	}
+	if err := parse(); err != nil {
+		file.Close()
+		return encode.Input{}, err
+	}
+	return encode.Input{
+		Format: format,
+		File:   file,
+	}, nil
+}
+
+func (a EncodeAPI) parseRaw(r *http.Request, mediaType, tempDir string, spec inputSpec, parse func() error) (encode.Input, error) {
+	var values []string
+	if v := r.URL.Query().Get(APISpecKey); v != "" {
+		values = append(values, v)
+	}
+	if err := parseSpec(values, spec); err != nil {
+		return encode.Input{}, err
+	}
+	format := contentTypes[mediaType]
+	if spec.input() != "" || format == nil {
+		var err error
+		if format, err = inputFormat(spec.input(), ""); err != nil {
+			return encode.Input{}, err
+		}
+	}
+	if err := parse(); err != nil {
+		return encode.Input{}, err
+	}
+
+	// body is stored in temp file, because sources need to seek.
+	file, err := ioutil.TempFile(tempDir, "")
+	if err != nil {
+		return encode.Input{}, encode.NewError(http.StatusInternalServerError, encode.CodeInternal, "%v", err)
+	}
+	body := io.Reader(r.Body)
+	maxSize := a.limits[format]
+	if maxSize > 0 {
+		body = io.LimitReader(body, maxSize+1)
+	}
+	n, err := io.Copy(file, body)
+	if err == nil {
+		_, err = file.Seek(0, 0)
+	}
+	if err != nil {
+		removeFile{file}.Close()
+		return encode.Input{}, encode.NewError(http.StatusBadRequest, encode.CodeInvalidRequest, "Failed to read request body: %v", err)
+	}
+	if maxSize > 0 && n > maxSize {
+		removeFile{file}.Close()
+		return encode.Input{}, encode.NewError(http.StatusRequestEntityTooLarge, encode.CodeFileTooLarge, "File exceeds %d bytes", maxSize)
+	}
+	if n == 0 {
+		removeFile{file}.Close()
+		return encode.Input{}, encode.NewError(http.StatusBadRequest, encode.CodeInvalidRequest, "File not provided")
+	}
+	return encode.Input{
+		Format: format,
+		File:   removeFile{file},
+	}, nil
+}
+
+// maxSize returns the largest limit among input formats. Zero is returned
+// if any of the formats has no limit.
+func (a EncodeAPI) maxSize() int64 {
+	var max int64
+	for _, format := range []*fileformat.Format{fileformat.WAV(), fileformat.AIFF(), fileformat.MP3(), fileformat.FLAC()} {
+		limit := a.limits[format]
+		if limit <= 0 {
+			return 0
+		}
+		if limit > max {
+			max = limit
+		}
+	}
+	return max
+}
+
+// multipartCover returns the cover provided in the part with the key.
+// Nil is returned if form doesn't contain the part.
+func multipartCover(form *multipart.Form, key string, maxSize int) (*tag.Picture, error) {
+	if form == nil || len(form.File[key]) == 0 {
+		return nil, nil
+	}
+	f, err := form.File[key][0].Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Tags.Cover(f, maxSize)
+}
+
+// multipartSpec returns spec provided either as a value or as a file.
+func multipartSpec(form *multipart.Form) []string {
+	if values := form.Value[APISpecKey]; len(values) > 0 {
+		return values
+	}
+	headers := form.File[APISpecKey]
+	if len(headers) == 0 {
+		return nil
+	}
+	f, err := headers[0].Open()
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	b, err := ioutil.ReadAll(io.LimitReader(f, maxSpecSize))
+	if err != nil {
+		return nil
+	}
+	return []string{string(b)}
+}
+
+// parseSpec decodes JSON spec into provided value. Unknown fields are
+// not allowed.
+func parseSpec(values []string, spec interface{}) error {
+	if len(values) == 0 || values[0] == "" {
+		return encode.NewError(http.StatusBadRequest, encode.CodeInvalidSpec, "Spec not provided")
+	}
+	d := json.NewDecoder(strings.NewReader(values[0]))
+	d.DisallowUnknownFields()
+	if err := d.Decode(spec); err != nil {
+		return encode.NewError(http.StatusBadRequest, encode.CodeInvalidSpec, "Failed to parse spec: %v", err)
+	}
+	return nil
+}
+
+// inputFormat returns format defined by input or by file name.
+func inputFormat(input, fileName string) (*fileformat.Format, error) {
+	name := fileName
+	if input != "" {
+		name = "." + strings.TrimPrefix(strings.ToLower(input), ".")
+	}
+	format := fileformat.FormatByPath(name)
+	if format == nil {
+		return nil, encode.NewError(http.StatusBadRequest, encode.CodeUnsupportedInput, "Unsupported input format: %v", name)
+	}
+	return format, nil
+}
+
+func (s *EncodeSpec) input() string {
+	return s.Input
+}
+
+func (s *WaveformRequestSpec) input() string {
+	return s.Input
+}
+
+// formData validates output and processing parameters.
+func (s EncodeSpec) formData() (encode.FormData, error) {
+	output, err := s.output()
+	if err != nil {
+		return encode.FormData{}, err
+	}
+	processing, err := s.processing()
+	if err != nil {
+		return encode.FormData{}, err
+	}
+	tags, err := Tags.Edit(s.Tags, s.StripTags)
+	if err != nil {
+		return encode.FormData{}, invalidParameter(err)
+	}
+	if tags.MaxCoverSize, err = Tags.CoverSize(s.CoverMaxSize); err != nil {
+		return encode.FormData{}, invalidParameter(err)
+	}
+	data := encode.FormData{
+		Output:     output,
+		Processing: processing,
+		Tags:       tags,
+	}
+	if s.Waveform != nil {
+		samplesPerPixel, format, err := s.Waveform.parse()
+		if err != nil {
+			return encode.FormData{}, err
+		}
+		data.Processing.Waveform = samplesPerPixel
+		data.Waveform = &format
+	}
+	return data, nil
+}
+
+// parse validates waveform parameters. Defaults are used for zero
+// values.
+func (s WaveformSpec) parse() (int, waveform.Format, error) {
+	samplesPerPixel := s.SamplesPerPixel
+	if samplesPerPixel == 0 {
+		samplesPerPixel = Waveform.DefaultSamplesPerPixel
+	}
+	if err := Waveform.SamplesPerPixel(samplesPerPixel); err != nil {
+		return 0, waveform.Format{}, invalidParameter(err)
+	}
+	name, bits, height, fg := s.Format, s.Bits, s.Height, s.Color
+	if name == "" {
+		name = Waveform.DefaultFormat
+	}
+	if bits == 0 {
+		bits = Waveform.DefaultBits
+	}
+	if height == 0 {
+		height = Waveform.DefaultHeight
+	}
+	if fg == "" {
+		fg = Waveform.DefaultColor
+	}
+	format, err := Waveform.Format(name, bits, height, fg, s.Background)
+	if err != nil {
+		return 0, waveform.Format{}, invalidParameter(err)
+	}
+	return samplesPerPixel, format, nil
+}
+
+// output validates output parameters and returns output.
+func (s EncodeSpec) output() (encode.Output, error) {
+	formatString := "." + strings.TrimPrefix(strings.ToLower(s.Format), ".")
+	format := fileformat.FormatByPath(formatString)
+	var (
+		sink   Sink
+		stream Stream
+		err    error
+	)
+	switch format {
+	case fileformat.WAV():
+		if s.WAV == nil {
+			return encode.Output{}, missingParameters("wav")
+		}
+		sink, err = WAV.Sink(s.WAV.BitDepth)
+	case fileformat.AIFF():
+		if s.AIFF == nil {
+			return encode.Output{}, missingParameters("aiff")
+		}
+		sink, err = AIFF.Sink(s.AIFF.BitDepth)
+	case fileformat.MP3():
+		if s.MP3 == nil {
+			return encode.Output{}, missingParameters("mp3")
+		}
+		var quality int
+		if s.MP3.Quality != nil {
+			quality = *s.MP3.Quality
+		}
+		stream, err = MP3.Stream(s.MP3.BitRateMode, s.MP3.BitRate, s.MP3.ChannelMode, s.MP3.Quality != nil, quality)
+		sink = stream.Sink()
+	case fileformat.FLAC():
+		if s.FLAC == nil {
+			return encode.Output{}, missingParameters("flac")
+		}
+		compressionLevel := int(flac.DefaultCompressionLevel)
+		if s.FLAC.CompressionLevel != nil {
+			compressionLevel = *s.FLAC.CompressionLevel
+		}
+		sink, err = FLAC.Sink(s.FLAC.BitDepth, compressionLevel)
+	default:
+		return encode.Output{}, encode.NewError(http.StatusBadRequest, encode.CodeUnsupportedOutput, "Unsupported output format: %v", s.Format)
+	}
+	if err != nil {
+		return encode.Output{}, invalidParameter(err)
+	}
+	return encode.Output{
+		Format: format,
+		Sink:   sink,
+		Stream: stream,
+	}, nil
+}
+
+// processing validates processing parameters and returns processing.
+func (s EncodeSpec) processing() (encode.Processing, error) {
+	if s.Process == nil {
+		return encode.Processing{}, nil
+	}
+	p := ProcessParams{
+		Start:           s.Process.Start,
+		End:             s.Process.End,
+		Duration:        s.Process.Duration,
+		TrimSilence:     s.Process.TrimSilence != nil,
+		FadeIn:          s.Process.FadeIn,
+		FadeOut:         s.Process.FadeOut,
+		FadeCurve:       s.Process.FadeCurve,
+		SampleRate:      s.Process.SampleRate,
+		ResampleQuality: s.Process.ResampleQuality,
+		Channels:        s.Process.Channels,
+		ChannelMap:      s.Process.ChannelMap,
+		TruePeak:        Normalize.DefaultCeiling,
+		Gain:            s.Process.Gain,
+		PeakNormalize:   s.Process.PeakNormalize,
+	}
+	if s.Process.TrimSilence != nil {
+		p.SilenceThreshold = Silence.DefaultThreshold
+		if s.Process.TrimSilence.Threshold != nil {
+			p.SilenceThreshold = *s.Process.TrimSilence.Threshold
+		}
+		p.SilenceDuration = s.Process.TrimSilence.MinDuration
+		p.SilencePadding = s.Process.TrimSilence.Padding
+	}
+	if s.Process.Normalize != 0 {
+		p.Normalize = &s.Process.Normalize
+	}
+	if s.Process.TruePeak != nil {
+		p.TruePeak = *s.Process.TruePeak
+	}
+	processing, err := p.Processing()
+	if err != nil {
+		return encode.Processing{}, invalidParameter(err)
+	}
+	return processing, nil
+}
+
+func invalidParameter(err error) error {
+	return encode.NewError(http.StatusBadRequest, encode.CodeInvalidParameter, "%v", err)
+}
+
+func missingParameters(format string) error {
+	return encode.NewError(http.StatusBadRequest, encode.CodeInvalidParameter, "Parameters of %s output not provided", format)
+}
+
+// describeFormats returns description of formats derived from sinks.
+func describeFormats() Formats {
+	var f Formats
+	f.Inputs = inputExtensions(fileformat.WAV(), fileformat.AIFF(), fileformat.MP3(), fileformat.FLAC())
+	f.Outputs.WAV = WAVFormat{
+		Extension: fileformat.WAV().DefaultExtension(),
+		BitDepths: bitDepths(WAV.BitDepths),
+	}
+	f.Outputs.AIFF = AIFFFormat{
+		Extension: fileformat.AIFF().DefaultExtension(),
+		BitDepths: bitDepths(AIFF.BitDepths),
+	}
+	f.Outputs.MP3 = MP3Format{
+		Extension:    fileformat.MP3().DefaultExtension(),
+		ChannelModes: make(map[string]int),
+		BitRateModes: map[string]Range{
+			MP3.VBR: {Min: MP3.MinVBR, Max: MP3.MaxVBR},
+			MP3.CBR: {Min: MP3.MinBitRate, Max: MP3.MaxBitRate},
+			MP3.ABR: {Min: MP3.MinBitRate, Max: MP3.MaxBitRate},
+		},
+		Quality: Range{Min: MP3.MinQuality, Max: MP3.MaxQuality},
+	}
+	for cm := range MP3.ChannelModes {
+		f.Outputs.MP3.ChannelModes[channelModeName(cm)] = int(cm)
+	}
+	f.Outputs.FLAC = FLACFormat{
+		Extension:               fileformat.FLAC().DefaultExtension(),
+		BitDepths:               bitDepths(FLAC.BitDepths),
+		CompressionLevel:        Range{Min: FLAC.MinCompressionLevel, Max: FLAC.MaxCompressionLevel},
+		DefaultCompressionLevel: int(flac.DefaultCompressionLevel),
+	}
+	f.Process.Silence = SilenceFormat{
+		Threshold:          FloatRange{Min: Silence.MinThreshold, Max: Silence.MaxThreshold},
+		DefaultThreshold:   Silence.DefaultThreshold,
+		DefaultMinDuration: Silence.DefaultMinDuration,
+		DefaultPadding:     Silence.DefaultPadding,
+	}
+	f.Process.Fade.DefaultCurve = string(Fade.DefaultCurve)
+	for c := range Fade.Curves {
+		f.Process.Fade.Curves = append(f.Process.Fade.Curves, string(c))
+	}
+	sort.Strings(f.Process.Fade.Curves)
+	f.Process.Resample = ResampleFormat{
+		SampleRate:     Range{Min: Resample.MinSampleRate, Max: Resample.MaxSampleRate},
+		DefaultQuality: string(Resample.DefaultQuality),
+	}
+	for q := range Resample.Qualities {
+		f.Process.Resample.Qualities = append(f.Process.Resample.Qualities, string(q))
+	}
+	sort.Strings(f.Process.Resample.Qualities)
+	f.Process.Channels = Range{Min: Channels.MinChannels, Max: Channels.MaxChannels}
+	f.Process.Normalize = NormalizeFormat{
+		Target:          FloatRange{Min: Normalize.MinTarget, Max: Normalize.MaxTarget},
+		TruePeak:        FloatRange{Min: Normalize.MinCeiling, Max: Normalize.MaxCeiling},
+		DefaultTruePeak: Normalize.DefaultCeiling,
+	}
+	f.Process.Gain = FloatRange{Min: Gain.MinGain, Max: Gain.MaxGain}
+	f.Process.Peak = FloatRange{Min: Gain.MinPeak, Max: Gain.MaxPeak}
+	f.Waveform = WaveformFormat{
+		SamplesPerPixel:        Range{Min: Waveform.MinSamplesPerPixel, Max: Waveform.MaxSamplesPerPixel},
+		DefaultSamplesPerPixel: Waveform.DefaultSamplesPerPixel,
+		Formats:                Waveform.FormatNames(),
+		DefaultFormat:          Waveform.DefaultFormat,
+		DefaultBits:            Waveform.DefaultBits,
+		Height:                 Range{Min: Waveform.MinHeight, Max: Waveform.MaxHeight},
+		DefaultHeight:          Waveform.DefaultHeight,
+		DefaultColor:           Waveform.DefaultColor,
+	}
+	for b := range Waveform.Bits {
+		f.Waveform.Bits = append(f.Waveform.Bits, b)
+	}
+	sort.Ints(f.Waveform.Bits)
+	f.Tags = TagsFormat{
+		Keys:          Tags.FormKeys,
+		MaxLength:     Tags.MaxLength,
+		MaxCoverBytes: Tags.MaxCoverBytes,
+		CoverMaxSize:  Range{Min: Tags.MinCoverSize, Max: Tags.MaxCoverSize},
+	}
+	return f
+}
+
+// channelModeName returns name of channel mode, e.g. joint-stereo.
+func channelModeName(cm mp3.ChannelMode) string {
+	return strings.ReplaceAll(strings.ToLower(cm.String()), " ", "-")
+}
+
+func bitDepths(m map[signal.BitDepth]struct{}) []int {
+	result := make([]int, 0, len(m))
+	for bd := range m {
+		result = append(result, int(bd))
+	}
+	sort.Ints(result)
+	return result
+}
+
+// removeFile removes the file when it's closed.
+type removeFile struct {
+	*os.File
+}
+
+func (f removeFile) Close() error {
+	err := f.File.Close()
+	if rmErr := os.Remove(f.Name()); err == nil {
+		err = rmErr
+	}
+	return err
+}
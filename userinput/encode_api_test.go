@@ -0,0 +1,32 @@
+package userinput_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/phono/userinput"
+)
+
+func TestAPIFormats(t *testing.T) {
+	api := userinput.NewEncodeAPI(userinput.Limits{})
+	b, err := json.Marshal(api.Formats())
+	assert.Nil(t, err)
+
+	var formats userinput.Formats
+	assert.Nil(t, json.Unmarshal(b, &formats))
+	assert.Contains(t, formats.Inputs, ".wav")
+	assert.Contains(t, formats.Inputs, ".mp3")
+	assert.Contains(t, formats.Inputs, ".flac")
+	assert.Contains(t, formats.Inputs, ".aif")
+	assert.Contains(t, formats.Inputs, ".aiff")
+	assert.Equal(t, []int{8, 16, 24, 32}, formats.Outputs.WAV.BitDepths)
+	assert.Equal(t, []int{8, 16, 24, 32}, formats.Outputs.AIFF.BitDepths)
+	assert.Equal(t, []int{8, 16, 24}, formats.Outputs.FLAC.BitDepths)
+	assert.Equal(t, userinput.Range{Min: userinput.FLAC.MinCompressionLevel, Max: userinput.FLAC.MaxCompressionLevel}, formats.Outputs.FLAC.CompressionLevel)
+	assert.Equal(t, map[string]int{"mono": 0, "stereo": 1, "joint-stereo": 2}, formats.Outputs.MP3.ChannelModes)
+	assert.Equal(t, userinput.Range{Min: userinput.MP3.MinVBR, Max: userinput.MP3.MaxVBR}, formats.Outputs.MP3.BitRateModes[userinput.MP3.VBR])
+	assert.Equal(t, userinput.Range{Min: userinput.MP3.MinBitRate, Max: userinput.MP3.MaxBitRate}, formats.Outputs.MP3.BitRateModes[userinput.MP3.CBR])
+	assert.Equal(t, userinput.Tags.FormKeys, formats.Tags.Keys)
+}
@@ -4,15 +4,16 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"mime/multipart"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
 	"text/template"
 
-	"pipelined.dev/audio/fileformat"
-
 	"pipelined.dev/phono/encode"
+	"pipelined.dev/phono/fileformat"
+	"pipelined.dev/phono/tag"
 )
 
 var (
@@ -25,6 +26,9 @@ var formTemplate = template.Must(template.New("encode").Parse(encodeHTML))
 // FormFileKey is the id of the file userinput in the HTML form.
 const FormFileKey = "form-file"
 
+// FormCoverKey is the id of the optional cover image in the HTML form.
+const FormCoverKey = "form-cover"
+
 type (
 	// Limits for user-provided input files.
 	Limits map[*fileformat.Format]int64
@@ -41,7 +45,16 @@ type (
 		Accept     string
 		OutFormats []string
 		WAV        interface{}
+		AIFF       interface{}
 		MP3        interface{}
+		FLAC       interface{}
+		Resample   interface{}
+		Channels   interface{}
+		Normalize  interface{}
+		Gain       interface{}
+		Fade       interface{}
+		Silence    interface{}
+		Tags       interface{}
 		MaxSizes   map[string]int64
 	}
 )
@@ -54,16 +67,28 @@ func NewEncodeForm(limits Limits) EncodeForm {
 		Accept: strings.Join(
 			inputExtensions(
 				fileformat.WAV(),
+				fileformat.AIFF(),
 				fileformat.MP3(),
 				fileformat.FLAC(),
 			),
 			", "),
 		OutFormats: outputExtensions(
 			fileformat.WAV(),
+			fileformat.AIFF(),
 			fileformat.MP3(),
+			fileformat.FLAC(),
 		),
-		WAV: WAV,
-		MP3: MP3,
+		WAV:       WAV,
+		AIFF:      AIFF,
+		MP3:       MP3,
+		FLAC:      FLAC,
+		Resample:  Resample,
+		Channels:  Channels,
+		Normalize: Normalize,
+		Gain:      Gain,
+		Fade:      Fade,
+		Silence:   Silence,
+		Tags:      Tags,
 	})
 	if err != nil {
 		panic(fmt.Sprintf("failed to parse encode template: %v", err))
@@ -90,7 +115,7 @@ func (f EncodeForm) Parse(r *http.Request) (encode.FormData, error) {
 	if maxSize > 0 {
 		// check if limit is defined
 		if maxSize > 0 {
-			r.Body = http.MaxBytesReader(nil, r.Body, maxSize)
+			r.Body = http.MaxBytesReader(nil, r.Body, maxSize+Tags.MaxCoverBytes)
 		}
 	}
 	// check max size
@@ -104,7 +129,15 @@ func (f EncodeForm) Parse(r *http.Request) (encode.FormData, error) {
 	}
 
 	// parse sink and validate parameters
-	sink, outputFormat, err := parseOutput(r.MultipartForm.Value)
+	output, err := parseOutput(r.MultipartForm.Value)
+	if err != nil {
+		return encode.FormData{}, err
+	}
+	processing, err := parseProcessing(r.MultipartForm.Value)
+	if err != nil {
+		return encode.FormData{}, err
+	}
+	tags, err := parseTags(r.MultipartForm)
 	if err != nil {
 		return encode.FormData{}, err
 	}
@@ -114,10 +147,9 @@ func (f EncodeForm) Parse(r *http.Request) (encode.FormData, error) {
 			Format: inputFormat,
 			File:   file,
 		},
-		Output: encode.Output{
-			Format: outputFormat,
-			Sink:   sink,
-		},
+		Output:     output,
+		Processing: processing,
+		Tags:       tags,
 	}, nil
 }
 
@@ -153,24 +185,36 @@ func (f EncodeForm) inputMaxSize(format *fileformat.Format) int64 {
 	return f.limits[format]
 }
 
-// ParseForm provided via form.
-// This function should return extensions, sinkbuilder
-func parseOutput(formData url.Values) (Sink, *fileformat.Format, error) {
+// parseOutput provided via form. Returns output with validated sink.
+func parseOutput(formData url.Values) (encode.Output, error) {
 	formatString := strings.ToLower(formData.Get("format"))
 	format := fileformat.FormatByPath(formatString)
 	var (
-		sink Sink
-		err  error
+		sink   Sink
+		stream Stream
+		err    error
 	)
 	switch format {
 	case fileformat.WAV():
 		sink, err = parseWAVSink(formData)
+	case fileformat.AIFF():
+		sink, err = parseAIFFSink(formData)
 	case fileformat.MP3():
-		sink, err = parseMP3Sink(formData)
+		stream, err = parseMP3Stream(formData)
+		sink = stream.Sink()
+	case fileformat.FLAC():
+		sink, err = parseFLACSink(formData)
 	default:
-		return nil, nil, fmt.Errorf("Unsupported format: %v", formatString)
+		return encode.Output{}, fmt.Errorf("Unsupported format: %v", formatString)
+	}
+	if err != nil {
+		return encode.Output{}, err
 	}
-	return sink, format, err
+	return encode.Output{
+		Format: format,
+		Sink:   sink,
+		Stream: stream,
+	}, nil
 }
 
 func parseWAVSink(data url.Values) (Sink, error) {
@@ -182,7 +226,16 @@ func parseWAVSink(data url.Values) (Sink, error) {
 	return WAV.Sink(bitDepth)
 }
 
-func parseMP3Sink(data url.Values) (Sink, error) {
+func parseAIFFSink(data url.Values) (Sink, error) {
+	// try to get bit depth
+	bitDepth, err := parseIntValue(data, "aiff-bit-depth", "bit depth")
+	if err != nil {
+		return nil, err
+	}
+	return AIFF.Sink(bitDepth)
+}
+
+func parseMP3Stream(data url.Values) (Stream, error) {
 	// try to get channel mode
 	channelMode, err := parseIntValue(data, "mp3-channel-mode", "channel mode")
 	if err != nil {
@@ -222,7 +275,53 @@ func parseMP3Sink(data url.Values) (Sink, error) {
 		}
 	}
 
-	return MP3.Sink(bitRateMode, bitRate, channelMode, useQuality, quality)
+	return MP3.Stream(bitRateMode, bitRate, channelMode, useQuality, quality)
+}
+
+func parseFLACSink(data url.Values) (Sink, error) {
+	// try to get bit depth
+	bitDepth, err := parseIntValue(data, "flac-bit-depth", "bit depth")
+	if err != nil {
+		return nil, err
+	}
+	// try to get compression level
+	compressionLevel, err := parseIntValue(data, "flac-compression-level", "compression level")
+	if err != nil {
+		return nil, err
+	}
+	return FLAC.Sink(bitDepth, compressionLevel)
+}
+
+// parseTags provided via form. Empty fields keep tags of the input.
+func parseTags(form *multipart.Form) (tag.Edit, error) {
+	data := url.Values(form.Value)
+	strip, err := parseBoolValue(data, "strip-tags", "strip tags")
+	if err != nil {
+		return tag.Edit{}, err
+	}
+	values := make(map[string]string)
+	for _, key := range Tags.FormKeys {
+		if v := data.Get("tag-" + key); v != "" {
+			values[key] = v
+		}
+	}
+	edit, err := Tags.Edit(values, strip)
+	if err != nil {
+		return tag.Edit{}, err
+	}
+	if data.Get("cover-max-size") != "" {
+		size, err := parseIntValue(data, "cover-max-size", "cover max size")
+		if err != nil {
+			return tag.Edit{}, err
+		}
+		if edit.MaxCoverSize, err = Tags.CoverSize(size); err != nil {
+			return tag.Edit{}, err
+		}
+	}
+	if edit.Cover, err = multipartCover(form, FormCoverKey, edit.MaxCoverSize); err != nil {
+		return tag.Edit{}, err
+	}
+	return edit, nil
 }
 
 // parseIntValue parses value of key provided in the html form. Returns
@@ -240,6 +339,21 @@ func parseIntValue(data url.Values, key, name string) (int, error) {
 	return val, nil
 }
 
+// parseFloatValue parses value of key provided in the html form. Returns
+// error if value is not provided or cannot be parsed as float.
+func parseFloatValue(data url.Values, key, name string) (float64, error) {
+	str := data.Get(key)
+	if str == "" {
+		return 0, fmt.Errorf("%s not provided", name)
+	}
+
+	val, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Failed parsing %s %s: %v", name, str, err)
+	}
+	return val, nil
+}
+
 // parseBoolValue parses value of key provided in the html form. Returns
 // false if value is not provided. Returns error when cannot be parsed as
 // bool.
@@ -303,6 +417,10 @@ const encodeHTML = `
         .option {
             margin-right: 7px;
         }
+        .processing {
+            margin-bottom: 20px;
+            display: none;
+        }
         .footer{
             position: fixed;
             padding-top: 15px;
@@ -372,6 +490,7 @@ const encodeHTML = `
             displayClass('output-options', 'none');
             // need to cut the dot
         	displayId(this.value.slice(1)+'-options', 'inline');
+        	displayClass('processing', 'block');
         	displayClass('submit', 'block');
         }
         function onMp3BitRateModeChange(){
@@ -433,6 +552,15 @@ const encodeHTML = `
                     {{end}}
                 </select>
             </div>
+            <div id="aiff-options" class="output-options">
+                bit depth
+                <select name="aiff-bit-depth" class="option">
+                    <option hidden disabled selected value>select</option>
+                    {{range $key, $value := .AIFF.BitDepths}}
+                        <option value="{{ printf "%d" $key }}">{{ $key }}</option>
+                    {{end}}
+                </select>
+            </div>
             <div id="mp3-options" class="output-options">
                 channel mode
                 <select name="mp3-channel-mode" class="option">
@@ -464,6 +592,92 @@ const encodeHTML = `
                     </div>
                 </div>
             </div>
+            <div id="flac-options" class="output-options">
+                bit depth
+                <select name="flac-bit-depth" class="option">
+                    <option hidden disabled selected value>select</option>
+                    {{range $key, $value := .FLAC.BitDepths}}
+                        <option value="{{ printf "%d" $key }}">{{ $key }}</option>
+                    {{end}}
+                </select>
+                compression level [{{ .FLAC.MinCompressionLevel }}-{{ .FLAC.MaxCompressionLevel }}]
+                <input type="text" class="option" name="flac-compression-level" maxlength="1" size="3">
+            </div>
+        </div>
+        <div class="processing">
+            <div class="option">
+                start
+                <input type="text" class="option" name="start" size="12" placeholder="00:00:00.000">
+                end
+                <input type="text" class="option" name="end" size="12">
+                duration
+                <input type="text" class="option" name="duration" size="12">
+            </div>
+            <div class="option">
+                trim silence
+                <input type="checkbox" class="option" name="trim-silence" value="true">
+                threshold, dBFS [{{ .Silence.MinThreshold }}-{{ .Silence.MaxThreshold }}]
+                <input type="text" class="option" name="silence-threshold" maxlength="6" size="6" value="{{ .Silence.DefaultThreshold }}">
+                min duration
+                <input type="text" class="option" name="silence-duration" size="12" value="{{ .Silence.DefaultMinDuration }}">
+                padding
+                <input type="text" class="option" name="silence-padding" size="12" value="{{ .Silence.DefaultPadding }}">
+            </div>
+            <div class="option">
+                fade-in
+                <input type="text" class="option" name="fade-in" size="12">
+                fade-out
+                <input type="text" class="option" name="fade-out" size="12">
+                curve
+                <select name="fade-curve" class="option">
+                    {{range $key, $value := .Fade.Curves}}
+                        <option value="{{ $key }}" {{ if eq $key $.Fade.DefaultCurve }}selected{{ end }}>{{ $key }}</option>
+                    {{end}}
+                </select>
+            </div>
+            <div class="option">
+                sample rate [{{ .Resample.MinSampleRate }}-{{ .Resample.MaxSampleRate }}]
+                <input type="text" class="option" name="sample-rate" maxlength="6" size="6">
+                quality
+                <select name="resample-quality" class="option">
+                    {{range $key, $value := .Resample.Qualities}}
+                        <option value="{{ $key }}" {{ if eq $key $.Resample.DefaultQuality }}selected{{ end }}>{{ $key }}</option>
+                    {{end}}
+                </select>
+            </div>
+            <div class="option">
+                channels [{{ .Channels.MinChannels }}-{{ .Channels.MaxChannels }}]
+                <input type="text" class="option" name="channels" maxlength="1" size="3">
+                channel map
+                <input type="text" class="option" name="channel-map" size="16" placeholder="1,0">
+            </div>
+            <div class="option">
+                normalize loudness, LUFS [{{ .Normalize.MinTarget }}-{{ .Normalize.MaxTarget }}]
+                <input type="text" class="option" name="normalize" maxlength="6" size="6" placeholder="-16">
+                true peak, dBTP [{{ .Normalize.MinCeiling }}-{{ .Normalize.MaxCeiling }}]
+                <input type="text" class="option" name="true-peak" maxlength="6" size="6" value="{{ .Normalize.DefaultCeiling }}">
+            </div>
+            <div class="option">
+                gain, dB [{{ .Gain.MinGain }}-{{ .Gain.MaxGain }}]
+                <input type="text" class="option" name="gain" maxlength="6" size="6" placeholder="-3">
+                peak normalize, dBFS [{{ .Gain.MinPeak }}-{{ .Gain.MaxPeak }}]
+                <input type="text" class="option" name="peak-normalize" maxlength="6" size="6" placeholder="-1">
+            </div>
+            <div class="option">
+                tags
+                {{range $key := .Tags.FormKeys}}
+                    {{ $key }}
+                    <input type="text" class="option" name="tag-{{ $key }}" size="12" maxlength="{{ $.Tags.MaxLength }}">
+                {{end}}
+                strip input tags
+                <input type="checkbox" class="option" name="strip-tags" value="true">
+            </div>
+            <div class="option">
+                cover image, jpeg or png
+                <input type="file" class="option" name="form-cover" accept="image/jpeg,image/png">
+                cover max size, px [{{ .Tags.MinCoverSize }}-{{ .Tags.MaxCoverSize }}]
+                <input type="text" class="option" name="cover-max-size" maxlength="4" size="4" placeholder="1000">
+            </div>
         </div>
         </form>
         <div class="submit" style="display:none">
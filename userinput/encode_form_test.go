@@ -11,8 +11,8 @@ import (
 	"testing"
 
 	"golang.org/x/net/html"
-	"pipelined.dev/audio/fileformat"
 
+	"pipelined.dev/phono/fileformat"
 	"pipelined.dev/phono/userinput"
 )
 
@@ -113,6 +113,106 @@ func TestFormParsing(t *testing.T) {
 			}),
 		),
 	)
+	t.Run("ok flac",
+		testOk(userinput.NewEncodeForm(noLimits),
+			newWavRequest(map[string]string{
+				"format":                 ".flac",
+				"flac-bit-depth":         "16",
+				"flac-compression-level": "5",
+			}),
+		),
+	)
+	t.Run("ok aiff",
+		testOk(userinput.NewEncodeForm(noLimits),
+			newWavRequest(map[string]string{
+				"format":         ".aiff",
+				"aiff-bit-depth": "24",
+			}),
+		),
+	)
+	t.Run("ok wav resample",
+		testOk(userinput.NewEncodeForm(noLimits),
+			newWavRequest(map[string]string{
+				"format":           ".wav",
+				"wav-bit-depth":    "16",
+				"sample-rate":      "48000",
+				"resample-quality": "medium",
+			}),
+		),
+	)
+	t.Run("ok wav channels",
+		testOk(userinput.NewEncodeForm(noLimits),
+			newWavRequest(map[string]string{
+				"format":        ".wav",
+				"wav-bit-depth": "16",
+				"channels":      "1",
+			}),
+		),
+	)
+	t.Run("ok wav channel map",
+		testOk(userinput.NewEncodeForm(noLimits),
+			newWavRequest(map[string]string{
+				"format":        ".wav",
+				"wav-bit-depth": "16",
+				"channel-map":   "1,0",
+			}),
+		),
+	)
+	t.Run("ok wav normalize",
+		testOk(userinput.NewEncodeForm(noLimits),
+			newWavRequest(map[string]string{
+				"format":        ".wav",
+				"wav-bit-depth": "16",
+				"normalize":     "-16",
+				"true-peak":     "-1.5",
+			}),
+		),
+	)
+	t.Run("ok wav gain",
+		testOk(userinput.NewEncodeForm(noLimits),
+			newWavRequest(map[string]string{
+				"format":         ".wav",
+				"wav-bit-depth":  "16",
+				"gain":           "-3dB",
+				"peak-normalize": "-1dBFS",
+			}),
+		),
+	)
+	t.Run("ok wav trim",
+		testOk(userinput.NewEncodeForm(noLimits),
+			newWavRequest(map[string]string{
+				"format":        ".wav",
+				"wav-bit-depth": "16",
+				"start":         "00:00:01.500",
+				"duration":      "22050smp",
+			}),
+		),
+	)
+	t.Run("ok wav fade",
+		testOk(userinput.NewEncodeForm(noLimits),
+			newWavRequest(map[string]string{
+				"format":        ".wav",
+				"wav-bit-depth": "16",
+				"start":         "1",
+				"end":           "3",
+				"fade-in":       "0.5",
+				"fade-out":      "0.5",
+				"fade-curve":    "equal-power",
+			}),
+		),
+	)
+	t.Run("ok wav trim silence",
+		testOk(userinput.NewEncodeForm(noLimits),
+			newWavRequest(map[string]string{
+				"format":            ".wav",
+				"wav-bit-depth":     "16",
+				"trim-silence":      "true",
+				"silence-threshold": "-50",
+				"silence-duration":  "1",
+				"silence-padding":   "0.2",
+			}),
+		),
+	)
 	t.Run("fail size exceeded",
 		testFail(userinput.NewEncodeForm(userinput.Limits{fileformat.WAV(): 10}),
 			newWavRequest(nil),
@@ -142,6 +242,113 @@ func TestFormParsing(t *testing.T) {
 				"wav-bit-depth": "",
 			})),
 	)
+	t.Run("fail aiff invalid bit depth",
+		testFail(userinput.NewEncodeForm(userinput.Limits{}),
+			newWavRequest(map[string]string{
+				"format":         ".aiff",
+				"aiff-bit-depth": "12",
+			})),
+	)
+	t.Run("fail invalid sample rate",
+		testFail(userinput.NewEncodeForm(userinput.Limits{}),
+			newWavRequest(map[string]string{
+				"format":        ".wav",
+				"wav-bit-depth": "16",
+				"sample-rate":   "1000",
+			})),
+	)
+	t.Run("fail invalid resample quality",
+		testFail(userinput.NewEncodeForm(userinput.Limits{}),
+			newWavRequest(map[string]string{
+				"format":           ".wav",
+				"wav-bit-depth":    "16",
+				"sample-rate":      "48000",
+				"resample-quality": "best",
+			})),
+	)
+	t.Run("fail invalid channels",
+		testFail(userinput.NewEncodeForm(userinput.Limits{}),
+			newWavRequest(map[string]string{
+				"format":        ".wav",
+				"wav-bit-depth": "16",
+				"channels":      "0",
+			})),
+	)
+	t.Run("fail invalid channel map",
+		testFail(userinput.NewEncodeForm(userinput.Limits{}),
+			newWavRequest(map[string]string{
+				"format":        ".wav",
+				"wav-bit-depth": "16",
+				"channel-map":   "1,x",
+			})),
+	)
+	t.Run("fail invalid normalize",
+		testFail(userinput.NewEncodeForm(userinput.Limits{}),
+			newWavRequest(map[string]string{
+				"format":        ".wav",
+				"wav-bit-depth": "16",
+				"normalize":     "loud",
+			})),
+	)
+	t.Run("fail invalid true peak",
+		testFail(userinput.NewEncodeForm(userinput.Limits{}),
+			newWavRequest(map[string]string{
+				"format":        ".wav",
+				"wav-bit-depth": "16",
+				"normalize":     "-16",
+				"true-peak":     "3",
+			})),
+	)
+	t.Run("fail invalid gain",
+		testFail(userinput.NewEncodeForm(userinput.Limits{}),
+			newWavRequest(map[string]string{
+				"format":        ".wav",
+				"wav-bit-depth": "16",
+				"gain":          "loud",
+			})),
+	)
+	t.Run("fail invalid peak",
+		testFail(userinput.NewEncodeForm(userinput.Limits{}),
+			newWavRequest(map[string]string{
+				"format":         ".wav",
+				"wav-bit-depth":  "16",
+				"peak-normalize": "3dBFS",
+			})),
+	)
+	t.Run("fail invalid trim",
+		testFail(userinput.NewEncodeForm(userinput.Limits{}),
+			newWavRequest(map[string]string{
+				"format":        ".wav",
+				"wav-bit-depth": "16",
+				"start":         "5",
+				"end":           "1",
+			})),
+	)
+	t.Run("fail invalid fade curve",
+		testFail(userinput.NewEncodeForm(userinput.Limits{}),
+			newWavRequest(map[string]string{
+				"format":        ".wav",
+				"wav-bit-depth": "16",
+				"fade-in":       "1",
+				"fade-curve":    "cubic",
+			})),
+	)
+	t.Run("fail invalid silence threshold",
+		testFail(userinput.NewEncodeForm(userinput.Limits{}),
+			newWavRequest(map[string]string{
+				"format":            ".wav",
+				"wav-bit-depth":     "16",
+				"trim-silence":      "true",
+				"silence-threshold": "10",
+			})),
+	)
+	t.Run("fail flac missing compression level",
+		testFail(userinput.NewEncodeForm(userinput.Limits{}),
+			newWavRequest(map[string]string{
+				"format":         ".flac",
+				"flac-bit-depth": "16",
+			})),
+	)
 	t.Run("fail mp3 invalid channel mode",
 		testFail(userinput.NewEncodeForm(userinput.Limits{}),
 			newWavRequest(map[string]string{
@@ -202,6 +409,25 @@ func TestFormParsing(t *testing.T) {
 			}),
 		),
 	)
+	t.Run("ok tags",
+		testOk(userinput.NewEncodeForm(userinput.Limits{}),
+			newWavRequest(map[string]string{
+				"format":        ".wav",
+				"wav-bit-depth": "16",
+				"tag-title":     "Title",
+				"strip-tags":    "true",
+			}),
+		),
+	)
+	t.Run("fail tags invalid strip flag",
+		testFail(userinput.NewEncodeForm(userinput.Limits{}),
+			newWavRequest(map[string]string{
+				"format":        ".wav",
+				"wav-bit-depth": "16",
+				"strip-tags":    "yes",
+			}),
+		),
+	)
 }
 
 func TestForm(t *testing.T) {
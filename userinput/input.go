@@ -9,6 +9,9 @@ import (
 	"pipelined.dev/audio/wav"
 	"pipelined.dev/pipe"
 	"pipelined.dev/signal"
+
+	"pipelined.dev/phono/aiff"
+	"pipelined.dev/phono/flac"
 )
 
 type (
@@ -16,6 +19,10 @@ type (
 		BitDepths map[signal.BitDepth]struct{}
 	}
 
+	aiffSink struct {
+		BitDepths map[signal.BitDepth]struct{}
+	}
+
 	mp3Sink struct {
 		ChannelModes map[mp3.ChannelMode]struct{}
 		VBR          string
@@ -29,8 +36,17 @@ type (
 		MaxVBR       int
 	}
 
+	flacSink struct {
+		BitDepths           map[signal.BitDepth]struct{}
+		MinCompressionLevel int
+		MaxCompressionLevel int
+	}
+
 	// Sink is used to inject WriteSeeker into Sink.
 	Sink func(io.WriteSeeker) pipe.SinkAllocatorFunc
+
+	// Stream is used to inject Writer into Sink that doesn't need to seek.
+	Stream func(io.Writer) pipe.SinkAllocatorFunc
 )
 
 var (
@@ -44,6 +60,16 @@ var (
 		},
 	}
 
+	// AIFF provides structures required to handle aiff files.
+	AIFF = aiffSink{
+		BitDepths: map[signal.BitDepth]struct{}{
+			signal.BitDepth8:  {},
+			signal.BitDepth16: {},
+			signal.BitDepth24: {},
+			signal.BitDepth32: {},
+		},
+	}
+
 	// MP3 provides structures required to handle mp3 files.
 	MP3 = mp3Sink{
 		ChannelModes: map[mp3.ChannelMode]struct{}{
@@ -61,6 +87,17 @@ var (
 		MinVBR:     0,
 		MaxVBR:     9,
 	}
+
+	// FLAC provides structures required to handle flac files.
+	FLAC = flacSink{
+		BitDepths: map[signal.BitDepth]struct{}{
+			signal.BitDepth8:  {},
+			signal.BitDepth16: {},
+			signal.BitDepth24: {},
+		},
+		MinCompressionLevel: int(flac.MinCompressionLevel),
+		MaxCompressionLevel: int(flac.MaxCompressionLevel),
+	}
 )
 
 // WAVSink validates all parameters required to build wav sink. If valid, build closure is returned.
@@ -76,9 +113,33 @@ func (f wavSink) Sink(bitDepth int) (Sink, error) {
 	}, nil
 }
 
+// Sink validates all parameters required to build aiff sink. If valid, Sink closure is returned.
+// Closure allows to postpone io opertaions and do them only after all sink parameters are validated.
+func (f aiffSink) Sink(bitDepth int) (Sink, error) {
+	bd := signal.BitDepth(bitDepth)
+	if _, ok := f.BitDepths[bd]; !ok {
+		return nil, fmt.Errorf("Bit depth %v is not supported", bitDepth)
+	}
+
+	return func(ws io.WriteSeeker) pipe.SinkAllocatorFunc {
+		return aiff.Sink(ws, bd)
+	}, nil
+}
+
 // Sink validates all parameters required to build mp3 sink. If valid, Sink closure is returned.
 // Closure allows to postpone io opertaions and do them only after all sink parameters are validated.
 func (f mp3Sink) Sink(bitRateMode string, bitRate, channelMode int, useQuality bool, quality int) (Sink, error) {
+	stream, err := f.Stream(bitRateMode, bitRate, channelMode, useQuality, quality)
+	if err != nil {
+		return nil, err
+	}
+	return stream.Sink(), nil
+}
+
+// Stream validates all parameters required to build mp3 sink. If valid,
+// Stream closure is returned. Mp3 sink doesn't need to seek, so it can
+// write directly into the network connection.
+func (f mp3Sink) Stream(bitRateMode string, bitRate, channelMode int, useQuality bool, quality int) (Stream, error) {
 	cm := mp3.ChannelMode(channelMode)
 	if _, ok := f.ChannelModes[cm]; !ok {
 		return nil, fmt.Errorf("Channel mode %v is not supported", cm)
@@ -111,12 +172,28 @@ func (f mp3Sink) Sink(bitRateMode string, bitRate, channelMode int, useQuality b
 		}
 	}
 
-	return func(ws io.WriteSeeker) pipe.SinkAllocatorFunc {
+	return func(w io.Writer) pipe.SinkAllocatorFunc {
 		eq := mp3.DefaultEncodingQuality
 		if useQuality {
 			eq = mp3.EncodingQuality(quality)
 		}
-		return mp3.Sink(ws, brm, cm, eq)
+		return mp3.Sink(w, brm, cm, eq)
+	}, nil
+}
+
+// Sink validates all parameters required to build flac sink. If valid, Sink closure is returned.
+// Closure allows to postpone io opertaions and do them only after all sink parameters are validated.
+func (f flacSink) Sink(bitDepth, compressionLevel int) (Sink, error) {
+	bd := signal.BitDepth(bitDepth)
+	if _, ok := f.BitDepths[bd]; !ok {
+		return nil, fmt.Errorf("Bit depth %v is not supported", bitDepth)
+	}
+	if compressionLevel < f.MinCompressionLevel || compressionLevel > f.MaxCompressionLevel {
+		return nil, fmt.Errorf("Compression level %v is not supported. Provide value between %d and %d", compressionLevel, f.MinCompressionLevel, f.MaxCompressionLevel)
+	}
+
+	return func(ws io.WriteSeeker) pipe.SinkAllocatorFunc {
+		return flac.Sink(ws, bd, flac.CompressionLevel(compressionLevel))
 	}, nil
 }
 
@@ -127,3 +204,10 @@ func (f mp3Sink) bitRate(v int) error {
 	}
 	return nil
 }
+
+// Sink returns Sink closure that uses the stream.
+func (s Stream) Sink() Sink {
+	return func(ws io.WriteSeeker) pipe.SinkAllocatorFunc {
+		return s(ws)
+	}
+}
@@ -1,10 +1,16 @@
 package userinput_test
 
 import (
+	"bytes"
+	"image"
+	"image/png"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
+	"pipelined.dev/phono/process"
+	"pipelined.dev/phono/tag"
 	"pipelined.dev/phono/userinput"
 )
 
@@ -35,6 +41,77 @@ func TestBuildWav(t *testing.T) {
 	}
 }
 
+func TestBuildAIFF(t *testing.T) {
+	var tests = []struct {
+		bitDepth int
+		negative bool
+	}{
+		{
+			bitDepth: 24,
+		},
+		{
+			bitDepth: 12,
+			negative: true,
+		},
+	}
+	for _, test := range tests {
+		sinkFn, err := userinput.AIFF.Sink(test.bitDepth)
+		if test.negative {
+			assert.NotNil(t, err)
+			assert.Nil(t, sinkFn)
+		} else {
+			assert.Nil(t, err)
+			assert.NotNil(t, sinkFn)
+			sink := sinkFn(nil)
+			assert.NotNil(t, sink)
+		}
+	}
+}
+
+func TestBuildFlac(t *testing.T) {
+	var tests = []struct {
+		bitDepth         int
+		compressionLevel int
+		negative         bool
+	}{
+		{
+			bitDepth:         16,
+			compressionLevel: 5,
+		},
+		{
+			bitDepth:         24,
+			compressionLevel: 0,
+		},
+		{
+			bitDepth:         32,
+			compressionLevel: 5,
+			negative:         true,
+		},
+		{
+			bitDepth:         16,
+			compressionLevel: 9,
+			negative:         true,
+		},
+		{
+			bitDepth:         16,
+			compressionLevel: -1,
+			negative:         true,
+		},
+	}
+	for _, test := range tests {
+		sinkFn, err := userinput.FLAC.Sink(test.bitDepth, test.compressionLevel)
+		if test.negative {
+			assert.NotNil(t, err)
+			assert.Nil(t, sinkFn)
+		} else {
+			assert.Nil(t, err)
+			assert.NotNil(t, sinkFn)
+			sink := sinkFn(nil)
+			assert.NotNil(t, sink)
+		}
+	}
+}
+
 func TestBuildMp3(t *testing.T) {
 	var tests = []struct {
 		bitRateMode string
@@ -126,3 +203,723 @@ func TestBuildMp3(t *testing.T) {
 		}
 	}
 }
+
+func TestBuildResample(t *testing.T) {
+	var tests = []struct {
+		sampleRate int
+		quality    string
+		negative   bool
+	}{
+		{
+			sampleRate: 48000,
+		},
+		{
+			sampleRate: 22050,
+			quality:    "low",
+		},
+		{
+			sampleRate: 1000,
+			negative:   true,
+		},
+		{
+			sampleRate: 48000,
+			quality:    "best",
+			negative:   true,
+		},
+	}
+	for _, test := range tests {
+		stage, err := userinput.Resample.Source(test.sampleRate, test.quality)
+		if test.negative {
+			assert.NotNil(t, err)
+			assert.Nil(t, stage)
+		} else {
+			assert.Nil(t, err)
+			assert.NotNil(t, stage)
+		}
+	}
+}
+
+func TestBuildResampleQuality(t *testing.T) {
+	q, err := userinput.Resample.Quality("")
+	assert.Nil(t, err)
+	assert.Equal(t, userinput.Resample.DefaultQuality, q)
+	q, err = userinput.Resample.Quality("low")
+	assert.Nil(t, err)
+	assert.Equal(t, process.ResampleLow, q)
+	_, err = userinput.Resample.Quality("hgih")
+	assert.NotNil(t, err)
+}
+
+func TestBuildChannels(t *testing.T) {
+	var tests = []struct {
+		channels   int
+		channelMap string
+		negative   bool
+	}{
+		{
+			channels: 1,
+		},
+		{
+			channelMap: "1,0",
+		},
+		{
+			channels:   1,
+			channelMap: "0.5*0+0.5*1",
+		},
+		{
+			negative: true,
+		},
+		{
+			channels: 9,
+			negative: true,
+		},
+		{
+			channels:   1,
+			channelMap: "1,0",
+			negative:   true,
+		},
+		{
+			channelMap: "0,-1",
+			negative:   true,
+		},
+	}
+	for _, test := range tests {
+		processor, err := userinput.Channels.Processor(test.channels, test.channelMap)
+		if test.negative {
+			assert.NotNil(t, err)
+			assert.Nil(t, processor)
+		} else {
+			assert.Nil(t, err)
+			assert.NotNil(t, processor)
+		}
+	}
+}
+
+func TestBuildNormalize(t *testing.T) {
+	var tests = []struct {
+		target   float64
+		ceiling  float64
+		negative bool
+	}{
+		{
+			target:  -16,
+			ceiling: -1,
+		},
+		{
+			target:  -23,
+			ceiling: 0,
+		},
+		{
+			target:   -80,
+			ceiling:  -1,
+			negative: true,
+		},
+		{
+			target:   0,
+			ceiling:  -1,
+			negative: true,
+		},
+		{
+			target:   -16,
+			ceiling:  1,
+			negative: true,
+		},
+	}
+	for _, test := range tests {
+		analyzer, err := userinput.Normalize.Analyzer(test.target, test.ceiling)
+		if test.negative {
+			assert.NotNil(t, err)
+			assert.Nil(t, analyzer)
+		} else {
+			assert.Nil(t, err)
+			assert.NotNil(t, analyzer)
+		}
+	}
+}
+
+func TestBuildGain(t *testing.T) {
+	var tests = []struct {
+		gain     string
+		peak     string
+		negative bool
+	}{
+		{
+			gain: "-3dB",
+			peak: "-1dBFS",
+		},
+		{
+			gain: "6",
+			peak: "0",
+		},
+		{
+			gain: " 1.5 db ",
+			peak: "-0.1 DBFS",
+		},
+		{
+			gain:     "-3dBA",
+			peak:     "-1",
+			negative: true,
+		},
+		{
+			gain:     "100dB",
+			peak:     "-1",
+			negative: true,
+		},
+		{
+			gain:     "-3",
+			peak:     "1dBFS",
+			negative: true,
+		},
+	}
+	for _, test := range tests {
+		gain, err := userinput.ParseDecibels(test.gain)
+		if err == nil {
+			_, err = userinput.Gain.Processor(gain)
+		}
+		if err == nil {
+			var peak float64
+			if peak, err = userinput.ParseDecibels(test.peak); err == nil {
+				_, err = userinput.Gain.Analyzer(peak)
+			}
+		}
+		if test.negative {
+			assert.NotNil(t, err)
+		} else {
+			assert.Nil(t, err)
+		}
+	}
+}
+
+func TestBuildTrim(t *testing.T) {
+	var tests = []struct {
+		start    string
+		end      string
+		duration string
+		negative bool
+	}{
+		{
+			start: "1.5",
+		},
+		{
+			start: "00:01.000",
+			end:   "00:02.500",
+		},
+		{
+			start:    "44100smp",
+			duration: "1",
+		},
+		{
+			start:    "abc",
+			negative: true,
+		},
+		{
+			start:    "2",
+			end:      "1",
+			negative: true,
+		},
+		{
+			end:      "2",
+			duration: "1",
+			negative: true,
+		},
+	}
+	for _, test := range tests {
+		stage, err := userinput.Trim.Source(test.start, test.end, test.duration)
+		if test.negative {
+			assert.NotNil(t, err)
+			assert.Nil(t, stage)
+		} else {
+			assert.Nil(t, err)
+			assert.NotNil(t, stage)
+		}
+	}
+}
+
+func TestBuildFade(t *testing.T) {
+	var tests = []struct {
+		in       string
+		out      string
+		curve    string
+		negative bool
+	}{
+		{
+			in: "1.5",
+		},
+		{
+			out:   "22050smp",
+			curve: "equal-power",
+		},
+		{
+			in:    "00:00:01",
+			out:   "2",
+			curve: "log",
+		},
+		{
+			in:       "abc",
+			negative: true,
+		},
+		{
+			in:       "1",
+			curve:    "cubic",
+			negative: true,
+		},
+	}
+	for _, test := range tests {
+		stage, err := userinput.Fade.Source(test.in, test.out, test.curve)
+		if test.negative {
+			assert.NotNil(t, err)
+			assert.Nil(t, stage)
+		} else {
+			assert.Nil(t, err)
+			assert.NotNil(t, stage)
+		}
+	}
+}
+
+func TestBuildSilence(t *testing.T) {
+	var tests = []struct {
+		threshold   float64
+		minDuration string
+		padding     string
+		negative    bool
+	}{
+		{
+			threshold: -60,
+		},
+		{
+			threshold:   -40,
+			minDuration: "1",
+			padding:     "441smp",
+		},
+		{
+			threshold: 0,
+			negative:  true,
+		},
+		{
+			threshold:   -60,
+			minDuration: "abc",
+			negative:    true,
+		},
+		{
+			threshold: -60,
+			padding:   "-1",
+			negative:  true,
+		},
+	}
+	for _, test := range tests {
+		stage, err := userinput.Silence.Source(test.threshold, test.minDuration, test.padding)
+		if test.negative {
+			assert.NotNil(t, err)
+			assert.Nil(t, stage)
+		} else {
+			assert.Nil(t, err)
+			assert.NotNil(t, stage)
+		}
+	}
+}
+
+func TestBuildSplit(t *testing.T) {
+	t.Run("silence", func(t *testing.T) {
+		splitter, err := userinput.Silence.Splitter(-60, "2", "")
+		assert.Nil(t, err)
+		assert.NotNil(t, splitter)
+		splitter, err = userinput.Silence.Splitter(-60, "0", "")
+		assert.NotNil(t, err)
+		assert.Nil(t, splitter)
+	})
+	t.Run("chunks", func(t *testing.T) {
+		for length, negative := range map[string]bool{
+			"30":       false,
+			"01:00":    false,
+			"44100smp": false,
+			"0":        true,
+			"abc":      true,
+		} {
+			splitter, err := userinput.Split.Chunks(length)
+			if negative {
+				assert.NotNil(t, err, length)
+				assert.Nil(t, splitter)
+			} else {
+				assert.Nil(t, err, length)
+				assert.NotNil(t, splitter)
+			}
+		}
+	})
+	var tests = []struct {
+		name     string
+		cues     string
+		negative bool
+	}{
+		{
+			name: "cues.csv",
+			cues: "start,title\n0,Intro\n01:30.5,\"Second, part\"\n",
+		},
+		{
+			name: "cues.txt",
+			cues: "# comment\n0\n90\n441000smp\n",
+		},
+		{
+			name:     "cues.csv",
+			cues:     "0,Intro\nabc,Second\n",
+			negative: true,
+		},
+		{
+			name:     "cues.csv",
+			cues:     "start,title\n",
+			negative: true,
+		},
+		{
+			name: "album.cue",
+			cues: "TITLE \"Album\"\nFILE \"album.wav\" WAVE\n" +
+				"  TRACK 01 AUDIO\n    TITLE \"First\"\n    INDEX 01 00:00:00\n" +
+				"  TRACK 02 AUDIO\n    TITLE \"Second\"\n    INDEX 00 03:10:00\n    INDEX 01 03:12:74\n",
+		},
+		{
+			name:     "album.CUE",
+			cues:     "TRACK 01 AUDIO\n  INDEX 01 00:00:75\n",
+			negative: true,
+		},
+		{
+			name:     "album.cue",
+			cues:     "TRACK 01 AUDIO\n  INDEX 01 00:00:00\nTRACK 02 AUDIO\n  TITLE \"No index\"\n",
+			negative: true,
+		},
+		{
+			name:     "album.cue",
+			cues:     "TITLE \"Album\"\n",
+			negative: true,
+		},
+	}
+	for _, test := range tests {
+		splitter, err := userinput.Split.Cues(strings.NewReader(test.cues), test.name)
+		if test.negative {
+			assert.NotNil(t, err, test.cues)
+			assert.Nil(t, splitter)
+		} else {
+			assert.Nil(t, err, test.cues)
+			assert.NotNil(t, splitter)
+		}
+	}
+}
+
+func TestBuildConcat(t *testing.T) {
+	var tests = []struct {
+		gap       string
+		crossfade string
+		curve     string
+		quality   string
+		negative  bool
+	}{
+		{},
+		{
+			gap: "0.5",
+		},
+		{
+			crossfade: "2",
+			curve:     "log",
+			quality:   "low",
+		},
+		{
+			gap:       "1",
+			crossfade: "1",
+			negative:  true,
+		},
+		{
+			gap:      "abc",
+			negative: true,
+		},
+		{
+			crossfade: "1",
+			curve:     "fake",
+			negative:  true,
+		},
+		{
+			quality:  "fake",
+			negative: true,
+		},
+	}
+	for _, test := range tests {
+		_, err := userinput.Concat.Join(test.gap, test.crossfade, test.curve, true, test.quality)
+		if test.negative {
+			assert.NotNil(t, err)
+		} else {
+			assert.Nil(t, err)
+		}
+	}
+}
+
+func TestBuildMix(t *testing.T) {
+	var tests = []struct {
+		gains    []string
+		offsets  []string
+		negative bool
+	}{
+		{},
+		{
+			gains:   []string{"0", "-12dB"},
+			offsets: []string{"", "00:01.5"},
+		},
+		{
+			gains: []string{"-6"},
+		},
+		{
+			gains:    []string{"0", "0", "0"},
+			negative: true,
+		},
+		{
+			gains:    []string{"100"},
+			negative: true,
+		},
+		{
+			offsets:  []string{"abc"},
+			negative: true,
+		},
+	}
+	for _, test := range tests {
+		tracks, err := userinput.Mix.Tracks(2, test.gains, test.offsets)
+		if test.negative {
+			assert.NotNil(t, err)
+			assert.Nil(t, tracks)
+		} else {
+			assert.Nil(t, err)
+			assert.Equal(t, 2, len(tracks))
+		}
+	}
+}
+
+func TestBuildWaveform(t *testing.T) {
+	var tests = []struct {
+		format     string
+		bits       int
+		height     int
+		color      string
+		background string
+		extension  string
+		negative   bool
+	}{
+		{
+			format:    "json",
+			bits:      8,
+			height:    128,
+			color:     "#000000",
+			extension: ".json",
+		},
+		{
+			format:    "DAT",
+			bits:      16,
+			height:    128,
+			color:     "#000000",
+			extension: ".dat",
+		},
+		{
+			format:     "png",
+			bits:       8,
+			height:     64,
+			color:      "#3a7bd580",
+			background: "#ffffff",
+			extension:  ".png",
+		},
+		{
+			format:   "ogg",
+			bits:     8,
+			height:   128,
+			color:    "#000000",
+			negative: true,
+		},
+		{
+			format:   "json",
+			bits:     12,
+			height:   128,
+			color:    "#000000",
+			negative: true,
+		},
+		{
+			format:   "svg",
+			bits:     8,
+			height:   1,
+			color:    "#000000",
+			negative: true,
+		},
+		{
+			format:   "svg",
+			bits:     8,
+			height:   128,
+			color:    "black",
+			negative: true,
+		},
+		{
+			format:     "svg",
+			bits:       8,
+			height:     128,
+			color:      "#000000",
+			background: "#fff",
+			negative:   true,
+		},
+	}
+	for _, test := range tests {
+		format, err := userinput.Waveform.Format(test.format, test.bits, test.height, test.color, test.background)
+		if test.negative {
+			assert.NotNil(t, err)
+			assert.Nil(t, format.Encode)
+		} else {
+			assert.Nil(t, err)
+			assert.Equal(t, test.extension, format.Extension)
+		}
+	}
+	assert.Nil(t, userinput.Waveform.SamplesPerPixel(userinput.Waveform.DefaultSamplesPerPixel))
+	assert.NotNil(t, userinput.Waveform.SamplesPerPixel(0))
+}
+
+func TestBuildSpectrogram(t *testing.T) {
+	var analyzerTests = []struct {
+		windowSize int
+		overlap    float64
+		window     string
+		negative   bool
+	}{
+		{
+			windowSize: 2048,
+			overlap:    0.5,
+		},
+		{
+			windowSize: 4096,
+			overlap:    0.75,
+			window:     "Blackman",
+		},
+		{
+			windowSize: 1000,
+			negative:   true,
+		},
+		{
+			windowSize: 1 << 20,
+			negative:   true,
+		},
+		{
+			windowSize: 2048,
+			overlap:    1,
+			negative:   true,
+		},
+		{
+			windowSize: 2048,
+			window:     "kaiser",
+			negative:   true,
+		},
+	}
+	for _, test := range analyzerTests {
+		a, err := userinput.Spectrogram.Analyzer(test.windowSize, test.overlap, test.window)
+		if test.negative {
+			assert.NotNil(t, err)
+		} else {
+			assert.Nil(t, err)
+			assert.Equal(t, test.windowSize, a.Size)
+		}
+	}
+
+	var imageTests = []struct {
+		width    int
+		height   int
+		scale    string
+		rng      float64
+		negative bool
+	}{
+		{
+			width:  1024,
+			height: 512,
+			rng:    120,
+		},
+		{
+			width:  800,
+			height: 600,
+			scale:  "linear",
+			rng:    90,
+		},
+		{
+			width:    10,
+			height:   512,
+			rng:      120,
+			negative: true,
+		},
+		{
+			width:    1024,
+			height:   1 << 20,
+			rng:      120,
+			negative: true,
+		},
+		{
+			width:    1024,
+			height:   512,
+			scale:    "mel",
+			rng:      120,
+			negative: true,
+		},
+		{
+			width:    1024,
+			height:   512,
+			rng:      0,
+			negative: true,
+		},
+	}
+	for _, test := range imageTests {
+		_, err := userinput.Spectrogram.Image(test.width, test.height, test.scale, test.rng)
+		if test.negative {
+			assert.NotNil(t, err)
+		} else {
+			assert.Nil(t, err)
+		}
+	}
+}
+
+func TestBuildTags(t *testing.T) {
+	var tests = []struct {
+		values   map[string]string
+		strip    bool
+		expected tag.Edit
+		negative bool
+	}{
+		{
+			values:   map[string]string{"Title": "Title", "year": ""},
+			expected: tag.Edit{Set: tag.Tags{tag.Title: "Title", tag.Date: ""}},
+		},
+		{
+			strip:    true,
+			expected: tag.Edit{Strip: true},
+		},
+		{
+			values:   map[string]string{"a=b": "value"},
+			negative: true,
+		},
+		{
+			values:   map[string]string{tag.Title: strings.Repeat("a", userinput.Tags.MaxLength+1)},
+			negative: true,
+		},
+	}
+	for _, test := range tests {
+		edit, err := userinput.Tags.Edit(test.values, test.strip)
+		if test.negative {
+			assert.NotNil(t, err)
+		} else {
+			assert.Nil(t, err)
+			assert.Equal(t, test.expected, edit)
+		}
+	}
+
+	values, err := userinput.Tags.Pairs([]string{"title=a=b", "artist="})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"title": "a=b", "artist": ""}, values)
+	_, err = userinput.Tags.Pairs([]string{"title"})
+	assert.NotNil(t, err)
+
+	_, err = userinput.Tags.CoverSize(userinput.Tags.MinCoverSize - 1)
+	assert.NotNil(t, err)
+	size, err := userinput.Tags.CoverSize(500)
+	assert.Nil(t, err)
+	assert.Equal(t, 500, size)
+
+	var cover bytes.Buffer
+	assert.Nil(t, png.Encode(&cover, image.NewGray(image.Rect(0, 0, 64, 64))))
+	p, err := userinput.Tags.Cover(bytes.NewReader(cover.Bytes()), 32)
+	assert.Nil(t, err)
+	assert.Equal(t, "image/jpeg", p.MIME)
+	_, err = userinput.Tags.Cover(strings.NewReader("not an image"), 0)
+	assert.NotNil(t, err)
+}
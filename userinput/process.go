@@ -0,0 +1,599 @@
+package userinput
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/signal"
+
+	"pipelined.dev/phono/encode"
+	"pipelined.dev/phono/process"
+)
+
+type (
+	resampleStage struct {
+		Qualities      map[process.ResampleQuality]struct{}
+		DefaultQuality process.ResampleQuality
+		MinSampleRate  int
+		MaxSampleRate  int
+	}
+
+	channelsStage struct {
+		MinChannels int
+		MaxChannels int
+	}
+
+	normalizeStage struct {
+		MinTarget      float64
+		MaxTarget      float64
+		MinCeiling     float64
+		MaxCeiling     float64
+		DefaultCeiling float64
+	}
+
+	gainStage struct {
+		MinGain float64
+		MaxGain float64
+		MinPeak float64
+		MaxPeak float64
+	}
+
+	// trimStage has no limits, it only parses positions.
+	trimStage struct{}
+
+	silenceStage struct {
+		MinThreshold       float64
+		MaxThreshold       float64
+		DefaultThreshold   float64
+		DefaultMinDuration string
+		DefaultPadding     string
+	}
+
+	fadeStage struct {
+		Curves       map[process.Curve]struct{}
+		DefaultCurve process.Curve
+	}
+
+	concatStage struct {
+		Curves       map[process.Curve]struct{}
+		DefaultCurve process.Curve
+	}
+
+	mixStage struct {
+		MinGain float64
+		MaxGain float64
+	}
+
+	// SourceStage is used to wrap the source with processing.
+	SourceStage func(pipe.SourceAllocatorFunc, *encode.Report) pipe.SourceAllocatorFunc
+)
+
+// Resample provides structures required to handle sample rate conversion.
+var Resample = resampleStage{
+	Qualities: map[process.ResampleQuality]struct{}{
+		process.ResampleLow:    {},
+		process.ResampleMedium: {},
+		process.ResampleHigh:   {},
+	},
+	DefaultQuality: process.ResampleHigh,
+	MinSampleRate:  8000,
+	MaxSampleRate:  192000,
+}
+
+// Channels provides structures required to handle channel remapping.
+var Channels = channelsStage{
+	MinChannels: 1,
+	MaxChannels: 8,
+}
+
+// Normalize provides structures required to handle loudness
+// normalization.
+var Normalize = normalizeStage{
+	MinTarget:      -70,
+	MaxTarget:      -5,
+	MinCeiling:     -9,
+	MaxCeiling:     0,
+	DefaultCeiling: -1,
+}
+
+// Gain provides structures required to handle gain and peak
+// normalization.
+var Gain = gainStage{
+	MinGain: -60,
+	MaxGain: 60,
+	MinPeak: -60,
+	MaxPeak: 0,
+}
+
+// Trim provides structures required to handle trimming.
+var Trim trimStage
+
+// Silence provides structures required to handle silence trimming.
+var Silence = silenceStage{
+	MinThreshold:       -120,
+	MaxThreshold:       -20,
+	DefaultThreshold:   -60,
+	DefaultMinDuration: "0.5",
+	DefaultPadding:     "0.1",
+}
+
+// Fade provides structures required to handle fades.
+var Fade = fadeStage{
+	Curves: map[process.Curve]struct{}{
+		process.CurveLinear:      {},
+		process.CurveLogarithmic: {},
+		process.CurveEqualPower:  {},
+	},
+	DefaultCurve: process.CurveLinear,
+}
+
+// Concat provides structures required to handle concatenation.
+var Concat = concatStage{
+	Curves: map[process.Curve]struct{}{
+		process.CurveLinear:      {},
+		process.CurveLogarithmic: {},
+		process.CurveEqualPower:  {},
+	},
+	DefaultCurve: process.CurveEqualPower,
+}
+
+// Mix provides structures required to handle mixing.
+var Mix = mixStage{
+	MinGain: -60,
+	MaxGain: 60,
+}
+
+// Source validates all parameters required to resample the source. If
+// valid, SourceStage closure is returned. Default quality is used if
+// quality is empty.
+func (r resampleStage) Source(sampleRate int, quality string) (SourceStage, error) {
+	if sampleRate < r.MinSampleRate || sampleRate > r.MaxSampleRate {
+		return nil, fmt.Errorf("Sample rate %v is not supported. Provide value between %d and %d", sampleRate, r.MinSampleRate, r.MaxSampleRate)
+	}
+	q, err := r.Quality(quality)
+	if err != nil {
+		return nil, err
+	}
+	return func(source pipe.SourceAllocatorFunc, _ *encode.Report) pipe.SourceAllocatorFunc {
+		return process.Resample(source, signal.Frequency(sampleRate), q)
+	}, nil
+}
+
+// Quality validates sample rate conversion quality. Default quality is
+// returned if quality is empty.
+func (r resampleStage) Quality(quality string) (process.ResampleQuality, error) {
+	if quality == "" {
+		return r.DefaultQuality, nil
+	}
+	q := process.ResampleQuality(quality)
+	if _, ok := r.Qualities[q]; !ok {
+		return "", fmt.Errorf("Resample quality %v is not supported", quality)
+	}
+	return q, nil
+}
+
+// Source validates the range of the source. Positions are provided in
+// format accepted by process.ParsePosition. Empty values are not limited.
+// If valid, SourceStage closure is returned.
+func (t trimStage) Source(start, end, duration string) (SourceStage, error) {
+	var (
+		r   process.Range
+		err error
+	)
+	if start != "" {
+		if r.Start, err = process.ParsePosition(start); err != nil {
+			return nil, fmt.Errorf("Start %v is not valid: %v", start, err)
+		}
+	}
+	if end != "" {
+		if r.End, err = process.ParsePosition(end); err != nil {
+			return nil, fmt.Errorf("End %v is not valid: %v", end, err)
+		}
+	}
+	if duration != "" {
+		if r.Duration, err = process.ParsePosition(duration); err != nil {
+			return nil, fmt.Errorf("Duration %v is not valid: %v", duration, err)
+		}
+	}
+	if err := r.Validate(); err != nil {
+		return nil, fmt.Errorf("Range is not valid: %v", err)
+	}
+	return func(source pipe.SourceAllocatorFunc, _ *encode.Report) pipe.SourceAllocatorFunc {
+		return process.Trim(source, r)
+	}, nil
+}
+
+// Source validates all parameters required to trim silence. Threshold
+// is provided in dBFS, minimum duration and padding are provided in
+// format accepted by process.ParsePosition. Defaults are used for empty
+// values. If valid, SourceStage closure is returned, it adds trimmed
+// durations to the report.
+func (s silenceStage) Source(threshold float64, minDuration, padding string) (SourceStage, error) {
+	silence, err := s.silence(threshold, minDuration, padding)
+	if err != nil {
+		return nil, err
+	}
+	return func(source pipe.SourceAllocatorFunc, report *encode.Report) pipe.SourceAllocatorFunc {
+		return process.TrimSilence(source, silence, func(start, end time.Duration) {
+			report.Silence = &encode.TrimmedSilence{
+				Start: start,
+				End:   end,
+			}
+		})
+	}, nil
+}
+
+// Splitter validates all parameters required to split at silences. They
+// have the same format as for Source, but minimum duration can't be
+// zero. If valid, splitter constructor is returned.
+func (s silenceStage) Splitter(threshold float64, minDuration, padding string) (func() encode.Splitter, error) {
+	silence, err := s.silence(threshold, minDuration, padding)
+	if err != nil {
+		return nil, err
+	}
+	if silence.MinDuration.IsZero() {
+		return nil, fmt.Errorf("Minimum silence duration must be positive")
+	}
+	return func() encode.Splitter {
+		return process.NewSilenceSplitter(silence)
+	}, nil
+}
+
+func (s silenceStage) silence(threshold float64, minDuration, padding string) (process.Silence, error) {
+	if threshold < s.MinThreshold || threshold > s.MaxThreshold {
+		return process.Silence{}, fmt.Errorf("Silence threshold %v is not supported. Provide value between %v and %v", threshold, s.MinThreshold, s.MaxThreshold)
+	}
+	if minDuration == "" {
+		minDuration = s.DefaultMinDuration
+	}
+	if padding == "" {
+		padding = s.DefaultPadding
+	}
+	silence := process.Silence{Threshold: threshold}
+	var err error
+	if silence.MinDuration, err = process.ParsePosition(minDuration); err != nil {
+		return process.Silence{}, fmt.Errorf("Minimum silence duration %v is not valid: %v", minDuration, err)
+	}
+	if silence.Padding, err = process.ParsePosition(padding); err != nil {
+		return process.Silence{}, fmt.Errorf("Silence padding %v is not valid: %v", padding, err)
+	}
+	return silence, nil
+}
+
+// Source validates fade lengths and curve. Lengths are provided in format
+// accepted by process.ParsePosition, empty length disables the fade.
+// Default curve is used if curve is empty. If valid, SourceStage closure
+// is returned.
+func (f fadeStage) Source(in, out, curve string) (SourceStage, error) {
+	c := process.Curve(curve)
+	if curve == "" {
+		c = f.DefaultCurve
+	}
+	if _, ok := f.Curves[c]; !ok {
+		return nil, fmt.Errorf("Fade curve %v is not supported", curve)
+	}
+	fadeIn, fadeOut := process.Ramp{Curve: c}, process.Ramp{Curve: c}
+	var err error
+	if in != "" {
+		if fadeIn.Length, err = process.ParsePosition(in); err != nil {
+			return nil, fmt.Errorf("Fade-in %v is not valid: %v", in, err)
+		}
+	}
+	if out != "" {
+		if fadeOut.Length, err = process.ParsePosition(out); err != nil {
+			return nil, fmt.Errorf("Fade-out %v is not valid: %v", out, err)
+		}
+	}
+	return func(source pipe.SourceAllocatorFunc, _ *encode.Report) pipe.SourceAllocatorFunc {
+		return process.Fade(source, fadeIn, fadeOut)
+	}, nil
+}
+
+// Join validates all parameters required to concatenate sources. Gap
+// and crossfade are provided in format accepted by process.ParsePosition,
+// only one of them can be provided. Defaults are used for empty curve and
+// resample quality.
+func (c concatStage) Join(gap, crossfade, curve string, convert bool, quality string) (process.Join, error) {
+	j := process.Join{
+		Crossfade: process.Ramp{Curve: process.Curve(curve)},
+		Convert:   convert,
+	}
+	if curve == "" {
+		j.Crossfade.Curve = c.DefaultCurve
+	}
+	if _, ok := c.Curves[j.Crossfade.Curve]; !ok {
+		return process.Join{}, fmt.Errorf("Crossfade curve %v is not supported", curve)
+	}
+	var err error
+	if j.Quality, err = Resample.Quality(quality); err != nil {
+		return process.Join{}, err
+	}
+	if gap != "" {
+		if j.Gap, err = process.ParsePosition(gap); err != nil {
+			return process.Join{}, fmt.Errorf("Gap %v is not valid: %v", gap, err)
+		}
+	}
+	if crossfade != "" {
+		if j.Crossfade.Length, err = process.ParsePosition(crossfade); err != nil {
+			return process.Join{}, fmt.Errorf("Crossfade %v is not valid: %v", crossfade, err)
+		}
+	}
+	if !j.Gap.IsZero() && !j.Crossfade.Length.IsZero() {
+		return process.Join{}, fmt.Errorf("Only one of gap and crossfade can be provided")
+	}
+	return j, nil
+}
+
+// Tracks validates gains and offsets of mixed tracks. Values are provided
+// in order of tracks, missing and empty values are zero. Gain has format
+// accepted by ParseDecibels, offset has format accepted by
+// process.ParsePosition. If valid, tracks without sources are returned.
+func (m mixStage) Tracks(count int, gains, offsets []string) ([]process.Track, error) {
+	if len(gains) > count || len(offsets) > count {
+		return nil, fmt.Errorf("Number of gains and offsets must not exceed number of tracks %d", count)
+	}
+	tracks := make([]process.Track, count)
+	for i, gain := range gains {
+		if gain == "" {
+			continue
+		}
+		db, err := ParseDecibels(gain)
+		if err != nil {
+			return nil, err
+		}
+		if db < m.MinGain || db > m.MaxGain {
+			return nil, fmt.Errorf("Track gain %v is not supported. Provide value between %v and %v", db, m.MinGain, m.MaxGain)
+		}
+		tracks[i].Gain = db
+	}
+	for i, offset := range offsets {
+		if offset == "" {
+			continue
+		}
+		p, err := process.ParsePosition(offset)
+		if err != nil {
+			return nil, fmt.Errorf("Track offset %v is not valid: %v", offset, err)
+		}
+		tracks[i].Offset = p
+	}
+	return tracks, nil
+}
+
+// Processor validates all parameters required to remap channels. If
+// valid, processor is returned. Zero channels means that number of
+// channels is defined by the map. Default mapping is used if map is empty.
+func (c channelsStage) Processor(channels int, channelMap string) (pipe.ProcessorAllocatorFunc, error) {
+	if channels == 0 && channelMap == "" {
+		return nil, fmt.Errorf("Number of channels or channel map must be provided")
+	}
+	var m process.ChannelMap
+	if channelMap != "" {
+		var err error
+		if m, err = process.ParseChannelMap(channelMap); err != nil {
+			return nil, fmt.Errorf("Channel map %v is not valid: %v", channelMap, err)
+		}
+		if channels != 0 && channels != len(m) {
+			return nil, fmt.Errorf("Channel map %v defines %d channels, but %d requested", channelMap, len(m), channels)
+		}
+		channels = len(m)
+	}
+	if channels < c.MinChannels || channels > c.MaxChannels {
+		return nil, fmt.Errorf("Number of channels %v is not supported. Provide value between %d and %d", channels, c.MinChannels, c.MaxChannels)
+	}
+	return process.Channels(channels, m), nil
+}
+
+// Analyzer validates all parameters required to normalize loudness. If
+// valid, closure that creates normalizer is returned. Target loudness is
+// provided in LUFS and true peak ceiling in dBTP.
+func (n normalizeStage) Analyzer(target, ceiling float64) (func() encode.Analyzer, error) {
+	if target < n.MinTarget || target > n.MaxTarget {
+		return nil, fmt.Errorf("Target loudness %v is not supported. Provide value between %v and %v", target, n.MinTarget, n.MaxTarget)
+	}
+	if ceiling < n.MinCeiling || ceiling > n.MaxCeiling {
+		return nil, fmt.Errorf("True peak %v is not supported. Provide value between %v and %v", ceiling, n.MinCeiling, n.MaxCeiling)
+	}
+	return func() encode.Analyzer {
+		return process.NewNormalizer(target, ceiling)
+	}, nil
+}
+
+// Processor validates gain in dB. If valid, processor is returned.
+func (g gainStage) Processor(gain float64) (pipe.ProcessorAllocatorFunc, error) {
+	if gain < g.MinGain || gain > g.MaxGain {
+		return nil, fmt.Errorf("Gain %vdB is not supported. Provide value between %v and %v", gain, g.MinGain, g.MaxGain)
+	}
+	return process.Gain(gain), nil
+}
+
+// Analyzer validates target peak in dBFS. If valid, closure that creates
+// peak normalizer is returned.
+func (g gainStage) Analyzer(peak float64) (func() encode.Analyzer, error) {
+	if peak < g.MinPeak || peak > g.MaxPeak {
+		return nil, fmt.Errorf("Peak %vdBFS is not supported. Provide value between %v and %v", peak, g.MinPeak, g.MaxPeak)
+	}
+	return func() encode.Analyzer {
+		return process.NewPeakNormalizer(peak)
+	}, nil
+}
+
+// ParseDecibels parses level with optional dB or dBFS suffix, e.g. -3dB.
+func ParseDecibels(s string) (float64, error) {
+	v := strings.TrimSpace(s)
+	for _, suffix := range []string{"dbfs", "db"} {
+		if strings.HasSuffix(strings.ToLower(v), suffix) {
+			v = strings.TrimSpace(v[:len(v)-len(suffix)])
+			break
+		}
+	}
+	db, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Failed parsing level %s", s)
+	}
+	return db, nil
+}
+
+// ProcessParams contains parameters of all processing stages. Stages
+// with zero values are disabled. Silence threshold and true peak must be
+// provided when silence trimming and normalization are enabled.
+type ProcessParams struct {
+	Start            string
+	End              string
+	Duration         string
+	TrimSilence      bool
+	SilenceThreshold float64
+	SilenceDuration  string
+	SilencePadding   string
+	FadeIn           string
+	FadeOut          string
+	FadeCurve        string
+	SampleRate       int
+	ResampleQuality  string
+	Channels         int
+	ChannelMap       string
+	// Normalize is a target loudness in LUFS.
+	Normalize *float64
+	TruePeak  float64
+	// Gain in dB.
+	Gain float64
+	// PeakNormalize is a target peak in dBFS.
+	PeakNormalize *float64
+}
+
+// Processing validates parameters and returns processing with enabled
+// stages in the order they are applied.
+func (p ProcessParams) Processing() (encode.Processing, error) {
+	var result encode.Processing
+	// trim goes first, so positions are defined by the input.
+	if p.Start != "" || p.End != "" || p.Duration != "" {
+		stage, err := Trim.Source(p.Start, p.End, p.Duration)
+		if err != nil {
+			return encode.Processing{}, err
+		}
+		result.Sources = append(result.Sources, stage)
+	}
+	if p.TrimSilence {
+		stage, err := Silence.Source(p.SilenceThreshold, p.SilenceDuration, p.SilencePadding)
+		if err != nil {
+			return encode.Processing{}, err
+		}
+		result.Sources = append(result.Sources, stage)
+	}
+	if p.FadeIn != "" || p.FadeOut != "" {
+		stage, err := Fade.Source(p.FadeIn, p.FadeOut, p.FadeCurve)
+		if err != nil {
+			return encode.Processing{}, err
+		}
+		result.Sources = append(result.Sources, stage)
+	}
+	if p.SampleRate != 0 {
+		stage, err := Resample.Source(p.SampleRate, p.ResampleQuality)
+		if err != nil {
+			return encode.Processing{}, err
+		}
+		result.Sources = append(result.Sources, stage)
+	}
+	if p.Channels != 0 || p.ChannelMap != "" {
+		processor, err := Channels.Processor(p.Channels, p.ChannelMap)
+		if err != nil {
+			return encode.Processing{}, err
+		}
+		result.Processors = append(result.Processors, processor)
+	}
+	if p.Normalize != nil {
+		analyzer, err := Normalize.Analyzer(*p.Normalize, p.TruePeak)
+		if err != nil {
+			return encode.Processing{}, err
+		}
+		result.Analyzers = append(result.Analyzers, analyzer)
+	}
+	if p.Gain != 0 {
+		processor, err := Gain.Processor(p.Gain)
+		if err != nil {
+			return encode.Processing{}, err
+		}
+		result.Processors = append(result.Processors, processor)
+	}
+	if p.PeakNormalize != nil {
+		analyzer, err := Gain.Analyzer(*p.PeakNormalize)
+		if err != nil {
+			return encode.Processing{}, err
+		}
+		result.Analyzers = append(result.Analyzers, analyzer)
+	}
+	return result, nil
+}
+
+// parseProcessing parses optional processing parameters provided in the
+// html form.
+func parseProcessing(data url.Values) (encode.Processing, error) {
+	p := ProcessParams{
+		Start:            data.Get("start"),
+		End:              data.Get("end"),
+		Duration:         data.Get("duration"),
+		SilenceThreshold: Silence.DefaultThreshold,
+		SilenceDuration:  data.Get("silence-duration"),
+		SilencePadding:   data.Get("silence-padding"),
+		FadeIn:           data.Get("fade-in"),
+		FadeOut:          data.Get("fade-out"),
+		FadeCurve:        data.Get("fade-curve"),
+		ResampleQuality:  data.Get("resample-quality"),
+		ChannelMap:       data.Get("channel-map"),
+		TruePeak:         Normalize.DefaultCeiling,
+	}
+	var err error
+	if p.TrimSilence, err = parseBoolValue(data, "trim-silence", "trim silence"); err != nil {
+		return encode.Processing{}, err
+	}
+	if p.TrimSilence && data.Get("silence-threshold") != "" {
+		if p.SilenceThreshold, err = parseFloatValue(data, "silence-threshold", "silence threshold"); err != nil {
+			return encode.Processing{}, err
+		}
+	}
+	// zero values disable stages, so provided zeros are rejected.
+	if data.Get("sample-rate") != "" {
+		if p.SampleRate, err = parseIntValue(data, "sample-rate", "sample rate"); err != nil {
+			return encode.Processing{}, err
+		}
+		if p.SampleRate == 0 {
+			return encode.Processing{}, fmt.Errorf("Sample rate 0 is not supported")
+		}
+	}
+	if data.Get("channels") != "" {
+		if p.Channels, err = parseIntValue(data, "channels", "channels"); err != nil {
+			return encode.Processing{}, err
+		}
+		if p.Channels == 0 && p.ChannelMap == "" {
+			return encode.Processing{}, fmt.Errorf("Number of channels 0 is not supported")
+		}
+	}
+	if data.Get("normalize") != "" {
+		target, err := parseFloatValue(data, "normalize", "target loudness")
+		if err != nil {
+			return encode.Processing{}, err
+		}
+		p.Normalize = &target
+		if data.Get("true-peak") != "" {
+			if p.TruePeak, err = parseFloatValue(data, "true-peak", "true peak"); err != nil {
+				return encode.Processing{}, err
+			}
+		}
+	}
+	if data.Get("gain") != "" {
+		if p.Gain, err = ParseDecibels(data.Get("gain")); err != nil {
+			return encode.Processing{}, err
+		}
+	}
+	if data.Get("peak-normalize") != "" {
+		peak, err := ParseDecibels(data.Get("peak-normalize"))
+		if err != nil {
+			return encode.Processing{}, err
+		}
+		p.PeakNormalize = &peak
+	}
+	return p.Processing()
+}
@@ -0,0 +1,124 @@
+package userinput
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+	"strings"
+
+	"pipelined.dev/phono/process"
+	"pipelined.dev/phono/spectrogram"
+)
+
+type spectrogramStage struct {
+	MinWindowSize     int
+	MaxWindowSize     int
+	DefaultWindowSize int
+	MinOverlap        float64
+	MaxOverlap        float64
+	DefaultOverlap    float64
+	Windows           map[process.Window]struct{}
+	DefaultWindow     process.Window
+	Scales            map[spectrogram.Scale]struct{}
+	DefaultScale      spectrogram.Scale
+	MinWidth          int
+	MaxWidth          int
+	DefaultWidth      int
+	MinHeight         int
+	MaxHeight         int
+	DefaultHeight     int
+	MinRange          float64
+	MaxRange          float64
+	DefaultRange      float64
+}
+
+// Spectrogram provides structures required to handle spectrograms.
+var Spectrogram = spectrogramStage{
+	MinWindowSize:     64,
+	MaxWindowSize:     32768,
+	DefaultWindowSize: 2048,
+	MinOverlap:        0,
+	MaxOverlap:        0.95,
+	DefaultOverlap:    0.5,
+	Windows: map[process.Window]struct{}{
+		process.WindowRectangular: {},
+		process.WindowHann:        {},
+		process.WindowHamming:     {},
+		process.WindowBlackman:    {},
+	},
+	DefaultWindow: process.WindowHann,
+	Scales: map[spectrogram.Scale]struct{}{
+		spectrogram.Linear: {},
+		spectrogram.Log:    {},
+	},
+	DefaultScale:  spectrogram.Log,
+	MinWidth:      64,
+	MaxWidth:      8192,
+	DefaultWidth:  1024,
+	MinHeight:     64,
+	MaxHeight:     4096,
+	DefaultHeight: 512,
+	MinRange:      20,
+	MaxRange:      200,
+	DefaultRange:  120,
+}
+
+// Analyzer validates all parameters required to measure spectrogram.
+// Window size must be a power of two. If valid, analyzer is returned.
+func (s spectrogramStage) Analyzer(windowSize int, overlap float64, window string) (process.SpectrumAnalyzer, error) {
+	if windowSize < s.MinWindowSize || windowSize > s.MaxWindowSize || bits.OnesCount(uint(windowSize)) != 1 {
+		return process.SpectrumAnalyzer{}, fmt.Errorf("Window size %v is not supported. Provide power of two between %d and %d", windowSize, s.MinWindowSize, s.MaxWindowSize)
+	}
+	if overlap < s.MinOverlap || overlap > s.MaxOverlap {
+		return process.SpectrumAnalyzer{}, fmt.Errorf("Overlap %v is not supported. Provide value between %v and %v", overlap, s.MinOverlap, s.MaxOverlap)
+	}
+	w := s.DefaultWindow
+	if window != "" {
+		w = process.Window(strings.ToLower(window))
+	}
+	if _, ok := s.Windows[w]; !ok {
+		return process.SpectrumAnalyzer{}, fmt.Errorf("Window %v is not supported. Provide one of: %s", window, strings.Join(s.WindowNames(), ", "))
+	}
+	return process.SpectrumAnalyzer{
+		Size:    windowSize,
+		Overlap: overlap,
+		Window:  w,
+	}, nil
+}
+
+// Image validates all parameters required to render spectrogram. Range
+// is in dB below full scale. If valid, image is returned.
+func (s spectrogramStage) Image(width, height int, scale string, dynamicRange float64) (spectrogram.Image, error) {
+	if width < s.MinWidth || width > s.MaxWidth {
+		return spectrogram.Image{}, fmt.Errorf("Width %v is not supported. Provide value between %d and %d", width, s.MinWidth, s.MaxWidth)
+	}
+	if height < s.MinHeight || height > s.MaxHeight {
+		return spectrogram.Image{}, fmt.Errorf("Height %v is not supported. Provide value between %d and %d", height, s.MinHeight, s.MaxHeight)
+	}
+	sc := s.DefaultScale
+	if scale != "" {
+		sc = spectrogram.Scale(strings.ToLower(scale))
+	}
+	if _, ok := s.Scales[sc]; !ok {
+		return spectrogram.Image{}, fmt.Errorf("Frequency scale %v is not supported. Provide %s or %s", scale, spectrogram.Linear, spectrogram.Log)
+	}
+	if dynamicRange < s.MinRange || dynamicRange > s.MaxRange {
+		return spectrogram.Image{}, fmt.Errorf("Range %v is not supported. Provide value between %v and %v", dynamicRange, s.MinRange, s.MaxRange)
+	}
+	return spectrogram.Image{
+		Width:  width,
+		Height: height,
+		Scale:  sc,
+		Range:  dynamicRange,
+	}, nil
+}
+
+// WindowNames returns sorted names of supported windows.
+func (s spectrogramStage) WindowNames() []string {
+	result := make([]string, 0, len(s.Windows))
+	for w := range s.Windows {
+		result = append(result, string(w))
+	}
+	sort.Strings(result)
+	return result
+}
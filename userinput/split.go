@@ -0,0 +1,171 @@
+package userinput
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"pipelined.dev/phono/encode"
+	"pipelined.dev/phono/process"
+)
+
+type splitStage struct {
+	// CueSheetExtension is used to detect cue sheets, other cue files are
+	// parsed as csv.
+	CueSheetExtension string
+}
+
+// Split provides structures required to handle splitting. Splitting at
+// silences is provided by Silence.
+var Split = splitStage{
+	CueSheetExtension: ".cue",
+}
+
+// cueSheetFrames is the number of cue sheet frames per second.
+const cueSheetFrames = 75
+
+// Chunks validates the length of parts. It's provided in format accepted
+// by process.ParsePosition. If valid, splitter constructor is returned.
+func (s splitStage) Chunks(length string) (func() encode.Splitter, error) {
+	l, err := process.ParsePosition(length)
+	if err != nil {
+		return nil, fmt.Errorf("Chunk length %v is not valid: %v", length, err)
+	}
+	if l.IsZero() {
+		return nil, fmt.Errorf("Chunk length must be positive")
+	}
+	return func() encode.Splitter {
+		return process.NewChunkSplitter(l)
+	}, nil
+}
+
+// Cues parses the cues. Files with cue sheet extension are parsed as cue
+// sheets, all other files are parsed as csv with start and optional title
+// columns. Start has format accepted by process.ParsePosition. If valid,
+// splitter constructor is returned.
+func (s splitStage) Cues(r io.Reader, name string) (func() encode.Splitter, error) {
+	var (
+		cues []process.Cue
+		err  error
+	)
+	if strings.EqualFold(filepath.Ext(name), s.CueSheetExtension) {
+		cues, err = parseCueSheet(r)
+	} else {
+		cues, err = parseCueCSV(r)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Cues %v are not valid: %v", name, err)
+	}
+	if len(cues) == 0 {
+		return nil, fmt.Errorf("Cues %v are empty", name)
+	}
+	return func() encode.Splitter {
+		return process.NewCueSplitter(cues)
+	}, nil
+}
+
+// parseCueSheet returns a cue for every track of the sheet. Start of the
+// track is defined by its INDEX 01.
+func parseCueSheet(r io.Reader) ([]process.Cue, error) {
+	var (
+		cues []process.Cue
+		// track is the current track, nil before the first one.
+		track   *process.Cue
+		indexed bool
+	)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "TRACK":
+			if track != nil && !indexed {
+				return nil, fmt.Errorf("line %d: previous track has no index 01", line)
+			}
+			cues = append(cues, process.Cue{})
+			track, indexed = &cues[len(cues)-1], false
+		case "TITLE":
+			if track != nil {
+				title := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(scanner.Text()), fields[0]))
+				if unquoted, err := strconv.Unquote(title); err == nil {
+					title = unquoted
+				}
+				track.Title = title
+			}
+		case "INDEX":
+			if track == nil || len(fields) != 3 || fields[1] != "01" {
+				continue
+			}
+			start, err := parseCueSheetTime(fields[2])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			track.Start, indexed = start, true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if track != nil && !indexed {
+		return nil, fmt.Errorf("last track has no index 01")
+	}
+	return cues, nil
+}
+
+// parseCueSheetTime parses time in mm:ss:ff format, where ff is a number
+// of cue sheet frames.
+func parseCueSheetTime(s string) (process.Position, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return process.Position{}, fmt.Errorf("invalid cue time %q", s)
+	}
+	var values [3]int
+	for i, limit := range [3]int{-1, 60, cueSheetFrames} {
+		v, err := strconv.Atoi(parts[i])
+		if err != nil || v < 0 || (limit > 0 && v >= limit) {
+			return process.Position{}, fmt.Errorf("invalid cue time %q", s)
+		}
+		values[i] = v
+	}
+	return process.AtTime(time.Duration(values[0]*60+values[1])*time.Second +
+		time.Duration(values[2])*time.Second/cueSheetFrames), nil
+}
+
+// parseCueCSV returns a cue for every record. The first record is
+// skipped if it's a header.
+func parseCueCSV(r io.Reader) ([]process.Cue, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+	var cues []process.Cue
+	for i := 0; ; i++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return cues, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, err := process.ParsePosition(record[0])
+		if err != nil {
+			if i == 0 {
+				continue
+			}
+			line, _ := reader.FieldPos(0)
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		cue := process.Cue{Start: start}
+		if len(record) > 1 {
+			cue.Title = strings.TrimSpace(record[1])
+		}
+		cues = append(cues, cue)
+	}
+}
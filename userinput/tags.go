@@ -0,0 +1,109 @@
+package userinput
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"pipelined.dev/phono/tag"
+)
+
+type tagStage struct {
+	MaxLength int
+	// FormKeys are keys of tags that can be provided via http form.
+	FormKeys []string
+	// MaxCoverBytes limits the size of provided cover image.
+	MaxCoverBytes int64
+	// Covers larger than max cover size are scaled down. Zero size
+	// disables scaling.
+	MinCoverSize int
+	MaxCoverSize int
+}
+
+// Tags provides structures required to handle metadata tags.
+var Tags = tagStage{
+	MaxLength: 4096,
+	FormKeys: []string{
+		tag.Title,
+		tag.Artist,
+		tag.Album,
+		tag.AlbumArtist,
+		tag.Date,
+		tag.Genre,
+		tag.TrackNumber,
+		tag.Comment,
+	},
+	MaxCoverBytes: 8 << 20,
+	MinCoverSize:  32,
+	MaxCoverSize:  4096,
+}
+
+// Edit validates tags that override input tags. Empty values remove the
+// tags. If strip is set, input tags are removed. If valid, edit is
+// returned.
+func (s tagStage) Edit(values map[string]string, strip bool) (tag.Edit, error) {
+	e := tag.Edit{Strip: strip}
+	for k, v := range values {
+		key := tag.Key(k)
+		if !tag.ValidKey(key) {
+			return tag.Edit{}, fmt.Errorf("Tag key %q is not supported. Provide printable ASCII without '='", k)
+		}
+		if len(v) > s.MaxLength {
+			return tag.Edit{}, fmt.Errorf("Tag %s exceeds %d bytes", key, s.MaxLength)
+		}
+		if e.Set == nil {
+			e.Set = make(tag.Tags)
+		}
+		e.Set[key] = v
+	}
+	return e, nil
+}
+
+// Pairs parses tags provided as key=value pairs.
+func (s tagStage) Pairs(pairs []string) (map[string]string, error) {
+	values := make(map[string]string, len(pairs))
+	for _, p := range pairs {
+		i := strings.IndexByte(p, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("Tag %q is not supported. Provide key=value", p)
+		}
+		values[p[:i]] = p[i+1:]
+	}
+	return values, nil
+}
+
+// CoverSize validates the max dimension of covers. Zero disables
+// scaling. If valid, size is returned.
+func (s tagStage) CoverSize(size int) (int, error) {
+	if size == 0 {
+		return 0, nil
+	}
+	if size < s.MinCoverSize || size > s.MaxCoverSize {
+		return 0, fmt.Errorf("Cover size %d is not supported. Provide value between %d and %d", size, s.MinCoverSize, s.MaxCoverSize)
+	}
+	return size, nil
+}
+
+// Cover reads and validates JPEG or PNG cover image. If max size is
+// positive, larger images are scaled down. If valid, picture is
+// returned.
+func (s tagStage) Cover(r io.Reader, maxSize int) (*tag.Picture, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, s.MaxCoverBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read cover: %v", err)
+	}
+	if int64(len(data)) > s.MaxCoverBytes {
+		return nil, fmt.Errorf("Cover exceeds %d bytes", s.MaxCoverBytes)
+	}
+	p, err := tag.NewPicture(data)
+	if err != nil {
+		return nil, fmt.Errorf("Cover is not supported: %v", err)
+	}
+	if maxSize > 0 {
+		if p, err = p.Resize(maxSize); err != nil {
+			return nil, fmt.Errorf("Failed to resize cover: %v", err)
+		}
+	}
+	return p, nil
+}
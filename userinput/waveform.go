@@ -0,0 +1,116 @@
+package userinput
+
+import (
+	"fmt"
+	"image/color"
+	"sort"
+	"strconv"
+	"strings"
+
+	"pipelined.dev/phono/waveform"
+)
+
+type waveformStage struct {
+	MinSamplesPerPixel     int
+	MaxSamplesPerPixel     int
+	DefaultSamplesPerPixel int
+	// Formats maps names to constructors of formats.
+	Formats       map[string]func(bits int, img waveform.Image) waveform.Format
+	DefaultFormat string
+	Bits          map[int]struct{}
+	DefaultBits   int
+	MinHeight     int
+	MaxHeight     int
+	DefaultHeight int
+	DefaultColor  string
+}
+
+// Waveform provides structures required to handle waveform peaks.
+var Waveform = waveformStage{
+	MinSamplesPerPixel:     16,
+	MaxSamplesPerPixel:     1 << 20,
+	DefaultSamplesPerPixel: 256,
+	Formats: map[string]func(int, waveform.Image) waveform.Format{
+		"json": func(bits int, _ waveform.Image) waveform.Format { return waveform.JSON(bits) },
+		"dat":  func(bits int, _ waveform.Image) waveform.Format { return waveform.Binary(bits) },
+		"png":  func(_ int, img waveform.Image) waveform.Format { return waveform.PNG(img) },
+		"svg":  func(_ int, img waveform.Image) waveform.Format { return waveform.SVG(img) },
+	},
+	DefaultFormat: "json",
+	Bits: map[int]struct{}{
+		8:  {},
+		16: {},
+	},
+	DefaultBits:   8,
+	MinHeight:     8,
+	MaxHeight:     4096,
+	DefaultHeight: 128,
+	DefaultColor:  "#3a7bd5",
+}
+
+// SamplesPerPixel checks if provided resolution is supported.
+func (s waveformStage) SamplesPerPixel(v int) error {
+	if v < s.MinSamplesPerPixel || v > s.MaxSamplesPerPixel {
+		return fmt.Errorf("Samples per pixel %v is not supported. Provide value between %d and %d", v, s.MinSamplesPerPixel, s.MaxSamplesPerPixel)
+	}
+	return nil
+}
+
+// Format validates all parameters required to encode waveform in
+// provided format. Bits are used by data formats, height and colors are
+// used by images. Colors have #rrggbb or #rrggbbaa format, empty
+// background is transparent. If valid, format is returned.
+func (s waveformStage) Format(name string, bits, height int, fg, bg string) (waveform.Format, error) {
+	fn, ok := s.Formats[strings.ToLower(name)]
+	if !ok {
+		return waveform.Format{}, fmt.Errorf("Waveform format %v is not supported. Provide one of: %s", name, strings.Join(s.FormatNames(), ", "))
+	}
+	if _, ok := s.Bits[bits]; !ok {
+		return waveform.Format{}, fmt.Errorf("Waveform bits %v are not supported", bits)
+	}
+	if height < s.MinHeight || height > s.MaxHeight {
+		return waveform.Format{}, fmt.Errorf("Waveform height %v is not supported. Provide value between %d and %d", height, s.MinHeight, s.MaxHeight)
+	}
+	img := waveform.Image{Height: height}
+	var err error
+	if img.Color, err = parseColor(fg); err != nil {
+		return waveform.Format{}, fmt.Errorf("Waveform color %v is not valid: %v", fg, err)
+	}
+	if bg != "" {
+		if img.Background, err = parseColor(bg); err != nil {
+			return waveform.Format{}, fmt.Errorf("Waveform background %v is not valid: %v", bg, err)
+		}
+	}
+	return fn(bits, img), nil
+}
+
+// FormatNames returns sorted names of supported formats.
+func (s waveformStage) FormatNames() []string {
+	result := make([]string, 0, len(s.Formats))
+	for name := range s.Formats {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// parseColor parses color in #rrggbb or #rrggbbaa format.
+func parseColor(s string) (color.Color, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) != 6 && len(hex) != 8 {
+		return nil, fmt.Errorf("expected #rrggbb or #rrggbbaa")
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("expected #rrggbb or #rrggbbaa")
+	}
+	if len(hex) == 6 {
+		v = v<<8 | 0xff
+	}
+	return color.NRGBA{
+		R: uint8(v >> 24),
+		G: uint8(v >> 16),
+		B: uint8(v >> 8),
+		A: uint8(v),
+	}, nil
+}
@@ -0,0 +1,215 @@
+// Package waveform provides representations of waveform peaks for web
+// players. Data formats are compatible with audiowaveform version 2
+// with a single channel.
+package waveform
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+
+	"pipelined.dev/phono/process"
+)
+
+// version of audiowaveform data format.
+const version = 2
+
+// flag8Bit is set in binary header if data has 8 bits per value.
+const flag8Bit = 1
+
+type (
+	// Format encodes waveform into the writer.
+	Format struct {
+		Extension   string
+		ContentType string
+		Encode      func(io.Writer, process.Waveform) error
+	}
+
+	// Image defines how waveform is rendered. Width of the image is the
+	// number of peaks.
+	Image struct {
+		Height int
+		Color  color.Color
+		// Background is transparent if nil.
+		Background color.Color
+	}
+
+	// data is json representation of waveform.
+	data struct {
+		Version         int   `json:"version"`
+		Channels        int   `json:"channels"`
+		SampleRate      int   `json:"sample_rate"`
+		SamplesPerPixel int   `json:"samples_per_pixel"`
+		Bits            int   `json:"bits"`
+		Length          int   `json:"length"`
+		Data            []int `json:"data"`
+	}
+
+	// header of binary format.
+	header struct {
+		Version         int32
+		Flags           uint32
+		SampleRate      int32
+		SamplesPerPixel int32
+		Length          uint32
+		Channels        int32
+	}
+)
+
+// JSON returns format that encodes peaks as integers with provided bits,
+// either 8 or 16.
+func JSON(bits int) Format {
+	return Format{
+		Extension:   ".json",
+		ContentType: "application/json",
+		Encode: func(w io.Writer, wf process.Waveform) error {
+			return json.NewEncoder(w).Encode(data{
+				Version:         version,
+				Channels:        1,
+				SampleRate:      int(wf.SampleRate),
+				SamplesPerPixel: wf.SamplesPerPixel,
+				Bits:            bits,
+				Length:          len(wf.Peaks),
+				Data:            values(wf.Peaks, bits),
+			})
+		},
+	}
+}
+
+// Binary returns format that encodes peaks as little-endian integers
+// with provided bits, either 8 or 16.
+func Binary(bits int) Format {
+	return Format{
+		Extension:   ".dat",
+		ContentType: "application/octet-stream",
+		Encode: func(w io.Writer, wf process.Waveform) error {
+			h := header{
+				Version:         version,
+				SampleRate:      int32(wf.SampleRate),
+				SamplesPerPixel: int32(wf.SamplesPerPixel),
+				Length:          uint32(len(wf.Peaks)),
+				Channels:        1,
+			}
+			if bits == 8 {
+				h.Flags = flag8Bit
+			}
+			bw := bufio.NewWriter(w)
+			if err := binary.Write(bw, binary.LittleEndian, h); err != nil {
+				return err
+			}
+			for _, v := range values(wf.Peaks, bits) {
+				var err error
+				if bits == 8 {
+					err = bw.WriteByte(byte(int8(v)))
+				} else {
+					err = binary.Write(bw, binary.LittleEndian, int16(v))
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return bw.Flush()
+		},
+	}
+}
+
+// PNG returns format that renders peaks as png image.
+func PNG(img Image) Format {
+	return Format{
+		Extension:   ".png",
+		ContentType: "image/png",
+		Encode: func(w io.Writer, wf process.Waveform) error {
+			width := len(wf.Peaks)
+			if width == 0 {
+				width = 1
+			}
+			m := image.NewRGBA(image.Rect(0, 0, width, img.Height))
+			if img.Background != nil {
+				draw.Draw(m, m.Bounds(), image.NewUniform(img.Background), image.Point{}, draw.Src)
+			}
+			for x, p := range wf.Peaks {
+				top, bottom := pixel(p.Max, img.Height), pixel(p.Min, img.Height)
+				for y := top; y <= bottom; y++ {
+					m.Set(x, y, img.Color)
+				}
+			}
+			return png.Encode(w, m)
+		},
+	}
+}
+
+// SVG returns format that renders peaks as svg polygon. Image is scaled
+// to the viewport, so it can be stretched by the player.
+func SVG(img Image) Format {
+	return Format{
+		Extension:   ".svg",
+		ContentType: "image/svg+xml",
+		Encode: func(w io.Writer, wf process.Waveform) error {
+			width, height := len(wf.Peaks), float64(img.Height)
+			bw := bufio.NewWriter(w)
+			fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" preserveAspectRatio="none">`+"\n", width, img.Height, width, img.Height)
+			if img.Background != nil {
+				fill, opacity := svgColor(img.Background)
+				fmt.Fprintf(bw, `<rect width="100%%" height="100%%" fill="%s" fill-opacity="%.3g"/>`+"\n", fill, opacity)
+			}
+			if width > 0 {
+				fill, opacity := svgColor(img.Color)
+				fmt.Fprintf(bw, `<path fill="%s" fill-opacity="%.3g" d="`, fill, opacity)
+				// upper edge goes forward and lower edge goes back.
+				for x, p := range wf.Peaks {
+					y := (1 - clamp(p.Max)) / 2 * height
+					if x == 0 {
+						fmt.Fprintf(bw, "M%d,%.2f", x, y)
+					} else {
+						fmt.Fprintf(bw, "L%d,%.2f", x, y)
+					}
+					fmt.Fprintf(bw, "L%d,%.2f", x+1, y)
+				}
+				for x := width - 1; x >= 0; x-- {
+					y := (1 - clamp(wf.Peaks[x].Min)) / 2 * height
+					fmt.Fprintf(bw, "L%d,%.2fL%d,%.2f", x+1, y, x, y)
+				}
+				fmt.Fprint(bw, "Z\"/>\n")
+			}
+			fmt.Fprint(bw, "</svg>\n")
+			return bw.Flush()
+		},
+	}
+}
+
+// values returns min and max of every peak scaled to integers with
+// provided bits.
+func values(peaks []process.Peak, bits int) []int {
+	scale := float64(int(1)<<uint(bits-1) - 1)
+	result := make([]int, 0, 2*len(peaks))
+	for _, p := range peaks {
+		result = append(result,
+			int(math.Round(clamp(p.Min)*scale)),
+			int(math.Round(clamp(p.Max)*scale)),
+		)
+	}
+	return result
+}
+
+// pixel returns row of the image for provided value.
+func pixel(v float64, height int) int {
+	return int(math.Round((1 - clamp(v)) / 2 * float64(height-1)))
+}
+
+// clamp limits the value to full scale.
+func clamp(v float64) float64 {
+	return math.Max(-1, math.Min(1, v))
+}
+
+// svgColor returns hex color and its opacity.
+func svgColor(c color.Color) (string, float64) {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("#%02x%02x%02x", n.R, n.G, n.B), float64(n.A) / 255
+}
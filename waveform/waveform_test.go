@@ -0,0 +1,123 @@
+package waveform_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/phono/process"
+	"pipelined.dev/phono/waveform"
+)
+
+var testWaveform = process.Waveform{
+	SampleRate:      44100,
+	SamplesPerPixel: 256,
+	Frames:          700,
+	Peaks: []process.Peak{
+		{Min: -0.5, Max: 0.5},
+		{Min: -1.5, Max: 1},
+		{Min: 0, Max: 0.25},
+	},
+}
+
+func TestJSON(t *testing.T) {
+	testJSON := func(bits int, expected []int) func(*testing.T) {
+		return func(t *testing.T) {
+			t.Helper()
+			var b bytes.Buffer
+			assert.Nil(t, waveform.JSON(bits).Encode(&b, testWaveform))
+			var result struct {
+				Version         int   `json:"version"`
+				Channels        int   `json:"channels"`
+				SampleRate      int   `json:"sample_rate"`
+				SamplesPerPixel int   `json:"samples_per_pixel"`
+				Bits            int   `json:"bits"`
+				Length          int   `json:"length"`
+				Data            []int `json:"data"`
+			}
+			assert.Nil(t, json.NewDecoder(&b).Decode(&result))
+			assert.Equal(t, 2, result.Version)
+			assert.Equal(t, 1, result.Channels)
+			assert.Equal(t, 44100, result.SampleRate)
+			assert.Equal(t, 256, result.SamplesPerPixel)
+			assert.Equal(t, bits, result.Bits)
+			assert.Equal(t, 3, result.Length)
+			assert.Equal(t, expected, result.Data)
+		}
+	}
+	t.Run("8 bits", testJSON(8, []int{-64, 64, -127, 127, 0, 32}))
+	t.Run("16 bits", testJSON(16, []int{-16384, 16384, -32767, 32767, 0, 8192}))
+}
+
+func TestBinary(t *testing.T) {
+	testBinary := func(bits int, flags uint32, size int) func(*testing.T) {
+		return func(t *testing.T) {
+			t.Helper()
+			var b bytes.Buffer
+			assert.Nil(t, waveform.Binary(bits).Encode(&b, testWaveform))
+			assert.Equal(t, size, b.Len())
+			var header [6]int32
+			assert.Nil(t, binary.Read(&b, binary.LittleEndian, &header))
+			assert.Equal(t, [6]int32{2, int32(flags), 44100, 256, 3, 1}, header)
+			if bits == 8 {
+				assert.Equal(t, []byte{0xc0, 0x40, 0x81, 0x7f, 0, 0x20}, b.Bytes())
+				return
+			}
+			data := make([]int16, 6)
+			assert.Nil(t, binary.Read(&b, binary.LittleEndian, data))
+			assert.Equal(t, []int16{-16384, 16384, -32767, 32767, 0, 8192}, data)
+		}
+	}
+	t.Run("8 bits", testBinary(8, 1, 24+6))
+	t.Run("16 bits", testBinary(16, 0, 24+12))
+}
+
+func TestPNG(t *testing.T) {
+	var b bytes.Buffer
+	img := waveform.Image{
+		Height:     5,
+		Color:      color.Black,
+		Background: color.White,
+	}
+	assert.Nil(t, waveform.PNG(img).Encode(&b, testWaveform))
+	m, err := png.Decode(&b)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, m.Bounds().Dx())
+	assert.Equal(t, 5, m.Bounds().Dy())
+	isBlack := func(x, y int) bool {
+		r, g, b, _ := m.At(x, y).RGBA()
+		return r == 0 && g == 0 && b == 0
+	}
+	// half scale covers three middle rows.
+	assert.False(t, isBlack(0, 0))
+	assert.True(t, isBlack(0, 1))
+	assert.True(t, isBlack(0, 3))
+	assert.False(t, isBlack(0, 4))
+	// full scale covers all rows.
+	assert.True(t, isBlack(1, 0))
+	assert.True(t, isBlack(1, 4))
+	// positive peak is above the middle.
+	assert.True(t, isBlack(2, 2))
+	assert.False(t, isBlack(2, 3))
+}
+
+func TestSVG(t *testing.T) {
+	var b bytes.Buffer
+	img := waveform.Image{
+		Height: 100,
+		Color:  color.NRGBA{R: 0x3a, G: 0x7b, B: 0xd5, A: 0xff},
+	}
+	assert.Nil(t, waveform.SVG(img).Encode(&b, testWaveform))
+	svg := b.String()
+	assert.True(t, strings.HasPrefix(svg, "<svg "))
+	assert.Contains(t, svg, `viewBox="0 0 3 100"`)
+	assert.Contains(t, svg, `fill="#3a7bd5"`)
+	assert.Contains(t, svg, `d="M0,25.00L1,25.00L1,0.00L2,0.00`)
+	assert.NotContains(t, svg, "<rect")
+}